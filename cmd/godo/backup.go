@@ -0,0 +1,118 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/biisal/godo/internal/backup"
+	"github.com/biisal/godo/internal/config"
+	"github.com/biisal/godo/internal/logger"
+	"github.com/biisal/godo/internal/migrate"
+)
+
+func runBackup(args []string) error {
+	fs := flag.NewFlagSet("backup", flag.ContinueOnError)
+	list := fs.Bool("list", false, "list existing backups instead of creating one")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	dir, prefix := config.BackupDir(), config.BackupPrefix(config.ActiveStore())
+	if *list {
+		backups, err := backup.List(dir, prefix)
+		if err != nil {
+			return err
+		}
+		if len(backups) == 0 {
+			fmt.Printf("No backups of the %s store in %s\n", config.StoreLabel(), dir)
+		}
+		for _, b := range backups {
+			fmt.Printf("%s  %s  %d KB\n", b.Time.Format(time.DateTime), b.Path, b.Size/1024)
+		}
+		return nil
+	}
+
+	path, err := backup.Create(config.Cfg.DB, dir, prefix, time.Now())
+	if err != nil {
+		return err
+	}
+	removed, err := backup.Rotate(dir, prefix, config.Cfg.BACKUP_KEEP)
+	if err != nil {
+		return err
+	}
+	logger.Success("Backed up the %s store to %s", config.StoreLabel(), path)
+	if len(removed) > 0 {
+		fmt.Printf("Removed %d old backup(s), keeping %d\n", len(removed), config.Cfg.BACKUP_KEEP)
+	}
+	return nil
+}
+
+func runRestore(args []string) error {
+	fs := flag.NewFlagSet("restore", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: godo restore <backup file>")
+	}
+	src := resolveBackup(fs.Arg(0))
+
+	info, err := backup.Verify(src)
+	if err != nil {
+		return err
+	}
+	latest, err := migrate.Latest()
+	if err != nil {
+		return err
+	}
+	if info.SchemaVersion > latest {
+		return fmt.Errorf("%s has schema v%d, newer than this godo supports (v%d)", src, info.SchemaVersion, latest)
+	}
+
+	safety, err := backup.Create(config.Cfg.DB, config.BackupDir(), config.BackupPrefix(config.ActiveStore()), time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to back up the live database before restoring: %w", err)
+	}
+	fmt.Printf("Saved the current database to %s\n", safety)
+
+	dst := config.ActiveDBPath()
+	if err := config.CloseDB(); err != nil {
+		return err
+	}
+	if _, err := backup.Restore(src, dst); err != nil {
+		return err
+	}
+	slog.Info("restored database", "from", src, "to", dst)
+	logger.Success("Restored %d todos from %s into %s", info.Todos, src, dst)
+	return nil
+}
+
+// resolveBackup accepts either a path or the bare name of a file in the
+// backup directory.
+func resolveBackup(name string) string {
+	if _, err := os.Stat(name); err == nil || filepath.Base(name) != name {
+		return name
+	}
+	return filepath.Join(config.BackupDir(), name)
+}
+
+// autoBackup takes the daily backup of every open store, so the global
+// store with the memories is kept safe while godo runs in a project too.
+// Failures are only logged so a broken backup directory never keeps godo
+// from starting.
+func autoBackup() {
+	for _, s := range openStores() {
+		path, err := backup.Daily(s.db, config.BackupDir(), config.BackupPrefix(s.name), config.Cfg.BACKUP_KEEP, time.Now())
+		if err != nil {
+			slog.Error("daily backup failed", "store", s.name, "err", err)
+			continue
+		}
+		if path != "" {
+			slog.Info("created daily backup", "store", s.name, "path", path)
+		}
+	}
+}
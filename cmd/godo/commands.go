@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/biisal/godo/internal/config"
+	"github.com/biisal/godo/internal/logger"
+)
+
+func runCommand(name string, args []string) int {
+	closeLog := initLogger()
+	defer closeLog()
+
+	initStorage()
+	defer func() {
+		if err := config.CloseDB(); err != nil {
+			slog.Error("error closing db", "err", err)
+		}
+	}()
+
+	var err error
+	switch name {
+	case "scan":
+		err = runScan(args)
+	case "hook":
+		err = runHook(args)
+	case "backup":
+		err = runBackup(args)
+	case "restore":
+		err = runRestore(args)
+	case "encrypt":
+		err = runEncrypt(args)
+	case "mcp":
+		err = runMCP(args)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", name)
+		return 2
+	}
+	if err != nil {
+		slog.Error("command failed", "command", name, "err", err)
+		logger.Error("%s: %v", name, err)
+		return 1
+	}
+	return 0
+}
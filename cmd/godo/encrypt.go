@@ -0,0 +1,207 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/biisal/godo/internal/backup"
+	"github.com/biisal/godo/internal/config"
+	"github.com/biisal/godo/internal/crypt"
+	"github.com/biisal/godo/internal/logger"
+	"github.com/biisal/godo/internal/memory"
+	"github.com/biisal/godo/internal/store"
+)
+
+// unlocker derives the cipher of each encrypted database from one secret,
+// asked for at most once.
+type unlocker struct {
+	secret []byte
+}
+
+// cipherFor returns the cipher of db, or nil when db is not encrypted.
+func (u *unlocker) cipherFor(db *sql.DB) (*crypt.Cipher, error) {
+	params, err := crypt.LoadParams(db)
+	if errors.Is(err, crypt.ErrNotEncrypted) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if u.secret == nil {
+		if u.secret, err = config.ReadSecret(false); err != nil {
+			return nil, err
+		}
+	}
+	return crypt.Unlock(params, u.secret)
+}
+
+func (u *unlocker) chatStore(db *sql.DB) (store.ChatStore, error) {
+	c, err := u.cipherFor(db)
+	if err != nil || c == nil {
+		return store.NewSQLiteChatStore(db), err
+	}
+	return store.NewEncryptedChatStore(db, c), nil
+}
+
+func (u *unlocker) memoryStore(db *sql.DB) (store.MemoryStore, error) {
+	c, err := u.cipherFor(db)
+	if err != nil || c == nil {
+		return memory.NewMemoryStore(db), err
+	}
+	return store.NewEncryptedMemoryStore(memory.NewMemoryStore(db), c), nil
+}
+
+// openPrivateStores opens the chat and memory stores, unlocking them when
+// their database is encrypted.
+func openPrivateStores() (store.ChatStore, store.MemoryStore, error) {
+	u := &unlocker{}
+	chats, err := u.chatStore(config.Cfg.DB)
+	if err != nil {
+		return nil, nil, err
+	}
+	memories, err := u.memoryStore(config.Cfg.GlobalDB)
+	if err != nil {
+		return nil, nil, err
+	}
+	return chats, memories, nil
+}
+
+type storeDB struct {
+	name string
+	path string
+	db   *sql.DB
+}
+
+// openStores lists the databases godo has open, the global one first.
+func openStores() []storeDB {
+	dbs := []storeDB{{config.StoreGlobal, config.Cfg.DB_PATH, config.Cfg.GlobalDB}}
+	if config.Cfg.DB != config.Cfg.GlobalDB {
+		dbs = append(dbs, storeDB{config.StoreProject, config.ActiveDBPath(), config.Cfg.DB})
+	}
+	return dbs
+}
+
+func runEncrypt(args []string) error {
+	fs := flag.NewFlagSet("encrypt", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var pending []storeDB
+	var encrypted *crypt.Params
+	for _, s := range openStores() {
+		params, err := crypt.LoadParams(s.db)
+		switch {
+		case errors.Is(err, crypt.ErrNotEncrypted):
+			pending = append(pending, s)
+		case err != nil:
+			return err
+		default:
+			encrypted = &params
+		}
+	}
+	if len(pending) == 0 {
+		fmt.Println("The database is already encrypted")
+		return nil
+	}
+
+	// Every store has to open with the same secret, so a store that is
+	// already encrypted decides it.
+	secret, err := config.ReadSecret(encrypted == nil)
+	if err != nil {
+		return err
+	}
+	if encrypted != nil {
+		if _, err := crypt.Unlock(*encrypted, secret); err != nil {
+			return err
+		}
+	}
+
+	for _, s := range pending {
+		path, err := backup.Create(s.db, config.BackupDir(), config.BackupPrefix(s.name), time.Now())
+		if err != nil {
+			return fmt.Errorf("failed to back up the %s store before encrypting: %w", s.name, err)
+		}
+		n, err := encryptDB(s.db, secret)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt the %s store: %w", s.name, err)
+		}
+		logger.Success("Encrypted %d chat and memory rows in the %s store", n, s.name)
+		fmt.Printf("The backup taken before encrypting is %s\n", path)
+	}
+	return removePlaintext(pending)
+}
+
+// removePlaintext lists the copies of stores made before they were
+// encrypted, which still hold their chats and memories in the clear, and
+// offers to delete them.
+func removePlaintext(stores []storeDB) error {
+	var copies []string
+	for _, s := range stores {
+		backups, err := backup.List(config.BackupDir(), config.BackupPrefix(s.name))
+		if err != nil {
+			return err
+		}
+		for _, b := range backups {
+			copies = append(copies, b.Path)
+		}
+		// Older versions left a backup next to the database before each
+		// migration.
+		migrated, err := filepath.Glob(s.path + ".v*.bak")
+		if err != nil {
+			return err
+		}
+		copies = append(copies, migrated...)
+	}
+	if len(copies) == 0 {
+		return nil
+	}
+	fmt.Println("These backups are not encrypted and still hold your chats and memories in the clear:")
+	for _, path := range copies {
+		fmt.Println("  " + path)
+	}
+	if !config.Confirm("Delete them now? Keep them until you are sure the passphrase works.") {
+		fmt.Println("Kept them, delete them once you are sure")
+		return nil
+	}
+	for _, path := range copies {
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	}
+	logger.Success("Deleted %d plaintext backup(s)", len(copies))
+	return nil
+}
+
+// encryptDB sets up encryption for db and encrypts its existing payloads in
+// one transaction, then vacuums so no plaintext is left in free pages.
+func encryptDB(db *sql.DB, secret []byte) (int, error) {
+	params, c, err := crypt.NewParams(secret, crypt.DefaultIterations)
+	if err != nil {
+		return 0, err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	n, err := store.EncryptPayloads(tx, c)
+	if err == nil {
+		err = crypt.SaveParams(tx, params)
+	}
+	if err != nil {
+		_ = tx.Rollback()
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	if _, err := db.Exec(`VACUUM`); err != nil {
+		return n, err
+	}
+	return n, nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/biisal/godo/internal/githook"
+	"github.com/biisal/godo/internal/logger"
+)
+
+func runHook(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: godo hook <install|uninstall|post-commit>")
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "install":
+		fs := flag.NewFlagSet("hook install", flag.ContinueOnError)
+		force := fs.Bool("force", false, "replace an existing post-commit hook")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		executable, err := os.Executable()
+		if err != nil {
+			executable = "godo"
+		}
+		path, err := githook.Install(cwd, executable, *force)
+		if err != nil {
+			return err
+		}
+		logger.Success("Installed post-commit hook at %s", path)
+	case "uninstall":
+		path, err := githook.Uninstall(cwd)
+		if err != nil {
+			return err
+		}
+		logger.Success("Removed post-commit hook at %s", path)
+	case "post-commit":
+		commit, err := githook.LastCommit(cwd)
+		if err != nil {
+			return err
+		}
+		todos, _ := initTodos()
+		outcomes, err := githook.Apply(todos, commit)
+		if err != nil {
+			return err
+		}
+		for _, o := range outcomes {
+			switch {
+			case o.Missing:
+				logger.Error("godo: todo #%d not found", o.ID)
+			case o.Changed:
+				logger.Success("godo: closed todo #%d", o.ID)
+			default:
+				fmt.Printf("godo: recorded commit on todo #%d (%s)\n", o.ID, o.Event)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown hook command %q", args[0])
+	}
+	return nil
+}
@@ -1,13 +1,24 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"os"
+	"time"
 
+	"github.com/biisal/godo/internal/bus"
 	"github.com/biisal/godo/internal/config"
+	"github.com/biisal/godo/internal/llm"
 	"github.com/biisal/godo/internal/logger"
+	"github.com/biisal/godo/internal/mcp"
+	"github.com/biisal/godo/internal/permission"
+	"github.com/biisal/godo/internal/plugin"
+	"github.com/biisal/godo/internal/sandbox"
+	"github.com/biisal/godo/internal/store"
 	"github.com/biisal/godo/internal/tui/actions/agent"
+	todoAction "github.com/biisal/godo/internal/tui/actions/todo"
+	"github.com/biisal/godo/internal/workspace"
 	"github.com/muesli/termenv"
 )
 
@@ -30,15 +41,118 @@ func initConfig() {
 	}
 }
 
-func initBot() *agent.Bot {
-	bot := agent.NewBot()
-	history, err := bot.GetChatHistoryFromDB()
+func initStorage() {
+	if err := config.LoadStorage(); err != nil {
+		slog.Error("Error loading config", "err", err)
+		fmt.Printf("Failed To Load Config: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// initTodos returns the service of the active store and, inside a project,
+// a second service for the global store.
+func initTodos() (todos, globalTodos *todoAction.Service) {
+	todos = todoAction.NewService(store.NewSQLiteTodoStore(config.Cfg.DB), config.ActiveStore())
+	if config.ActiveStore() == config.StoreProject {
+		globalTodos = todoAction.NewService(store.NewSQLiteTodoStore(config.Cfg.GlobalDB), config.StoreGlobal)
+	}
+	return todos, globalTodos
+}
+
+// initBot returns the agent with a new, not yet stored, session; see
+// startSession for resuming one.
+func initBot(todos *todoAction.Service) *agent.Bot {
+	chats, memories, err := openPrivateStores()
 	if err != nil {
-		slog.Error("Error getting chat history from DB", "err", err)
-		fmt.Println("Exiting")
+		slog.Error("Error unlocking the database", "err", err)
+		fmt.Printf("Failed To Unlock Database: %v\n", err)
+		os.Exit(1)
+	}
+	bot := agent.NewBot(agent.Stores{
+		Todos:    todos,
+		Chats:    chats,
+		Memories: memories,
+		SQL:      config.Cfg.DB,
+	}, initProvider())
+	if bot.Permissions, err = permission.Load(config.PermissionsPath(), agent.DefaultPermissions); err != nil {
+		slog.Error("Error loading tool permissions", "err", err)
+		fmt.Printf("Failed To Load Permissions: %v\n", err)
+		os.Exit(1)
+	}
+	plugins, errs := plugin.LoadDir(config.ToolsDir())
+	errs = append(errs, bot.AddPlugins(plugins)...)
+	for _, err := range errs {
+		slog.Warn("skipping plugin", "err", err)
+		fmt.Fprintf(os.Stderr, "Skipping plugin: %v\n", err)
+	}
+	bot.MCP = initMCP()
+	bot.WatchMCP()
+	bot.ContextBudget = config.Cfg.CONTEXT_BUDGET
+	bot.ToolWorkers = config.Cfg.TOOL_WORKERS
+	if bot.Dir, err = os.Getwd(); err != nil {
+		slog.Warn("failed to get the working directory", "err", err)
+	}
+	if bot.Workspace, err = workspace.New(bot.Dir, config.Cfg.READ_ROOTS); err != nil {
+		slog.Error("Error setting up the workspace", "err", err)
+		fmt.Printf("Failed To Set Up Workspace: %v\n", err)
 		os.Exit(1)
 	}
-	bot.History = *history
-	bot.InitOAIMessages()
+	if config.Cfg.SHELL_SANDBOX {
+		bot.Sandbox = sandbox.New(bot.Workspace.Root(), config.Cfg.SANDBOX_NETWORK)
+		slog.Info("shell sandbox", "kind", bot.Sandbox.Kind, "network", bot.Sandbox.Network)
+		if !bot.Sandbox.Isolated() {
+			slog.Warn("shell sandbox is degraded", "kind", bot.Sandbox.Kind)
+			fmt.Fprintln(os.Stderr, "Warning: neither bubblewrap nor Linux namespaces work here, so the shell sandbox only cleans the environment; files and the network are not isolated.")
+		}
+	}
 	return bot
 }
+
+// initMCP starts the MCP servers in config.MCPPath and waits for them to
+// list their tools. Servers that fail are reported and can be restarted
+// from the TUI with /mcp restart.
+func initMCP() *mcp.Manager {
+	servers, err := mcp.LoadConfig(config.MCPPath())
+	if err != nil {
+		slog.Error("Error loading MCP servers", "err", err)
+		fmt.Printf("Failed To Load MCP Servers: %v\n", err)
+		os.Exit(1)
+	}
+	if len(servers) == 0 {
+		return nil
+	}
+	manager := mcp.NewManager(servers)
+	for _, err := range manager.Start(context.Background()) {
+		slog.Warn("MCP server failed to start", "err", err)
+		fmt.Fprintf(os.Stderr, "Skipping %v\n", err)
+	}
+	return manager
+}
+
+// initProvider returns the model API selected by PROVIDER, retrying
+// transient failures with the retries shown in the status line.
+func initProvider() llm.Provider {
+	var provider llm.Provider
+	if config.Cfg.PROVIDER == config.ProviderAnthropic {
+		provider = llm.NewAnthropic(llm.AnthropicConfig{
+			APIKey:         config.Cfg.ANTHROPIC_API_KEY,
+			BaseURL:        config.Cfg.ANTHROPIC_BASE_URL,
+			Model:          config.Cfg.ANTHROPIC_MODEL,
+			MaxTokens:      config.Cfg.ANTHROPIC_MAX_TOKENS,
+			ThinkingBudget: config.Cfg.ANTHROPIC_THINKING_BUDGET,
+		})
+	} else {
+		provider = llm.NewOpenAI(llm.OpenAIConfig{
+			APIKey:  config.Cfg.OPENAI_API_KEY,
+			BaseURL: config.Cfg.OPENAI_BASE_URL,
+			Model:   config.Cfg.OPENAI_MODEL,
+		})
+	}
+
+	policy := llm.DefaultRetryPolicy
+	policy.OnRetry = func(attempt int, delay time.Duration, err error) {
+		slog.Warn("retrying model request", "attempt", attempt, "delay", delay, "err", err)
+		bus.EmitState(agent.RetryStatus(attempt, policy.MaxRetries, delay, err))
+	}
+	return llm.WithRetry(provider, policy)
+}
@@ -1,15 +1,32 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
 	"log/slog"
+	"os"
+	"strings"
 
 	"github.com/biisal/godo/internal/config"
+	"github.com/biisal/godo/internal/sandbox"
 )
 
 var version = "dev"
 
 func main() {
+	sandbox.RunHelper()
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		os.Exit(runCommand(os.Args[1], os.Args[2:]))
+	}
+	opts, err := parseStartFlags(os.Args[1:])
+	if err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		os.Exit(2)
+	}
+
 	if err := runAutoUpdate(version); err != nil {
 		slog.Error("Auto-update error", "err", err)
 	}
@@ -19,13 +36,25 @@ func main() {
 
 	initConfig()
 	defer func() {
-		if err := config.Cfg.DB.Close(); err != nil {
+		if err := config.CloseDB(); err != nil {
 			slog.Error("error closing db", "err", err)
 		}
 	}()
 
-	bot := initBot()
-	run(bot)
+	autoBackup()
+
+	todos, globalTodos := initTodos()
+	bot := initBot(todos)
+	pick, err := startSession(bot, opts)
+	if err != nil {
+		slog.Error("Error resuming chat session", "err", err)
+		fmt.Printf("Failed To Resume Session: %v\n", err)
+		os.Exit(1)
+	}
+	run(bot, todos, globalTodos, pick)
+	if bot.MCP != nil {
+		bot.MCP.Close()
+	}
 
 	fmt.Println("Goodbye!")
 }
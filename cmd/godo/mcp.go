@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/biisal/godo/internal/config"
+	"github.com/biisal/godo/internal/crypt"
+	"github.com/biisal/godo/internal/mcpserve"
+	"github.com/fatih/color"
+)
+
+func runMCP(args []string) error {
+	if len(args) == 0 || args[0] != "serve" {
+		return fmt.Errorf("usage: godo mcp serve")
+	}
+	// Stdout carries the protocol, so messages go to stderr.
+	color.Output = os.Stderr
+	fs := flag.NewFlagSet("mcp serve", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	// A passphrase would be read from stdin, which belongs to the client.
+	if config.Cfg.ENCRYPTION_KEY_FILE == "" {
+		for _, s := range openStores() {
+			_, err := crypt.LoadParams(s.db)
+			if errors.Is(err, crypt.ErrNotEncrypted) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			return fmt.Errorf("the %s store is encrypted: set ENCRYPTION_KEY_FILE to serve it over MCP", s.name)
+		}
+	}
+	memories, err := (&unlocker{}).memoryStore(config.Cfg.GlobalDB)
+	if err != nil {
+		return err
+	}
+	// Like the TUI, serve the project's todos inside a project and the
+	// global ones elsewhere. Memories are always global.
+	todos, _ := initTodos()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return mcpserve.New(todos, memories, version).Serve(ctx, os.Stdin, os.Stdout)
+}
@@ -6,12 +6,19 @@ import (
 
 	"github.com/biisal/godo/internal/bus"
 	"github.com/biisal/godo/internal/tui/actions/agent"
+	todoAction "github.com/biisal/godo/internal/tui/actions/todo"
 	"github.com/biisal/godo/internal/tui/ui"
 	tea "github.com/charmbracelet/bubbletea"
 )
 
-func run(bot *agent.Bot) {
-	m := ui.InitialModel(bot)
+// run starts the TUI, with the session picker open when pickSession is set.
+func run(bot *agent.Bot, todos, globalTodos *todoAction.Service, pickSession bool) {
+	m := ui.InitialModel(bot, todos, globalTodos)
+	if pickSession {
+		if err := m.OpenSessionPicker(); err != nil {
+			slog.Error("Error listing chat sessions", "err", err)
+		}
+	}
 	p := tea.NewProgram(m, tea.WithAltScreen())
 
 	if err := tea.ClearScreen(); err != nil {
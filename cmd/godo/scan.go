@@ -0,0 +1,23 @@
+package main
+
+import (
+	"flag"
+
+	"github.com/biisal/godo/internal/logger"
+)
+
+func runScan(args []string) error {
+	fs := flag.NewFlagSet("scan", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	todos, _ := initTodos()
+	result, err := todos.ScanAndImport(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	logger.Success("Scanned %s: %d markers, %d added, %d updated, %d closed, %d reopened",
+		result.Root, result.Found, result.Added, result.Updated, result.Closed, result.Reopened)
+	return nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"strconv"
+
+	"github.com/biisal/godo/internal/store"
+	"github.com/biisal/godo/internal/tui/actions/agent"
+)
+
+// startOptions are the flags of a plain godo run, which choose the chat
+// session the agent starts in.
+type startOptions struct {
+	// continueLast resumes the most recent session of the working directory.
+	continueLast bool
+	// pick opens the session picker, unless session names one to resume.
+	pick    bool
+	session int
+}
+
+func parseStartFlags(args []string) (startOptions, error) {
+	var opts startOptions
+	fs := flag.NewFlagSet("godo", flag.ContinueOnError)
+	fs.BoolVar(&opts.continueLast, "continue", false, "continue the most recent chat session in this directory")
+	fs.BoolVar(&opts.continueLast, "c", false, "shorthand for --continue")
+	fs.BoolVar(&opts.pick, "resume", false, "pick a chat session to resume, or resume the session id given")
+	fs.BoolVar(&opts.pick, "r", false, "shorthand for --resume")
+	if err := fs.Parse(args); err != nil {
+		return opts, err
+	}
+	switch {
+	case fs.NArg() == 1 && opts.pick:
+		id, err := strconv.Atoi(fs.Arg(0))
+		if err != nil {
+			return opts, fmt.Errorf("invalid session id %q", fs.Arg(0))
+		}
+		opts.session, opts.pick = id, false
+	case fs.NArg() > 0:
+		return opts, fmt.Errorf("usage: godo [--continue | --resume [session id]]")
+	}
+	if opts.continueLast && (opts.pick || opts.session != 0) {
+		return opts, fmt.Errorf("--continue and --resume cannot be combined")
+	}
+	return opts, nil
+}
+
+// startSession resumes the session chosen by opts and reports whether the
+// TUI should open the session picker.
+func startSession(bot *agent.Bot, opts startOptions) (bool, error) {
+	switch {
+	case opts.session != 0:
+		return false, bot.ResumeSession(opts.session)
+	case opts.continueLast:
+		err := bot.ContinueSession()
+		if errors.Is(err, store.ErrNotFound) {
+			slog.Info("no session to continue, starting a new one", "dir", bot.Dir)
+			return false, nil
+		}
+		return false, err
+	}
+	return opts.pick, nil
+}
@@ -0,0 +1,228 @@
+// Package backup copies godo SQLite databases with VACUUM INTO, keeps a
+// rotated set of dated backups and restores a verified backup over the live
+// database file.
+package backup
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+const (
+	timeLayout = "20060102-150405"
+	extension  = ".db"
+)
+
+// nameLayout adds milliseconds so backups taken in the same second, such as
+// a manual backup right before a restore, get distinct names. Parsing with
+// timeLayout accepts the fractional part.
+const nameLayout = timeLayout + ".000"
+
+// Backup is one backup file found in a backup directory.
+type Backup struct {
+	Path string
+	Time time.Time
+	Size int64
+}
+
+// Info describes a database that passed Verify.
+type Info struct {
+	SchemaVersion int
+	Todos         int
+}
+
+// Into writes a consistent copy of db to path. It works while db is in use
+// and fails if path already exists.
+func Into(db *sql.DB, path string) error {
+	if _, err := db.Exec(`VACUUM INTO ?`, path); err != nil {
+		return fmt.Errorf("failed to back up database to %s: %w", path, err)
+	}
+	return nil
+}
+
+// Create backs db up into dir as <prefix>-<timestamp>.db and returns the path
+// of the new file.
+func Create(db *sql.DB, dir, prefix string, now time.Time) (string, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, prefix+"-"+now.Format(nameLayout)+extension)
+	if err := Into(db, path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// List returns the backups of prefix in dir, newest first. A missing
+// directory has no backups.
+func List(dir, prefix string) ([]Backup, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var backups []Backup
+	for _, entry := range entries {
+		stamp, ok := strings.CutPrefix(entry.Name(), prefix+"-")
+		if !ok || entry.IsDir() {
+			continue
+		}
+		stamp, ok = strings.CutSuffix(stamp, extension)
+		if !ok {
+			continue
+		}
+		t, err := time.ParseInLocation(timeLayout, stamp, time.Local)
+		if err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		backups = append(backups, Backup{Path: filepath.Join(dir, entry.Name()), Time: t, Size: info.Size()})
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].Time.After(backups[j].Time) })
+	return backups, nil
+}
+
+// Rotate deletes all but the newest keep backups of prefix and returns the
+// paths it removed.
+func Rotate(dir, prefix string, keep int) ([]string, error) {
+	backups, err := List(dir, prefix)
+	if err != nil || len(backups) <= keep {
+		return nil, err
+	}
+	var removed []string
+	for _, b := range backups[max(keep, 0):] {
+		if err := os.Remove(b.Path); err != nil {
+			return removed, err
+		}
+		removed = append(removed, b.Path)
+	}
+	return removed, nil
+}
+
+// Daily backs db up unless a backup of prefix was already taken on the same
+// day as now, then rotates down to keep backups. It returns the new backup
+// path, or "" when today's backup already existed.
+func Daily(db *sql.DB, dir, prefix string, keep int, now time.Time) (string, error) {
+	backups, err := List(dir, prefix)
+	if err != nil {
+		return "", err
+	}
+	if len(backups) > 0 && sameDay(backups[0].Time, now) {
+		return "", nil
+	}
+	path, err := Create(db, dir, prefix, now)
+	if err != nil {
+		return "", err
+	}
+	_, err = Rotate(dir, prefix, keep)
+	return path, err
+}
+
+func sameDay(a, b time.Time) bool {
+	ay, am, ad := a.Date()
+	by, bm, bd := b.Date()
+	return ay == by && am == bm && ad == bd
+}
+
+// Verify checks that path is an intact SQLite database holding godo todos.
+// The file is opened read-only.
+func Verify(path string) (Info, error) {
+	if _, err := os.Stat(path); err != nil {
+		return Info{}, err
+	}
+	db, err := sql.Open("sqlite", "file:"+path+"?mode=ro")
+	if err != nil {
+		return Info{}, err
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+
+	var result string
+	if err := db.QueryRow(`PRAGMA integrity_check`).Scan(&result); err != nil {
+		return Info{}, fmt.Errorf("%s is not a readable SQLite database: %w", path, err)
+	}
+	if result != "ok" {
+		return Info{}, fmt.Errorf("%s failed the integrity check: %s", path, result)
+	}
+
+	var info Info
+	if err := db.QueryRow(`SELECT COUNT(*) FROM todos`).Scan(&info.Todos); err != nil {
+		return Info{}, fmt.Errorf("%s is not a godo database: %w", path, err)
+	}
+	var hasVersion int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_version'`).Scan(&hasVersion); err != nil {
+		return Info{}, err
+	}
+	if hasVersion > 0 {
+		if err := db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_version`).Scan(&info.SchemaVersion); err != nil {
+			return Info{}, err
+		}
+	}
+	return info, nil
+}
+
+// Restore replaces the database file at dst with a verified copy of src.
+// Every connection to dst must be closed first. The copy is written next to
+// dst and renamed into place, so dst is never left half written.
+func Restore(src, dst string) (Info, error) {
+	info, err := Verify(src)
+	if err != nil {
+		return Info{}, err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(dst), ".restore-*"+extension)
+	if err != nil {
+		return Info{}, err
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+	if err := copyFile(tmp, src); err != nil {
+		_ = tmp.Close()
+		return Info{}, err
+	}
+	if err := tmp.Close(); err != nil {
+		return Info{}, err
+	}
+
+	// A journal left behind by the old database would be replayed into the
+	// restored one.
+	for _, suffix := range []string{"-wal", "-shm", "-journal"} {
+		if err := os.Remove(dst + suffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return Info{}, err
+		}
+	}
+	if err := os.Rename(tmp.Name(), dst); err != nil {
+		return Info{}, err
+	}
+	return info, nil
+}
+
+func copyFile(dst *os.File, src string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = in.Close()
+	}()
+	if _, err := io.Copy(dst, in); err != nil {
+		return err
+	}
+	return dst.Sync()
+}
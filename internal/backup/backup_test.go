@@ -0,0 +1,186 @@
+package backup
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// openTodoDB creates a file database with a todos table holding n rows.
+func openTodoDB(t *testing.T, path string, n int) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+	if _, err := db.Exec(`CREATE TABLE todos (Id INTEGER PRIMARY KEY, Title TEXT)`); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+	for i := 0; i < n; i++ {
+		if _, err := db.Exec(`INSERT INTO todos (Title) VALUES ('todo')`); err != nil {
+			t.Fatalf("Failed to insert todo: %v", err)
+		}
+	}
+	return db
+}
+
+func TestCreateAndList(t *testing.T) {
+	dir := t.TempDir()
+	db := openTodoDB(t, filepath.Join(dir, "todo.db"), 2)
+	backups := filepath.Join(dir, "backups")
+	day := time.Date(2026, 3, 1, 9, 0, 0, 0, time.Local)
+
+	first, err := Create(db, backups, "todo", day)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if _, err := Create(db, backups, "todo", day.Add(time.Hour)); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if _, err := Create(db, backups, "other-todo", day); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if _, err := Create(db, backups, "todo", day); err == nil {
+		t.Error("Expected an error when the backup file already exists")
+	}
+
+	list, err := List(backups, "todo")
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("Expected 2 backups for the prefix, got %+v", list)
+	}
+	if !list[0].Time.Equal(day.Add(time.Hour)) || list[1].Path != first {
+		t.Errorf("Expected newest first, got %+v", list)
+	}
+
+	info, err := Verify(first)
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if info.Todos != 2 {
+		t.Errorf("Expected 2 todos in the backup, got %d", info.Todos)
+	}
+}
+
+func TestListMissingDir(t *testing.T) {
+	list, err := List(filepath.Join(t.TempDir(), "missing"), "todo")
+	if err != nil || len(list) != 0 {
+		t.Errorf("Expected no backups and no error, got %v, %v", list, err)
+	}
+}
+
+func TestDailyRotates(t *testing.T) {
+	dir := t.TempDir()
+	db := openTodoDB(t, filepath.Join(dir, "todo.db"), 1)
+	backups := filepath.Join(dir, "backups")
+	start := time.Date(2026, 3, 1, 9, 0, 0, 0, time.Local)
+
+	for i := 0; i < 5; i++ {
+		day := start.AddDate(0, 0, i)
+		path, err := Daily(db, backups, "todo", 3, day)
+		if err != nil {
+			t.Fatalf("Daily failed: %v", err)
+		}
+		if path == "" {
+			t.Fatalf("Expected a backup on day %d", i)
+		}
+		again, err := Daily(db, backups, "todo", 3, day.Add(2*time.Hour))
+		if err != nil || again != "" {
+			t.Fatalf("Expected no second backup on the same day, got %q, %v", again, err)
+		}
+	}
+
+	list, _ := List(backups, "todo")
+	if len(list) != 3 {
+		t.Fatalf("Expected rotation to keep 3 backups, got %d", len(list))
+	}
+	if !list[2].Time.Equal(start.AddDate(0, 0, 2)) {
+		t.Errorf("Expected the oldest kept backup from day 2, got %v", list[2].Time)
+	}
+}
+
+func TestVerifyRejectsBadFiles(t *testing.T) {
+	dir := t.TempDir()
+
+	garbage := filepath.Join(dir, "garbage.db")
+	if err := os.WriteFile(garbage, []byte(strings.Repeat("not sqlite ", 100)), 0600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if _, err := Verify(garbage); err == nil {
+		t.Error("Expected a non-SQLite file to be rejected")
+	}
+
+	other := filepath.Join(dir, "other.db")
+	db, err := sql.Open("sqlite", other)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	if _, err := db.Exec(`CREATE TABLE notes (id INTEGER)`); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+	_ = db.Close()
+	if _, err := Verify(other); err == nil || !strings.Contains(err.Error(), "not a godo database") {
+		t.Errorf("Expected a database without todos to be rejected, got %v", err)
+	}
+
+	if _, err := Verify(filepath.Join(dir, "missing.db")); err == nil {
+		t.Error("Expected a missing file to be rejected")
+	}
+}
+
+func TestRestore(t *testing.T) {
+	dir := t.TempDir()
+	live := filepath.Join(dir, "todo.db")
+	db := openTodoDB(t, live, 1)
+
+	src, err := Create(openTodoDB(t, filepath.Join(dir, "old.db"), 4), filepath.Join(dir, "backups"), "todo", time.Now())
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("Failed to close database: %v", err)
+	}
+	stale := live + "-wal"
+	if err := os.WriteFile(stale, []byte("stale"), 0600); err != nil {
+		t.Fatalf("Failed to write wal: %v", err)
+	}
+
+	info, err := Restore(src, live)
+	if err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if info.Todos != 4 {
+		t.Errorf("Expected 4 restored todos, got %d", info.Todos)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Error("Expected the stale WAL file to be removed")
+	}
+	if restored, err := Verify(live); err != nil || restored.Todos != 4 {
+		t.Errorf("Expected the live database to hold the backup, got %+v, %v", restored, err)
+	}
+}
+
+func TestRestoreKeepsLiveDatabaseOnBadBackup(t *testing.T) {
+	dir := t.TempDir()
+	live := filepath.Join(dir, "todo.db")
+	_ = openTodoDB(t, live, 3).Close()
+
+	bad := filepath.Join(dir, "bad.db")
+	if err := os.WriteFile(bad, []byte("definitely not a database"), 0600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if _, err := Restore(bad, live); err == nil {
+		t.Fatal("Expected restoring a corrupt backup to fail")
+	}
+	if info, err := Verify(live); err != nil || info.Todos != 3 {
+		t.Errorf("Expected the live database to be untouched, got %+v, %v", info, err)
+	}
+}
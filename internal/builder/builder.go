@@ -8,7 +8,6 @@ import (
 	"strings"
 
 	"github.com/biisal/godo/internal/config"
-	"github.com/biisal/godo/internal/memory"
 )
 
 type SkillsLoader interface {
@@ -33,7 +32,9 @@ type ContextBuilder struct {
 	baseDir      string
 }
 
-func NewContextBuilder() *ContextBuilder {
+// NewContextBuilder returns a ContextBuilder that injects mem into the system
+// prompt.
+func NewContextBuilder(mem Memory) *ContextBuilder {
 	baseDir, _ := os.Getwd()
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
@@ -44,7 +45,7 @@ func NewContextBuilder() *ContextBuilder {
 
 	return &ContextBuilder{
 		skillsLoader: NewFileSkillsLoader(filepath.Join(homeDir, config.AppDIR, "content")),
-		memory:       memory.NewMemoryStore(config.Cfg.DB),
+		memory:       mem,
 		baseDir:      identityDir,
 	}
 }
@@ -54,7 +55,9 @@ func (cb *ContextBuilder) getIdentity() string {
 Use the tools available to fulfill the user's requests.
 Always respond in plain text. Do NOT use markdown formatting (no headers, bold, italic, bullet points, or code blocks) as the output is displayed in a terminal.
 When you learn important facts about the user or their preferences, save them with the SaveMemory tool so you remember across sessions.
-Use RecallMemories when you need to look up previously saved information.`
+Use RecallMemories when you need to look up previously saved information.
+Manage todos with ListTodos, AddTodo, UpdateTodo, ToggleTodo and DeleteTodo. Only fall back to PerformSql when those tools cannot do what the user asked.
+File tools work inside the directory GoDo was started in; reading or writing anywhere else asks the user first, so prefer paths inside it.`
 }
 
 func (cb *ContextBuilder) LoadBootstrapFiles() string {
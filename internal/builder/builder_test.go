@@ -8,7 +8,7 @@ import (
 )
 
 func TestNewContextBuilder(t *testing.T) {
-	cb := NewContextBuilder()
+	cb := NewContextBuilder(&DummyMemory{})
 	if cb == nil {
 		t.Fatal("NewContextBuilder should not return nil")
 	}
@@ -21,14 +21,14 @@ func TestNewContextBuilder(t *testing.T) {
 }
 
 func TestContextBuilderBaseDir(t *testing.T) {
-	cb := NewContextBuilder()
+	cb := NewContextBuilder(&DummyMemory{})
 	if cb.baseDir == "" {
 		t.Error("baseDir should not be empty")
 	}
 }
 
 func TestGetIdentity(t *testing.T) {
-	cb := NewContextBuilder()
+	cb := NewContextBuilder(&DummyMemory{})
 	identity := cb.getIdentity()
 
 	if identity == "" {
@@ -42,7 +42,7 @@ func TestGetIdentity(t *testing.T) {
 }
 
 func TestLoadBootstrapFiles(t *testing.T) {
-	cb := NewContextBuilder()
+	cb := NewContextBuilder(&DummyMemory{})
 
 	content := cb.LoadBootstrapFiles()
 	_ = content
@@ -101,7 +101,7 @@ func TestLoadBootstrapFilesWithMockFiles(t *testing.T) {
 }
 
 func TestBuildSystemPrompt(t *testing.T) {
-	cb := NewContextBuilder()
+	cb := NewContextBuilder(&DummyMemory{})
 	prompt := cb.BuildSystemPrompt()
 
 	if prompt == "" {
@@ -1,11 +1,54 @@
 package bus
 
-import "fmt"
+import (
+	"fmt"
+	"sync"
+)
 
 // StreamMsg represents a message sent through the event bus to the TUI.
 type StreamMsg struct {
 	Text string
 	Type string
+	// CallID and Tool name the tool call a "shell" or tool status message
+	// comes from, as read-only tool calls run concurrently.
+	CallID string
+	Tool   string
+	// Reply carries the user's answer to a "confirm" message.
+	Reply chan bool
+	// Approval carries the user's answer to an "approve" message.
+	Approval chan Approval
+	// Plan is the plan of a "plan" message and PlanReply carries the
+	// user's review of it.
+	Plan      *Plan
+	PlanReply chan PlanReview
+}
+
+// Approval is the user's answer to a tool permission prompt.
+type Approval int
+
+const (
+	Deny Approval = iota
+	AllowOnce
+	AllowAlways
+)
+
+// Plan is a step plan the agent submits for the user's review.
+type Plan struct {
+	Summary string     `json:"summary"`
+	Steps   []PlanStep `json:"steps"`
+}
+
+// PlanStep is one step of a Plan.
+type PlanStep struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+}
+
+// PlanReview is the user's answer to a plan. Steps are the approved steps,
+// which the user may have edited.
+type PlanReview struct {
+	Approved bool
+	Steps    []PlanStep
 }
 
 // StreamResponse is the channel used to communicate between the agent and the TUI.
@@ -45,15 +88,34 @@ func EmitStreamEnd() {
 	StreamResponse <- StreamMsg{Type: "stream_end"}
 }
 
-// EmitToolCall sends a status for an active tool call.
-func EmitToolCall(name string) {
-	EmitState(toolStatusMessage(name))
+// EmitToolCall sends a status for the tool call id that just started.
+func EmitToolCall(id, name string) {
+	StreamResponse <- StreamMsg{Text: toolStatusMessage(name), Type: "status", CallID: id, Tool: name}
+}
+
+var (
+	statusMu     sync.RWMutex
+	toolStatuses = map[string]string{}
+)
+
+// SetToolStatus sets the status shown while the tool name runs, for tools
+// such as plugins that are not built in.
+func SetToolStatus(name, status string) {
+	statusMu.Lock()
+	defer statusMu.Unlock()
+	toolStatuses[name] = status
 }
 
 func toolStatusMessage(name string) string {
+	statusMu.RLock()
+	status, ok := toolStatuses[name]
+	statusMu.RUnlock()
+	if ok {
+		return status
+	}
 	switch name {
 	case "PerformSql":
-		return "Checking your todos..."
+		return "Running SQL query..."
 	case "RunShellCommand":
 		return "Running command..."
 	case "ReadSkill":
@@ -76,12 +138,71 @@ func toolStatusMessage(name string) string {
 		return "Applying patch..."
 	case "InsertAtLine":
 		return "Inserting content into file..."
+	case "ScanTodos":
+		return "Scanning code for TODOs..."
+	case "ListTodos":
+		return "Checking your todos..."
+	case "AddTodo":
+		return "Adding todo..."
+	case "UpdateTodo":
+		return "Updating todo..."
+	case "ToggleTodo":
+		return "Toggling todo..."
+	case "DeleteTodo":
+		return "Deleting todo..."
+	case "SQLiteSchema":
+		return "Reading database schema..."
+	case "QuerySQLite":
+		return "Querying database..."
+	case "Delegate":
+		return "Delegating to a sub-agent..."
+	case "SubmitPlan":
+		return "Submitting the plan..."
 	default:
 		return fmt.Sprintf("Running %s...", name)
 	}
 }
 
+// EmitMCPStatus tells the TUI that an MCP server's connection changed.
+func EmitMCPStatus() {
+	StreamResponse <- StreamMsg{Type: "mcp"}
+}
+
 // EmitShell sends shell command or output for the side panel.
 func EmitShell(text string) {
 	StreamResponse <- StreamMsg{Text: text, Type: "shell"}
 }
+
+// EmitToolShell sends output of the tool call id for the side panel.
+func EmitToolShell(id, name, text string) {
+	StreamResponse <- StreamMsg{Text: text, Type: "shell", CallID: id, Tool: name}
+}
+
+// EmitDelegate sends output of the sub-agent started by the tool call id
+// for the delegate panel.
+func EmitDelegate(id, text string) {
+	StreamResponse <- StreamMsg{Text: text, Type: "delegate", CallID: id}
+}
+
+// Confirm asks the user a yes/no question and blocks until it is answered.
+func Confirm(question string) bool {
+	reply := make(chan bool, 1)
+	StreamResponse <- StreamMsg{Text: question, Type: "confirm", Reply: reply}
+	return <-reply
+}
+
+// Approve asks the user whether a tool call may run and blocks until they
+// allow it once, allow it always or deny it.
+func Approve(question string) Approval {
+	reply := make(chan Approval, 1)
+	StreamResponse <- StreamMsg{Text: question, Type: "approve", Approval: reply}
+	return <-reply
+}
+
+// ReviewPlan shows plan to the user and blocks until they approve, edit or
+// reject it.
+func ReviewPlan(plan Plan) PlanReview {
+	reply := make(chan PlanReview, 1)
+	StreamResponse <- StreamMsg{Type: "plan", Plan: &plan, PlanReply: reply}
+	return <-reply
+}
@@ -205,13 +205,60 @@ func TestEmitShell(t *testing.T) {
 	}
 }
 
+func TestEmitMCPStatus(t *testing.T) {
+	go EmitMCPStatus()
+
+	select {
+	case msg := <-StreamResponse:
+		if msg.Type != "mcp" {
+			t.Errorf("Expected Type to be 'mcp', got '%s'", msg.Type)
+		}
+	case <-time.After(1 * time.Second):
+		t.Error("Timeout waiting for StreamResponse")
+	}
+}
+
+func TestEmitDelegate(t *testing.T) {
+	go EmitDelegate("call_1", "Task: read the docs\n")
+
+	select {
+	case msg := <-StreamResponse:
+		if msg.Type != "delegate" || msg.CallID != "call_1" || msg.Text != "Task: read the docs\n" {
+			t.Errorf("Unexpected delegate message %+v", msg)
+		}
+	case <-time.After(1 * time.Second):
+		t.Error("Timeout waiting for StreamResponse")
+	}
+}
+
+func TestEmitToolCallAndShell(t *testing.T) {
+	go func() {
+		EmitToolCall("call_1", "ReadFiles")
+		EmitToolShell("call_1", "ReadFiles", "reading go.mod\n")
+	}()
+
+	for _, want := range []StreamMsg{
+		{Text: "Reading files...", Type: "status", CallID: "call_1", Tool: "ReadFiles"},
+		{Text: "reading go.mod\n", Type: "shell", CallID: "call_1", Tool: "ReadFiles"},
+	} {
+		select {
+		case msg := <-StreamResponse:
+			if msg.Text != want.Text || msg.Type != want.Type || msg.CallID != want.CallID || msg.Tool != want.Tool {
+				t.Errorf("Expected %+v, got %+v", want, msg)
+			}
+		case <-time.After(1 * time.Second):
+			t.Fatal("Timeout waiting for StreamResponse")
+		}
+	}
+}
+
 func TestToolStatusMessage(t *testing.T) {
 	tests := []struct {
 		name     string
 		toolName string
 		expected string
 	}{
-		{"PerformSql", "PerformSql", "Checking your todos..."},
+		{"PerformSql", "PerformSql", "Running SQL query..."},
 		{"RunShellCommand", "RunShellCommand", "Running command..."},
 		{"ReadSkill", "ReadSkill", "Loading skill instructions..."},
 		{"GlobSearch", "GlobSearch", "Searching files by pattern..."},
@@ -223,8 +270,19 @@ func TestToolStatusMessage(t *testing.T) {
 		{"EditFile", "EditFile", "Editing file..."},
 		{"PatchFile", "PatchFile", "Applying patch..."},
 		{"InsertAtLine", "InsertAtLine", "Inserting content into file..."},
+		{"ScanTodos", "ScanTodos", "Scanning code for TODOs..."},
+		{"ListTodos", "ListTodos", "Checking your todos..."},
+		{"AddTodo", "AddTodo", "Adding todo..."},
+		{"UpdateTodo", "UpdateTodo", "Updating todo..."},
+		{"ToggleTodo", "ToggleTodo", "Toggling todo..."},
+		{"DeleteTodo", "DeleteTodo", "Deleting todo..."},
+		{"SQLiteSchema", "SQLiteSchema", "Reading database schema..."},
+		{"QuerySQLite", "QuerySQLite", "Querying database..."},
 		{"Unknown tool", "UnknownTool", "Running UnknownTool..."},
+		{"Plugin", "Weather", "Checking the weather..."},
 	}
+	SetToolStatus("Weather", "Checking the weather...")
+	t.Cleanup(func() { SetToolStatus("Weather", "Running Weather...") })
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -455,3 +513,70 @@ func TestMultipleEmitSequences(t *testing.T) {
 		t.Error("Timeout waiting for sequence")
 	}
 }
+
+func TestConfirm(t *testing.T) {
+	go func() {
+		msg := <-StreamResponse
+		if msg.Type != "confirm" || msg.Text != "Delete everything?" {
+			t.Errorf("Expected a confirm message, got %+v", msg)
+		}
+		msg.Reply <- true
+	}()
+
+	done := make(chan bool)
+	go func() { done <- Confirm("Delete everything?") }()
+
+	select {
+	case ok := <-done:
+		if !ok {
+			t.Error("Expected Confirm to return the reply")
+		}
+	case <-time.After(1 * time.Second):
+		t.Error("Timeout waiting for Confirm")
+	}
+}
+
+func TestApprove(t *testing.T) {
+	go func() {
+		msg := <-StreamResponse
+		if msg.Type != "approve" || msg.Text != "Allow RunShellCommand?" {
+			t.Errorf("Expected an approve message, got %+v", msg)
+		}
+		msg.Approval <- AllowAlways
+	}()
+
+	done := make(chan Approval)
+	go func() { done <- Approve("Allow RunShellCommand?") }()
+
+	select {
+	case got := <-done:
+		if got != AllowAlways {
+			t.Errorf("Expected Approve to return the reply, got %d", got)
+		}
+	case <-time.After(1 * time.Second):
+		t.Error("Timeout waiting for Approve")
+	}
+}
+
+func TestReviewPlan(t *testing.T) {
+	go func() {
+		msg := <-StreamResponse
+		if msg.Type != "plan" || msg.Plan == nil || msg.Plan.Summary != "Fix the bug" {
+			t.Errorf("Expected a plan message, got %+v", msg)
+		}
+		msg.PlanReply <- PlanReview{Approved: true, Steps: msg.Plan.Steps[:1]}
+	}()
+
+	done := make(chan PlanReview)
+	plan := Plan{Summary: "Fix the bug", Steps: []PlanStep{{Title: "Write a test"}, {Title: "Fix it"}}}
+	go func() { done <- ReviewPlan(plan) }()
+
+	select {
+	case review := <-done:
+		if !review.Approved || len(review.Steps) != 1 || review.Steps[0].Title != "Write a test" {
+			t.Errorf("Expected ReviewPlan to return the review, got %+v", review)
+		}
+	case <-time.After(1 * time.Second):
+		t.Error("Timeout waiting for ReviewPlan")
+	}
+}
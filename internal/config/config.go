@@ -2,28 +2,54 @@ package config
 
 import (
 	"bufio"
+	"crypto/sha1"
 	"database/sql"
 	"fmt"
 	"os"
 	"path/filepath"
 	"slices"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/biisal/godo/internal/crypt"
+	"github.com/biisal/godo/internal/migrate"
+	"github.com/charmbracelet/x/term"
 	"github.com/ilyakaznacheev/cleanenv"
 	"github.com/joho/godotenv"
 	_ "modernc.org/sqlite"
 )
 
 type Config struct {
+	PROVIDER        string `env:"PROVIDER"`
 	OPENAI_API_KEY  string `env:"OPENAI_API_KEY"`
 	OPENAI_MODEL    string `env:"OPENAI_MODEL"`
 	OPENAI_BASE_URL string `env:"OPENAI_BASE_URL"`
 	ENVIRONMENT     string `env:"ENVIRONMENT"`
 	MODE            string `env:"MODE"`
-	DB_PATH         string
-	DB_NAME         string
-	DB              *sql.DB
+	BACKUP_KEEP     int    `env:"BACKUP_KEEP"`
+	CONTEXT_BUDGET  int    `env:"CONTEXT_BUDGET"`
+	TOOL_WORKERS    int    `env:"TOOL_WORKERS"`
+	// READ_ROOTS are directories outside the workspace the agent may read,
+	// separated like PATH.
+	READ_ROOTS []string `env:"READ_ROOTS" env-separator:":"`
+	// SHELL_SANDBOX runs shell commands isolated from everything but the
+	// workspace; SANDBOX_NETWORK lets them reach the network.
+	SHELL_SANDBOX   bool `env:"SHELL_SANDBOX"`
+	SANDBOX_NETWORK bool `env:"SANDBOX_NETWORK"`
+
+	ANTHROPIC_API_KEY         string `env:"ANTHROPIC_API_KEY"`
+	ANTHROPIC_MODEL           string `env:"ANTHROPIC_MODEL"`
+	ANTHROPIC_BASE_URL        string `env:"ANTHROPIC_BASE_URL"`
+	ANTHROPIC_MAX_TOKENS      int    `env:"ANTHROPIC_MAX_TOKENS"`
+	ANTHROPIC_THINKING_BUDGET int    `env:"ANTHROPIC_THINKING_BUDGET"`
+
+	ENCRYPTION_KEY_FILE string `env:"ENCRYPTION_KEY_FILE"`
+	DB_PATH             string
+	DB_NAME             string
+	PROJECT_DIR         string
+	DB                  *sql.DB
+	GlobalDB            *sql.DB
 }
 
 var (
@@ -40,6 +66,11 @@ var (
 	LogDIR         string
 	ErrorType      = "error"
 	MessageType    = "message"
+	StoreGlobal    = "global"
+	StoreProject   = "project"
+
+	ProviderOpenAI    = "openai"
+	ProviderAnthropic = "anthropic"
 
 	stdin = bufio.NewScanner(os.Stdin)
 )
@@ -50,6 +81,12 @@ func readLine(prompt string) string {
 	return strings.TrimSpace(stdin.Text())
 }
 
+// Confirm asks question on stdin and reports whether the answer was yes.
+func Confirm(question string) bool {
+	answer := strings.ToLower(readLine(question + " [y/N] "))
+	return answer == "y" || answer == "yes"
+}
+
 func loadEnv(paths []string) error {
 	for _, path := range paths {
 		if err := godotenv.Load(path); err == nil {
@@ -60,6 +97,15 @@ func loadEnv(paths []string) error {
 }
 
 func MustLoad() error {
+	if err := LoadStorage(); err != nil {
+		return err
+	}
+	return getApiKey()
+}
+
+// LoadStorage loads the config and opens the databases without asking for an
+// API key, for commands that never talk to the model (git hooks, scans).
+func LoadStorage() error {
 	var err error
 
 	HomeDIR, err = os.UserHomeDir()
@@ -79,13 +125,8 @@ func MustLoad() error {
 
 	StartTime = time.Now()
 
-	if Cfg.OPENAI_MODEL == "" {
-		Cfg.OPENAI_MODEL = "gpt-4o-mini"
-		fmt.Println("OPENAI_MODEL is not set, using default value:", Cfg.OPENAI_MODEL)
-	}
-
-	if Cfg.OPENAI_BASE_URL == "" {
-		Cfg.OPENAI_BASE_URL = "https://api.openai.com/v1"
+	if err = setProviderDefaults(); err != nil {
+		return err
 	}
 
 	if Cfg.ENVIRONMENT == "" {
@@ -102,24 +143,83 @@ func MustLoad() error {
 		Cfg.MODE = ModeAgent
 	}
 
+	if Cfg.BACKUP_KEEP <= 0 {
+		Cfg.BACKUP_KEEP = 7
+	}
+
+	if Cfg.CONTEXT_BUDGET <= 0 {
+		Cfg.CONTEXT_BUDGET = 64000
+	}
+
+	if Cfg.TOOL_WORKERS <= 0 {
+		Cfg.TOOL_WORKERS = 4
+	}
+
+	if Cfg.DB_NAME == "" {
+		Cfg.DB_NAME = "todo.db"
+	}
 	if Cfg.DB_PATH == "" {
-		if Cfg.DB_NAME == "" {
-			Cfg.DB_NAME = "todo.db"
-		}
 		Cfg.DB_PATH = HomeDIR + AppDIR + Cfg.DB_NAME
 	}
 
-	if err = getApiKey(); err != nil {
-		return err
+	if cwd, err := os.Getwd(); err == nil {
+		Cfg.PROJECT_DIR = FindProjectDir(cwd, HomeDIR)
 	}
 
-	if err = initDb(); err != nil {
-		return err
+	return initDb()
+}
+
+// setProviderDefaults validates PROVIDER and fills in the model settings
+// that were left unset.
+func setProviderDefaults() error {
+	switch Cfg.PROVIDER {
+	case "":
+		Cfg.PROVIDER = ProviderOpenAI
+	case ProviderOpenAI, ProviderAnthropic:
+	default:
+		return fmt.Errorf("unknown PROVIDER %q, expected %s or %s", Cfg.PROVIDER, ProviderOpenAI, ProviderAnthropic)
 	}
 
+	if Cfg.OPENAI_MODEL == "" {
+		Cfg.OPENAI_MODEL = "gpt-4o-mini"
+		if Cfg.PROVIDER == ProviderOpenAI {
+			fmt.Fprintln(os.Stderr, "OPENAI_MODEL is not set, using default value:", Cfg.OPENAI_MODEL)
+		}
+	}
+
+	if Cfg.OPENAI_BASE_URL == "" {
+		Cfg.OPENAI_BASE_URL = "https://api.openai.com/v1"
+	}
+
+	if Cfg.ANTHROPIC_MODEL == "" {
+		Cfg.ANTHROPIC_MODEL = "claude-sonnet-4-5"
+		if Cfg.PROVIDER == ProviderAnthropic {
+			fmt.Fprintln(os.Stderr, "ANTHROPIC_MODEL is not set, using default value:", Cfg.ANTHROPIC_MODEL)
+		}
+	}
+
+	if Cfg.ANTHROPIC_BASE_URL == "" {
+		Cfg.ANTHROPIC_BASE_URL = "https://api.anthropic.com"
+	}
+
+	if Cfg.ANTHROPIC_MAX_TOKENS <= 0 {
+		Cfg.ANTHROPIC_MAX_TOKENS = 8192
+	}
+
+	if Cfg.ANTHROPIC_THINKING_BUDGET >= Cfg.ANTHROPIC_MAX_TOKENS {
+		return fmt.Errorf("ANTHROPIC_THINKING_BUDGET (%d) must be below ANTHROPIC_MAX_TOKENS (%d)", Cfg.ANTHROPIC_THINKING_BUDGET, Cfg.ANTHROPIC_MAX_TOKENS)
+	}
 	return nil
 }
 
+// ModelName is the model of the selected provider.
+func ModelName() string {
+	if Cfg.PROVIDER == ProviderAnthropic {
+		return Cfg.ANTHROPIC_MODEL
+	}
+	return Cfg.OPENAI_MODEL
+}
+
 func SaveCfg() error {
 	envPath := HomeDIR + AppDIR + ".env"
 
@@ -131,63 +231,218 @@ func SaveCfg() error {
 		_ = f.Close()
 	}()
 
-	content := "OPENAI_API_KEY=" + Cfg.OPENAI_API_KEY + "\n" +
+	content := "PROVIDER=" + Cfg.PROVIDER + "\n" +
+		"OPENAI_API_KEY=" + Cfg.OPENAI_API_KEY + "\n" +
 		"OPENAI_MODEL=" + Cfg.OPENAI_MODEL + "\n" +
 		"OPENAI_BASE_URL=" + Cfg.OPENAI_BASE_URL + "\n" +
-		"MODE=" + Cfg.MODE + "\n"
+		"MODE=" + Cfg.MODE + "\n" +
+		"BACKUP_KEEP=" + strconv.Itoa(Cfg.BACKUP_KEEP) + "\n" +
+		"CONTEXT_BUDGET=" + strconv.Itoa(Cfg.CONTEXT_BUDGET) + "\n" +
+		"TOOL_WORKERS=" + strconv.Itoa(Cfg.TOOL_WORKERS) + "\n"
+	if Cfg.PROVIDER == ProviderAnthropic || Cfg.ANTHROPIC_API_KEY != "" {
+		content += "ANTHROPIC_API_KEY=" + Cfg.ANTHROPIC_API_KEY + "\n" +
+			"ANTHROPIC_MODEL=" + Cfg.ANTHROPIC_MODEL + "\n" +
+			"ANTHROPIC_BASE_URL=" + Cfg.ANTHROPIC_BASE_URL + "\n" +
+			"ANTHROPIC_MAX_TOKENS=" + strconv.Itoa(Cfg.ANTHROPIC_MAX_TOKENS) + "\n" +
+			"ANTHROPIC_THINKING_BUDGET=" + strconv.Itoa(Cfg.ANTHROPIC_THINKING_BUDGET) + "\n"
+	}
+	if Cfg.SHELL_SANDBOX {
+		content += "SHELL_SANDBOX=true\n" +
+			"SANDBOX_NETWORK=" + strconv.FormatBool(Cfg.SANDBOX_NETWORK) + "\n"
+	}
+	if len(Cfg.READ_ROOTS) > 0 {
+		content += "READ_ROOTS=" + strings.Join(Cfg.READ_ROOTS, string(os.PathListSeparator)) + "\n"
+	}
+	if Cfg.ENCRYPTION_KEY_FILE != "" {
+		content += "ENCRYPTION_KEY_FILE=" + Cfg.ENCRYPTION_KEY_FILE + "\n"
+	}
 
 	_, err = f.WriteString(content)
 	return err
 }
 
+// FindProjectDir walks up from start looking for a project-local .godo
+// directory, the same way git looks for .git. The walk stops at home, whose
+// .godo directory is the global store. It returns "" when no project is found.
+func FindProjectDir(start, home string) string {
+	dir := filepath.Clean(start)
+	home = filepath.Clean(home)
+	for dir != home {
+		if info, err := os.Stat(filepath.Join(dir, AppDIR)); err == nil && info.IsDir() {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+	return ""
+}
+
+// ActiveStore reports which store todos and chats are read from.
+func ActiveStore() string {
+	if Cfg.PROJECT_DIR != "" {
+		return StoreProject
+	}
+	return StoreGlobal
+}
+
+// StoreLabel is the human readable name of the active store.
+func StoreLabel() string {
+	if Cfg.PROJECT_DIR != "" {
+		return StoreProject + " (" + filepath.Base(Cfg.PROJECT_DIR) + ")"
+	}
+	return StoreGlobal
+}
+
+// DBForStore returns the database handle backing the given store.
+func DBForStore(store string) *sql.DB {
+	if store == StoreGlobal {
+		return Cfg.GlobalDB
+	}
+	return Cfg.DB
+}
+
+// ActiveDBPath returns the file behind Cfg.DB.
+func ActiveDBPath() string {
+	if Cfg.PROJECT_DIR != "" {
+		return filepath.Join(Cfg.PROJECT_DIR, AppDIR, Cfg.DB_NAME)
+	}
+	return Cfg.DB_PATH
+}
+
+// BackupDir is where database backups are kept.
+func BackupDir() string {
+	return filepath.Join(HomeDIR, AppDIR, "backups")
+}
+
+// ToolsDir is the directory plugin tool manifests are loaded from.
+func ToolsDir() string {
+	return filepath.Join(HomeDIR, AppDIR, "tools")
+}
+
+// MCPPath is the file MCP server definitions are read from.
+func MCPPath() string {
+	return filepath.Join(HomeDIR, AppDIR, "mcp.json")
+}
+
+// PermissionsPath is the file the agent's tool permission rules are kept in.
+func PermissionsPath() string {
+	return filepath.Join(HomeDIR, AppDIR, "permissions.json")
+}
+
+// BackupPrefix names the backups of a store, so global and project backups
+// can share BackupDir. Project prefixes carry a short hash of the project
+// path to keep two projects with the same directory name apart.
+func BackupPrefix(store string) string {
+	name := strings.TrimSuffix(Cfg.DB_NAME, filepath.Ext(Cfg.DB_NAME))
+	if store == StoreProject && Cfg.PROJECT_DIR != "" {
+		sum := sha1.Sum([]byte(Cfg.PROJECT_DIR))
+		return fmt.Sprintf("%s-%x-%s", filepath.Base(Cfg.PROJECT_DIR), sum[:3], name)
+	}
+	return name
+}
+
+// CloseDB closes the project and global databases.
+func CloseDB() error {
+	var err error
+	if Cfg.DB != nil && Cfg.DB != Cfg.GlobalDB {
+		err = Cfg.DB.Close()
+	}
+	if Cfg.GlobalDB != nil {
+		if closeErr := Cfg.GlobalDB.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	Cfg.DB, Cfg.GlobalDB = nil, nil
+	return err
+}
+
 func initDb() error {
-	db, err := sql.Open("sqlite", Cfg.DB_PATH)
+	globalDb, err := OpenDB(Cfg.DB_PATH, StoreGlobal)
 	if err != nil {
 		return err
 	}
+	Cfg.GlobalDB = globalDb
+	Cfg.DB = globalDb
 
-	sqlStmt := `
-	BEGIN;
-	CREATE TABLE IF NOT EXISTS todos (
-		Id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
-		Title TEXT NOT NULL,
-		Description TEXT NOT NULL,
-		Done BOOLEAN NOT NULL DEFAULT FALSE
-	);
-	CREATE TABLE IF NOT EXISTS chats(
-		Id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
-		chat TEXT
-	);
-	CREATE TABLE IF NOT EXISTS memories (
-		id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
-		key TEXT NOT NULL UNIQUE,
-		content TEXT NOT NULL,
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
-	);
-	COMMIT;
-	`
-
-	if _, err = db.Exec(sqlStmt); err != nil {
-		return err
+	if Cfg.PROJECT_DIR != "" {
+		projectDb, err := OpenDB(ActiveDBPath(), StoreProject)
+		if err != nil {
+			return fmt.Errorf("failed to open project database: %w", err)
+		}
+		Cfg.DB = projectDb
 	}
-
-	Cfg.DB = db
 	return nil
 }
 
+// OpenDB opens the SQLite database at path and migrates it to the latest
+// schema, backing it up first with the other backups of store.
+func OpenDB(path, store string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+
+	backups := migrate.Backups{Dir: BackupDir(), Prefix: BackupPrefix(store), Keep: Cfg.BACKUP_KEEP}
+	if _, err = migrate.Run(db, backups); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// ReadSecret returns the database encryption secret: the contents of
+// ENCRYPTION_KEY_FILE when set, otherwise a passphrase typed without echo.
+// With confirm the passphrase is asked twice, for setting up a new one.
+func ReadSecret(confirm bool) ([]byte, error) {
+	if Cfg.ENCRYPTION_KEY_FILE != "" {
+		return crypt.ReadKeyFile(Cfg.ENCRYPTION_KEY_FILE)
+	}
+	secret, err := readPassword("Database passphrase: ")
+	if err != nil {
+		return nil, err
+	}
+	if len(secret) == 0 {
+		return nil, crypt.ErrEmptySecret
+	}
+	if confirm {
+		again, err := readPassword("Repeat passphrase: ")
+		if err != nil {
+			return nil, err
+		}
+		if string(again) != string(secret) {
+			return nil, fmt.Errorf("passphrases do not match")
+		}
+	}
+	return secret, nil
+}
+
+func readPassword(prompt string) ([]byte, error) {
+	if !term.IsTerminal(os.Stdin.Fd()) {
+		return []byte(readLine(prompt)), nil
+	}
+	fmt.Print(prompt)
+	defer fmt.Println()
+	return term.ReadPassword(os.Stdin.Fd())
+}
+
 func getApiKey() error {
-	Cfg.OPENAI_API_KEY = os.Getenv("OPENAI_API_KEY")
-	if Cfg.OPENAI_API_KEY != "" {
-		return nil
+	envName, key, prompt := "OPENAI_API_KEY", &Cfg.OPENAI_API_KEY, "Enter your OpenAI API key (or compatible API key): "
+	if Cfg.PROVIDER == ProviderAnthropic {
+		envName, key, prompt = "ANTHROPIC_API_KEY", &Cfg.ANTHROPIC_API_KEY, "Enter your Anthropic API key: "
 	}
 
-	key := readLine("Enter your OpenAI API key (or compatible API key): ")
-	if key == "" {
-		return fmt.Errorf("OPENAI_API_KEY is not set")
+	*key = os.Getenv(envName)
+	if *key != "" {
+		return nil
 	}
 
-	Cfg.OPENAI_API_KEY = key
+	*key = readLine(prompt)
+	if *key == "" {
+		return fmt.Errorf("%s is not set", envName)
+	}
 
 	if err := SaveCfg(); err != nil {
 		return fmt.Errorf("failed to save config after setting API key: %w", err)
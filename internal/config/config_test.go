@@ -176,3 +176,83 @@ func TestMessageTypeConstants(t *testing.T) {
 		t.Error("MessageType should not be empty")
 	}
 }
+
+func TestFindProjectDir(t *testing.T) {
+	home := t.TempDir()
+	project := filepath.Join(home, "code", "project")
+	nested := filepath.Join(project, "internal", "pkg")
+	if err := os.MkdirAll(nested, 0755); err != nil {
+		t.Fatalf("Failed to create dirs: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(home, AppDIR), 0755); err != nil {
+		t.Fatalf("Failed to create global dir: %v", err)
+	}
+
+	if got := FindProjectDir(nested, home); got != "" {
+		t.Errorf("Expected no project dir before .godo exists, got '%s'", got)
+	}
+
+	if err := os.MkdirAll(filepath.Join(project, AppDIR), 0755); err != nil {
+		t.Fatalf("Failed to create project dir: %v", err)
+	}
+	if got := FindProjectDir(nested, home); got != project {
+		t.Errorf("Expected project dir '%s', got '%s'", project, got)
+	}
+	if got := FindProjectDir(project, home); got != project {
+		t.Errorf("Expected project dir '%s' when starting at the root, got '%s'", project, got)
+	}
+}
+
+func TestFindProjectDirIgnoresGlobalStore(t *testing.T) {
+	home := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(home, AppDIR), 0755); err != nil {
+		t.Fatalf("Failed to create global dir: %v", err)
+	}
+	if got := FindProjectDir(home, home); got != "" {
+		t.Errorf("Expected the global .godo to be ignored, got '%s'", got)
+	}
+}
+
+func TestFindProjectDirIgnoresFiles(t *testing.T) {
+	home := t.TempDir()
+	project := filepath.Join(home, "project")
+	if err := os.MkdirAll(project, 0755); err != nil {
+		t.Fatalf("Failed to create dirs: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(project, ".godo"), []byte("not a dir"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if got := FindProjectDir(project, home); got != "" {
+		t.Errorf("Expected a .godo file to be ignored, got '%s'", got)
+	}
+}
+
+func TestSetProviderDefaults(t *testing.T) {
+	saved := Cfg
+	t.Cleanup(func() { Cfg = saved })
+
+	Cfg = Config{}
+	if err := setProviderDefaults(); err != nil {
+		t.Fatalf("setProviderDefaults failed: %v", err)
+	}
+	if Cfg.PROVIDER != ProviderOpenAI || ModelName() != Cfg.OPENAI_MODEL || Cfg.ANTHROPIC_MAX_TOKENS == 0 {
+		t.Errorf("Expected OpenAI defaults, got %+v", Cfg)
+	}
+
+	Cfg = Config{PROVIDER: ProviderAnthropic, ANTHROPIC_MODEL: "claude-test"}
+	if err := setProviderDefaults(); err != nil {
+		t.Fatalf("setProviderDefaults failed: %v", err)
+	}
+	if ModelName() != "claude-test" || Cfg.ANTHROPIC_BASE_URL == "" {
+		t.Errorf("Expected the Anthropic model to be active, got %+v", Cfg)
+	}
+
+	Cfg = Config{PROVIDER: "gemini"}
+	if err := setProviderDefaults(); err == nil {
+		t.Error("Expected an unknown provider to be rejected")
+	}
+	Cfg = Config{PROVIDER: ProviderAnthropic, ANTHROPIC_MAX_TOKENS: 1000, ANTHROPIC_THINKING_BUDGET: 1000}
+	if err := setProviderDefaults(); err == nil {
+		t.Error("Expected a thinking budget at max tokens to be rejected")
+	}
+}
@@ -0,0 +1,195 @@
+// Package crypt encrypts individual database values with AES-256-GCM. Keys
+// are derived from a passphrase or key file with PBKDF2, and the salt plus a
+// key verifier are kept in the database's encryption table so a wrong
+// passphrase is detected before anything is decrypted.
+package crypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Prefix marks an encrypted value. Values without it are plaintext.
+const Prefix = "enc:v1:"
+
+// DefaultIterations is the PBKDF2-SHA256 work factor for new databases.
+const DefaultIterations = 600_000
+
+const (
+	saltSize     = 16
+	verifierText = "godo-key-verifier"
+)
+
+var (
+	ErrWrongKey     = errors.New("wrong passphrase or key file")
+	ErrEmptySecret  = errors.New("passphrase cannot be empty")
+	ErrNotEncrypted = errors.New("database is not encrypted")
+)
+
+// Params are the key derivation settings stored alongside encrypted data.
+type Params struct {
+	Salt       []byte
+	Iterations int
+	Verifier   []byte
+}
+
+// Cipher seals and opens values with a key derived from a secret.
+type Cipher struct {
+	aead cipher.AEAD
+	mac  []byte
+}
+
+// NewParams derives a key from secret with a fresh salt and returns the
+// parameters to store together with the matching Cipher.
+func NewParams(secret []byte, iterations int) (Params, *Cipher, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return Params{}, nil, err
+	}
+	p := Params{Salt: salt, Iterations: iterations}
+	c, err := derive(secret, p)
+	if err != nil {
+		return Params{}, nil, err
+	}
+	p.Verifier = c.verifier()
+	return p, c, nil
+}
+
+// Unlock derives the key for p from secret. It returns ErrWrongKey when the
+// secret does not match the one the parameters were created with.
+func Unlock(p Params, secret []byte) (*Cipher, error) {
+	c, err := derive(secret, p)
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal(c.verifier(), p.Verifier) {
+		return nil, ErrWrongKey
+	}
+	return c, nil
+}
+
+func derive(secret []byte, p Params) (*Cipher, error) {
+	if len(secret) == 0 {
+		return nil, ErrEmptySecret
+	}
+	key, err := pbkdf2.Key(sha256.New, string(secret), p.Salt, p.Iterations, 64)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key[:32])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Cipher{aead: aead, mac: key[32:]}, nil
+}
+
+func (c *Cipher) verifier() []byte {
+	return c.sum([]byte(verifierText))
+}
+
+func (c *Cipher) sum(data []byte) []byte {
+	h := hmac.New(sha256.New, c.mac)
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+// Seal encrypts plaintext with a random nonce.
+func (c *Cipher) Seal(plaintext string) (string, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return c.seal(nonce, plaintext), nil
+}
+
+// SealDeterministic encrypts plaintext with a nonce derived from it, so equal
+// plaintexts give equal ciphertexts. It is meant for lookup keys such as
+// memory names, where the database must still find a row by value.
+func (c *Cipher) SealDeterministic(plaintext string) string {
+	nonce := c.sum([]byte(plaintext))[:c.aead.NonceSize()]
+	return c.seal(nonce, plaintext)
+}
+
+func (c *Cipher) seal(nonce []byte, plaintext string) string {
+	sealed := c.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return Prefix + base64.StdEncoding.EncodeToString(sealed)
+}
+
+// Open decrypts a value produced by Seal or SealDeterministic. Values without
+// Prefix are returned unchanged so rows written before encryption was
+// enabled stay readable.
+func (c *Cipher) Open(value string) (string, error) {
+	encoded, ok := strings.CutPrefix(value, Prefix)
+	if !ok {
+		return value, nil
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("invalid encrypted value: %w", err)
+	}
+	size := c.aead.NonceSize()
+	if len(sealed) < size {
+		return "", errors.New("invalid encrypted value: too short")
+	}
+	plaintext, err := c.aead.Open(nil, sealed[:size], sealed[size:], nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt value: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// IsSealed reports whether value is encrypted.
+func IsSealed(value string) bool {
+	return strings.HasPrefix(value, Prefix)
+}
+
+// ReadKeyFile returns the secret stored in a key file, without surrounding
+// whitespace.
+func ReadKeyFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+	secret := []byte(strings.TrimSpace(string(data)))
+	if len(secret) == 0 {
+		return nil, fmt.Errorf("key file %s is empty", path)
+	}
+	return secret, nil
+}
+
+// querier is satisfied by both *sql.DB and *sql.Tx.
+type querier interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// LoadParams reads the encryption parameters of a database. It returns
+// ErrNotEncrypted when encryption was never set up.
+func LoadParams(q querier) (Params, error) {
+	var p Params
+	err := q.QueryRow(`SELECT salt, iterations, verifier FROM encryption WHERE id = 1`).Scan(&p.Salt, &p.Iterations, &p.Verifier)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Params{}, ErrNotEncrypted
+	}
+	return p, err
+}
+
+// SaveParams records the encryption parameters of a database.
+func SaveParams(q querier, p Params) error {
+	_, err := q.Exec(`INSERT INTO encryption (id, salt, iterations, verifier) VALUES (1, ?, ?, ?)`, p.Salt, p.Iterations, p.Verifier)
+	return err
+}
@@ -0,0 +1,163 @@
+package crypt
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+// testIterations keeps key derivation fast in tests.
+const testIterations = 1000
+
+func newTestCipher(t *testing.T, secret string) (Params, *Cipher) {
+	t.Helper()
+	p, c, err := NewParams([]byte(secret), testIterations)
+	if err != nil {
+		t.Fatalf("NewParams failed: %v", err)
+	}
+	return p, c
+}
+
+func TestSealOpen(t *testing.T) {
+	_, c := newTestCipher(t, "correct horse")
+
+	sealed, err := c.Seal("my bank pin is 1234")
+	if err != nil {
+		t.Fatalf("Seal failed: %v", err)
+	}
+	if !IsSealed(sealed) || strings.Contains(sealed, "1234") {
+		t.Fatalf("Expected an opaque sealed value, got %q", sealed)
+	}
+	again, _ := c.Seal("my bank pin is 1234")
+	if again == sealed {
+		t.Error("Expected Seal to use a fresh nonce each time")
+	}
+
+	opened, err := c.Open(sealed)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if opened != "my bank pin is 1234" {
+		t.Errorf("Expected the plaintext back, got %q", opened)
+	}
+}
+
+func TestSealDeterministic(t *testing.T) {
+	_, c := newTestCipher(t, "secret")
+
+	a, b := c.SealDeterministic("editor"), c.SealDeterministic("editor")
+	if a != b {
+		t.Error("Expected equal plaintexts to give equal ciphertexts")
+	}
+	if c.SealDeterministic("shell") == a {
+		t.Error("Expected different plaintexts to give different ciphertexts")
+	}
+	if opened, err := c.Open(a); err != nil || opened != "editor" {
+		t.Errorf("Expected 'editor', got %q, %v", opened, err)
+	}
+}
+
+func TestOpenPassesPlaintextThrough(t *testing.T) {
+	_, c := newTestCipher(t, "secret")
+	if opened, err := c.Open(`{"role":"user"}`); err != nil || opened != `{"role":"user"}` {
+		t.Errorf("Expected plaintext to be returned unchanged, got %q, %v", opened, err)
+	}
+}
+
+func TestOpenRejectsTampering(t *testing.T) {
+	_, c := newTestCipher(t, "secret")
+	sealed, _ := c.Seal("payload")
+
+	raw := []byte(sealed)
+	raw[len(raw)-3] ^= 1
+	if _, err := c.Open(string(raw)); err == nil {
+		t.Error("Expected a modified ciphertext to fail authentication")
+	}
+	if _, err := c.Open(Prefix + "AAAA"); err == nil {
+		t.Error("Expected a truncated ciphertext to be rejected")
+	}
+	if _, err := c.Open(Prefix + "not base64!"); err == nil {
+		t.Error("Expected invalid base64 to be rejected")
+	}
+}
+
+func TestUnlock(t *testing.T) {
+	p, c := newTestCipher(t, "correct horse")
+	sealed, _ := c.Seal("payload")
+
+	unlocked, err := Unlock(p, []byte("correct horse"))
+	if err != nil {
+		t.Fatalf("Unlock failed: %v", err)
+	}
+	if opened, err := unlocked.Open(sealed); err != nil || opened != "payload" {
+		t.Errorf("Expected the unlocked cipher to open old values, got %q, %v", opened, err)
+	}
+
+	if _, err := Unlock(p, []byte("battery staple")); !errors.Is(err, ErrWrongKey) {
+		t.Errorf("Expected ErrWrongKey, got %v", err)
+	}
+	if _, err := Unlock(p, nil); !errors.Is(err, ErrEmptySecret) {
+		t.Errorf("Expected ErrEmptySecret, got %v", err)
+	}
+
+	_, other := newTestCipher(t, "correct horse")
+	if _, err := other.Open(sealed); err == nil {
+		t.Error("Expected a key with another salt to fail")
+	}
+}
+
+func TestParamsRoundTrip(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "todo.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+	if _, err := db.Exec(`CREATE TABLE encryption (id INTEGER PRIMARY KEY CHECK (id = 1), salt BLOB NOT NULL, iterations INTEGER NOT NULL, verifier BLOB NOT NULL)`); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+
+	if _, err := LoadParams(db); !errors.Is(err, ErrNotEncrypted) {
+		t.Fatalf("Expected ErrNotEncrypted, got %v", err)
+	}
+	p, _ := newTestCipher(t, "secret")
+	if err := SaveParams(db, p); err != nil {
+		t.Fatalf("SaveParams failed: %v", err)
+	}
+	if err := SaveParams(db, p); err == nil {
+		t.Error("Expected a second setup to be rejected")
+	}
+	loaded, err := LoadParams(db)
+	if err != nil {
+		t.Fatalf("LoadParams failed: %v", err)
+	}
+	if _, err := Unlock(loaded, []byte("secret")); err != nil {
+		t.Errorf("Expected the stored params to unlock, got %v", err)
+	}
+}
+
+func TestReadKeyFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "key")
+	if err := os.WriteFile(path, []byte("  s3cret\n"), 0600); err != nil {
+		t.Fatalf("Failed to write key file: %v", err)
+	}
+	secret, err := ReadKeyFile(path)
+	if err != nil || string(secret) != "s3cret" {
+		t.Errorf("Expected 's3cret', got %q, %v", secret, err)
+	}
+
+	empty := filepath.Join(dir, "empty")
+	if err := os.WriteFile(empty, []byte("\n"), 0600); err != nil {
+		t.Fatalf("Failed to write key file: %v", err)
+	}
+	if _, err := ReadKeyFile(empty); err == nil {
+		t.Error("Expected an empty key file to be rejected")
+	}
+}
@@ -0,0 +1,281 @@
+// Package extdb opens SQLite files chosen by the user, such as an app's
+// database, for inspection. Files are opened read-only and every connection
+// runs in query_only mode, so neither a query nor SQLite itself writes to
+// them; queries also pass through sqlguard and must be reads.
+package extdb
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/biisal/godo/internal/sqlguard"
+	_ "modernc.org/sqlite"
+)
+
+const (
+	// DefaultLimit is the number of rows Query returns when no limit is given.
+	DefaultLimit = 50
+	// MaxLimit caps the rows a single query may return.
+	MaxLimit = 500
+)
+
+var ErrNotRead = errors.New("only read-only queries can run on an external database")
+
+// DB is a read-only connection to an external SQLite file.
+type DB struct {
+	Path string
+	db   *sql.DB
+}
+
+// Column is one column of a table or view.
+type Column struct {
+	Name       string `json:"name"`
+	Type       string `json:"type"`
+	NotNull    bool   `json:"notNull"`
+	PrimaryKey bool   `json:"primaryKey"`
+}
+
+// Index is one index on a table.
+type Index struct {
+	Name    string   `json:"name"`
+	Unique  bool     `json:"unique"`
+	Columns []string `json:"columns"`
+}
+
+// Table describes a table or view.
+type Table struct {
+	Name    string   `json:"name"`
+	Type    string   `json:"type"`
+	Columns []Column `json:"columns"`
+	Indexes []Index  `json:"indexes,omitempty"`
+}
+
+// Result holds the rows of a query, rendered as text.
+type Result struct {
+	Columns []string   `json:"columns"`
+	Rows    [][]string `json:"rows"`
+	// Truncated is set when the query had more rows than the limit.
+	Truncated bool `json:"truncated"`
+}
+
+// Open opens the SQLite file at path read-only. The file must exist; it is
+// never created.
+func Open(path string) (*DB, error) {
+	if strings.HasPrefix(path, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		path = filepath.Join(home, path[2:])
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("%s is not a file", abs)
+	}
+
+	dsn := (&url.URL{
+		Scheme:   "file",
+		Path:     abs,
+		RawQuery: "mode=ro&_pragma=query_only(1)",
+	}).String()
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	// One connection keeps the query_only setting and temp state in one
+	// place.
+	db.SetMaxOpenConns(1)
+	var version int
+	if err := db.QueryRow(`PRAGMA schema_version`).Scan(&version); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("%s is not a readable SQLite database: %w", abs, err)
+	}
+	return &DB{Path: abs, db: db}, nil
+}
+
+func (d *DB) Close() error {
+	return d.db.Close()
+}
+
+// Schema lists the tables and views with their columns and indexes.
+func (d *DB) Schema() ([]Table, error) {
+	rows, err := d.db.Query(`SELECT name, type FROM sqlite_master
+	WHERE type IN ('table', 'view') AND name NOT LIKE 'sqlite_%'
+	ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	var tables []Table
+	for rows.Next() {
+		var t Table
+		if err := rows.Scan(&t.Name, &t.Type); err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+		tables = append(tables, t)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range tables {
+		if tables[i].Columns, err = d.columns(tables[i].Name); err != nil {
+			return nil, err
+		}
+		if tables[i].Indexes, err = d.indexes(tables[i].Name); err != nil {
+			return nil, err
+		}
+	}
+	return tables, nil
+}
+
+func (d *DB) columns(table string) ([]Column, error) {
+	rows, err := d.db.Query(`SELECT name, type, "notnull", pk FROM pragma_table_info(?)`, table)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	var columns []Column
+	for rows.Next() {
+		var c Column
+		var pk int
+		if err := rows.Scan(&c.Name, &c.Type, &c.NotNull, &pk); err != nil {
+			return nil, err
+		}
+		c.PrimaryKey = pk > 0
+		columns = append(columns, c)
+	}
+	return columns, rows.Err()
+}
+
+func (d *DB) indexes(table string) ([]Index, error) {
+	rows, err := d.db.Query(`SELECT name, "unique" FROM pragma_index_list(?) ORDER BY name`, table)
+	if err != nil {
+		return nil, err
+	}
+	var indexes []Index
+	for rows.Next() {
+		var idx Index
+		if err := rows.Scan(&idx.Name, &idx.Unique); err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+		indexes = append(indexes, idx)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range indexes {
+		cols, err := d.db.Query(`SELECT name FROM pragma_index_info(?) ORDER BY seqno`, indexes[i].Name)
+		if err != nil {
+			return nil, err
+		}
+		for cols.Next() {
+			var name sql.NullString
+			if err := cols.Scan(&name); err != nil {
+				_ = cols.Close()
+				return nil, err
+			}
+			// Expression columns have no name.
+			if !name.Valid {
+				name.String = "<expr>"
+			}
+			indexes[i].Columns = append(indexes[i].Columns, name.String)
+		}
+		if err := cols.Close(); err != nil {
+			return nil, err
+		}
+	}
+	return indexes, nil
+}
+
+// Query runs a read-only statement and returns at most limit rows. A limit
+// of zero or less means DefaultLimit; limits above MaxLimit are capped.
+func (d *DB) Query(query string, limit int) (*Result, error) {
+	st, err := sqlguard.Check(query)
+	// sqlguard's writable table belongs to godo's own database; here every
+	// write is refused.
+	if errors.Is(err, sqlguard.ErrTable) {
+		return nil, ErrNotRead
+	}
+	if err != nil {
+		return nil, err
+	}
+	if st.Kind != sqlguard.Read {
+		return nil, fmt.Errorf("%w: %s", ErrNotRead, st.Verb)
+	}
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	limit = min(limit, MaxLimit)
+
+	rows, err := d.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{Columns: columns, Rows: [][]string{}}
+	values := make([]any, len(columns))
+	ptrs := make([]any, len(columns))
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+	for rows.Next() {
+		if len(result.Rows) == limit {
+			result.Truncated = true
+			break
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+		row := make([]string, len(values))
+		for i, v := range values {
+			row[i] = formatValue(v)
+		}
+		result.Rows = append(result.Rows, row)
+	}
+	return result, rows.Err()
+}
+
+func formatValue(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "NULL"
+	case []byte:
+		if utf8.Valid(v) {
+			return string(v)
+		}
+		return fmt.Sprintf("<blob %d bytes>", len(v))
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,179 @@
+package extdb
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newExternalDB writes a small database the way another app would and
+// returns its path.
+func newExternalDB(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "app.db")
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+	for _, stmt := range []string{
+		`CREATE TABLE users (id INTEGER PRIMARY KEY, email TEXT NOT NULL UNIQUE, note TEXT)`,
+		`CREATE INDEX users_note ON users (note)`,
+		`CREATE VIEW emails AS SELECT email FROM users`,
+		`INSERT INTO users (email, note) VALUES ('a@example.com', 'first'), ('b@example.com', NULL), ('c@example.com', 'line one
+line two')`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("Failed to set up database: %v", err)
+		}
+	}
+	return path
+}
+
+func openTest(t *testing.T, path string) *DB {
+	t.Helper()
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+	return db
+}
+
+func TestSchema(t *testing.T) {
+	db := openTest(t, newExternalDB(t))
+
+	tables, err := db.Schema()
+	if err != nil {
+		t.Fatalf("Schema failed: %v", err)
+	}
+	if len(tables) != 2 || tables[0].Name != "emails" || tables[0].Type != "view" || tables[1].Name != "users" {
+		t.Fatalf("Expected the emails view and users table, got %+v", tables)
+	}
+	users := tables[1]
+	if len(users.Columns) != 3 || !users.Columns[0].PrimaryKey || !users.Columns[1].NotNull {
+		t.Errorf("Unexpected columns: %+v", users.Columns)
+	}
+	if len(users.Indexes) != 2 {
+		t.Fatalf("Expected the unique and the note index, got %+v", users.Indexes)
+	}
+
+	text := FormatSchema(tables)
+	for _, want := range []string{"table users", "id INTEGER PRIMARY KEY", "email TEXT NOT NULL", "index users_note (note)", "unique index"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Expected the schema to mention %q, got:\n%s", want, text)
+		}
+	}
+}
+
+func TestQuery(t *testing.T) {
+	db := openTest(t, newExternalDB(t))
+
+	result, err := db.Query(`SELECT email, note FROM users ORDER BY id`, 2)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if len(result.Rows) != 2 || !result.Truncated {
+		t.Fatalf("Expected 2 rows and a truncation flag, got %+v", result)
+	}
+	if result.Rows[1][1] != "NULL" {
+		t.Errorf("Expected NULL to be rendered, got %q", result.Rows[1][1])
+	}
+
+	result, err = db.Query(`SELECT id, note FROM users WHERE id = 3`, 0)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	want := "id | note\n" +
+		"---+------------------\n" +
+		"3  | line one line two\n" +
+		"(1 row)\n"
+	if got := result.Table(); got != want {
+		t.Errorf("Unexpected table:\n%s", got)
+	}
+}
+
+func TestNeverWrites(t *testing.T) {
+	path := newExternalDB(t)
+	before, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read database: %v", err)
+	}
+	db := openTest(t, path)
+
+	for _, query := range []string{
+		`DELETE FROM users`,
+		`UPDATE users SET note = 'x' WHERE id = 1`,
+		`INSERT INTO users (email) VALUES ('d@example.com')`,
+		`DROP TABLE users`,
+		`CREATE TABLE x (id INTEGER)`,
+		`SELECT 1; DELETE FROM users`,
+		`ATTACH DATABASE 'other.db' AS other`,
+		`PRAGMA user_version = 7`,
+		`VACUUM`,
+	} {
+		if _, err := db.Query(query, 0); err == nil {
+			t.Errorf("Expected %q to be rejected", query)
+		}
+	}
+	for _, query := range []string{`INSERT INTO users (email) VALUES ('d@example.com')`, `WITH x AS (SELECT 1) DELETE FROM todos`} {
+		if _, err := db.Query(query, 0); !errors.Is(err, ErrNotRead) {
+			t.Errorf("Expected %q to be refused as a write, got %v", query, err)
+		}
+	}
+
+	// Even a write that slipped past the checks is refused by SQLite.
+	if _, err := db.db.Exec(`DELETE FROM users`); err == nil {
+		t.Error("Expected the read-only connection to refuse a write")
+	}
+	if _, err := db.db.Exec(`PRAGMA query_only = OFF`); err != nil {
+		t.Fatalf("Failed to leave query_only mode: %v", err)
+	}
+	if _, err := db.db.Exec(`DELETE FROM users`); err == nil {
+		t.Error("Expected mode=ro to refuse a write outside query_only mode")
+	}
+
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	after, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read database: %v", err)
+	}
+	if !bytes.Equal(before, after) {
+		t.Error("Expected the database file to be unchanged")
+	}
+	for _, suffix := range []string{"-wal", "-shm", "-journal"} {
+		if _, err := os.Stat(path + suffix); err == nil {
+			t.Errorf("Expected no %s file next to the database", suffix)
+		}
+	}
+}
+
+func TestOpenRejects(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := Open(filepath.Join(dir, "missing.db")); err == nil {
+		t.Error("Expected a missing file to be rejected")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "missing.db")); err == nil {
+		t.Error("Expected Open not to create a missing file")
+	}
+	if _, err := Open(dir); err == nil {
+		t.Error("Expected a directory to be rejected")
+	}
+	text := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(text, []byte("not a database, just some text that is long enough"), 0600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if _, err := Open(text); err == nil {
+		t.Error("Expected a non-SQLite file to be rejected")
+	}
+}
@@ -0,0 +1,111 @@
+package extdb
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxCellWidth keeps one long value from stretching a whole table.
+const maxCellWidth = 40
+
+// Table renders the result as a plain-text table with a header row.
+func (r *Result) Table() string {
+	if len(r.Columns) == 0 {
+		return "(no columns)\n"
+	}
+	cells := make([][]string, 0, len(r.Rows)+1)
+	cells = append(cells, r.Columns)
+	for _, row := range r.Rows {
+		cells = append(cells, row)
+	}
+
+	widths := make([]int, len(r.Columns))
+	for _, row := range cells {
+		for i := range row {
+			row[i] = cell(row[i])
+			widths[i] = max(widths[i], utf8.RuneCountInString(row[i]))
+		}
+	}
+
+	var b strings.Builder
+	writeRow := func(row []string) {
+		for i, c := range row {
+			if i > 0 {
+				b.WriteString(" | ")
+			}
+			b.WriteString(c)
+			if i < len(row)-1 {
+				b.WriteString(strings.Repeat(" ", widths[i]-utf8.RuneCountInString(c)))
+			}
+		}
+		b.WriteString("\n")
+	}
+	writeRow(cells[0])
+	for i, w := range widths {
+		if i > 0 {
+			b.WriteString("-+-")
+		}
+		b.WriteString(strings.Repeat("-", w))
+	}
+	b.WriteString("\n")
+	for _, row := range cells[1:] {
+		writeRow(row)
+	}
+
+	switch {
+	case r.Truncated:
+		fmt.Fprintf(&b, "(first %d rows, more available)\n", len(r.Rows))
+	case len(r.Rows) == 1:
+		b.WriteString("(1 row)\n")
+	default:
+		fmt.Fprintf(&b, "(%d rows)\n", len(r.Rows))
+	}
+	return b.String()
+}
+
+// cell flattens a value onto one line and shortens it to maxCellWidth.
+func cell(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if utf8.RuneCountInString(s) <= maxCellWidth {
+		return s
+	}
+	return string([]rune(s)[:maxCellWidth-1]) + "…"
+}
+
+// FormatSchema renders tables as one block per table listing its columns
+// and indexes.
+func FormatSchema(tables []Table) string {
+	if len(tables) == 0 {
+		return "(no tables)\n"
+	}
+	var b strings.Builder
+	for i, t := range tables {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "%s %s\n", t.Type, t.Name)
+		for _, c := range t.Columns {
+			var flags []string
+			if c.PrimaryKey {
+				flags = append(flags, "PRIMARY KEY")
+			}
+			if c.NotNull {
+				flags = append(flags, "NOT NULL")
+			}
+			line := strings.TrimSpace(c.Name + " " + c.Type)
+			if len(flags) > 0 {
+				line += " " + strings.Join(flags, " ")
+			}
+			fmt.Fprintf(&b, "  %s\n", line)
+		}
+		for _, idx := range t.Indexes {
+			kind := "index"
+			if idx.Unique {
+				kind = "unique index"
+			}
+			fmt.Fprintf(&b, "  %s %s (%s)\n", kind, idx.Name, strings.Join(idx.Columns, ", "))
+		}
+	}
+	return b.String()
+}
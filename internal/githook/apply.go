@@ -0,0 +1,52 @@
+package githook
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/biisal/godo/internal/store"
+	todoAction "github.com/biisal/godo/internal/tui/actions/todo"
+)
+
+// Outcome describes what a commit did to one referenced todo.
+type Outcome struct {
+	ID      int
+	Event   string
+	Changed bool
+	Missing bool
+}
+
+// Apply closes the todos a commit closes and records the commit in the
+// history of every todo it references. References to unknown todos are
+// reported as missing and otherwise ignored.
+func Apply(todos *todoAction.Service, commit Commit) ([]Outcome, error) {
+	refs := ParseReferences(commit.Message)
+	outcomes := make([]Outcome, 0, len(refs))
+	detail := fmt.Sprintf("commit %s: %s", commit.Hash, commit.Subject())
+
+	for _, ref := range refs {
+		outcome := Outcome{ID: ref.ID, Event: todoAction.EventReferenced}
+		if _, err := todos.GetTodoById(ref.ID); err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				outcome.Missing = true
+				outcomes = append(outcomes, outcome)
+				continue
+			}
+			return outcomes, err
+		}
+
+		if ref.Closes {
+			outcome.Event = todoAction.EventClosed
+			changed, err := todos.SetDone(ref.ID, true)
+			if err != nil {
+				return outcomes, err
+			}
+			outcome.Changed = changed
+		}
+		if err := todos.AddHistory(ref.ID, outcome.Event, detail); err != nil {
+			return outcomes, err
+		}
+		outcomes = append(outcomes, outcome)
+	}
+	return outcomes, nil
+}
@@ -0,0 +1,159 @@
+// Package githook links git commits to todos. A post-commit hook hands each
+// new commit message to godo, which closes the todos it references.
+package githook
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	hookName   = "post-commit"
+	hookMarker = "# installed by godo"
+)
+
+var ErrForeignHook = errors.New("a post-commit hook not installed by godo already exists (use --force to replace it)")
+
+// Reference is a todo mentioned in a commit message.
+type Reference struct {
+	ID     int  `json:"id"`
+	Closes bool `json:"closes"`
+}
+
+// Commit is the part of a git commit godo cares about.
+type Commit struct {
+	Hash    string
+	Message string
+}
+
+// Subject returns the first line of the commit message.
+func (c Commit) Subject() string {
+	subject, _, _ := strings.Cut(strings.TrimSpace(c.Message), "\n")
+	return subject
+}
+
+var (
+	refListRe = regexp.MustCompile(`(?i)\b(close[sd]?|fix(?:e[sd])?|resolve[sd]?|refs?|see)\b:?\s+((?:(?:godo)?#\d+)(?:\s*(?:,|and)\s*(?:godo)?#\d+)*)`)
+	refIDRe   = regexp.MustCompile(`#(\d+)`)
+)
+
+// ParseReferences finds todo references such as "fixes #12" or
+// "refs godo#3, #4" in a commit message. Closing keywords (close, fix,
+// resolve and their forms) close the todo, "refs" and "see" only record it.
+// A todo referenced more than once closes if any reference closes it.
+func ParseReferences(message string) []Reference {
+	refs := []Reference{}
+	index := map[int]int{}
+	for _, match := range refListRe.FindAllStringSubmatch(message, -1) {
+		keyword := strings.ToLower(match[1])
+		closes := !strings.HasPrefix(keyword, "ref") && keyword != "see"
+		for _, idMatch := range refIDRe.FindAllStringSubmatch(match[2], -1) {
+			id, err := strconv.Atoi(idMatch[1])
+			if err != nil || id <= 0 {
+				continue
+			}
+			if i, ok := index[id]; ok {
+				refs[i].Closes = refs[i].Closes || closes
+				continue
+			}
+			index[id] = len(refs)
+			refs = append(refs, Reference{ID: id, Closes: closes})
+		}
+	}
+	return refs
+}
+
+// Install writes a post-commit hook into the repository containing dir that
+// runs "<executable> hook post-commit" after every commit. An existing hook
+// installed by godo is replaced; any other hook is only replaced with force.
+func Install(dir, executable string, force bool) (string, error) {
+	hookPath, err := hookPath(dir)
+	if err != nil {
+		return "", err
+	}
+	if existing, err := os.ReadFile(hookPath); err == nil {
+		if !strings.Contains(string(existing), hookMarker) && !force {
+			return "", ErrForeignHook
+		}
+	} else if !os.IsNotExist(err) {
+		return "", err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(hookPath), 0o755); err != nil {
+		return "", fmt.Errorf("failed to create hooks directory: %w", err)
+	}
+	script := fmt.Sprintf("#!/bin/sh\n%s\n# Closes todos referenced in commit messages, e.g. \"fixes #12\".\n%s hook %s || true\n",
+		hookMarker, shellQuote(executable), hookName)
+	if err := os.WriteFile(hookPath, []byte(script), 0o755); err != nil {
+		return "", fmt.Errorf("failed to write hook: %w", err)
+	}
+	return hookPath, nil
+}
+
+// Uninstall removes the hook if godo installed it.
+func Uninstall(dir string) (string, error) {
+	hookPath, err := hookPath(dir)
+	if err != nil {
+		return "", err
+	}
+	existing, err := os.ReadFile(hookPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("no post-commit hook installed")
+		}
+		return "", err
+	}
+	if !strings.Contains(string(existing), hookMarker) {
+		return "", fmt.Errorf("the post-commit hook was not installed by godo")
+	}
+	return hookPath, os.Remove(hookPath)
+}
+
+// LastCommit reads the hash and message of HEAD in the repository containing dir.
+func LastCommit(dir string) (Commit, error) {
+	out, err := git(dir, "log", "-1", "--format=%H%x00%B")
+	if err != nil {
+		return Commit{}, err
+	}
+	hash, message, ok := strings.Cut(out, "\x00")
+	if !ok {
+		return Commit{}, fmt.Errorf("unexpected git log output: %q", out)
+	}
+	return Commit{Hash: strings.TrimSpace(hash), Message: strings.TrimSpace(message)}, nil
+}
+
+func hookPath(dir string) (string, error) {
+	hooksDir, err := git(dir, "rev-parse", "--git-path", "hooks")
+	if err != nil {
+		return "", err
+	}
+	hooksDir = strings.TrimSpace(hooksDir)
+	if !filepath.IsAbs(hooksDir) {
+		hooksDir = filepath.Join(dir, hooksDir)
+	}
+	return filepath.Join(hooksDir, hookName), nil
+}
+
+func git(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return "", fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return string(out), nil
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,230 @@
+package githook
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/biisal/godo/internal/config"
+	"github.com/biisal/godo/internal/store"
+	todoAction "github.com/biisal/godo/internal/tui/actions/todo"
+)
+
+// setupRepo creates a temporary git repository with a committer identity.
+func setupRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	dir := t.TempDir()
+	runGit(t, dir, "init", "-q")
+	runGit(t, dir, "config", "user.email", "test@example.com")
+	runGit(t, dir, "config", "user.name", "Test")
+	runGit(t, dir, "config", "commit.gpgsign", "false")
+	return dir
+}
+
+func runGit(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %s failed: %v\n%s", strings.Join(args, " "), err, out)
+	}
+}
+
+func commit(t *testing.T, dir, message string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, "file.txt"), []byte(message), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	runGit(t, dir, "add", "-A")
+	runGit(t, dir, "commit", "-q", "--no-verify", "-m", message)
+}
+
+// setupTodos returns a todo service over a fresh database in a temp dir.
+func setupTodos(t *testing.T) *todoAction.Service {
+	t.Helper()
+	db, err := config.OpenDB(filepath.Join(t.TempDir(), "todo.db"), config.StoreGlobal)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	})
+	return todoAction.NewService(store.NewSQLiteTodoStore(db), config.StoreGlobal)
+}
+
+func TestParseReferences(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		want    []Reference
+	}{
+		{"fixes", "Fix login bug\n\nfixes #12", []Reference{{ID: 12, Closes: true}}},
+		{"keyword forms", "closed #1, resolves #2 and Fixed #3", []Reference{{1, true}, {2, true}, {3, true}}},
+		{"list", "Closes #4, #5 and #6", []Reference{{4, true}, {5, true}, {6, true}}},
+		{"godo prefix", "fix: godo#7", []Reference{{ID: 7, Closes: true}}},
+		{"refs only", "Refactor parser, refs #8; see #9", []Reference{{8, false}, {9, false}}},
+		{"closing wins", "refs #3\nfixes #3", []Reference{{ID: 3, Closes: true}}},
+		{"no keyword", "Bump version to #10", []Reference{}},
+		{"no number", "fixes the build", []Reference{}},
+		{"prefix word", "prefixes #11", []Reference{}},
+		{"zero id", "fixes #0", []Reference{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseReferences(tt.message)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseReferences(%q) = %+v, want %+v", tt.message, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommitSubject(t *testing.T) {
+	c := Commit{Message: "Add feature\n\nLong body"}
+	if got := c.Subject(); got != "Add feature" {
+		t.Errorf("Expected 'Add feature', got '%s'", got)
+	}
+}
+
+func TestInstallWritesExecutableHook(t *testing.T) {
+	dir := setupRepo(t)
+
+	path, err := Install(dir, "/opt/my godo/godo", false)
+	if err != nil {
+		t.Fatalf("Install failed: %v", err)
+	}
+	if path != filepath.Join(dir, ".git", "hooks", "post-commit") {
+		t.Errorf("Unexpected hook path: %s", path)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Hook not written: %v", err)
+	}
+	if info.Mode().Perm()&0o100 == 0 {
+		t.Error("Hook should be executable")
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read hook: %v", err)
+	}
+	if !strings.Contains(string(content), `'/opt/my godo/godo' hook post-commit`) {
+		t.Errorf("Hook should run godo with a quoted path, got:\n%s", content)
+	}
+
+	if _, err := Install(dir, "godo", false); err != nil {
+		t.Errorf("Reinstalling over a godo hook should succeed, got %v", err)
+	}
+}
+
+func TestInstallRefusesForeignHook(t *testing.T) {
+	dir := setupRepo(t)
+	hook := filepath.Join(dir, ".git", "hooks", "post-commit")
+	if err := os.MkdirAll(filepath.Dir(hook), 0755); err != nil {
+		t.Fatalf("Failed to create hooks dir: %v", err)
+	}
+	if err := os.WriteFile(hook, []byte("#!/bin/sh\necho mine\n"), 0755); err != nil {
+		t.Fatalf("Failed to write hook: %v", err)
+	}
+
+	if _, err := Install(dir, "godo", false); !errors.Is(err, ErrForeignHook) {
+		t.Fatalf("Expected ErrForeignHook, got %v", err)
+	}
+	if _, err := Uninstall(dir); err == nil {
+		t.Error("Uninstall should refuse to remove a foreign hook")
+	}
+	if _, err := Install(dir, "godo", true); err != nil {
+		t.Fatalf("Install with force failed: %v", err)
+	}
+	if _, err := Uninstall(dir); err != nil {
+		t.Fatalf("Uninstall failed: %v", err)
+	}
+	if _, err := os.Stat(hook); !os.IsNotExist(err) {
+		t.Error("Hook should be removed")
+	}
+}
+
+func TestInstallOutsideRepository(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	if _, err := Install(t.TempDir(), "godo", false); err == nil {
+		t.Error("Install should fail outside a git repository")
+	}
+}
+
+func TestLastCommitAndApply(t *testing.T) {
+	dir := setupRepo(t)
+	todos := setupTodos(t)
+
+	for _, title := range []string{"first", "second", "third"} {
+		if _, err := todos.AddTodo(title, title+" description"); err != nil {
+			t.Fatalf("AddTodo failed: %v", err)
+		}
+	}
+
+	commit(t, dir, "Fix the parser\n\nfixes #1, refs #2 and closes #99")
+	c, err := LastCommit(dir)
+	if err != nil {
+		t.Fatalf("LastCommit failed: %v", err)
+	}
+	if len(c.Hash) != 40 {
+		t.Fatalf("Expected a full commit hash, got %q", c.Hash)
+	}
+	if c.Subject() != "Fix the parser" {
+		t.Errorf("Unexpected subject %q", c.Subject())
+	}
+
+	outcomes, err := Apply(todos, c)
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	want := []Outcome{
+		{ID: 1, Event: todoAction.EventClosed, Changed: true},
+		{ID: 2, Event: todoAction.EventReferenced},
+		{ID: 99, Event: todoAction.EventReferenced, Missing: true},
+	}
+	if !reflect.DeepEqual(outcomes, want) {
+		t.Errorf("Apply = %+v, want %+v", outcomes, want)
+	}
+
+	first, err := todos.GetTodoById(1)
+	if err != nil {
+		t.Fatalf("GetTodoById failed: %v", err)
+	}
+	if !first.Done {
+		t.Error("Todo 1 should be closed")
+	}
+	second, err := todos.GetTodoById(2)
+	if err != nil {
+		t.Fatalf("GetTodoById failed: %v", err)
+	}
+	if second.Done {
+		t.Error("Todo 2 should only be referenced")
+	}
+
+	history, err := todos.GetHistory(1)
+	if err != nil {
+		t.Fatalf("GetHistory failed: %v", err)
+	}
+	if len(history) != 1 || history[0].Event != todoAction.EventClosed || !strings.Contains(history[0].Detail, c.Hash) {
+		t.Errorf("Expected a closed event with the commit hash, got %+v", history)
+	}
+
+	// Applying the same commit again records it but changes nothing.
+	outcomes, err = Apply(todos, c)
+	if err != nil {
+		t.Fatalf("Second Apply failed: %v", err)
+	}
+	if outcomes[0].Changed {
+		t.Error("Closing an already closed todo should not report a change")
+	}
+}
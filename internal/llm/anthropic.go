@@ -0,0 +1,320 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	DefaultAnthropicBaseURL = "https://api.anthropic.com"
+	anthropicVersion        = "2023-06-01"
+)
+
+// AnthropicConfig configures the native Anthropic Messages API backend.
+type AnthropicConfig struct {
+	APIKey    string
+	BaseURL   string
+	Model     string
+	MaxTokens int
+	// ThinkingBudget enables extended thinking with this many tokens when
+	// positive. It must be below MaxTokens.
+	ThinkingBudget int
+	// HTTPClient defaults to http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+// Anthropic streams from the Messages API over plain HTTP.
+type Anthropic struct {
+	cfg AnthropicConfig
+}
+
+func NewAnthropic(cfg AnthropicConfig) *Anthropic {
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = DefaultAnthropicBaseURL
+	}
+	cfg.BaseURL = strings.TrimRight(cfg.BaseURL, "/")
+	if cfg.MaxTokens <= 0 {
+		cfg.MaxTokens = 8192
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = http.DefaultClient
+	}
+	return &Anthropic{cfg: cfg}
+}
+
+func (p *Anthropic) Name() string {
+	return "anthropic"
+}
+
+func (p *Anthropic) Model() string {
+	return p.cfg.Model
+}
+
+type anthropicRequest struct {
+	Model     string             `json:"model"`
+	MaxTokens int                `json:"max_tokens"`
+	System    string             `json:"system,omitempty"`
+	Messages  []anthropicMessage `json:"messages"`
+	Tools     []anthropicTool    `json:"tools,omitempty"`
+	Thinking  *anthropicThinking `json:"thinking,omitempty"`
+	Stream    bool               `json:"stream"`
+}
+
+type anthropicThinking struct {
+	Type         string `json:"type"`
+	BudgetTokens int    `json:"budget_tokens"`
+}
+
+type anthropicTool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	InputSchema map[string]any `json:"input_schema"`
+}
+
+type anthropicMessage struct {
+	Role    string           `json:"role"`
+	Content []anthropicBlock `json:"content"`
+}
+
+// anthropicBlock is any content block; only the fields of its Type are set.
+type anthropicBlock struct {
+	Type string `json:"type"`
+
+	Text string `json:"text,omitempty"`
+
+	Thinking  string `json:"thinking,omitempty"`
+	Signature string `json:"signature,omitempty"`
+	Data      string `json:"data,omitempty"`
+
+	ID    string          `json:"id,omitempty"`
+	Name  string          `json:"name,omitempty"`
+	Input json.RawMessage `json:"input,omitempty"`
+
+	ToolUseID string `json:"tool_use_id,omitempty"`
+	Content   string `json:"content,omitempty"`
+}
+
+type anthropicError struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+// anthropicEvent is the data of one server-sent event.
+type anthropicEvent struct {
+	Type         string         `json:"type"`
+	Index        int            `json:"index"`
+	ContentBlock anthropicBlock `json:"content_block"`
+	Delta        struct {
+		Type        string `json:"type"`
+		Text        string `json:"text"`
+		Thinking    string `json:"thinking"`
+		Signature   string `json:"signature"`
+		PartialJSON string `json:"partial_json"`
+	} `json:"delta"`
+	Error anthropicError `json:"error"`
+}
+
+func (p *Anthropic) Stream(ctx context.Context, req Request, onDelta func(Delta)) (Message, error) {
+	body := anthropicRequest{
+		Model:     p.cfg.Model,
+		MaxTokens: p.cfg.MaxTokens,
+		System:    req.System,
+		Messages:  anthropicMessages(req.Messages),
+		Stream:    true,
+	}
+	for _, t := range req.Tools {
+		body.Tools = append(body.Tools, anthropicTool{Name: t.Name, Description: t.Description, InputSchema: t.Parameters})
+	}
+	if p.cfg.ThinkingBudget > 0 {
+		body.Thinking = &anthropicThinking{Type: "enabled", BudgetTokens: p.cfg.ThinkingBudget}
+	}
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return Message{}, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.cfg.BaseURL+"/v1/messages", bytes.NewReader(payload))
+	if err != nil {
+		return Message{}, err
+	}
+	httpReq.Header.Set("x-api-key", p.cfg.APIKey)
+	httpReq.Header.Set("anthropic-version", anthropicVersion)
+	httpReq.Header.Set("content-type", "application/json")
+	httpReq.Header.Set("accept", "text/event-stream")
+
+	resp, err := p.cfg.HTTPClient.Do(httpReq)
+	if err != nil {
+		return Message{}, connectionError(p.Name(), err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode/100 != 2 {
+		return Message{}, anthropicStatusError(resp)
+	}
+	msg, err := readAnthropicStream(resp.Body, onDelta)
+	return msg, connectionError(p.Name(), err)
+}
+
+func anthropicStatusError(resp *http.Response) error {
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	var body struct {
+		Error anthropicError `json:"error"`
+	}
+	if json.Unmarshal(raw, &body) == nil && body.Error.Message != "" {
+		return newAPIError("anthropic", resp.StatusCode, body.Error.Type, body.Error.Message, resp.Header)
+	}
+	message := strings.TrimSpace(string(raw))
+	if message == "" {
+		message = http.StatusText(resp.StatusCode)
+	}
+	return newAPIError("anthropic", resp.StatusCode, "", message, resp.Header)
+}
+
+// readAnthropicStream assembles the assistant message from the event
+// stream, passing text and thinking to onDelta as they arrive.
+func readAnthropicStream(r io.Reader, onDelta func(Delta)) (Message, error) {
+	var blocks []anthropicBlock
+	var inputs []string
+	stopped := false
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64<<10), 4<<20)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		var ev anthropicEvent
+		if err := json.Unmarshal([]byte(strings.TrimSpace(data)), &ev); err != nil {
+			return Message{}, fmt.Errorf("anthropic: invalid stream event: %w", err)
+		}
+
+		switch ev.Type {
+		case "content_block_start":
+			for len(blocks) <= ev.Index {
+				blocks = append(blocks, anthropicBlock{})
+				inputs = append(inputs, "")
+			}
+			blocks[ev.Index] = ev.ContentBlock
+			if ev.ContentBlock.Text != "" {
+				onDelta(Delta{Content: ev.ContentBlock.Text})
+			}
+		case "content_block_delta":
+			if ev.Index >= len(blocks) {
+				return Message{}, fmt.Errorf("anthropic: delta for unknown content block %d", ev.Index)
+			}
+			b := &blocks[ev.Index]
+			switch ev.Delta.Type {
+			case "text_delta":
+				b.Text += ev.Delta.Text
+				onDelta(Delta{Content: ev.Delta.Text})
+			case "thinking_delta":
+				b.Thinking += ev.Delta.Thinking
+				onDelta(Delta{Reasoning: ev.Delta.Thinking})
+			case "signature_delta":
+				b.Signature += ev.Delta.Signature
+			case "input_json_delta":
+				inputs[ev.Index] += ev.Delta.PartialJSON
+			}
+		case "message_stop":
+			stopped = true
+		case "error":
+			return Message{}, newAPIError("anthropic", 0, ev.Error.Type, ev.Error.Message, nil)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Message{}, err
+	}
+	if !stopped {
+		return Message{}, &APIError{Provider: "anthropic", Category: ErrConnection, Message: "stream ended before message_stop"}
+	}
+
+	msg := Message{Role: RoleAssistant}
+	var text, reasoning strings.Builder
+	for i, b := range blocks {
+		switch b.Type {
+		case "text":
+			text.WriteString(b.Text)
+		case "thinking":
+			reasoning.WriteString(b.Thinking)
+			msg.Thinking = append(msg.Thinking, Thinking{Text: b.Thinking, Signature: b.Signature})
+		case "redacted_thinking":
+			msg.Thinking = append(msg.Thinking, Thinking{Redacted: b.Data})
+		case "tool_use":
+			args := inputs[i]
+			if args == "" {
+				args = "{}"
+			}
+			msg.ToolCalls = append(msg.ToolCalls, ToolCall{
+				ID:       b.ID,
+				Type:     "function",
+				Function: FunctionCall{Name: b.Name, Arguments: args},
+			})
+		}
+	}
+	msg.Content = text.String()
+	msg.Reasoning = reasoning.String()
+	return msg, nil
+}
+
+// anthropicMessages converts the history to alternating user and assistant
+// turns. Tool results become tool_result blocks of a user turn, and
+// consecutive turns of the same role are merged.
+func anthropicMessages(msgs []Message) []anthropicMessage {
+	var out []anthropicMessage
+	add := func(role string, blocks ...anthropicBlock) {
+		if len(blocks) == 0 {
+			return
+		}
+		if n := len(out); n > 0 && out[n-1].Role == role {
+			out[n-1].Content = append(out[n-1].Content, blocks...)
+			return
+		}
+		out = append(out, anthropicMessage{Role: role, Content: blocks})
+	}
+
+	for _, m := range msgs {
+		switch m.Role {
+		case RoleAssistant:
+			var blocks []anthropicBlock
+			for _, t := range m.Thinking {
+				switch {
+				case t.Redacted != "":
+					blocks = append(blocks, anthropicBlock{Type: "redacted_thinking", Data: t.Redacted})
+				case t.Signature != "":
+					blocks = append(blocks, anthropicBlock{Type: "thinking", Thinking: t.Text, Signature: t.Signature})
+				}
+			}
+			if strings.TrimSpace(m.Content) != "" {
+				blocks = append(blocks, anthropicBlock{Type: "text", Text: m.Content})
+			}
+			for _, tc := range m.ToolCalls {
+				blocks = append(blocks, anthropicBlock{
+					Type:  "tool_use",
+					ID:    tc.ID,
+					Name:  tc.Function.Name,
+					Input: json.RawMessage(toolArguments(tc)),
+				})
+			}
+			add(RoleAssistant, blocks...)
+		case RoleTool:
+			add(RoleUser, anthropicBlock{Type: "tool_result", ToolUseID: m.ToolCallID, Content: m.Content})
+		case RoleSystem:
+			// The system prompt is sent separately; stray system turns are
+			// kept as user context.
+			add(RoleUser, anthropicBlock{Type: "text", Text: m.Content})
+		default:
+			if strings.TrimSpace(m.Content) != "" {
+				add(RoleUser, anthropicBlock{Type: "text", Text: m.Content})
+			}
+		}
+	}
+	return out
+}
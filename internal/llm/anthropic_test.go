@@ -0,0 +1,152 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func newTestAnthropic(url string) *Anthropic {
+	return NewAnthropic(AnthropicConfig{
+		APIKey:         "sk-ant-test",
+		BaseURL:        url,
+		Model:          "claude-sonnet-4-5",
+		MaxTokens:      4096,
+		ThinkingBudget: 1024,
+	})
+}
+
+var listTodosTool = Tool{
+	Name:        "ListTodos",
+	Description: "List todos.",
+	Parameters: map[string]any{
+		"type":       "object",
+		"properties": map[string]any{"status": map[string]any{"type": "string"}},
+	},
+}
+
+func TestAnthropicStreamToolUse(t *testing.T) {
+	srv, rec := replay(t, 200, "anthropic_tool_use.sse")
+	p := newTestAnthropic(srv.URL)
+
+	var c collect
+	msg, err := p.Stream(context.Background(), Request{
+		System:   "You are a test agent.",
+		Messages: []Message{{Role: RoleUser, Content: "What is open?"}},
+		Tools:    []Tool{listTodosTool},
+	}, c.onDelta)
+	if err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+
+	if c.reasoning != "The user wants their open todos." || c.content != "Let me check." {
+		t.Errorf("Unexpected deltas: reasoning %q, content %q", c.reasoning, c.content)
+	}
+	want := Message{
+		Role:      RoleAssistant,
+		Reasoning: "The user wants their open todos.",
+		Thinking:  []Thinking{{Text: "The user wants their open todos.", Signature: "EqQBCkgIARABGAIiQL2u"}},
+		Content:   "Let me check.",
+		ToolCalls: []ToolCall{{
+			ID:       "toolu_01AbC",
+			Type:     "function",
+			Function: FunctionCall{Name: "ListTodos", Arguments: `{"status": "open"}`},
+		}},
+	}
+	if !reflect.DeepEqual(msg, want) {
+		t.Errorf("Unexpected message:\n got %+v\nwant %+v", msg, want)
+	}
+
+	if rec.path != "/v1/messages" {
+		t.Errorf("Expected a request to /v1/messages, got %s", rec.path)
+	}
+	if rec.header.Get("x-api-key") != "sk-ant-test" || rec.header.Get("anthropic-version") != anthropicVersion {
+		t.Errorf("Missing authentication headers: %v", rec.header)
+	}
+}
+
+func TestAnthropicRequestBody(t *testing.T) {
+	srv, rec := replay(t, 200, "anthropic_text.sse")
+	p := newTestAnthropic(srv.URL)
+
+	history := []Message{
+		{Role: RoleUser, Content: "What is open?"},
+		{
+			Role:      RoleAssistant,
+			Reasoning: "The user wants their open todos.",
+			Thinking:  []Thinking{{Text: "The user wants their open todos.", Signature: "EqQBCkgIARABGAIiQL2u"}},
+			Content:   "Let me check.",
+			ToolCalls: []ToolCall{
+				{ID: "toolu_01AbC", Type: "function", Function: FunctionCall{Name: "ListTodos", Arguments: `{"status": "open"}`}},
+				{ID: "toolu_01DeF", Type: "function", Function: FunctionCall{Name: "ListTodos", Arguments: `not json`}},
+			},
+		},
+		{Role: RoleTool, ToolCallID: "toolu_01AbC", Name: "ListTodos", Content: `{"count":1}`},
+		{Role: RoleTool, ToolCallID: "toolu_01DeF", Name: "ListTodos", Content: `{"count":2}`},
+		{Role: RoleUser, Content: "Thanks"},
+	}
+	if _, err := p.Stream(context.Background(), Request{System: "You are a test agent.", Messages: history, Tools: []Tool{listTodosTool}}, func(Delta) {}); err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+
+	raw, err := os.ReadFile(filepath.Join("testdata", "anthropic_request.json"))
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+	var want map[string]any
+	if err := json.Unmarshal(raw, &want); err != nil {
+		t.Fatalf("Invalid fixture: %v", err)
+	}
+	if !reflect.DeepEqual(rec.body, want) {
+		got, _ := json.MarshalIndent(rec.body, "", "  ")
+		t.Errorf("Unexpected request body:\n%s", got)
+	}
+}
+
+func TestAnthropicStreamText(t *testing.T) {
+	srv, _ := replay(t, 200, "anthropic_text.sse")
+	p := newTestAnthropic(srv.URL)
+
+	var c collect
+	msg, err := p.Stream(context.Background(), Request{Messages: []Message{{Role: RoleUser, Content: "hi"}}}, c.onDelta)
+	if err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+	if msg.Content != "You have one open todo." || c.content != msg.Content || len(msg.ToolCalls) != 0 {
+		t.Errorf("Unexpected reply %+v after deltas %q", msg, c.content)
+	}
+	if len(msg.Thinking) != 1 || msg.Thinking[0].Redacted != "EmwKAhgBEgy3va3pzix" || msg.Reasoning != "" {
+		t.Errorf("Expected the redacted thinking block to be kept, got %+v", msg.Thinking)
+	}
+
+	// The redacted block goes back unchanged on the next turn.
+	out := anthropicMessages([]Message{{Role: RoleUser, Content: "hi"}, msg})
+	if len(out) != 2 || out[1].Content[0].Type != "redacted_thinking" || out[1].Content[0].Data != "EmwKAhgBEgy3va3pzix" {
+		t.Errorf("Unexpected messages: %+v", out)
+	}
+}
+
+func TestAnthropicErrors(t *testing.T) {
+	srv, _ := replay(t, 200, "anthropic_overloaded.sse")
+	_, err := newTestAnthropic(srv.URL).Stream(context.Background(), Request{}, func(Delta) {})
+	if err == nil || !strings.Contains(err.Error(), "overloaded_error") {
+		t.Errorf("Expected the stream error event to surface, got %v", err)
+	}
+
+	srv, _ = replay(t, 400, "anthropic_invalid_request.json")
+	_, err = newTestAnthropic(srv.URL).Stream(context.Background(), Request{}, func(Delta) {})
+	if err == nil || !strings.Contains(err.Error(), "invalid_request_error") || !strings.Contains(err.Error(), "400") {
+		t.Errorf("Expected the API error to surface, got %v", err)
+	}
+}
+
+func TestAnthropicTruncatedStream(t *testing.T) {
+	_, err := readAnthropicStream(strings.NewReader("event: message_start\ndata: {\"type\":\"message_start\"}\n\n"), func(Delta) {})
+	if err == nil {
+		t.Error("Expected a stream without message_stop to fail")
+	}
+}
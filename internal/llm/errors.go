@@ -0,0 +1,195 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Error categories. An *APIError matches its category with errors.Is, so
+// callers can tell failures apart without knowing the provider.
+var (
+	ErrAuth           = errors.New("authentication failed")
+	ErrRateLimit      = errors.New("rate limited")
+	ErrModelNotFound  = errors.New("model not found")
+	ErrContextTooLong = errors.New("context too long")
+	ErrServer         = errors.New("server error")
+	ErrConnection     = errors.New("connection failed")
+)
+
+// APIError is a failed model request.
+type APIError struct {
+	Provider string
+	// Category is one of the Err* categories, or nil if the failure fits
+	// none of them.
+	Category error
+	// StatusCode is the HTTP status, or 0 for errors sent inside the stream
+	// and failures before a response arrived.
+	StatusCode int
+	// Type is the provider's error type or code, e.g. "overloaded_error".
+	Type    string
+	Message string
+	// RetryAfter is how long the server asked to wait before retrying.
+	RetryAfter time.Duration
+	// Err is the underlying error, if any.
+	Err error
+}
+
+func (e *APIError) Error() string {
+	var b strings.Builder
+	b.WriteString(e.Provider + ": ")
+	if e.Category != nil {
+		b.WriteString(e.Category.Error())
+	} else {
+		b.WriteString("request failed")
+	}
+	var detail []string
+	if e.StatusCode != 0 {
+		detail = append(detail, strconv.Itoa(e.StatusCode))
+	}
+	if e.Type != "" {
+		detail = append(detail, e.Type)
+	}
+	if len(detail) > 0 {
+		fmt.Fprintf(&b, " (%s)", strings.Join(detail, " "))
+	}
+	switch {
+	case e.Message != "":
+		b.WriteString(": " + e.Message)
+	case e.Err != nil:
+		b.WriteString(": " + e.Err.Error())
+	}
+	return b.String()
+}
+
+func (e *APIError) Unwrap() []error {
+	var errs []error
+	if e.Category != nil {
+		errs = append(errs, e.Category)
+	}
+	if e.Err != nil {
+		errs = append(errs, e.Err)
+	}
+	return errs
+}
+
+// Temporary reports whether the same request may succeed if sent again.
+func (e *APIError) Temporary() bool {
+	switch e.Category {
+	case ErrRateLimit:
+		// An exhausted quota does not come back by waiting.
+		return e.Type != "insufficient_quota"
+	case ErrServer, ErrConnection:
+		return true
+	}
+	return false
+}
+
+// newAPIError classifies a failure reported by the API.
+func newAPIError(provider string, status int, typ, message string, header http.Header) *APIError {
+	return &APIError{
+		Provider:   provider,
+		Category:   category(status, typ, message),
+		StatusCode: status,
+		Type:       typ,
+		Message:    message,
+		RetryAfter: retryAfter(header),
+	}
+}
+
+// contextTooLong matches the messages providers send when the prompt does
+// not fit the model.
+var contextTooLong = []string{
+	"context length",
+	"context window",
+	"context_length_exceeded",
+	"prompt is too long",
+	"too many tokens",
+}
+
+func category(status int, typ, message string) error {
+	switch typ {
+	case "authentication_error", "permission_error", "invalid_api_key":
+		return ErrAuth
+	case "rate_limit_error", "rate_limit_exceeded", "insufficient_quota":
+		return ErrRateLimit
+	case "not_found_error", "model_not_found":
+		return ErrModelNotFound
+	case "context_length_exceeded", "request_too_large":
+		return ErrContextTooLong
+	case "overloaded_error", "api_error", "server_error":
+		return ErrServer
+	}
+	lower := strings.ToLower(message)
+	for _, s := range contextTooLong {
+		if strings.Contains(lower, s) {
+			return ErrContextTooLong
+		}
+	}
+	switch {
+	case status == http.StatusUnauthorized, status == http.StatusForbidden:
+		return ErrAuth
+	case status == http.StatusTooManyRequests:
+		return ErrRateLimit
+	case status == http.StatusNotFound:
+		return ErrModelNotFound
+	case status == http.StatusRequestEntityTooLarge:
+		return ErrContextTooLong
+	case status >= 500:
+		return ErrServer
+	}
+	return nil
+}
+
+// connectionError wraps a transport failure such as a reset connection so
+// it can be retried. Other errors, including a cancelled context, are
+// returned unchanged.
+func connectionError(provider string, err error) error {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return err
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) {
+		return &APIError{Provider: provider, Category: ErrConnection, Err: err}
+	}
+	return err
+}
+
+// retryAfter reads the delay a server asked for, in the retry-after-ms
+// header some APIs send or the standard Retry-After in seconds or as a date.
+func retryAfter(h http.Header) time.Duration {
+	if h == nil {
+		return 0
+	}
+	if ms, err := strconv.ParseFloat(h.Get("retry-after-ms"), 64); err == nil && ms > 0 {
+		return time.Duration(ms * float64(time.Millisecond))
+	}
+	v := strings.TrimSpace(h.Get("retry-after"))
+	if v == "" {
+		return 0
+	}
+	if s, err := strconv.ParseFloat(v, 64); err == nil {
+		if s <= 0 {
+			return 0
+		}
+		return time.Duration(s * float64(time.Second))
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
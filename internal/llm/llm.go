@@ -0,0 +1,108 @@
+// Package llm is the provider-neutral interface between the agent and the
+// model APIs it talks to. Messages, tool definitions and tool calls are plain
+// structs; each Provider translates them to its own wire format and streams
+// the reply back as deltas.
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+)
+
+const (
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+	RoleSystem    = "system"
+	RoleTool      = "tool"
+)
+
+// Message is one turn of a conversation. Its JSON form is what the chats
+// table stores, so the field names must stay stable.
+type Message struct {
+	Role      string `json:"role"`
+	Reasoning string `json:"reasoning,omitempty"`
+	// Thinking holds the provider's thinking blocks when they must be sent
+	// back verbatim on the next request, as Anthropic requires during tool
+	// use. Reasoning is the readable text of the same blocks.
+	Thinking   []Thinking `json:"thinking,omitempty"`
+	Content    string     `json:"content,omitempty"`
+	ToolCallID string     `json:"tool_call_id,omitempty"`
+	Name       string     `json:"name,omitempty"`
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
+	// Summary marks a message that stands in for the older part of the
+	// conversation, which stays in storage but is no longer sent. It
+	// replaces everything before the last KeptTurns user turns.
+	Summary   bool `json:"summary,omitempty"`
+	KeptTurns int  `json:"kept_turns,omitempty"`
+}
+
+// Thinking is a signed thinking block, or a redacted one whose content is
+// only available to the provider.
+type Thinking struct {
+	Text      string `json:"text,omitempty"`
+	Signature string `json:"signature,omitempty"`
+	Redacted  string `json:"redacted,omitempty"`
+}
+
+// ToolCall is a request from the model to run a tool. It marshals the same
+// way as OpenAI tool calls, which older chat rows were saved as.
+type ToolCall struct {
+	ID       string       `json:"id"`
+	Type     string       `json:"type,omitempty"`
+	Function FunctionCall `json:"function"`
+}
+
+// FunctionCall names the tool and carries its arguments as a JSON object.
+type FunctionCall struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// Tool describes a tool the model may call. Parameters is a JSON schema of
+// type object.
+type Tool struct {
+	Name        string
+	Description string
+	Parameters  map[string]any
+}
+
+// Request is one model call.
+type Request struct {
+	System   string
+	Messages []Message
+	Tools    []Tool
+}
+
+// Delta is a piece of the streamed reply. Only one field is set.
+type Delta struct {
+	Content   string
+	Reasoning string
+}
+
+// Provider streams chat completions from a model API.
+type Provider interface {
+	// Name identifies the provider, e.g. "openai".
+	Name() string
+	// Model is the model requests are sent to.
+	Model() string
+	// Stream sends req and calls onDelta for every piece of text or
+	// reasoning as it arrives. It returns the complete assistant message,
+	// including any tool calls.
+	Stream(ctx context.Context, req Request, onDelta func(Delta)) (Message, error)
+}
+
+// toolArguments returns the arguments of tc as a JSON object, replacing a
+// missing or invalid value with {} so a bad call from the model does not
+// break every later request.
+func toolArguments(tc ToolCall) string {
+	args := tc.Function.Arguments
+	if args == "" || !jsonObject(args) {
+		return "{}"
+	}
+	return args
+}
+
+func jsonObject(s string) bool {
+	return json.Valid([]byte(s)) && bytes.HasPrefix(bytes.TrimSpace([]byte(s)), []byte("{"))
+}
@@ -0,0 +1,125 @@
+package llm
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/openai/openai-go"
+)
+
+// recorded is the last request a fixture server received.
+type recorded struct {
+	path   string
+	header http.Header
+	body   map[string]any
+}
+
+// replay serves the recorded response in testdata/fixture for every request.
+func replay(t *testing.T, status int, fixture string) (*httptest.Server, *recorded) {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", fixture))
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+	rec := &recorded{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		rec.path, rec.header, rec.body = r.URL.Path, r.Header.Clone(), nil
+		if err := json.Unmarshal(raw, &rec.body); err != nil {
+			t.Errorf("Expected a JSON request body, got %s", raw)
+		}
+		if filepath.Ext(fixture) == ".sse" {
+			w.Header().Set("content-type", "text/event-stream")
+		} else {
+			w.Header().Set("content-type", "application/json")
+		}
+		w.WriteHeader(status)
+		_, _ = w.Write(data)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, rec
+}
+
+// collect gathers the deltas passed to onDelta.
+type collect struct {
+	content, reasoning string
+}
+
+func (c *collect) onDelta(d Delta) {
+	c.content += d.Content
+	c.reasoning += d.Reasoning
+}
+
+func TestToolCallMatchesStoredOpenAIFormat(t *testing.T) {
+	// Chat rows written before the provider interface hold openai-go tool
+	// calls; they must still decode.
+	stored, err := json.Marshal(openai.ChatCompletionMessageToolCall{
+		ID:   "call_1",
+		Type: "function",
+		Function: openai.ChatCompletionMessageToolCallFunction{
+			Name:      "PerformSql",
+			Arguments: `{"query":"SELECT 1"}`,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to marshal: %v", err)
+	}
+	var tc ToolCall
+	if err := json.Unmarshal(stored, &tc); err != nil {
+		t.Fatalf("Failed to unmarshal: %v", err)
+	}
+	if tc.ID != "call_1" || tc.Type != "function" || tc.Function.Name != "PerformSql" || tc.Function.Arguments != `{"query":"SELECT 1"}` {
+		t.Errorf("Unexpected tool call: %+v", tc)
+	}
+
+	again, _ := json.Marshal(tc)
+	var a, b any
+	_ = json.Unmarshal(stored, &a)
+	_ = json.Unmarshal(again, &b)
+	if string(mustJSON(a)) != string(mustJSON(b)) {
+		t.Errorf("Expected the same JSON, got %s and %s", stored, again)
+	}
+}
+
+func mustJSON(v any) []byte {
+	raw, _ := json.Marshal(v)
+	return raw
+}
+
+func TestToolArguments(t *testing.T) {
+	tests := map[string]string{
+		``:                  `{}`,
+		`not json`:          `{}`,
+		`["a"]`:             `{}`,
+		`{"status":"open"}`: `{"status":"open"}`,
+	}
+	for in, want := range tests {
+		if got := toolArguments(ToolCall{Function: FunctionCall{Arguments: in}}); got != want {
+			t.Errorf("toolArguments(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestEstimateTokens(t *testing.T) {
+	if got := EstimateTokens(); got != 0 {
+		t.Errorf("Expected no tokens for no messages, got %d", got)
+	}
+	msg := Message{Role: RoleUser, Content: strings.Repeat("a", 400)}
+	if got := EstimateTokens(msg); got != 100+messageOverhead {
+		t.Errorf("Expected 104 tokens, got %d", got)
+	}
+	call := Message{Role: RoleAssistant, ToolCalls: []ToolCall{{ID: "call_1", Function: FunctionCall{Name: "ListTodos", Arguments: `{"status":"open"}`}}}}
+	if EstimateTokens(call) <= messageOverhead {
+		t.Error("Expected tool calls to count")
+	}
+	req := Request{System: strings.Repeat("s", 80), Messages: []Message{msg}, Tools: []Tool{{Name: "ListTodos", Parameters: map[string]any{"type": "object"}}}}
+	if got := EstimateRequestTokens(req); got <= EstimateTokens(msg)+20 {
+		t.Errorf("Expected the system prompt and tools to count, got %d", got)
+	}
+}
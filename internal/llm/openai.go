@@ -0,0 +1,223 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+	"github.com/openai/openai-go/shared"
+	"github.com/openai/openai-go/shared/constant"
+)
+
+// OpenAIConfig configures a provider for the OpenAI chat completions API or
+// any server compatible with it.
+type OpenAIConfig struct {
+	APIKey  string
+	BaseURL string
+	Model   string
+	// Options are extra request options, e.g. a custom HTTP client in tests.
+	Options []option.RequestOption
+}
+
+// OpenAI streams from the chat completions API.
+type OpenAI struct {
+	client openai.Client
+	model  string
+}
+
+func NewOpenAI(cfg OpenAIConfig) *OpenAI {
+	// Retries are left to WithRetry so both providers back off the same way.
+	opts := append([]option.RequestOption{
+		option.WithAPIKey(cfg.APIKey),
+		option.WithBaseURL(cfg.BaseURL),
+		option.WithMaxRetries(0),
+	}, cfg.Options...)
+	return &OpenAI{client: openai.NewClient(opts...), model: cfg.Model}
+}
+
+func (p *OpenAI) Name() string {
+	return "openai"
+}
+
+func (p *OpenAI) Model() string {
+	return p.model
+}
+
+func (p *OpenAI) Stream(ctx context.Context, req Request, onDelta func(Delta)) (Message, error) {
+	stream := p.client.Chat.Completions.NewStreaming(ctx, openai.ChatCompletionNewParams{
+		Model:    p.model,
+		Messages: openAIMessages(req.System, req.Messages),
+		Tools:    openAITools(req.Tools),
+	}, option.WithJSONSet("think", true))
+	defer func() {
+		_ = stream.Close()
+	}()
+
+	acc := openai.ChatCompletionAccumulator{}
+	var reasoning []byte
+	for stream.Next() {
+		chunk := stream.Current()
+		if slog.Default().Enabled(ctx, slog.LevelDebug) {
+			raw, _ := json.Marshal(chunk)
+			slog.Debug("Raw chunk received", "json", string(raw))
+		}
+		acc.AddChunk(chunk)
+
+		for _, choice := range chunk.Choices {
+			if r := deltaReasoning(choice.Delta); r != "" {
+				reasoning = append(reasoning, r...)
+				onDelta(Delta{Reasoning: r})
+			}
+			if choice.Delta.Content != "" {
+				onDelta(Delta{Content: choice.Delta.Content})
+			}
+		}
+	}
+	if err := stream.Err(); err != nil {
+		return Message{}, openAIError(err)
+	}
+
+	msg := Message{Role: RoleAssistant, Reasoning: string(reasoning)}
+	if len(acc.Choices) == 0 {
+		return msg, nil
+	}
+	choice := acc.Choices[0]
+	msg.Content = choice.Message.Content
+	for _, tc := range choice.Message.ToolCalls {
+		msg.ToolCalls = append(msg.ToolCalls, ToolCall{
+			ID:   tc.ID,
+			Type: "function",
+			Function: FunctionCall{
+				Name:      tc.Function.Name,
+				Arguments: tc.Function.Arguments,
+			},
+		})
+	}
+	return msg, nil
+}
+
+// streamErrorPrefix starts the error the SDK returns for an error event
+// inside the stream.
+const streamErrorPrefix = "received error while streaming: "
+
+// openAIError classifies an error from the SDK.
+func openAIError(err error) error {
+	var apiErr *openai.Error
+	if errors.As(err, &apiErr) {
+		typ := apiErr.Code
+		if typ == "" {
+			typ = apiErr.Type
+		}
+		var header http.Header
+		if apiErr.Response != nil {
+			header = apiErr.Response.Header
+		}
+		message := apiErr.Message
+		if message == "" {
+			message = http.StatusText(apiErr.StatusCode)
+		}
+		return newAPIError("openai", apiErr.StatusCode, typ, message, header)
+	}
+	if raw, ok := strings.CutPrefix(err.Error(), streamErrorPrefix); ok {
+		var body struct {
+			Message string `json:"message"`
+			Type    string `json:"type"`
+			Code    string `json:"code"`
+		}
+		if json.Unmarshal([]byte(raw), &body) == nil && body.Message != "" {
+			typ := body.Code
+			if typ == "" {
+				typ = body.Type
+			}
+			return newAPIError("openai", 0, typ, body.Message, nil)
+		}
+	}
+	return connectionError("openai", err)
+}
+
+// deltaReasoning reads the reasoning text that OpenAI-compatible servers
+// such as Ollama and DeepSeek send outside the official schema.
+func deltaReasoning(delta openai.ChatCompletionChunkChoiceDelta) string {
+	if delta.JSON.ExtraFields == nil {
+		return ""
+	}
+	for _, key := range []string{"reasoning_content", "reasoning"} {
+		f, ok := delta.JSON.ExtraFields[key]
+		if !ok {
+			continue
+		}
+		var r string
+		if err := json.Unmarshal([]byte(f.Raw()), &r); err != nil {
+			slog.Error("failed to unmarshal reasoning", "key", key, "err", err)
+			continue
+		}
+		if r != "" {
+			return r
+		}
+	}
+	return ""
+}
+
+func openAIMessages(system string, msgs []Message) []openai.ChatCompletionMessageParamUnion {
+	out := make([]openai.ChatCompletionMessageParamUnion, 0, len(msgs)+1)
+	if system != "" {
+		out = append(out, openai.SystemMessage(system))
+	}
+	for _, m := range msgs {
+		out = append(out, openAIMessage(m))
+	}
+	return out
+}
+
+func openAIMessage(m Message) openai.ChatCompletionMessageParamUnion {
+	switch m.Role {
+	case RoleUser:
+		return openai.UserMessage(m.Content)
+	case RoleSystem:
+		return openai.SystemMessage(m.Content)
+	case RoleAssistant:
+		if len(m.ToolCalls) > 0 {
+			calls := make([]openai.ChatCompletionMessageToolCallParam, 0, len(m.ToolCalls))
+			for _, tc := range m.ToolCalls {
+				calls = append(calls, openai.ChatCompletionMessageToolCallParam{
+					ID:   tc.ID,
+					Type: "function",
+					Function: openai.ChatCompletionMessageToolCallFunctionParam{
+						Name:      tc.Function.Name,
+						Arguments: toolArguments(tc),
+					},
+				})
+			}
+			asst := openai.ChatCompletionAssistantMessageParam{ToolCalls: calls}
+			if m.Content != "" {
+				asst.Content.OfString = openai.String(m.Content)
+			}
+			return openai.ChatCompletionMessageParamUnion{OfAssistant: &asst}
+		}
+		return openai.AssistantMessage(m.Content)
+	case RoleTool:
+		return openai.ToolMessage(m.Content, m.ToolCallID)
+	default:
+		return openai.UserMessage(m.Content)
+	}
+}
+
+func openAITools(tools []Tool) []openai.ChatCompletionToolParam {
+	out := make([]openai.ChatCompletionToolParam, 0, len(tools))
+	for _, t := range tools {
+		out = append(out, openai.ChatCompletionToolParam{
+			Type: constant.Function("function"),
+			Function: shared.FunctionDefinitionParam{
+				Name:        t.Name,
+				Description: openai.String(t.Description),
+				Parameters:  shared.FunctionParameters(t.Parameters),
+			},
+		})
+	}
+	return out
+}
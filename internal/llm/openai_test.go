@@ -0,0 +1,89 @@
+package llm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/openai/openai-go/option"
+)
+
+func newTestOpenAI(url string) *OpenAI {
+	return NewOpenAI(OpenAIConfig{
+		APIKey:  "sk-test",
+		BaseURL: url,
+		Model:   "gpt-4o-mini",
+		Options: []option.RequestOption{option.WithMaxRetries(0)},
+	})
+}
+
+func TestOpenAIStreamToolCall(t *testing.T) {
+	srv, rec := replay(t, 200, "openai_tool_call.sse")
+	p := newTestOpenAI(srv.URL)
+
+	var c collect
+	msg, err := p.Stream(context.Background(), Request{
+		System:   "You are a test agent.",
+		Messages: []Message{{Role: RoleUser, Content: "What is open?"}},
+		Tools:    []Tool{{Name: "ListTodos", Description: "List todos.", Parameters: map[string]any{"type": "object"}}},
+	}, c.onDelta)
+	if err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+
+	if c.reasoning != "The user wants open todos." || msg.Reasoning != c.reasoning {
+		t.Errorf("Expected the reasoning to stream, got %q and %q", c.reasoning, msg.Reasoning)
+	}
+	if len(msg.ToolCalls) != 1 {
+		t.Fatalf("Expected one tool call, got %+v", msg.ToolCalls)
+	}
+	tc := msg.ToolCalls[0]
+	if tc.ID != "call_abc123" || tc.Function.Name != "ListTodos" || tc.Function.Arguments != `{"status":"open"}` {
+		t.Errorf("Unexpected tool call: %+v", tc)
+	}
+
+	if rec.path != "/chat/completions" || rec.header.Get("Authorization") != "Bearer sk-test" {
+		t.Errorf("Unexpected request %s with headers %v", rec.path, rec.header)
+	}
+	messages := rec.body["messages"].([]any)
+	if len(messages) != 2 || messages[0].(map[string]any)["role"] != "system" {
+		t.Errorf("Expected the system prompt to lead, got %v", messages)
+	}
+	tools := rec.body["tools"].([]any)
+	fn := tools[0].(map[string]any)["function"].(map[string]any)
+	if fn["name"] != "ListTodos" || rec.body["model"] != "gpt-4o-mini" || rec.body["stream"] != true {
+		t.Errorf("Unexpected request body: %v", rec.body)
+	}
+}
+
+func TestOpenAIStreamText(t *testing.T) {
+	srv, _ := replay(t, 200, "openai_text.sse")
+	p := newTestOpenAI(srv.URL)
+
+	var c collect
+	msg, err := p.Stream(context.Background(), Request{Messages: []Message{{Role: RoleUser, Content: "hi"}}}, c.onDelta)
+	if err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+	if c.content != "You have one open todo." || msg.Content != c.content || len(msg.ToolCalls) != 0 {
+		t.Errorf("Unexpected reply %+v after deltas %q", msg, c.content)
+	}
+}
+
+func TestOpenAIMessages(t *testing.T) {
+	msgs := openAIMessages("System", []Message{
+		{Role: RoleUser, Content: "1"},
+		{Role: RoleAssistant, ToolCalls: []ToolCall{{ID: "call_1", Function: FunctionCall{Name: "ListTodos", Arguments: "oops"}}}},
+		{Role: RoleTool, ToolCallID: "call_1", Content: "[]"},
+		{Role: RoleAssistant, Content: "2"},
+	})
+	if len(msgs) != 5 || msgs[0].OfSystem == nil {
+		t.Fatalf("Expected the system prompt and 4 messages, got %d", len(msgs))
+	}
+	calls := msgs[2].OfAssistant.ToolCalls
+	if len(calls) != 1 || calls[0].Function.Arguments != "{}" {
+		t.Errorf("Expected invalid arguments to be replaced by {}, got %+v", calls)
+	}
+	if msgs[3].OfTool == nil || msgs[3].OfTool.ToolCallID != "call_1" {
+		t.Errorf("Expected a tool message, got %+v", msgs[3])
+	}
+}
@@ -0,0 +1,98 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+)
+
+// RetryPolicy controls how a Retrying provider retries failed requests.
+type RetryPolicy struct {
+	// MaxRetries is the number of retries after the first attempt.
+	MaxRetries int
+	// BaseDelay is the wait before the first retry. It doubles with each
+	// retry up to MaxDelay, and half of it is random jitter.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// OnRetry is called before waiting delay for retry number attempt.
+	OnRetry func(attempt int, delay time.Duration, err error)
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries: 4,
+	BaseDelay:  time.Second,
+	MaxDelay:   30 * time.Second,
+}
+
+// Retrying retries requests of the wrapped provider that fail with a
+// temporary *APIError: rate limits, server errors and dropped connections.
+// A Retry-After from the server replaces the computed backoff; if it asks
+// for longer than MaxDelay the error is returned instead.
+//
+// A request is only retried if nothing was streamed yet, since the deltas
+// already passed to onDelta cannot be taken back.
+type Retrying struct {
+	Provider
+	policy RetryPolicy
+	wait   func(ctx context.Context, d time.Duration) error
+}
+
+func WithRetry(p Provider, policy RetryPolicy) *Retrying {
+	return &Retrying{Provider: p, policy: policy, wait: sleep}
+}
+
+func (r *Retrying) Stream(ctx context.Context, req Request, onDelta func(Delta)) (Message, error) {
+	for attempt := 1; ; attempt++ {
+		streamed := false
+		msg, err := r.Provider.Stream(ctx, req, func(d Delta) {
+			streamed = true
+			onDelta(d)
+		})
+		if err == nil || streamed || ctx.Err() != nil || attempt > r.policy.MaxRetries {
+			return msg, err
+		}
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || !apiErr.Temporary() {
+			return msg, err
+		}
+		delay := r.policy.backoff(attempt)
+		if apiErr.RetryAfter > 0 {
+			if apiErr.RetryAfter > r.policy.MaxDelay {
+				return msg, err
+			}
+			delay = apiErr.RetryAfter
+		}
+		if r.policy.OnRetry != nil {
+			r.policy.OnRetry(attempt, delay, err)
+		}
+		if err := r.wait(ctx, delay); err != nil {
+			return msg, err
+		}
+	}
+}
+
+// backoff is the wait before retry number attempt: an exponential delay
+// of which the upper half is random.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < attempt && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	d = min(d, p.MaxDelay)
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,300 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// step is one canned response of a sequence server. reset drops the
+// connection instead of answering.
+type step struct {
+	status  int
+	header  map[string]string
+	fixture string
+	reset   bool
+}
+
+// sequence answers the n-th request with steps[n], repeating the last step
+// once they run out. It returns the server and the number of requests seen.
+func sequence(t *testing.T, steps ...step) (*httptest.Server, *int) {
+	t.Helper()
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s := steps[min(calls, len(steps)-1)]
+		calls++
+		if s.reset {
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				t.Errorf("Failed to hijack: %v", err)
+				return
+			}
+			_ = conn.Close()
+			return
+		}
+		data, err := os.ReadFile(filepath.Join("testdata", s.fixture))
+		if err != nil {
+			t.Errorf("Failed to read fixture: %v", err)
+		}
+		if filepath.Ext(s.fixture) == ".sse" {
+			w.Header().Set("content-type", "text/event-stream")
+		} else {
+			w.Header().Set("content-type", "application/json")
+		}
+		for k, v := range s.header {
+			w.Header().Set(k, v)
+		}
+		w.WriteHeader(s.status)
+		_, _ = w.Write(data)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+// retries records the retries of a Retrying provider instead of waiting.
+type retries struct {
+	attempts []int
+	waits    []time.Duration
+	errs     []error
+}
+
+func withTestRetry(p Provider, max int) (*Retrying, *retries) {
+	rec := &retries{}
+	r := WithRetry(p, RetryPolicy{
+		MaxRetries: max,
+		BaseDelay:  100 * time.Millisecond,
+		MaxDelay:   10 * time.Second,
+		OnRetry: func(attempt int, delay time.Duration, err error) {
+			rec.attempts = append(rec.attempts, attempt)
+			rec.errs = append(rec.errs, err)
+		},
+	})
+	r.wait = func(_ context.Context, d time.Duration) error {
+		rec.waits = append(rec.waits, d)
+		return nil
+	}
+	return r, rec
+}
+
+func TestRetryAnthropicOverloaded(t *testing.T) {
+	srv, calls := sequence(t,
+		step{status: 529, header: map[string]string{"retry-after": "2"}, fixture: "anthropic_overloaded.json"},
+		step{status: 200, fixture: "anthropic_text.sse"},
+	)
+	p, rec := withTestRetry(newTestAnthropic(srv.URL), 3)
+
+	msg, err := p.Stream(context.Background(), Request{Messages: []Message{{Role: RoleUser, Content: "hi"}}}, func(Delta) {})
+	if err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+	if msg.Content != "You have one open todo." || *calls != 2 {
+		t.Errorf("Expected the second attempt to answer, got %+v after %d requests", msg, *calls)
+	}
+	if len(rec.waits) != 1 || rec.waits[0] != 2*time.Second {
+		t.Errorf("Expected to wait the 2s Retry-After, got %v", rec.waits)
+	}
+	if len(rec.attempts) != 1 || rec.attempts[0] != 1 || !errors.Is(rec.errs[0], ErrServer) {
+		t.Errorf("Expected one retry after a server error, got %v %v", rec.attempts, rec.errs)
+	}
+}
+
+func TestRetryOpenAIRateLimit(t *testing.T) {
+	srv, calls := sequence(t,
+		step{status: 429, header: map[string]string{"retry-after-ms": "1500"}, fixture: "openai_rate_limit.json"},
+		step{status: 500, fixture: "openai_server_error.json"},
+		step{status: 200, fixture: "openai_text.sse"},
+	)
+	p, rec := withTestRetry(newTestOpenAI(srv.URL), 3)
+
+	var c collect
+	msg, err := p.Stream(context.Background(), Request{Messages: []Message{{Role: RoleUser, Content: "hi"}}}, c.onDelta)
+	if err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+	if msg.Content != "You have one open todo." || c.content != msg.Content || *calls != 3 {
+		t.Errorf("Expected the third attempt to answer, got %+v after %d requests", msg, *calls)
+	}
+	if len(rec.waits) != 2 || rec.waits[0] != 1500*time.Millisecond {
+		t.Fatalf("Expected to wait the 1.5s retry-after-ms first, got %v", rec.waits)
+	}
+	if rec.waits[1] < 100*time.Millisecond || rec.waits[1] > 200*time.Millisecond {
+		t.Errorf("Expected the second backoff within 100ms-200ms, got %v", rec.waits[1])
+	}
+	if !errors.Is(rec.errs[0], ErrRateLimit) || !errors.Is(rec.errs[1], ErrServer) {
+		t.Errorf("Expected a rate limit then a server error, got %v", rec.errs)
+	}
+}
+
+func TestRetryConnectionReset(t *testing.T) {
+	srv, calls := sequence(t,
+		step{reset: true},
+		step{status: 200, fixture: "anthropic_text.sse"},
+	)
+	p, rec := withTestRetry(newTestAnthropic(srv.URL), 3)
+
+	if _, err := p.Stream(context.Background(), Request{}, func(Delta) {}); err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+	if *calls != 2 || len(rec.errs) != 1 || !errors.Is(rec.errs[0], ErrConnection) {
+		t.Errorf("Expected one retry after a dropped connection, got %d requests and %v", *calls, rec.errs)
+	}
+
+	srv, calls = sequence(t, step{reset: true}, step{status: 200, fixture: "openai_text.sse"})
+	p, rec = withTestRetry(newTestOpenAI(srv.URL), 3)
+	if _, err := p.Stream(context.Background(), Request{}, func(Delta) {}); err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+	if *calls != 2 || len(rec.errs) != 1 || !errors.Is(rec.errs[0], ErrConnection) {
+		t.Errorf("Expected one retry after a dropped connection, got %d requests and %v", *calls, rec.errs)
+	}
+}
+
+func TestRetryStreamErrorEvent(t *testing.T) {
+	srv, calls := sequence(t,
+		step{status: 200, fixture: "anthropic_overloaded.sse"},
+		step{status: 200, fixture: "anthropic_text.sse"},
+	)
+	p, _ := withTestRetry(newTestAnthropic(srv.URL), 3)
+	if _, err := p.Stream(context.Background(), Request{}, func(Delta) {}); err != nil || *calls != 2 {
+		t.Errorf("Expected an overloaded event before any text to be retried, got %v after %d requests", err, *calls)
+	}
+
+	// Text already shown to the user cannot be taken back, so a stream that
+	// fails half way is not sent again.
+	srv, calls = sequence(t,
+		step{status: 200, fixture: "anthropic_interrupted.sse"},
+		step{status: 200, fixture: "anthropic_text.sse"},
+	)
+	p, rec := withTestRetry(newTestAnthropic(srv.URL), 3)
+	var c collect
+	_, err := p.Stream(context.Background(), Request{}, c.onDelta)
+	if !errors.Is(err, ErrServer) || *calls != 1 || len(rec.attempts) != 0 {
+		t.Errorf("Expected the interrupted stream to fail without a retry, got %v after %d requests", err, *calls)
+	}
+	if c.content != "You have " {
+		t.Errorf("Expected the partial text to be streamed once, got %q", c.content)
+	}
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	srv, calls := sequence(t, step{status: 500, fixture: "openai_server_error.json"})
+	p, rec := withTestRetry(newTestOpenAI(srv.URL), 2)
+
+	_, err := p.Stream(context.Background(), Request{}, func(Delta) {})
+	if !errors.Is(err, ErrServer) {
+		t.Fatalf("Expected the last server error, got %v", err)
+	}
+	if *calls != 3 || len(rec.attempts) != 2 || rec.attempts[1] != 2 {
+		t.Errorf("Expected the first attempt and 2 retries, got %d requests and %v", *calls, rec.attempts)
+	}
+}
+
+func TestRetryAfterBeyondMaxDelay(t *testing.T) {
+	srv, calls := sequence(t, step{status: 429, header: map[string]string{"retry-after": "60"}, fixture: "anthropic_rate_limit.json"})
+	p, rec := withTestRetry(newTestAnthropic(srv.URL), 3)
+
+	_, err := p.Stream(context.Background(), Request{}, func(Delta) {})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.RetryAfter != time.Minute || !errors.Is(err, ErrRateLimit) {
+		t.Fatalf("Expected the rate limit with its Retry-After, got %v", err)
+	}
+	if *calls != 1 || len(rec.waits) != 0 {
+		t.Errorf("Expected no retry past MaxDelay, got %d requests", *calls)
+	}
+}
+
+func TestRetryStopsWhenCancelled(t *testing.T) {
+	srv, calls := sequence(t, step{status: 529, fixture: "anthropic_overloaded.json"})
+	ctx, cancel := context.WithCancel(context.Background())
+	p := WithRetry(newTestAnthropic(srv.URL), RetryPolicy{
+		MaxRetries: 3,
+		BaseDelay:  time.Minute,
+		MaxDelay:   time.Minute,
+		OnRetry:    func(int, time.Duration, error) { cancel() },
+	})
+
+	_, err := p.Stream(ctx, Request{}, func(Delta) {})
+	if !errors.Is(err, context.Canceled) || *calls != 1 {
+		t.Errorf("Expected the wait to end with the context, got %v after %d requests", err, *calls)
+	}
+}
+
+func TestErrorCategories(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider string
+		status   int
+		fixture  string
+		want     error
+		message  string
+	}{
+		{"anthropic auth", "anthropic", 401, "anthropic_auth.json", ErrAuth, "anthropic: authentication failed (401 authentication_error): invalid x-api-key"},
+		{"anthropic model", "anthropic", 404, "anthropic_not_found.json", ErrModelNotFound, "anthropic: model not found (404 not_found_error): model: claude-sonnet-9"},
+		{"anthropic context", "anthropic", 400, "anthropic_prompt_too_long.json", ErrContextTooLong, "anthropic: context too long (400 invalid_request_error): prompt is too long: 208532 tokens > 200000 maximum"},
+		{"openai auth", "openai", 401, "openai_invalid_key.json", ErrAuth, "openai: authentication failed (401 invalid_api_key): Incorrect API key provided"},
+		{"openai model", "openai", 404, "openai_model_not_found.json", ErrModelNotFound, "openai: model not found (404 model_not_found): The model `gpt-9` does not exist"},
+		{"openai context", "openai", 400, "openai_context_length.json", ErrContextTooLong, "openai: context too long (400 context_length_exceeded): This model's maximum context length"},
+		{"openai quota", "openai", 429, "openai_quota.json", ErrRateLimit, "openai: rate limited (429 insufficient_quota): You exceeded your current quota"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, calls := sequence(t, step{status: tt.status, fixture: tt.fixture})
+			var inner Provider = newTestAnthropic(srv.URL)
+			if tt.provider == "openai" {
+				inner = newTestOpenAI(srv.URL)
+			}
+			p, rec := withTestRetry(inner, 3)
+
+			_, err := p.Stream(context.Background(), Request{}, func(Delta) {})
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Expected %v, got %v", tt.want, err)
+			}
+			if !strings.HasPrefix(err.Error(), tt.message) {
+				t.Errorf("Unexpected message %q", err.Error())
+			}
+			if *calls != 1 || len(rec.attempts) != 0 {
+				t.Errorf("Expected no retry, got %d requests", *calls)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	p := RetryPolicy{BaseDelay: time.Second, MaxDelay: 8 * time.Second}
+	for attempt, full := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 4: 8 * time.Second, 7: 8 * time.Second} {
+		for range 20 {
+			if d := p.backoff(attempt); d < full/2 || d > full {
+				t.Errorf("backoff(%d) = %v, want between %v and %v", attempt, d, full/2, full)
+			}
+		}
+	}
+}
+
+func TestRetryAfterHeader(t *testing.T) {
+	tests := map[string]struct {
+		header http.Header
+		want   time.Duration
+	}{
+		"none":    {http.Header{}, 0},
+		"seconds": {http.Header{"Retry-After": {"3"}}, 3 * time.Second},
+		"ms":      {http.Header{"Retry-After-Ms": {"250"}, "Retry-After": {"1"}}, 250 * time.Millisecond},
+		"past":    {http.Header{"Retry-After": {"Wed, 21 Oct 2015 07:28:00 GMT"}}, 0},
+		"invalid": {http.Header{"Retry-After": {"soon"}}, 0},
+	}
+	for name, tt := range tests {
+		if got := retryAfter(tt.header); got != tt.want {
+			t.Errorf("%s: retryAfter = %v, want %v", name, got, tt.want)
+		}
+	}
+
+	date := time.Now().Add(90 * time.Second).UTC().Format(http.TimeFormat)
+	if got := retryAfter(http.Header{"Retry-After": {date}}); got < 80*time.Second || got > 90*time.Second {
+		t.Errorf("Expected about 90s from an HTTP date, got %v", got)
+	}
+}
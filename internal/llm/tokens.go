@@ -0,0 +1,34 @@
+package llm
+
+import "encoding/json"
+
+// messageOverhead is roughly what the role and framing of a message cost.
+const messageOverhead = 4
+
+// EstimateTokens guesses how many tokens msgs take up, at about four
+// characters per token. It is only meant for budgeting, not billing.
+func EstimateTokens(msgs ...Message) int {
+	n := 0
+	for _, m := range msgs {
+		chars := len(m.Content) + len(m.Name) + len(m.ToolCallID)
+		for _, t := range m.Thinking {
+			chars += len(t.Text) + len(t.Signature) + len(t.Redacted)
+		}
+		for _, tc := range m.ToolCalls {
+			chars += len(tc.ID) + len(tc.Function.Name) + len(tc.Function.Arguments)
+		}
+		n += chars/4 + messageOverhead
+	}
+	return n
+}
+
+// EstimateRequestTokens guesses the size of req, including the system prompt
+// and tool definitions that are sent with every call.
+func EstimateRequestTokens(req Request) int {
+	n := len(req.System) / 4
+	for _, t := range req.Tools {
+		params, _ := json.Marshal(t.Parameters)
+		n += (len(t.Name)+len(t.Description)+len(params))/4 + messageOverhead
+	}
+	return n + EstimateTokens(req.Messages...)
+}
@@ -0,0 +1,439 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Status is the state of a server's connection.
+type Status string
+
+const (
+	StatusConnecting   Status = "connecting"
+	StatusConnected    Status = "connected"
+	StatusDisconnected Status = "disconnected"
+	StatusFailed       Status = "failed"
+)
+
+// StartTimeout bounds how long a server may take to start and list its
+// tools.
+const StartTimeout = 30 * time.Second
+
+const (
+	// maxRestarts is how many times in a row a server that exits is
+	// restarted before it is marked failed.
+	maxRestarts = 5
+	// stableAfter is how long a server must stay up for its restarts to
+	// count from zero again.
+	stableAfter = time.Minute
+	// stopGrace is how long a server has to exit after its stdin closes.
+	stopGrace = 2 * time.Second
+	maxStderr = 4 * 1024
+)
+
+// restartDelay is the wait before the first automatic restart; it doubles
+// with each attempt. Tests shorten it.
+var restartDelay = time.Second
+
+// clientInfo is how godo introduces itself to servers.
+var clientInfo = Implementation{Name: "godo", Version: "1"}
+
+// Client runs one MCP server and talks to it. A server that exits is
+// restarted with backoff, and a call to a server that is down restarts it
+// once before giving up.
+type Client struct {
+	Config ServerConfig
+
+	// onChange is called after the status or tools change.
+	onChange func()
+	// startMu serializes starts, so a restart on demand and a scheduled
+	// one don't both spawn a process.
+	startMu sync.Mutex
+
+	mu       sync.Mutex
+	status   Status
+	err      error
+	proc     *process
+	info     Implementation
+	tools    []Tool
+	started  time.Time
+	restarts int
+	timer    *time.Timer
+	closed   bool
+}
+
+// NewClient returns a client for server. It does not start it.
+func NewClient(server ServerConfig, onChange func()) *Client {
+	if onChange == nil {
+		onChange = func() {}
+	}
+	return &Client{Config: server, onChange: onChange, status: StatusDisconnected}
+}
+
+// Status returns the connection state and, unless connected, the last
+// error.
+func (c *Client) Status() (Status, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.status, c.err
+}
+
+// Tools returns the tools the server listed when it connected.
+func (c *Client) Tools() []Tool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.tools
+}
+
+// ServerInfo returns the name and version the server reported.
+func (c *Client) ServerInfo() Implementation {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.info
+}
+
+// Start launches the server and lists its tools. A failed start marks the
+// server failed; it is not retried until Restart.
+func (c *Client) Start(ctx context.Context) error {
+	c.startMu.Lock()
+	defer c.startMu.Unlock()
+	err := c.start(ctx)
+	if err != nil {
+		c.setStatus(StatusFailed, err)
+	}
+	return err
+}
+
+// Restart stops the server if it is running and starts it again.
+func (c *Client) Restart(ctx context.Context) error {
+	c.startMu.Lock()
+	defer c.startMu.Unlock()
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return ErrClosed
+	}
+	c.restarts = 0
+	c.mu.Unlock()
+	c.stop()
+	err := c.start(ctx)
+	if err != nil {
+		c.setStatus(StatusFailed, err)
+	}
+	return err
+}
+
+// Close stops the server for good.
+func (c *Client) Close() {
+	c.mu.Lock()
+	c.closed = true
+	c.mu.Unlock()
+	c.stop()
+}
+
+// CallTool calls the server's tool name with args, a JSON object, and
+// returns the text of the result. A result the tool marks as an error is
+// returned as an error.
+func (c *Client) CallTool(ctx context.Context, name string, args json.RawMessage) (string, error) {
+	conn, err := c.connection(ctx)
+	if err != nil {
+		return "", err
+	}
+	if len(strings.TrimSpace(string(args))) == 0 {
+		args = json.RawMessage("{}")
+	}
+	var result CallToolResult
+	if err := conn.Call(ctx, "tools/call", callToolParams{Name: name, Arguments: args}, &result); err != nil {
+		if errors.Is(err, ErrClosed) {
+			return "", fmt.Errorf("MCP server %s exited during the call%s", c.Config.Name, c.stderrSuffix())
+		}
+		return "", err
+	}
+	text := result.Text()
+	if result.IsError {
+		if text == "" {
+			text = "the tool reported an error"
+		}
+		return "", errors.New(text)
+	}
+	return text, nil
+}
+
+// connection returns the live connection, restarting the server once if it
+// is down.
+func (c *Client) connection(ctx context.Context) (*Conn, error) {
+	c.mu.Lock()
+	proc, ok, closed := c.proc, live(c.proc), c.closed
+	c.mu.Unlock()
+	if ok {
+		return proc.conn, nil
+	}
+	if closed {
+		return nil, fmt.Errorf("MCP server %s is shut down", c.Config.Name)
+	}
+
+	c.startMu.Lock()
+	defer c.startMu.Unlock()
+	c.mu.Lock()
+	proc, ok = c.proc, live(c.proc)
+	c.mu.Unlock()
+	if ok {
+		return proc.conn, nil
+	}
+	c.stop()
+	if err := c.start(ctx); err != nil {
+		c.setStatus(StatusFailed, err)
+		return nil, fmt.Errorf("MCP server %s is not running: %w", c.Config.Name, err)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.proc.conn, nil
+}
+
+// live reports whether proc has finished its handshake and is still
+// talking. A server that just exited may not be marked disconnected yet.
+// c.mu must be held.
+func live(proc *process) bool {
+	if proc == nil || !proc.ready {
+		return false
+	}
+	select {
+	case <-proc.conn.Done():
+		return false
+	default:
+		return true
+	}
+}
+
+// start spawns the server and does the handshake. startMu must be held.
+func (c *Client) start(ctx context.Context) error {
+	c.setStatus(StatusConnecting, nil)
+
+	cmd := exec.Command(c.Config.Command, c.Config.Args...)
+	cmd.Dir = c.Config.Dir
+	cmd.Env = os.Environ()
+	for k, v := range c.Config.Env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	stderr := &tail{max: maxStderr}
+	cmd.Stderr = stderr
+	// Don't wait on pipes a killed server's children still hold.
+	cmd.WaitDelay = time.Second
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	// Not StdoutPipe: Wait closes that as soon as the server exits, which
+	// can lose its last messages.
+	stdout, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	cmd.Stdout = w
+	err = cmd.Start()
+	w.Close()
+	if err != nil {
+		stdout.Close()
+		return fmt.Errorf("failed to start %s: %w", c.Config.Command, err)
+	}
+	proc := &process{cmd: cmd, conn: NewConn(stdout, stdin, nil), stdin: stdin, stdout: stdout, stderr: stderr, exited: make(chan struct{})}
+	go func() {
+		proc.err = cmd.Wait()
+		close(proc.exited)
+	}()
+
+	c.mu.Lock()
+	c.proc = proc
+	c.mu.Unlock()
+
+	info, tools, err := handshake(ctx, proc.conn)
+	if err != nil {
+		c.stop()
+		return fmt.Errorf("%w%s", err, stderr.suffix())
+	}
+
+	c.mu.Lock()
+	proc.ready = true
+	c.info, c.tools, c.started = info, tools, time.Now()
+	c.mu.Unlock()
+	c.setStatus(StatusConnected, nil)
+	go c.watch(proc)
+	return nil
+}
+
+// handshake initializes the connection and lists the server's tools.
+func handshake(ctx context.Context, conn *Conn) (Implementation, []Tool, error) {
+	var init initializeResult
+	err := conn.Call(ctx, "initialize", initializeParams{
+		ProtocolVersion: ProtocolVersion,
+		Capabilities:    map[string]any{},
+		ClientInfo:      clientInfo,
+	}, &init)
+	if err != nil {
+		return Implementation{}, nil, fmt.Errorf("initialize failed: %w", err)
+	}
+	if err := conn.Notify("notifications/initialized", nil); err != nil {
+		return Implementation{}, nil, err
+	}
+
+	var tools []Tool
+	var cursor string
+	for {
+		var page listToolsResult
+		if err := conn.Call(ctx, "tools/list", listParams{Cursor: cursor}, &page); err != nil {
+			return Implementation{}, nil, fmt.Errorf("listing tools failed: %w", err)
+		}
+		tools = append(tools, page.Tools...)
+		if page.NextCursor == "" || page.NextCursor == cursor {
+			break
+		}
+		cursor = page.NextCursor
+	}
+	return init.ServerInfo, tools, nil
+}
+
+// watch waits for proc to exit and schedules a restart unless it was
+// stopped on purpose.
+func (c *Client) watch(proc *process) {
+	<-proc.exited
+	err := proc.err
+
+	c.mu.Lock()
+	if c.proc != proc || c.closed {
+		c.mu.Unlock()
+		return
+	}
+	if time.Since(c.started) > stableAfter {
+		c.restarts = 0
+	}
+	if err == nil {
+		err = errors.New("server exited")
+	} else {
+		err = fmt.Errorf("server exited: %w", err)
+	}
+	err = fmt.Errorf("%w%s", err, proc.stderr.suffix())
+	c.restarts++
+	if c.restarts > maxRestarts {
+		c.status, c.err = StatusFailed, fmt.Errorf("%w; gave up after %d restarts", err, maxRestarts)
+	} else {
+		c.status, c.err = StatusDisconnected, err
+		delay := restartDelay << (c.restarts - 1)
+		c.timer = time.AfterFunc(delay, c.autoRestart)
+	}
+	c.mu.Unlock()
+	c.onChange()
+}
+
+// autoRestart is the scheduled restart of a server that exited.
+func (c *Client) autoRestart() {
+	c.startMu.Lock()
+	defer c.startMu.Unlock()
+	c.mu.Lock()
+	skip := c.closed || c.status != StatusDisconnected
+	c.mu.Unlock()
+	if skip {
+		return
+	}
+	c.stop()
+	ctx, cancel := context.WithTimeout(context.Background(), StartTimeout)
+	defer cancel()
+	if err := c.start(ctx); err != nil {
+		c.setStatus(StatusFailed, err)
+	}
+}
+
+// stop ends the running server, if any: it closes stdin, then kills the
+// server if it has not exited after stopGrace.
+func (c *Client) stop() {
+	c.mu.Lock()
+	proc := c.proc
+	c.proc = nil
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+	c.mu.Unlock()
+	if proc == nil {
+		return
+	}
+	_ = proc.stdin.Close()
+	select {
+	case <-proc.exited:
+	case <-time.After(stopGrace):
+		_ = proc.cmd.Process.Kill()
+		<-proc.exited
+	}
+	// Children of the server may still hold its stdout open.
+	_ = proc.stdout.Close()
+	c.setStatus(StatusDisconnected, nil)
+}
+
+func (c *Client) setStatus(status Status, err error) {
+	c.mu.Lock()
+	changed := c.status != status || c.err != err
+	c.status, c.err = status, err
+	c.mu.Unlock()
+	if changed {
+		c.onChange()
+	}
+}
+
+func (c *Client) stderrSuffix() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.proc == nil {
+		return ""
+	}
+	return c.proc.stderr.suffix()
+}
+
+// process is a running server.
+type process struct {
+	cmd    *exec.Cmd
+	conn   *Conn
+	stdin  io.Closer
+	stdout io.Closer
+	stderr *tail
+	// exited is closed once the server has exited, and err is then what
+	// Wait returned.
+	exited chan struct{}
+	err    error
+	// ready is set once the handshake is done.
+	ready bool
+}
+
+// tail keeps the last max bytes written to it.
+type tail struct {
+	mu  sync.Mutex
+	buf []byte
+	max int
+}
+
+func (t *tail) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.buf = append(t.buf, p...)
+	if len(t.buf) > t.max {
+		t.buf = append(t.buf[:0], t.buf[len(t.buf)-t.max:]...)
+	}
+	return len(p), nil
+}
+
+// suffix formats the captured stderr for an error message.
+func (t *tail) suffix() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := strings.TrimSpace(string(t.buf))
+	if s == "" {
+		return ""
+	}
+	return "; stderr: " + s
+}
@@ -0,0 +1,82 @@
+package mcp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+)
+
+// ServerConfig says how to start an MCP server.
+type ServerConfig struct {
+	// Name identifies the server; it prefixes the names of its tools.
+	Name    string            `json:"-"`
+	Command string            `json:"command"`
+	Args    []string          `json:"args"`
+	Env     map[string]string `json:"env"`
+	// Dir is the server's working directory, relative to the config file.
+	// Empty starts it in godo's working directory.
+	Dir      string `json:"cwd"`
+	Disabled bool   `json:"disabled"`
+	// Type is the transport. Only stdio is supported, which is also what
+	// an empty type means.
+	Type string `json:"type"`
+}
+
+// validServer matches server names; they end up in tool names, so they
+// keep to what the model APIs accept there.
+var validServer = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+
+// LoadConfig reads server definitions in the format other MCP clients use:
+//
+//	{
+//	  "mcpServers": {
+//	    "github": {"command": "github-mcp-server", "args": ["stdio"], "env": {"GITHUB_TOKEN": "..."}}
+//	  }
+//	}
+//
+// A missing file has no servers. Fields godo does not use, such as the
+// timeouts some clients add, are ignored. Disabled servers are left out, and
+// the rest are sorted by name.
+func LoadConfig(path string) ([]ServerConfig, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Servers map[string]ServerConfig `json:"mcpServers"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	servers := make([]ServerConfig, 0, len(file.Servers))
+	for name, server := range file.Servers {
+		if !validServer.MatchString(name) {
+			return nil, fmt.Errorf("%s: server name %q must be 1-32 letters, digits, '_' or '-'", path, name)
+		}
+		if server.Type != "" && server.Type != "stdio" {
+			return nil, fmt.Errorf("%s: server %s uses the %s transport, only stdio is supported", path, name, server.Type)
+		}
+		if server.Command == "" {
+			return nil, fmt.Errorf("%s: server %s has no command", path, name)
+		}
+		if server.Disabled {
+			continue
+		}
+		server.Name = name
+		if server.Dir != "" && !filepath.IsAbs(server.Dir) {
+			server.Dir = filepath.Join(filepath.Dir(path), server.Dir)
+		}
+		servers = append(servers, server)
+	}
+	sort.Slice(servers, func(i, j int) bool { return servers[i].Name < servers[j].Name })
+	return servers, nil
+}
@@ -0,0 +1,244 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"sync"
+)
+
+// ErrClosed is returned for calls on a connection whose peer went away.
+var ErrClosed = errors.New("connection closed")
+
+// Handler answers requests and notifications from the peer. The result of a
+// notification is ignored. Returning an *Error sends its code.
+type Handler func(ctx context.Context, method string, params json.RawMessage) (any, error)
+
+// Conn is a JSON-RPC connection over newline delimited JSON.
+type Conn struct {
+	handler Handler
+
+	wmu sync.Mutex
+	w   io.Writer
+
+	mu      sync.Mutex
+	nextID  int64
+	pending map[string]chan *message
+	// inflight cancels the handlers of requests the peer cancels.
+	inflight map[string]context.CancelFunc
+	err      error
+	done     chan struct{}
+}
+
+// NewConn starts reading messages from r and sends messages to w. Requests
+// from the peer are passed to handler, which may be nil.
+func NewConn(r io.Reader, w io.Writer, handler Handler) *Conn {
+	c := &Conn{
+		handler:  handler,
+		w:        w,
+		pending:  map[string]chan *message{},
+		inflight: map[string]context.CancelFunc{},
+		done:     make(chan struct{}),
+	}
+	go c.read(r)
+	return c
+}
+
+// Done is closed when the peer stops sending, and Err then says why.
+func (c *Conn) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err returns why the connection closed, or nil while it is open.
+func (c *Conn) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// Call sends a request and decodes its result into result, which may be
+// nil. Cancelling ctx tells the peer to stop working on it.
+func (c *Conn) Call(ctx context.Context, method string, params, result any) error {
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return c.err
+	}
+	c.nextID++
+	id := strconv.FormatInt(c.nextID, 10)
+	reply := make(chan *message, 1)
+	c.pending[id] = reply
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+	if err := c.send(&message{ID: json.RawMessage(id), Method: method, Params: marshal(params)}); err != nil {
+		return err
+	}
+
+	select {
+	case msg := <-reply:
+		if msg == nil {
+			return c.Err()
+		}
+		if msg.Error != nil {
+			return msg.Error
+		}
+		if result == nil {
+			return nil
+		}
+		if err := json.Unmarshal(msg.Result, result); err != nil {
+			return fmt.Errorf("invalid %s result: %w", method, err)
+		}
+		return nil
+	case <-ctx.Done():
+		_ = c.Notify("notifications/cancelled", map[string]any{"requestId": json.RawMessage(id), "reason": ctx.Err().Error()})
+		return ctx.Err()
+	}
+}
+
+// Notify sends a notification.
+func (c *Conn) Notify(method string, params any) error {
+	return c.send(&message{Method: method, Params: marshal(params)})
+}
+
+func marshal(v any) json.RawMessage {
+	if v == nil {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		// Params are our own types; failing to encode them is a bug.
+		panic(fmt.Sprintf("mcp: failed to encode %T: %v", v, err))
+	}
+	return data
+}
+
+func (c *Conn) send(msg *message) error {
+	msg.JSONRPC = "2.0"
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if _, err := c.w.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to send %s: %w", msg.Method, err)
+	}
+	return nil
+}
+
+func (c *Conn) read(r io.Reader) {
+	br := bufio.NewReader(r)
+	var err error
+	for {
+		var line []byte
+		line, err = br.ReadBytes('\n')
+		if len(line) > 0 {
+			c.dispatch(line)
+		}
+		if err != nil {
+			break
+		}
+	}
+	if errors.Is(err, io.EOF) {
+		err = ErrClosed
+	}
+
+	c.mu.Lock()
+	c.err = err
+	for id, reply := range c.pending {
+		close(reply)
+		delete(c.pending, id)
+	}
+	for _, cancel := range c.inflight {
+		cancel()
+	}
+	c.mu.Unlock()
+	close(c.done)
+}
+
+func (c *Conn) dispatch(line []byte) {
+	var msg message
+	if err := json.Unmarshal(line, &msg); err != nil {
+		slog.Warn("mcp: dropping invalid message", "err", err)
+		_ = c.send(&message{ID: json.RawMessage("null"), Error: &Error{Code: CodeParseError, Message: "invalid JSON"}})
+		return
+	}
+	switch {
+	case msg.Method == "" && msg.ID != nil:
+		c.mu.Lock()
+		reply, ok := c.pending[string(msg.ID)]
+		c.mu.Unlock()
+		if ok {
+			reply <- &msg
+		}
+	case msg.Method == "notifications/cancelled":
+		var p struct {
+			RequestID json.RawMessage `json:"requestId"`
+		}
+		_ = json.Unmarshal(msg.Params, &p)
+		c.mu.Lock()
+		if cancel, ok := c.inflight[string(p.RequestID)]; ok {
+			cancel()
+		}
+		c.mu.Unlock()
+	case msg.ID == nil:
+		if c.handler != nil {
+			go func() {
+				_, _ = c.handler(context.Background(), msg.Method, msg.Params)
+			}()
+		}
+	default:
+		go c.answer(&msg)
+	}
+}
+
+// answer runs the handler for a request and sends its response.
+func (c *Conn) answer(msg *message) {
+	id := string(msg.ID)
+	ctx, cancel := context.WithCancel(context.Background())
+	c.mu.Lock()
+	c.inflight[id] = cancel
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.inflight, id)
+		c.mu.Unlock()
+		cancel()
+	}()
+
+	reply := &message{ID: msg.ID}
+	var result any
+	var err error
+	if msg.Method == "ping" {
+		result = struct{}{}
+	} else if c.handler == nil {
+		err = &Error{Code: CodeMethodNotFound, Message: "method not found: " + msg.Method}
+	} else {
+		result, err = c.handler(ctx, msg.Method, msg.Params)
+	}
+	var rpcErr *Error
+	switch {
+	case errors.As(err, &rpcErr):
+		reply.Error = rpcErr
+	case err != nil:
+		reply.Error = &Error{Code: CodeInternalError, Message: err.Error()}
+	default:
+		if result == nil {
+			result = struct{}{}
+		}
+		reply.Result = marshal(result)
+	}
+	if err := c.send(reply); err != nil {
+		slog.Warn("mcp: failed to answer request", "method", msg.Method, "err", err)
+	}
+}
@@ -0,0 +1,200 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// ToolPrefix starts the names of MCP tools as the model sees them:
+// mcp__<server>__<tool>.
+const ToolPrefix = "mcp__"
+
+// maxToolName is the longest tool name the model APIs accept.
+const maxToolName = 64
+
+var unsafeToolChars = regexp.MustCompile(`[^A-Za-z0-9_-]`)
+
+// QualifiedName is the name the model calls a server's tool by. It keeps
+// tools of different servers, and godo's own, apart.
+func QualifiedName(server, tool string) string {
+	name := ToolPrefix + server + "__" + unsafeToolChars.ReplaceAllString(tool, "_")
+	if len(name) > maxToolName {
+		name = name[:maxToolName]
+	}
+	return name
+}
+
+// ServerTool is a tool of a connected server.
+type ServerTool struct {
+	Tool
+	Server string
+	// Name is the qualified name; Tool.Name is the server's own.
+	Name string
+}
+
+// ServerStatus describes a server for the user.
+type ServerStatus struct {
+	Name   string
+	Status Status
+	Err    error
+	Tools  int
+}
+
+// Manager runs the configured servers and routes tool calls to them.
+type Manager struct {
+	clients []*Client
+	byName  map[string]*Client
+
+	mu       sync.Mutex
+	onChange func()
+}
+
+// NewManager returns a manager for servers. Nothing starts until Start.
+func NewManager(servers []ServerConfig) *Manager {
+	m := &Manager{byName: map[string]*Client{}}
+	for _, server := range servers {
+		c := NewClient(server, m.changed)
+		m.clients = append(m.clients, c)
+		m.byName[server.Name] = c
+	}
+	return m
+}
+
+// OnChange sets a function called whenever a server's status changes.
+func (m *Manager) OnChange(fn func()) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onChange = fn
+}
+
+func (m *Manager) changed() {
+	m.mu.Lock()
+	fn := m.onChange
+	m.mu.Unlock()
+	if fn != nil {
+		fn()
+	}
+}
+
+// Start starts every server at once and waits for them to list their
+// tools, each for up to StartTimeout. It returns the servers that failed;
+// the others are usable either way.
+func (m *Manager) Start(ctx context.Context) []error {
+	errs := make([]error, len(m.clients))
+	var wg sync.WaitGroup
+	for i, c := range m.clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, StartTimeout)
+			defer cancel()
+			if err := c.Start(ctx); err != nil {
+				errs[i] = fmt.Errorf("MCP server %s: %w", c.Config.Name, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	var failed []error
+	for _, err := range errs {
+		if err != nil {
+			failed = append(failed, err)
+		}
+	}
+	return failed
+}
+
+// Tools returns the tools of every server, under their qualified names. A
+// server that is restarting keeps the tools it last listed, so a call to
+// one brings it back.
+func (m *Manager) Tools() []ServerTool {
+	var tools []ServerTool
+	seen := map[string]bool{}
+	for _, c := range m.clients {
+		for _, tool := range c.Tools() {
+			name := QualifiedName(c.Config.Name, tool.Name)
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			tools = append(tools, ServerTool{Tool: tool, Server: c.Config.Name, Name: name})
+		}
+	}
+	return tools
+}
+
+// Lookup finds a tool by its qualified name.
+func (m *Manager) Lookup(name string) (ServerTool, bool) {
+	if !strings.HasPrefix(name, ToolPrefix) {
+		return ServerTool{}, false
+	}
+	for _, tool := range m.Tools() {
+		if tool.Name == name {
+			return tool, true
+		}
+	}
+	return ServerTool{}, false
+}
+
+// Call calls the tool with the qualified name and returns its text.
+func (m *Manager) Call(ctx context.Context, name string, args json.RawMessage) (string, error) {
+	tool, ok := m.Lookup(name)
+	if !ok {
+		return "", fmt.Errorf("unknown MCP tool %s", name)
+	}
+	return m.byName[tool.Server].CallTool(ctx, tool.Tool.Name, args)
+}
+
+// Statuses describes every server, in name order.
+func (m *Manager) Statuses() []ServerStatus {
+	statuses := make([]ServerStatus, 0, len(m.clients))
+	for _, c := range m.clients {
+		status, err := c.Status()
+		statuses = append(statuses, ServerStatus{Name: c.Config.Name, Status: status, Err: err, Tools: len(c.Tools())})
+	}
+	return statuses
+}
+
+// Connected counts the servers that are connected.
+func (m *Manager) Connected() int {
+	n := 0
+	for _, c := range m.clients {
+		if status, _ := c.Status(); status == StatusConnected {
+			n++
+		}
+	}
+	return n
+}
+
+// Len is the number of configured servers.
+func (m *Manager) Len() int {
+	return len(m.clients)
+}
+
+// Restart restarts the named server.
+func (m *Manager) Restart(ctx context.Context, name string) error {
+	c, ok := m.byName[name]
+	if !ok {
+		return fmt.Errorf("no MCP server named %s", name)
+	}
+	ctx, cancel := context.WithTimeout(ctx, StartTimeout)
+	defer cancel()
+	return c.Restart(ctx)
+}
+
+// Close stops every server.
+func (m *Manager) Close() {
+	var wg sync.WaitGroup
+	for _, c := range m.clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.Close()
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,292 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeServer is the path of the built testdata/fakemcp server.
+var fakeServer string
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "fakemcp")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fakeServer = filepath.Join(dir, "fakemcp")
+	if runtime.GOOS == "windows" {
+		fakeServer += ".exe"
+	}
+	if out, err := exec.Command("go", "build", "-o", fakeServer, "./testdata/fakemcp").CombinedOutput(); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to build the fake server: %v\n%s", err, out)
+		os.Exit(1)
+	}
+	restartDelay = 10 * time.Millisecond
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func startFake(t *testing.T, env map[string]string) *Client {
+	t.Helper()
+	c := NewClient(ServerConfig{Name: "fake", Command: fakeServer, Env: env}, nil)
+	t.Cleanup(c.Close)
+	return c
+}
+
+func TestConn(t *testing.T) {
+	clientR, serverW := io.Pipe()
+	serverR, clientW := io.Pipe()
+	server := NewConn(serverR, serverW, func(ctx context.Context, method string, params json.RawMessage) (any, error) {
+		switch method {
+		case "add":
+			var nums []int
+			if err := json.Unmarshal(params, &nums); err != nil {
+				return nil, &Error{Code: CodeInvalidParams, Message: err.Error()}
+			}
+			return nums[0] + nums[1], nil
+		case "wait":
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		return nil, &Error{Code: CodeMethodNotFound, Message: "no " + method}
+	})
+	client := NewConn(clientR, clientW, nil)
+
+	var sum int
+	if err := client.Call(t.Context(), "add", []int{2, 3}, &sum); err != nil || sum != 5 {
+		t.Errorf("Expected 5, got %d, %v", sum, err)
+	}
+	var rpcErr *Error
+	if err := client.Call(t.Context(), "add", "x", nil); !errors.As(err, &rpcErr) || rpcErr.Code != CodeInvalidParams {
+		t.Errorf("Expected an invalid params error, got %v", err)
+	}
+	if err := client.Call(t.Context(), "nope", nil, nil); !errors.As(err, &rpcErr) || rpcErr.Code != CodeMethodNotFound {
+		t.Errorf("Expected a method not found error, got %v", err)
+	}
+	if err := server.Call(t.Context(), "ping", nil, nil); err != nil {
+		t.Errorf("Expected ping answered without a handler, got %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+	defer cancel()
+	if err := client.Call(ctx, "wait", nil, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the call cancelled, got %v", err)
+	}
+
+	serverW.Close()
+	<-client.Done()
+	if err := client.Call(t.Context(), "add", []int{1, 1}, nil); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected ErrClosed after the peer went away, got %v", err)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "mcp.json")
+	write := func(s string) {
+		if err := os.WriteFile(path, []byte(s), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if servers, err := LoadConfig(path); err != nil || servers != nil {
+		t.Errorf("Expected no servers without a file, got %v, %v", servers, err)
+	}
+
+	write(`{"mcpServers": {
+		"zeta": {"command": "z", "args": ["stdio"], "env": {"TOKEN": "x"}, "cwd": "servers"},
+		"alpha": {"command": "a", "type": "stdio", "timeout": 60000},
+		"off": {"command": "o", "disabled": true}
+	}}`)
+	servers, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if len(servers) != 2 || servers[0].Name != "alpha" || servers[1].Name != "zeta" {
+		t.Fatalf("Expected alpha and zeta, got %+v", servers)
+	}
+	if z := servers[1]; z.Args[0] != "stdio" || z.Env["TOKEN"] != "x" || z.Dir != filepath.Join(dir, "servers") {
+		t.Errorf("Expected zeta's settings kept, got %+v", z)
+	}
+
+	for _, bad := range []string{
+		`{"mcpServers": {"bad name": {"command": "x"}}}`,
+		`{"mcpServers": {"nocmd": {}}}`,
+		`{"mcpServers": {"remote": {"type": "http", "url": "https://example.com/mcp"}}}`,
+		`{"mcpServers":`,
+	} {
+		write(bad)
+		if _, err := LoadConfig(path); err == nil {
+			t.Errorf("Expected %s rejected", bad)
+		}
+	}
+}
+
+func TestClient(t *testing.T) {
+	c := startFake(t, nil)
+	if err := c.Start(t.Context()); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if status, err := c.Status(); status != StatusConnected || err != nil {
+		t.Errorf("Expected connected, got %s, %v", status, err)
+	}
+	if info := c.ServerInfo(); info.Name != "fakemcp" {
+		t.Errorf("Expected the server's info, got %+v", info)
+	}
+	tools := c.Tools()
+	if len(tools) != 6 {
+		t.Fatalf("Expected every page of tools listed, got %d", len(tools))
+	}
+	if !tools[0].ReadOnly() || tools[1].ReadOnly() {
+		t.Error("Expected the read-only hint kept")
+	}
+
+	if out, err := c.CallTool(t.Context(), "echo", json.RawMessage(`{"text":"hi"}`)); err != nil || out != "hi" {
+		t.Errorf("Expected hi, got %q, %v", out, err)
+	}
+	if _, err := c.CallTool(t.Context(), "fail", nil); err == nil || err.Error() != "it broke" {
+		t.Errorf("Expected the tool's error, got %v", err)
+	}
+	var rpcErr *Error
+	if _, err := c.CallTool(t.Context(), "missing", nil); !errors.As(err, &rpcErr) {
+		t.Errorf("Expected a protocol error, got %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := c.CallTool(ctx, "slow", nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected a slow call to stop at its deadline, got %v", err)
+	}
+}
+
+func TestClientRestart(t *testing.T) {
+	changes := make(chan struct{}, 100)
+	c := NewClient(ServerConfig{Name: "fake", Command: fakeServer}, func() { changes <- struct{}{} })
+	t.Cleanup(c.Close)
+	if err := c.Start(t.Context()); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	first, err := c.CallTool(t.Context(), "pid", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = c.CallTool(t.Context(), "crash", nil)
+	if err == nil || !strings.Contains(err.Error(), "exited during the call") || !strings.Contains(err.Error(), "fakemcp: crashing") {
+		t.Errorf("Expected the crash reported with stderr, got %v", err)
+	}
+	if len(changes) == 0 {
+		t.Error("Expected status changes reported")
+	}
+
+	second, err := c.CallTool(t.Context(), "pid", nil)
+	if err != nil || second == first {
+		t.Errorf("Expected a new server process, got %q after %q, %v", second, first, err)
+	}
+
+	if err := c.Restart(t.Context()); err != nil {
+		t.Fatalf("Restart failed: %v", err)
+	}
+	third, err := c.CallTool(t.Context(), "pid", nil)
+	if err != nil || third == second {
+		t.Errorf("Expected Restart to start a new process, got %q after %q, %v", third, second, err)
+	}
+}
+
+func TestClientAutoRestart(t *testing.T) {
+	var c *Client
+	statuses := make(chan Status, 100)
+	c = NewClient(ServerConfig{Name: "fake", Command: fakeServer}, func() {
+		status, _ := c.Status()
+		statuses <- status
+	})
+	t.Cleanup(c.Close)
+	if err := c.Start(t.Context()); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	_, _ = c.CallTool(t.Context(), "crash", nil)
+
+	var seen []Status
+	timeout := time.After(5 * time.Second)
+	for !slices.Contains(seen, StatusDisconnected) || seen[len(seen)-1] != StatusConnected {
+		select {
+		case status := <-statuses:
+			seen = append(seen, status)
+		case <-timeout:
+			t.Fatalf("Expected the server restarted, saw %v", seen)
+		}
+	}
+	if len(c.Tools()) != 6 {
+		t.Errorf("Expected the tools listed again, got %d", len(c.Tools()))
+	}
+}
+
+func TestClientStartFailure(t *testing.T) {
+	c := startFake(t, map[string]string{"FAKEMCP_FAIL_INIT": "1"})
+	err := c.Start(t.Context())
+	if err == nil || !strings.Contains(err.Error(), "refusing to start") {
+		t.Errorf("Expected the failure with stderr, got %v", err)
+	}
+	if status, _ := c.Status(); status != StatusFailed {
+		t.Errorf("Expected failed, got %s", status)
+	}
+
+	missing := NewClient(ServerConfig{Name: "missing", Command: filepath.Join(t.TempDir(), "nope")}, nil)
+	if err := missing.Start(t.Context()); err == nil {
+		t.Error("Expected a missing command to fail")
+	}
+}
+
+func TestManager(t *testing.T) {
+	m := NewManager([]ServerConfig{
+		{Name: "fake", Command: fakeServer},
+		{Name: "broken", Command: fakeServer, Env: map[string]string{"FAKEMCP_FAIL_INIT": "1"}},
+	})
+	t.Cleanup(m.Close)
+
+	errs := m.Start(t.Context())
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "MCP server broken") {
+		t.Errorf("Expected only broken to fail, got %v", errs)
+	}
+	if m.Connected() != 1 || m.Len() != 2 {
+		t.Errorf("Expected 1 of 2 connected, got %d of %d", m.Connected(), m.Len())
+	}
+	statuses := m.Statuses()
+	if statuses[0].Name != "fake" || statuses[0].Tools != 6 || statuses[1].Status != StatusFailed || statuses[1].Err == nil {
+		t.Errorf("Unexpected statuses %+v", statuses)
+	}
+
+	tools := m.Tools()
+	if len(tools) != 6 || tools[0].Name != "mcp__fake__echo" || tools[0].Tool.Name != "echo" {
+		t.Fatalf("Expected qualified tool names, got %+v", tools)
+	}
+	if _, ok := m.Lookup("mcp__fake__dotted_name"); !ok {
+		t.Error("Expected names sanitized for the model")
+	}
+	if out, err := m.Call(t.Context(), "mcp__fake__dotted_name", nil); err != nil || out != "dotted" {
+		t.Errorf("Expected the call routed by its server's tool name, got %q, %v", out, err)
+	}
+	if _, err := m.Call(t.Context(), "mcp__fake__nope", nil); err == nil {
+		t.Error("Expected an unknown tool rejected")
+	}
+	if err := m.Restart(t.Context(), "nope"); err == nil {
+		t.Error("Expected an unknown server rejected")
+	}
+
+	if got := QualifiedName("s", strings.Repeat("x", 100)); len(got) != 64 {
+		t.Errorf("Expected names capped at 64 characters, got %d", len(got))
+	}
+}
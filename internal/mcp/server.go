@@ -0,0 +1,198 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/biisal/godo/internal/plugin"
+)
+
+// ErrResourceNotFound is returned by a ResourceProvider for URIs it does
+// not know.
+var ErrResourceNotFound = errors.New("resource not found")
+
+// supportedVersions are the protocol revisions the server can speak, newest
+// first.
+var supportedVersions = []string{ProtocolVersion, "2025-03-26", "2024-11-05"}
+
+// ToolHandler runs a tool call with its JSON arguments. A string result is
+// sent as text and anything else as JSON; an error is reported to the
+// client as a failed call rather than a protocol error, so the model sees
+// it.
+type ToolHandler func(ctx context.Context, args json.RawMessage) (any, error)
+
+// ResourceProvider lists and reads the resources of a server.
+type ResourceProvider interface {
+	Resources() ([]Resource, error)
+	// Read returns the contents of uri, or ErrResourceNotFound.
+	Read(uri string) ([]ResourceContents, error)
+}
+
+// Server offers tools and resources to an MCP client over one connection.
+type Server struct {
+	info         Implementation
+	instructions string
+	tools        []Tool
+	handlers     map[string]ToolHandler
+	resources    ResourceProvider
+}
+
+// NewServer returns a server that introduces itself as info, with
+// instructions telling the client's model how to use it.
+func NewServer(info Implementation, instructions string) *Server {
+	return &Server{info: info, instructions: instructions, handlers: map[string]ToolHandler{}}
+}
+
+// AddTool offers tool, run by handler. Arguments are checked against the
+// tool's input schema before handler sees them.
+func (s *Server) AddTool(tool Tool, handler ToolHandler) {
+	if tool.InputSchema == nil {
+		tool.InputSchema = map[string]any{"type": "object", "properties": map[string]any{}}
+	}
+	// Validate expects schemas as decoded from JSON, with []any rather
+	// than the []string a schema written in Go may hold.
+	var schema map[string]any
+	if err := json.Unmarshal(marshal(tool.InputSchema), &schema); err != nil {
+		panic(fmt.Sprintf("mcp: invalid schema for %s: %v", tool.Name, err))
+	}
+	tool.InputSchema = schema
+	s.tools = append(s.tools, tool)
+	s.handlers[tool.Name] = handler
+}
+
+// SetResources offers the resources of p.
+func (s *Server) SetResources(p ResourceProvider) {
+	s.resources = p
+}
+
+// Serve answers requests read from r on w until the client closes r or
+// ctx is cancelled.
+func (s *Server) Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	conn := NewConn(r, w, s.handle)
+	select {
+	case <-conn.Done():
+		if err := conn.Err(); !errors.Is(err, ErrClosed) {
+			return err
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *Server) handle(ctx context.Context, method string, params json.RawMessage) (any, error) {
+	switch method {
+	case "initialize":
+		var p initializeParams
+		if err := decodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		version := ProtocolVersion
+		if slices.Contains(supportedVersions, p.ProtocolVersion) {
+			version = p.ProtocolVersion
+		}
+		capabilities := map[string]any{"tools": map[string]any{}}
+		if s.resources != nil {
+			capabilities["resources"] = map[string]any{}
+		}
+		return initializeResult{
+			ProtocolVersion: version,
+			Capabilities:    capabilities,
+			ServerInfo:      s.info,
+			Instructions:    s.instructions,
+		}, nil
+	case "notifications/initialized":
+		return nil, nil
+	case "tools/list":
+		return listToolsResult{Tools: s.tools}, nil
+	case "tools/call":
+		return s.callTool(ctx, params)
+	case "resources/list":
+		if s.resources == nil {
+			break
+		}
+		resources, err := s.resources.Resources()
+		if err != nil {
+			return nil, err
+		}
+		return listResourcesResult{Resources: resources}, nil
+	case "resources/read":
+		if s.resources == nil {
+			break
+		}
+		var p readResourceParams
+		if err := decodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		contents, err := s.resources.Read(p.URI)
+		if errors.Is(err, ErrResourceNotFound) {
+			return nil, &Error{Code: CodeResourceNotFound, Message: "resource not found: " + p.URI}
+		}
+		if err != nil {
+			return nil, err
+		}
+		return readResourceResult{Contents: contents}, nil
+	case "resources/templates/list":
+		if s.resources == nil {
+			break
+		}
+		return map[string]any{"resourceTemplates": []any{}}, nil
+	}
+	if strings.HasPrefix(method, "notifications/") {
+		return nil, nil
+	}
+	return nil, &Error{Code: CodeMethodNotFound, Message: "method not found: " + method}
+}
+
+func (s *Server) callTool(ctx context.Context, params json.RawMessage) (*CallToolResult, error) {
+	var p callToolParams
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	handler, ok := s.handlers[p.Name]
+	if !ok {
+		return nil, &Error{Code: CodeInvalidParams, Message: "unknown tool: " + p.Name}
+	}
+	args := p.Arguments
+	if len(args) == 0 || string(args) == "null" {
+		args = json.RawMessage("{}")
+	}
+	var value any
+	if err := json.Unmarshal(args, &value); err != nil {
+		return nil, &Error{Code: CodeInvalidParams, Message: "invalid arguments: " + err.Error()}
+	}
+	i := slices.IndexFunc(s.tools, func(t Tool) bool { return t.Name == p.Name })
+	if err := plugin.Validate(s.tools[i].InputSchema, value); err != nil {
+		return nil, &Error{Code: CodeInvalidParams, Message: "invalid arguments: " + err.Error()}
+	}
+
+	out, err := handler(ctx, args)
+	if err != nil {
+		return TextResult(err.Error(), true), nil
+	}
+	if text, ok := out.(string); ok {
+		return TextResult(text, false), nil
+	}
+	data, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode the result of %s: %w", p.Name, err)
+	}
+	result := TextResult(string(data), false)
+	result.StructuredContent = out
+	return result, nil
+}
+
+func decodeParams(params json.RawMessage, v any) error {
+	if len(params) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(params, v); err != nil {
+		return &Error{Code: CodeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
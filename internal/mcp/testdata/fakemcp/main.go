@@ -0,0 +1,136 @@
+// Command fakemcp is a small MCP server for tests. It speaks the protocol
+// by hand rather than through package mcp, so the client is checked against
+// an independent implementation.
+//
+// FAKEMCP_FAIL_INIT makes it exit before answering initialize.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  any             `json:"result,omitempty"`
+	Error   any             `json:"error,omitempty"`
+}
+
+var tools = []map[string]any{
+	{
+		"name":        "echo",
+		"description": "Echo text back.",
+		"inputSchema": map[string]any{
+			"type":       "object",
+			"properties": map[string]any{"text": map[string]any{"type": "string"}},
+			"required":   []string{"text"},
+		},
+		"annotations": map[string]any{"readOnlyHint": true},
+	},
+	{"name": "fail", "description": "Report an error.", "inputSchema": map[string]any{"type": "object"}},
+	{"name": "crash", "description": "Exit mid-call.", "inputSchema": map[string]any{"type": "object"}},
+	{"name": "pid", "description": "Print the process id.", "inputSchema": map[string]any{"type": "object"}},
+	{"name": "slow", "description": "Sleep for a while.", "inputSchema": map[string]any{"type": "object"}},
+	{"name": "dotted.name", "description": "A name models reject.", "inputSchema": map[string]any{"type": "object"}},
+}
+
+var out = struct {
+	sync.Mutex
+	enc *json.Encoder
+}{enc: json.NewEncoder(os.Stdout)}
+
+func send(msg message) {
+	msg.JSONRPC = "2.0"
+	out.Lock()
+	defer out.Unlock()
+	_ = out.enc.Encode(msg)
+}
+
+func text(s string, isError bool) map[string]any {
+	return map[string]any{"content": []map[string]any{{"type": "text", "text": s}}, "isError": isError}
+}
+
+func main() {
+	if os.Getenv("FAKEMCP_FAIL_INIT") != "" {
+		fmt.Fprintln(os.Stderr, "fakemcp: refusing to start")
+		os.Exit(2)
+	}
+	initialized := false
+	sc := bufio.NewScanner(os.Stdin)
+	for sc.Scan() {
+		var msg message
+		if err := json.Unmarshal(sc.Bytes(), &msg); err != nil {
+			send(message{ID: json.RawMessage("null"), Error: map[string]any{"code": -32700, "message": "parse error"}})
+			continue
+		}
+		switch msg.Method {
+		case "initialize":
+			send(message{ID: msg.ID, Result: map[string]any{
+				"protocolVersion": "2025-06-18",
+				"capabilities":    map[string]any{"tools": map[string]any{}},
+				"serverInfo":      map[string]any{"name": "fakemcp", "version": "0.1"},
+			}})
+		case "notifications/initialized":
+			initialized = true
+		case "tools/list":
+			if !initialized {
+				send(message{ID: msg.ID, Error: map[string]any{"code": -32600, "message": "not initialized"}})
+				continue
+			}
+			// Two tools a page, to exercise pagination.
+			var p struct {
+				Cursor string `json:"cursor"`
+			}
+			_ = json.Unmarshal(msg.Params, &p)
+			start, _ := strconv.Atoi(p.Cursor)
+			end := min(start+2, len(tools))
+			result := map[string]any{"tools": tools[start:end]}
+			if end < len(tools) {
+				result["nextCursor"] = strconv.Itoa(end)
+			}
+			send(message{ID: msg.ID, Result: result})
+		case "tools/call":
+			go call(msg)
+		case "ping":
+			send(message{ID: msg.ID, Result: map[string]any{}})
+		default:
+			if msg.ID != nil {
+				send(message{ID: msg.ID, Error: map[string]any{"code": -32601, "message": "method not found: " + msg.Method}})
+			}
+		}
+	}
+}
+
+func call(msg message) {
+	var p struct {
+		Name      string         `json:"name"`
+		Arguments map[string]any `json:"arguments"`
+	}
+	_ = json.Unmarshal(msg.Params, &p)
+	switch p.Name {
+	case "echo":
+		send(message{ID: msg.ID, Result: text(fmt.Sprint(p.Arguments["text"]), false)})
+	case "fail":
+		send(message{ID: msg.ID, Result: text("it broke", true)})
+	case "crash":
+		fmt.Fprintln(os.Stderr, "fakemcp: crashing")
+		os.Exit(1)
+	case "pid":
+		send(message{ID: msg.ID, Result: text(strconv.Itoa(os.Getpid()), false)})
+	case "slow":
+		time.Sleep(10 * time.Second)
+		send(message{ID: msg.ID, Result: text("done", false)})
+	case "dotted.name":
+		send(message{ID: msg.ID, Result: text("dotted", false)})
+	default:
+		send(message{ID: msg.ID, Error: map[string]any{"code": -32602, "message": "unknown tool " + p.Name}})
+	}
+}
@@ -0,0 +1,332 @@
+// Package mcpserve exposes godo's todos and memories to other AI clients as
+// an MCP server: todo and memory tools, and the todos as resources.
+package mcpserve
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/biisal/godo/internal/mcp"
+	"github.com/biisal/godo/internal/store"
+	"github.com/biisal/godo/internal/tui/actions/todo"
+	todoModel "github.com/biisal/godo/internal/tui/models/todo"
+)
+
+const (
+	// TodosURI lists every todo; TodoURIPrefix followed by an id is one.
+	TodosURI      = "godo://todos"
+	TodoURIPrefix = "godo://todos/"
+)
+
+const instructions = `godo is the user's todo list and long-term memory.
+Use the todo tools to list, add, update, toggle and delete todos, and save_memory and search_memories for facts and preferences worth keeping across conversations.`
+
+// New returns a server for todos and memories.
+func New(todos *todo.Service, memories store.MemoryStore, version string) *mcp.Server {
+	h := &handlers{todos: todos, memories: memories}
+	s := mcp.NewServer(mcp.Implementation{Name: "godo", Version: version}, instructions)
+	readOnly := &mcp.ToolAnnotations{ReadOnlyHint: true}
+	destructive := &mcp.ToolAnnotations{DestructiveHint: true}
+
+	s.AddTool(mcp.Tool{
+		Name:        "list_todos",
+		Title:       "List todos",
+		Description: "List the user's todos, newest first, with optional filters. Returns id, title, description and done for each, plus total, completed and pending counts.",
+		InputSchema: object(map[string]any{
+			"status": map[string]any{"type": "string", "enum": []string{"all", "open", "done"}, "description": "Only return open or done todos. Defaults to all."},
+			"query":  map[string]any{"type": "string", "description": "Case-insensitive text to match in the title or description."},
+			"limit":  map[string]any{"type": "integer", "minimum": 1, "description": "Maximum number of todos to return."},
+		}),
+		Annotations: readOnly,
+	}, h.listTodos)
+	s.AddTool(mcp.Tool{
+		Name:        "add_todo",
+		Title:       "Add todo",
+		Description: "Add a todo. Title and description are both required and cannot be blank. Returns the new todo with its id.",
+		InputSchema: object(map[string]any{
+			"title":       map[string]any{"type": "string", "description": "Short title of the todo."},
+			"description": map[string]any{"type": "string", "description": "Details of the todo."},
+		}, "title", "description"),
+	}, h.addTodo)
+	s.AddTool(mcp.Tool{
+		Name:        "update_todo",
+		Title:       "Update todo",
+		Description: "Update a todo by id. Only the fields passed are changed; done marks it complete or pending. Returns the updated todo.",
+		InputSchema: object(map[string]any{
+			"id":          map[string]any{"type": "integer", "description": "Id of the todo."},
+			"title":       map[string]any{"type": "string", "description": "New title. Cannot be blank."},
+			"description": map[string]any{"type": "string", "description": "New description. Cannot be blank."},
+			"done":        map[string]any{"type": "boolean", "description": "Whether the todo is complete."},
+		}, "id"),
+	}, h.updateTodo)
+	s.AddTool(mcp.Tool{
+		Name:        "toggle_todo",
+		Title:       "Toggle todo",
+		Description: "Flip a todo between done and pending. Returns its new done state.",
+		InputSchema: object(map[string]any{
+			"id": map[string]any{"type": "integer", "description": "Id of the todo."},
+		}, "id"),
+	}, h.toggleTodo)
+	s.AddTool(mcp.Tool{
+		Name:        "delete_todo",
+		Title:       "Delete todo",
+		Description: "Delete a todo by id. Returns the deleted todo.",
+		InputSchema: object(map[string]any{
+			"id": map[string]any{"type": "integer", "description": "Id of the todo."},
+		}, "id"),
+		Annotations: destructive,
+	}, h.deleteTodo)
+	s.AddTool(mcp.Tool{
+		Name:        "save_memory",
+		Title:       "Save memory",
+		Description: "Save a fact or preference to the user's long-term memory. A memory with the same key is replaced.",
+		InputSchema: object(map[string]any{
+			"key":     map[string]any{"type": "string", "minLength": 1, "description": "Short label such as 'favorite_language'."},
+			"content": map[string]any{"type": "string", "minLength": 1, "description": "The fact or preference."},
+		}, "key", "content"),
+	}, h.saveMemory)
+	s.AddTool(mcp.Tool{
+		Name:        "search_memories",
+		Title:       "Search memories",
+		Description: "Search the user's memories by keyword in their keys and content. An empty query lists them all.",
+		InputSchema: object(map[string]any{
+			"query": map[string]any{"type": "string", "description": "Keyword to match; empty lists every memory."},
+		}),
+		Annotations: readOnly,
+	}, h.searchMemories)
+
+	s.SetResources(&resources{todos: todos})
+	return s
+}
+
+func object(properties map[string]any, required ...string) map[string]any {
+	schema := map[string]any{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+type handlers struct {
+	todos    *todo.Service
+	memories store.MemoryStore
+}
+
+func (h *handlers) listTodos(ctx context.Context, raw json.RawMessage) (any, error) {
+	var args struct {
+		Status string `json:"status"`
+		Query  string `json:"query"`
+		Limit  int    `json:"limit"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return nil, fmt.Errorf("invalid tool arguments: %w", err)
+	}
+	todos, err := h.todos.ListTodos(todo.Filter{Status: args.Status, Query: args.Query, Limit: args.Limit})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list todos: %w", err)
+	}
+	total, completed, pending, err := h.todos.GetTodosInfo()
+	if err != nil {
+		return nil, fmt.Errorf("failed to count todos: %w", err)
+	}
+	return map[string]any{
+		"count":     len(todos),
+		"todos":     todos,
+		"total":     total,
+		"completed": completed,
+		"pending":   pending,
+	}, nil
+}
+
+func (h *handlers) addTodo(ctx context.Context, raw json.RawMessage) (any, error) {
+	var args struct {
+		Title       string `json:"title"`
+		Description string `json:"description"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return nil, fmt.Errorf("invalid tool arguments: %w", err)
+	}
+	added, err := h.todos.AddTodo(args.Title, args.Description)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add todo: %w", err)
+	}
+	added.Store = h.todos.Name()
+	return map[string]any{"todo": added}, nil
+}
+
+func (h *handlers) updateTodo(ctx context.Context, raw json.RawMessage) (any, error) {
+	var args struct {
+		ID          int     `json:"id"`
+		Title       *string `json:"title"`
+		Description *string `json:"description"`
+		Done        *bool   `json:"done"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return nil, fmt.Errorf("invalid tool arguments: %w", err)
+	}
+	if args.Title == nil && args.Description == nil && args.Done == nil {
+		return nil, errors.New("nothing to update: pass title, description or done")
+	}
+	current, err := h.todos.GetTodoById(args.ID)
+	if err != nil {
+		return nil, err
+	}
+	if args.Title != nil || args.Description != nil {
+		title, description := current.TitleText, current.DescriptionText
+		if args.Title != nil {
+			title = *args.Title
+		}
+		if args.Description != nil {
+			description = *args.Description
+		}
+		if _, err := h.todos.ModifyTodo(args.ID, title, description); err != nil {
+			return nil, fmt.Errorf("failed to update todo: %w", err)
+		}
+	}
+	if args.Done != nil {
+		if _, err := h.todos.SetDone(args.ID, *args.Done); err != nil {
+			return nil, fmt.Errorf("failed to update todo: %w", err)
+		}
+	}
+	updated, err := h.todos.GetTodoById(args.ID)
+	if err != nil {
+		return nil, err
+	}
+	updated.Store = h.todos.Name()
+	return map[string]any{"todo": updated}, nil
+}
+
+func (h *handlers) toggleTodo(ctx context.Context, raw json.RawMessage) (any, error) {
+	var args struct {
+		ID int `json:"id"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return nil, fmt.Errorf("invalid tool arguments: %w", err)
+	}
+	done, err := h.todos.ToggleDone(args.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to toggle todo: %w", err)
+	}
+	return map[string]any{"id": args.ID, "done": done}, nil
+}
+
+func (h *handlers) deleteTodo(ctx context.Context, raw json.RawMessage) (any, error) {
+	var args struct {
+		ID int `json:"id"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return nil, fmt.Errorf("invalid tool arguments: %w", err)
+	}
+	deleted, err := h.todos.GetTodoById(args.ID)
+	if err != nil {
+		return nil, err
+	}
+	deleted.Store = h.todos.Name()
+	if _, err := h.todos.DeleteTodo(args.ID); err != nil {
+		return nil, fmt.Errorf("failed to delete todo: %w", err)
+	}
+	return map[string]any{"todo": deleted}, nil
+}
+
+func (h *handlers) saveMemory(ctx context.Context, raw json.RawMessage) (any, error) {
+	var args struct {
+		Key     string `json:"key"`
+		Content string `json:"content"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return nil, fmt.Errorf("invalid tool arguments: %w", err)
+	}
+	key, content := strings.TrimSpace(args.Key), strings.TrimSpace(args.Content)
+	if key == "" || content == "" {
+		return nil, errors.New("key and content can't be empty")
+	}
+	if err := h.memories.Save(key, content); err != nil {
+		return nil, fmt.Errorf("failed to save memory: %w", err)
+	}
+	return map[string]any{"key": key, "saved": true}, nil
+}
+
+func (h *handlers) searchMemories(ctx context.Context, raw json.RawMessage) (any, error) {
+	var args struct {
+		Query string `json:"query"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return nil, fmt.Errorf("invalid tool arguments: %w", err)
+	}
+	entries, err := h.memories.Search(args.Query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search memories: %w", err)
+	}
+	results := make([]map[string]string, 0, len(entries))
+	for _, e := range entries {
+		results = append(results, map[string]string{"key": e.Key, "content": e.Content})
+	}
+	return map[string]any{"count": len(results), "results": results}, nil
+}
+
+// resources offers the todo list and each todo.
+type resources struct {
+	todos *todo.Service
+}
+
+func (r *resources) Resources() ([]mcp.Resource, error) {
+	todos, err := r.todos.GetTodos()
+	if err != nil {
+		return nil, err
+	}
+	list := []mcp.Resource{{
+		URI:         TodosURI,
+		Name:        "todos",
+		Title:       "Todos",
+		Description: "Every todo as JSON, newest first.",
+		MimeType:    "application/json",
+	}}
+	for _, t := range todos {
+		list = append(list, mcp.Resource{
+			URI:      TodoURIPrefix + strconv.Itoa(t.ID),
+			Name:     "todo-" + strconv.Itoa(t.ID),
+			Title:    t.TitleText,
+			MimeType: "text/markdown",
+		})
+	}
+	return list, nil
+}
+
+func (r *resources) Read(uri string) ([]mcp.ResourceContents, error) {
+	if uri == TodosURI {
+		todos, err := r.todos.ListTodos(todo.Filter{})
+		if err != nil {
+			return nil, err
+		}
+		data, err := json.MarshalIndent(todos, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return []mcp.ResourceContents{{URI: uri, MimeType: "application/json", Text: string(data)}}, nil
+	}
+
+	id, err := strconv.Atoi(strings.TrimPrefix(uri, TodoURIPrefix))
+	if !strings.HasPrefix(uri, TodoURIPrefix) || err != nil {
+		return nil, mcp.ErrResourceNotFound
+	}
+	t, err := r.todos.GetTodoById(id)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, mcp.ErrResourceNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []mcp.ResourceContents{{URI: uri, MimeType: "text/markdown", Text: todoMarkdown(t)}}, nil
+}
+
+func todoMarkdown(t *todoModel.Todo) string {
+	box := " "
+	if t.Done {
+		box = "x"
+	}
+	return fmt.Sprintf("- [%s] %s (#%d)\n\n%s\n", box, t.TitleText, t.ID, t.DescriptionText)
+}
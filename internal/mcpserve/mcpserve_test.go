@@ -0,0 +1,230 @@
+package mcpserve
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/biisal/godo/internal/mcp"
+	"github.com/biisal/godo/internal/store"
+	"github.com/biisal/godo/internal/tui/actions/todo"
+)
+
+// connect serves a server over pipes and returns a connection talking to
+// it as a client would.
+func connect(t *testing.T) (*mcp.Conn, *todo.Service, *store.FakeMemoryStore) {
+	t.Helper()
+	todos := todo.NewService(store.NewFakeTodoStore(), "global")
+	memories := store.NewFakeMemoryStore()
+	server := New(todos, memories, "test")
+
+	clientR, serverW := io.Pipe()
+	serverR, clientW := io.Pipe()
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- server.Serve(ctx, serverR, serverW) }()
+	t.Cleanup(func() {
+		clientW.Close()
+		if err := <-served; err != nil {
+			t.Errorf("Serve failed: %v", err)
+		}
+		cancel()
+	})
+	return mcp.NewConn(clientR, clientW, nil), todos, memories
+}
+
+// callTool calls name and returns its result decoded from JSON text, or the
+// error text of a failed call.
+func callTool(t *testing.T, conn *mcp.Conn, name, args string) (map[string]any, string) {
+	t.Helper()
+	var result mcp.CallToolResult
+	params := map[string]any{"name": name, "arguments": json.RawMessage(args)}
+	if err := conn.Call(t.Context(), "tools/call", params, &result); err != nil {
+		t.Fatalf("%s failed: %v", name, err)
+	}
+	if result.IsError {
+		return nil, result.Text()
+	}
+	var out map[string]any
+	if err := json.Unmarshal([]byte(result.Text()), &out); err != nil {
+		t.Fatalf("%s returned %q: %v", name, result.Text(), err)
+	}
+	return out, ""
+}
+
+func TestInitialize(t *testing.T) {
+	conn, _, _ := connect(t)
+	var init struct {
+		ProtocolVersion string         `json:"protocolVersion"`
+		Capabilities    map[string]any `json:"capabilities"`
+		ServerInfo      mcp.Implementation
+		Instructions    string
+	}
+	params := map[string]any{"protocolVersion": "2024-11-05", "capabilities": map[string]any{}, "clientInfo": map[string]any{"name": "test", "version": "1"}}
+	if err := conn.Call(t.Context(), "initialize", params, &init); err != nil {
+		t.Fatalf("initialize failed: %v", err)
+	}
+	if init.ProtocolVersion != "2024-11-05" {
+		t.Errorf("Expected the client's supported version kept, got %s", init.ProtocolVersion)
+	}
+	if init.ServerInfo.Name != "godo" || init.Capabilities["tools"] == nil || init.Capabilities["resources"] == nil || init.Instructions == "" {
+		t.Errorf("Unexpected initialize result %+v", init)
+	}
+
+	params["protocolVersion"] = "1999-01-01"
+	if err := conn.Call(t.Context(), "initialize", params, &init); err != nil || init.ProtocolVersion != mcp.ProtocolVersion {
+		t.Errorf("Expected an unknown version answered with ours, got %s, %v", init.ProtocolVersion, err)
+	}
+	if err := conn.Notify("notifications/initialized", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Call(t.Context(), "ping", nil, nil); err != nil {
+		t.Errorf("Expected ping answered, got %v", err)
+	}
+	var rpcErr *mcp.Error
+	if err := conn.Call(t.Context(), "prompts/list", nil, nil); !errors.As(err, &rpcErr) || rpcErr.Code != mcp.CodeMethodNotFound {
+		t.Errorf("Expected method not found, got %v", err)
+	}
+
+	var list struct {
+		Tools []mcp.Tool `json:"tools"`
+	}
+	if err := conn.Call(t.Context(), "tools/list", nil, &list); err != nil {
+		t.Fatalf("tools/list failed: %v", err)
+	}
+	var names []string
+	for _, tool := range list.Tools {
+		names = append(names, tool.Name)
+	}
+	want := "list_todos add_todo update_todo toggle_todo delete_todo save_memory search_memories"
+	if got := strings.Join(names, " "); got != want {
+		t.Errorf("Expected tools %s, got %s", want, got)
+	}
+	if !list.Tools[0].ReadOnly() || list.Tools[1].ReadOnly() {
+		t.Error("Expected list_todos marked read-only")
+	}
+}
+
+func TestTodoTools(t *testing.T) {
+	conn, todos, _ := connect(t)
+
+	out, errText := callTool(t, conn, "add_todo", `{"title": "Write docs", "description": "For the MCP server"}`)
+	if errText != "" {
+		t.Fatalf("add_todo failed: %s", errText)
+	}
+	added := out["todo"].(map[string]any)
+	if added["id"] != 1.0 || added["title"] != "Write docs" || added["store"] != "global" {
+		t.Errorf("Unexpected added todo %v", added)
+	}
+	callTool(t, conn, "add_todo", `{"title": "Ship it", "description": "Release"}`)
+
+	if _, errText := callTool(t, conn, "add_todo", `{"title": " ", "description": "x"}`); errText == "" {
+		t.Error("Expected a blank title reported as a failed call")
+	}
+
+	if out, errText = callTool(t, conn, "update_todo", `{"id": 1, "title": "Write more docs", "done": true}`); errText != "" {
+		t.Fatalf("update_todo failed: %s", errText)
+	}
+	if updated := out["todo"].(map[string]any); updated["title"] != "Write more docs" || updated["done"] != true {
+		t.Errorf("Unexpected updated todo %v", updated)
+	}
+	if out, _ = callTool(t, conn, "toggle_todo", `{"id": 2}`); out["done"] != true {
+		t.Errorf("Expected todo 2 toggled done, got %v", out)
+	}
+
+	out, _ = callTool(t, conn, "list_todos", `{"query": "docs"}`)
+	if out["count"] != 1.0 || out["total"] != 2.0 || out["completed"] != 2.0 {
+		t.Errorf("Unexpected list %v", out)
+	}
+
+	if out, _ = callTool(t, conn, "delete_todo", `{"id": 2}`); out["todo"].(map[string]any)["title"] != "Ship it" {
+		t.Errorf("Expected the deleted todo returned, got %v", out)
+	}
+	if _, errText := callTool(t, conn, "delete_todo", `{"id": 2}`); !strings.Contains(errText, "not found") {
+		t.Errorf("Expected a missing todo reported, got %q", errText)
+	}
+	if remaining, _ := todos.GetTodos(); len(remaining) != 1 {
+		t.Errorf("Expected one todo left, got %v", remaining)
+	}
+
+	var rpcErr *mcp.Error
+	for _, tt := range []struct{ name, args, want string }{
+		{"toggle_todo", `{"id": "one"}`, "$.id: expected integer, got string"},
+		{"add_todo", `{"title": "x"}`, `missing required property "description"`},
+		{"list_todos", `{"status": "later"}`, "must be one of"},
+		{"nope", `{}`, "unknown tool: nope"},
+	} {
+		params := map[string]any{"name": tt.name, "arguments": json.RawMessage(tt.args)}
+		err := conn.Call(t.Context(), "tools/call", params, nil)
+		if !errors.As(err, &rpcErr) || rpcErr.Code != mcp.CodeInvalidParams || !strings.Contains(rpcErr.Message, tt.want) {
+			t.Errorf("%s %s: expected invalid params mentioning %q, got %v", tt.name, tt.args, tt.want, err)
+		}
+	}
+}
+
+func TestMemoryTools(t *testing.T) {
+	conn, _, memories := connect(t)
+
+	if _, errText := callTool(t, conn, "save_memory", `{"key": "editor", "content": "Uses helix"}`); errText != "" {
+		t.Fatalf("save_memory failed: %s", errText)
+	}
+	callTool(t, conn, "save_memory", `{"key": "shell", "content": "fish"}`)
+	if _, errText := callTool(t, conn, "save_memory", `{"key": " ", "content": "x"}`); errText == "" {
+		t.Error("Expected a blank key rejected")
+	}
+	if entries, _ := memories.GetAll(); len(entries) != 2 {
+		t.Errorf("Expected two memories stored, got %v", entries)
+	}
+
+	out, _ := callTool(t, conn, "search_memories", `{"query": "HELIX"}`)
+	results := out["results"].([]any)
+	if out["count"] != 1.0 || results[0].(map[string]any)["key"] != "editor" {
+		t.Errorf("Unexpected search result %v", out)
+	}
+	if out, _ = callTool(t, conn, "search_memories", `{}`); out["count"] != 2.0 {
+		t.Errorf("Expected an empty query to list every memory, got %v", out)
+	}
+}
+
+func TestTodoResources(t *testing.T) {
+	conn, todos, _ := connect(t)
+	if _, err := todos.AddTodo("Write docs", "For the MCP server"); err != nil {
+		t.Fatal(err)
+	}
+
+	var list struct {
+		Resources []mcp.Resource `json:"resources"`
+	}
+	if err := conn.Call(t.Context(), "resources/list", nil, &list); err != nil {
+		t.Fatalf("resources/list failed: %v", err)
+	}
+	if len(list.Resources) != 2 || list.Resources[0].URI != TodosURI || list.Resources[1].URI != "godo://todos/1" || list.Resources[1].Title != "Write docs" {
+		t.Fatalf("Unexpected resources %+v", list.Resources)
+	}
+
+	read := func(uri string) (string, error) {
+		var result struct {
+			Contents []mcp.ResourceContents `json:"contents"`
+		}
+		err := conn.Call(t.Context(), "resources/read", map[string]string{"uri": uri}, &result)
+		if err != nil {
+			return "", err
+		}
+		return result.Contents[0].Text, nil
+	}
+	if text, err := read("godo://todos/1"); err != nil || text != "- [ ] Write docs (#1)\n\nFor the MCP server\n" {
+		t.Errorf("Unexpected todo resource %q, %v", text, err)
+	}
+	if text, err := read(TodosURI); err != nil || !strings.Contains(text, `"title": "Write docs"`) {
+		t.Errorf("Unexpected todo list resource %q, %v", text, err)
+	}
+	var rpcErr *mcp.Error
+	for _, uri := range []string{"godo://todos/9", "godo://todos/x", "file:///etc/passwd"} {
+		if _, err := read(uri); !errors.As(err, &rpcErr) || rpcErr.Code != mcp.CodeResourceNotFound {
+			t.Errorf("Expected %s not found, got %v", uri, err)
+		}
+	}
+}
@@ -117,10 +117,15 @@ func (m *MemoryStore) GetMemoryContext() string {
 		return ""
 	}
 	entries, err := m.GetAll()
-	if err != nil || len(entries) == 0 {
+	if err != nil {
 		return ""
 	}
+	return FormatContext(entries)
+}
 
+// FormatContext renders entries as the markdown list injected into the
+// system prompt.
+func FormatContext(entries []MemoryEntry) string {
 	var sb strings.Builder
 	for _, e := range entries {
 		fmt.Fprintf(&sb, "- **%s**: %s\n", e.Key, e.Content)
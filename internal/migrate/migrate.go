@@ -0,0 +1,166 @@
+// Package migrate evolves the godo SQLite schema through numbered
+// up-migrations. Migrations live in sql/NNNN_name.sql, are applied in order
+// inside their own transaction and are recorded in the schema_version table.
+package migrate
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/biisal/godo/internal/backup"
+)
+
+//go:embed sql/*.sql
+var migrationFiles embed.FS
+
+// Migration is a single numbered schema change.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+}
+
+// Migrations returns every known migration ordered by version.
+func Migrations() ([]Migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, "sql")
+	if err != nil {
+		return nil, err
+	}
+	migrations := make([]Migration, 0, len(entries))
+	for _, entry := range entries {
+		prefix, name, ok := strings.Cut(strings.TrimSuffix(entry.Name(), ".sql"), "_")
+		if !ok {
+			return nil, fmt.Errorf("migration %s: expected NNNN_name.sql", entry.Name())
+		}
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: invalid version: %w", entry.Name(), err)
+		}
+		up, err := migrationFiles.ReadFile(path.Join("sql", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, Migration{Version: version, Name: name, Up: string(up)})
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			return nil, fmt.Errorf("migration %d_%s: versions must be consecutive starting at 1", m.Version, m.Name)
+		}
+	}
+	return migrations, nil
+}
+
+// Latest returns the schema version a fully migrated database has.
+func Latest() (int, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return 0, err
+	}
+	return len(migrations), nil
+}
+
+// CurrentVersion returns the schema version recorded in db, or 0 when no
+// migration has run yet.
+func CurrentVersion(db *sql.DB) (int, error) {
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_version (
+		version INTEGER NOT NULL PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	)`); err != nil {
+		return 0, err
+	}
+	var version int
+	if err := db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_version`).Scan(&version); err != nil {
+		return 0, err
+	}
+	return version, nil
+}
+
+// Backups is where Run saves an existing database before migrating it. The
+// backup joins the rotated set of prefix in Dir, which keeps the newest Keep
+// backups, or all of them when Keep is 0. The zero value takes no backup.
+type Backups struct {
+	Dir    string
+	Prefix string
+	Keep   int
+}
+
+// Run brings db up to the latest schema, first backing up a database that
+// already holds data into to. It returns the number of migrations applied.
+func Run(db *sql.DB, to Backups) (int, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return 0, err
+	}
+	return run(db, to, migrations)
+}
+
+func run(db *sql.DB, to Backups, migrations []Migration) (int, error) {
+	current, err := CurrentVersion(db)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	if current > len(migrations) {
+		return 0, fmt.Errorf("database schema v%d is newer than this godo supports (v%d), please update godo", current, len(migrations))
+	}
+	pending := migrations[current:]
+	if len(pending) == 0 {
+		return 0, nil
+	}
+
+	populated, err := hasUserTables(db)
+	if err != nil {
+		return 0, err
+	}
+	if populated && to.Dir != "" {
+		backupPath, err := backup.Create(db, to.Dir, to.Prefix, time.Now())
+		if err != nil {
+			return 0, fmt.Errorf("failed to back up database before migrating: %w", err)
+		}
+		slog.Info("backed up database before migrating", "path", backupPath, "from", current)
+		if to.Keep > 0 {
+			if _, err := backup.Rotate(to.Dir, to.Prefix, to.Keep); err != nil {
+				slog.Warn("failed to rotate backups", "err", err)
+			}
+		}
+	}
+
+	for i, m := range pending {
+		if err := apply(db, m); err != nil {
+			return i, fmt.Errorf("migration %d_%s failed: %w", m.Version, m.Name, err)
+		}
+		slog.Info("applied migration", "version", m.Version, "name", m.Name)
+	}
+	return len(pending), nil
+}
+
+func apply(db *sql.DB, m Migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(m.Up); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if _, err := tx.Exec(`INSERT INTO schema_version (version, name) VALUES (?, ?)`, m.Version, m.Name); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func hasUserTables(db *sql.DB) (bool, error) {
+	var n int
+	err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name NOT IN ('schema_version', 'sqlite_sequence')`).Scan(&n)
+	return n > 0, err
+}
@@ -0,0 +1,240 @@
+package migrate
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/biisal/godo/internal/backup"
+	_ "modernc.org/sqlite"
+)
+
+// openFixture creates a database file from a testdata SQL fixture, and
+// returns it with a backup directory of its own.
+func openFixture(t *testing.T, fixture string) (*sql.DB, Backups) {
+	t.Helper()
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "todo.db")
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	})
+	if fixture != "" {
+		script, err := os.ReadFile(filepath.Join("testdata", fixture))
+		if err != nil {
+			t.Fatalf("Failed to read fixture: %v", err)
+		}
+		if _, err := db.Exec(string(script)); err != nil {
+			t.Fatalf("Failed to load fixture: %v", err)
+		}
+	}
+	return db, Backups{Dir: filepath.Join(dir, "backups"), Prefix: "todo", Keep: 2}
+}
+
+func tableExists(t *testing.T, db *sql.DB, name string) bool {
+	t.Helper()
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`, name).Scan(&n); err != nil {
+		t.Fatalf("Failed to query sqlite_master: %v", err)
+	}
+	return n == 1
+}
+
+func backups(t *testing.T, to Backups) []string {
+	t.Helper()
+	list, err := backup.List(to.Dir, to.Prefix)
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	var paths []string
+	for _, b := range list {
+		paths = append(paths, b.Path)
+	}
+	return paths
+}
+
+func TestMigrationsAreConsecutive(t *testing.T) {
+	migrations, err := Migrations()
+	if err != nil {
+		t.Fatalf("Migrations failed: %v", err)
+	}
+	if len(migrations) == 0 {
+		t.Fatal("Expected at least one migration")
+	}
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			t.Errorf("Expected migration %d to have version %d, got %d", i, i+1, m.Version)
+		}
+		if m.Name == "" || strings.TrimSpace(m.Up) == "" {
+			t.Errorf("Migration %d should have a name and a body", m.Version)
+		}
+	}
+}
+
+func TestRunFreshDatabase(t *testing.T) {
+	db, to := openFixture(t, "")
+
+	applied, err := Run(db, to)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	latest, _ := Latest()
+	if applied != latest {
+		t.Errorf("Expected %d migrations applied, got %d", latest, applied)
+	}
+	for _, table := range []string{"todos", "chats", "memories", "todo_sources", "todo_history", "encryption", "sessions"} {
+		if !tableExists(t, db, table) {
+			t.Errorf("Expected table %s to exist", table)
+		}
+	}
+	if b := backups(t, to); len(b) != 0 {
+		t.Errorf("A fresh database should not be backed up, got %v", b)
+	}
+}
+
+func TestRunIsIdempotent(t *testing.T) {
+	db, to := openFixture(t, "")
+	if _, err := Run(db, to); err != nil {
+		t.Fatalf("First run failed: %v", err)
+	}
+	applied, err := Run(db, to)
+	if err != nil {
+		t.Fatalf("Second run failed: %v", err)
+	}
+	if applied != 0 {
+		t.Errorf("Expected nothing to apply on the second run, got %d", applied)
+	}
+}
+
+func TestUpgradeFromFixtures(t *testing.T) {
+	latest, err := Latest()
+	if err != nil {
+		t.Fatalf("Latest failed: %v", err)
+	}
+	tests := []struct {
+		fixture     string
+		fromVersion int
+		todos       int
+	}{
+		{"v0.sql", 0, 2},
+		{"v1.sql", 1, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			db, to := openFixture(t, tt.fixture)
+
+			version, err := CurrentVersion(db)
+			if err != nil {
+				t.Fatalf("CurrentVersion failed: %v", err)
+			}
+			if version != tt.fromVersion {
+				t.Fatalf("Expected fixture at v%d, got v%d", tt.fromVersion, version)
+			}
+
+			applied, err := Run(db, to)
+			if err != nil {
+				t.Fatalf("Run failed: %v", err)
+			}
+			if applied != latest-tt.fromVersion {
+				t.Errorf("Expected %d migrations applied, got %d", latest-tt.fromVersion, applied)
+			}
+			if version, _ := CurrentVersion(db); version != latest {
+				t.Errorf("Expected v%d after migrating, got v%d", latest, version)
+			}
+
+			var todos int
+			if err := db.QueryRow(`SELECT COUNT(*) FROM todos`).Scan(&todos); err != nil {
+				t.Fatalf("Failed to count todos: %v", err)
+			}
+			if todos != tt.todos {
+				t.Errorf("Expected %d todos to survive, got %d", tt.todos, todos)
+			}
+			if !tableExists(t, db, "todo_history") {
+				t.Error("Expected todo_history to exist after upgrading")
+			}
+
+			// The existing conversation moves into one session.
+			var sessions, loose int
+			if err := db.QueryRow(`SELECT (SELECT COUNT(*) FROM sessions), (SELECT COUNT(*) FROM chats WHERE session_id IS NULL)`).Scan(&sessions, &loose); err != nil {
+				t.Fatalf("Failed to count sessions: %v", err)
+			}
+			if sessions != 1 || loose != 0 {
+				t.Errorf("Expected the chats in one session, got %d sessions and %d loose chats", sessions, loose)
+			}
+
+			b := backups(t, to)
+			if len(b) != 1 {
+				t.Fatalf("Expected one backup, got %v", b)
+			}
+			old, err := sql.Open("sqlite", b[0])
+			if err != nil {
+				t.Fatalf("Failed to open backup: %v", err)
+			}
+			defer func() {
+				_ = old.Close()
+			}()
+			if tableExists(t, old, "todo_history") {
+				t.Error("The backup should hold the pre-migration schema")
+			}
+		})
+	}
+}
+
+func TestRunRotatesBackups(t *testing.T) {
+	db, to := openFixture(t, "v1.sql")
+	for _, days := range []int{3, 2} {
+		if _, err := backup.Create(db, to.Dir, to.Prefix, time.Now().AddDate(0, 0, -days)); err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+	}
+	oldest := backups(t, to)[1]
+
+	if _, err := Run(db, to); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	b := backups(t, to)
+	if len(b) != to.Keep || slices.Contains(b, oldest) {
+		t.Errorf("Expected the pre-migration backup rotated in with the newest %d kept, got %v", to.Keep, b)
+	}
+}
+
+func TestFailedMigrationRollsBack(t *testing.T) {
+	db, to := openFixture(t, "v0.sql")
+	migrations := []Migration{
+		{Version: 1, Name: "init", Up: `CREATE TABLE IF NOT EXISTS todos (Id INTEGER PRIMARY KEY)`},
+		{Version: 2, Name: "broken", Up: `CREATE TABLE half_done (id INTEGER); INSERT INTO missing_table VALUES (1);`},
+	}
+
+	applied, err := run(db, to, migrations)
+	if err == nil {
+		t.Fatal("Expected the broken migration to fail")
+	}
+	if applied != 1 {
+		t.Errorf("Expected the first migration to be applied, got %d", applied)
+	}
+	if version, _ := CurrentVersion(db); version != 1 {
+		t.Errorf("Expected v1 after the failure, got v%d", version)
+	}
+	if tableExists(t, db, "half_done") {
+		t.Error("The failed migration should be rolled back")
+	}
+}
+
+func TestRunRejectsNewerSchema(t *testing.T) {
+	db, to := openFixture(t, "v1.sql")
+	if _, err := db.Exec(`INSERT INTO schema_version (version, name) VALUES (999, 'future')`); err != nil {
+		t.Fatalf("Failed to bump version: %v", err)
+	}
+	if _, err := Run(db, to); err == nil {
+		t.Error("Expected an error for a schema newer than this build")
+	}
+}
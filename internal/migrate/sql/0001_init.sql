@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS todos (
+	Id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+	Title TEXT NOT NULL,
+	Description TEXT NOT NULL,
+	Done BOOLEAN NOT NULL DEFAULT FALSE
+);
+CREATE TABLE IF NOT EXISTS chats(
+	Id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+	chat TEXT
+);
+CREATE TABLE IF NOT EXISTS memories (
+	id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+	key TEXT NOT NULL UNIQUE,
+	content TEXT NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS todo_sources (
+	todo_id INTEGER NOT NULL PRIMARY KEY,
+	root TEXT NOT NULL,
+	fingerprint TEXT NOT NULL,
+	path TEXT NOT NULL,
+	line INTEGER NOT NULL,
+	UNIQUE (root, fingerprint)
+);
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS todo_history (
+	id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+	todo_id INTEGER NOT NULL,
+	event TEXT NOT NULL,
+	detail TEXT NOT NULL DEFAULT '',
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
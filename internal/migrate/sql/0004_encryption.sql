@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS encryption (
+	id INTEGER NOT NULL PRIMARY KEY CHECK (id = 1),
+	salt BLOB NOT NULL,
+	iterations INTEGER NOT NULL,
+	verifier BLOB NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS sessions (
+	id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+	title TEXT NOT NULL DEFAULT '',
+	dir TEXT NOT NULL DEFAULT '',
+	created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+ALTER TABLE chats ADD COLUMN session_id INTEGER REFERENCES sessions(id);
+-- The single conversation kept so far becomes the first session.
+INSERT INTO sessions (title) SELECT 'Earlier chat' WHERE EXISTS (SELECT 1 FROM chats);
+UPDATE chats SET session_id = (SELECT MIN(id) FROM sessions) WHERE session_id IS NULL;
+CREATE INDEX IF NOT EXISTS chats_session ON chats (session_id, Id);
@@ -0,0 +1,23 @@
+-- A database created before migrations existed: initDb ran one
+-- CREATE TABLE IF NOT EXISTS block and no schema_version table was kept.
+CREATE TABLE todos (
+	Id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+	Title TEXT NOT NULL,
+	Description TEXT NOT NULL,
+	Done BOOLEAN NOT NULL DEFAULT FALSE
+);
+CREATE TABLE chats(
+	Id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+	chat TEXT
+);
+CREATE TABLE memories (
+	id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+	key TEXT NOT NULL UNIQUE,
+	content TEXT NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+INSERT INTO todos (Title, Description, Done) VALUES ('Write docs', 'README section', FALSE);
+INSERT INTO todos (Title, Description, Done) VALUES ('Ship it', 'Tag a release', TRUE);
+INSERT INTO chats (chat) VALUES ('{"role":"user","content":"hello"}');
+INSERT INTO memories (key, content) VALUES ('favorite_language', 'Go');
@@ -0,0 +1,26 @@
+-- A database at schema v1: the initial tables plus schema_version.
+CREATE TABLE schema_version (
+	version INTEGER NOT NULL PRIMARY KEY,
+	name TEXT NOT NULL,
+	applied_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+INSERT INTO schema_version (version, name) VALUES (1, 'init');
+CREATE TABLE todos (
+	Id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+	Title TEXT NOT NULL,
+	Description TEXT NOT NULL,
+	Done BOOLEAN NOT NULL DEFAULT FALSE
+);
+CREATE TABLE chats(
+	Id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+	chat TEXT
+);
+CREATE TABLE memories (
+	id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+	key TEXT NOT NULL UNIQUE,
+	content TEXT NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+INSERT INTO todos (Title, Description, Done) VALUES ('Write docs', 'README section', FALSE);
+INSERT INTO chats (chat) VALUES ('{"role":"assistant","content":"hi"}');
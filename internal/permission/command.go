@@ -0,0 +1,58 @@
+package permission
+
+import "strings"
+
+// SplitCommand splits a shell command at its control operators (;, &, |,
+// &&, ||, |&), newlines and subshell parentheses, leaving quoted text
+// alone. substitution reports whether the command substitutes the output
+// of another command with $(...), backquotes or <(...), which a rule cannot
+// see into.
+func SplitCommand(command string) (segments []string, substitution bool) {
+	var current strings.Builder
+	flush := func() {
+		segment := strings.TrimSpace(current.String())
+		// Braces group commands like parentheses do.
+		segment = strings.TrimSpace(strings.TrimPrefix(segment, "{"))
+		segment = strings.TrimSpace(strings.TrimSuffix(segment, "}"))
+		if segment != "" {
+			segments = append(segments, segment)
+		}
+		current.Reset()
+	}
+
+	runes := []rune(command)
+	var single, double bool
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		next := rune(0)
+		if i+1 < len(runes) {
+			next = runes[i+1]
+		}
+		switch {
+		case single:
+			single = r != '\''
+		case r == '\\' && next != 0:
+			current.WriteRune(r)
+			r = next
+			i++
+		case r == '`', (r == '$' || r == '<' || r == '>') && next == '(':
+			substitution = true
+		case double:
+			double = r != '"'
+		case r == '\'':
+			single = true
+		case r == '"':
+			double = true
+		case r == ';' || r == '&' || r == '|' || r == '\n' || r == '(' || r == ')':
+			// A redirection such as 2>&1 or >| stays in its segment.
+			if (r == '&' || r == '|') && i > 0 && (runes[i-1] == '>' || runes[i-1] == '<') {
+				break
+			}
+			flush()
+			continue
+		}
+		current.WriteRune(r)
+	}
+	flush()
+	return segments, substitution
+}
@@ -0,0 +1,253 @@
+// Package permission decides whether the agent may run a tool call. Rules
+// allow, deny or ask about a tool, optionally only when the call's subject
+// (the shell command, file path or query it acts on) matches a glob
+// pattern. Rules added by the user are kept in a JSON file.
+package permission
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Action is what happens to a tool call.
+type Action string
+
+const (
+	Allow Action = "allow"
+	Ask   Action = "ask"
+	Deny  Action = "deny"
+)
+
+// AnyTool as a rule's tool matches every tool.
+const AnyTool = "*"
+
+// Rule applies Action to calls of Tool whose subject matches Pattern. An
+// empty Pattern matches every call of the tool.
+type Rule struct {
+	Tool    string `json:"tool"`
+	Pattern string `json:"pattern,omitempty"`
+	Action  Action `json:"action"`
+}
+
+func (r Rule) String() string {
+	if r.Pattern == "" {
+		return fmt.Sprintf("%s %s", r.Action, r.Tool)
+	}
+	return fmt.Sprintf("%s %s %q", r.Action, r.Tool, r.Pattern)
+}
+
+func (r Rule) validate() error {
+	switch r.Action {
+	case Allow, Ask, Deny:
+	default:
+		return fmt.Errorf("rule %q: action must be allow, ask or deny", r)
+	}
+	if strings.TrimSpace(r.Tool) == "" {
+		return fmt.Errorf("rule %q: missing tool", r)
+	}
+	return nil
+}
+
+// strictness orders actions so the most restrictive matching rule wins.
+var strictness = map[Action]int{Allow: 0, Ask: 1, Deny: 2}
+
+// Policy holds the rules and the default action of each tool.
+type Policy struct {
+	mu    sync.Mutex
+	path  string
+	rules []Rule
+	// defaults is the action for calls no rule matches; tools missing from
+	// it are allowed.
+	defaults map[string]Action
+}
+
+// New returns a Policy with rules that is not saved anywhere.
+func New(rules []Rule, defaults map[string]Action) (*Policy, error) {
+	for _, r := range rules {
+		if err := r.validate(); err != nil {
+			return nil, err
+		}
+	}
+	return &Policy{rules: rules, defaults: defaults}, nil
+}
+
+// Load reads the rules kept at path, which may not exist yet. Rules added
+// later are saved there.
+func Load(path string, defaults map[string]Action) (*Policy, error) {
+	var file struct {
+		Rules []Rule `json:"rules"`
+	}
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, err
+	default:
+		if err := json.Unmarshal(data, &file); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+	}
+	p, err := New(file.Rules, defaults)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	p.path = path
+	return p, nil
+}
+
+// Decide returns the action for a call of tool on subject and the rule that
+// chose it, or nil when the tool's default applies. Among matching rules
+// deny beats ask, and ask beats allow.
+func (p *Policy) Decide(tool, subject string) (Action, *Rule) {
+	subject = strings.TrimSpace(subject)
+	return p.decide(tool, func(r Rule) bool {
+		return r.Pattern == "" || Match(r.Pattern, subject)
+	})
+}
+
+// DecideCommand is Decide for a shell command, split with SplitCommand so
+// that a pattern cannot be satisfied by one part of a compound command. A
+// deny or ask rule applies when any part matches it. An allow rule applies
+// only when every part matches it and nothing is substituted, or when it
+// names the whole command literally.
+func (p *Policy) DecideCommand(tool, command string) (Action, *Rule) {
+	command = strings.TrimSpace(command)
+	segments, substitution := SplitCommand(command)
+	return p.decide(tool, func(r Rule) bool {
+		if r.Pattern == "" || r.Pattern == Literal(command) {
+			return true
+		}
+		if r.Action == Allow {
+			if substitution || len(segments) == 0 {
+				return false
+			}
+			for _, segment := range segments {
+				if !Match(r.Pattern, segment) {
+					return false
+				}
+			}
+			return true
+		}
+		if Match(r.Pattern, command) {
+			return true
+		}
+		for _, segment := range segments {
+			if Match(r.Pattern, segment) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// decide picks the strictest rule for tool that matches, or the tool's
+// default.
+func (p *Policy) decide(tool string, matches func(Rule) bool) (Action, *Rule) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var chosen *Rule
+	for i, r := range p.rules {
+		if r.Tool != AnyTool && r.Tool != tool {
+			continue
+		}
+		if matches(r) && (chosen == nil || strictness[r.Action] > strictness[chosen.Action]) {
+			chosen = &p.rules[i]
+		}
+	}
+	if chosen != nil {
+		rule := *chosen
+		return rule.Action, &rule
+	}
+	if action, ok := p.defaults[tool]; ok {
+		return action, nil
+	}
+	return Allow, nil
+}
+
+// Add appends rule and saves the rules when the Policy was loaded from a
+// file.
+func (p *Policy) Add(rule Rule) error {
+	if err := rule.validate(); err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.rules = append(p.rules, rule)
+	if p.path == "" {
+		return nil
+	}
+	return p.save()
+}
+
+// Rules returns a copy of the rules.
+func (p *Policy) Rules() []Rule {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]Rule(nil), p.rules...)
+}
+
+func (p *Policy) save() error {
+	data, err := json.MarshalIndent(struct {
+		Rules []Rule `json:"rules"`
+	}{p.rules}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p.path), 0o755); err != nil {
+		return err
+	}
+	tmp := p.path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, p.path)
+}
+
+// Match reports whether s matches the glob pattern, where * matches any run
+// of characters, slashes and spaces included, ? matches one character and
+// a backslash makes the next character literal.
+func Match(pattern, s string) bool {
+	p, str := []rune(pattern), []rune(s)
+	// Backtrack to the most recent star on a mismatch.
+	pi, si, star, mark := 0, 0, -1, 0
+	for si < len(str) {
+		switch {
+		case pi < len(p) && p[pi] == '*':
+			star, mark = pi, si
+			pi++
+		case pi < len(p) && p[pi] == '\\' && pi+1 < len(p) && p[pi+1] == str[si]:
+			pi += 2
+			si++
+		case pi < len(p) && p[pi] != '\\' && (p[pi] == '?' || p[pi] == str[si]):
+			pi++
+			si++
+		case star >= 0:
+			pi = star + 1
+			mark++
+			si = mark
+		default:
+			return false
+		}
+	}
+	for pi < len(p) && p[pi] == '*' {
+		pi++
+	}
+	return pi == len(p)
+}
+
+// Literal escapes s so that Match only matches s itself.
+func Literal(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		if r == '*' || r == '?' || r == '\\' {
+			sb.WriteRune('\\')
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
@@ -0,0 +1,258 @@
+// Package plugin loads user-defined agent tools. Each tool is a JSON
+// manifest in the tools directory naming an executable; a call runs it with
+// the JSON arguments on stdin and uses what it prints on stdout as the
+// result.
+//
+// A manifest looks like:
+//
+//	{
+//	  "name": "Weather",
+//	  "description": "Current weather for a city.",
+//	  "parameters": {
+//	    "type": "object",
+//	    "properties": {"city": {"type": "string"}},
+//	    "required": ["city"]
+//	  },
+//	  "command": "./weather.sh",
+//	  "args": ["--metric"],
+//	  "timeout": "20s",
+//	  "status": "Checking the weather...",
+//	  "readOnly": true
+//	}
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultTimeout is how long a plugin may run when its manifest does
+	// not say.
+	DefaultTimeout = 30 * time.Second
+	// MaxTimeout caps the timeout a manifest may ask for.
+	MaxTimeout = 10 * time.Minute
+	// maxOutput caps how much of stdout becomes the result, and maxStderr
+	// how much of stderr is kept for error messages.
+	maxOutput = 256 * 1024
+	maxStderr = 4 * 1024
+)
+
+// validName matches names the model APIs accept for tools.
+var validName = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_-]{0,63}$`)
+
+// Manifest describes a plugin tool.
+type Manifest struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	Parameters  map[string]any `json:"parameters"`
+	// Command is the executable, relative to the manifest's directory
+	// unless absolute or a bare name found in PATH.
+	Command string   `json:"command"`
+	Args    []string `json:"args"`
+	// Timeout is a duration such as "20s"; empty uses DefaultTimeout.
+	Timeout string `json:"timeout"`
+	// Status is shown while the tool runs.
+	Status string `json:"status"`
+	// ReadOnly marks tools that change nothing, so several calls may run at
+	// once.
+	ReadOnly bool `json:"readOnly"`
+}
+
+// Plugin is a loaded, validated manifest.
+type Plugin struct {
+	Manifest
+	// Path is the manifest file.
+	Path    string
+	command string
+	timeout time.Duration
+}
+
+// Load reads the manifest at path.
+func Load(path string) (*Plugin, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&m); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	p, err := newPlugin(m, filepath.Dir(path))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	p.Path = path
+	return p, nil
+}
+
+// LoadDir loads every *.json manifest in dir, sorted by name. A missing dir
+// has no plugins. Manifests that fail to load are skipped and returned as
+// errors, so one broken plugin does not disable the others.
+func LoadDir(dir string) ([]*Plugin, []error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, []error{err}
+	}
+	var plugins []*Plugin
+	var errs []error
+	seen := map[string]string{}
+	for _, path := range paths {
+		p, err := Load(path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if other, ok := seen[p.Name]; ok {
+			errs = append(errs, fmt.Errorf("%s: tool %s is already defined in %s", path, p.Name, other))
+			continue
+		}
+		seen[p.Name] = path
+		plugins = append(plugins, p)
+	}
+	sort.Slice(plugins, func(i, j int) bool { return plugins[i].Name < plugins[j].Name })
+	return plugins, errs
+}
+
+func newPlugin(m Manifest, dir string) (*Plugin, error) {
+	if !validName.MatchString(m.Name) {
+		return nil, fmt.Errorf("invalid tool name %q: use letters, digits, _ or -, starting with a letter", m.Name)
+	}
+	if strings.TrimSpace(m.Description) == "" {
+		return nil, errors.New("description is required")
+	}
+	if strings.TrimSpace(m.Command) == "" {
+		return nil, errors.New("command is required")
+	}
+	if m.Parameters == nil {
+		m.Parameters = map[string]any{"type": "object", "properties": map[string]any{}}
+	}
+	if t, _ := m.Parameters["type"].(string); t != "object" {
+		return nil, errors.New(`parameters must be a schema of "type": "object"`)
+	}
+	if err := checkSchema(m.Parameters, "parameters"); err != nil {
+		return nil, err
+	}
+
+	p := &Plugin{Manifest: m, command: m.Command, timeout: DefaultTimeout}
+	if strings.ContainsRune(m.Command, filepath.Separator) || strings.ContainsRune(m.Command, '/') {
+		if !filepath.IsAbs(m.Command) {
+			p.command = filepath.Join(dir, m.Command)
+		}
+	}
+	if m.Timeout != "" {
+		d, err := time.ParseDuration(m.Timeout)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid timeout %q", m.Timeout)
+		}
+		p.timeout = min(d, MaxTimeout)
+	}
+	return p, nil
+}
+
+// Run validates args against the plugin's parameters and runs it in dir
+// with args on stdin. It returns what the plugin printed on stdout; a
+// failure includes the end of what it printed on stderr.
+func (p *Plugin) Run(ctx context.Context, dir string, args string) (string, error) {
+	if strings.TrimSpace(args) == "" {
+		args = "{}"
+	}
+	var value any
+	if err := json.Unmarshal([]byte(args), &value); err != nil {
+		return "", fmt.Errorf("invalid tool arguments: %w", err)
+	}
+	if err := Validate(p.Parameters, value); err != nil {
+		return "", fmt.Errorf("invalid tool arguments: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, p.command, p.Args...)
+	cmd.Dir = dir
+	cmd.Stdin = strings.NewReader(args)
+	stdout := &limitedBuffer{max: maxOutput}
+	stderr := &limitedBuffer{max: maxStderr, keepTail: true}
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	// Don't wait on pipes a killed plugin's children still hold.
+	cmd.WaitDelay = time.Second
+
+	err := cmd.Run()
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return "", fmt.Errorf("plugin %s timed out after %s%s", p.Name, p.timeout, stderr.suffix())
+	case ctx.Err() != nil:
+		return "", fmt.Errorf("plugin %s cancelled by the user", p.Name)
+	case err != nil:
+		return "", fmt.Errorf("plugin %s failed: %w%s", p.Name, err, stderr.suffix())
+	}
+
+	out := strings.TrimRight(stdout.String(), "\n")
+	if stdout.truncated {
+		out += fmt.Sprintf("\n[output truncated to %d bytes]", maxOutput)
+	}
+	return out, nil
+}
+
+// Summary lists the plugins for the system prompt.
+func Summary(plugins []*Plugin) string {
+	if len(plugins) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("# Plugins\nThe user added these tools; call them like the built-in ones.\n")
+	for _, p := range plugins {
+		desc, _, _ := strings.Cut(strings.TrimSpace(p.Description), "\n")
+		fmt.Fprintf(&sb, "\n- %s: %s", p.Name, desc)
+	}
+	return sb.String()
+}
+
+// limitedBuffer keeps up to max bytes of what is written to it: the start,
+// or the end when keepTail is set.
+type limitedBuffer struct {
+	buf       []byte
+	max       int
+	keepTail  bool
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > b.max {
+		b.truncated = true
+		if b.keepTail {
+			b.buf = append(b.buf[:0], b.buf[len(b.buf)-b.max:]...)
+		} else {
+			b.buf = b.buf[:b.max]
+		}
+	}
+	return len(p), nil
+}
+
+func (b *limitedBuffer) String() string {
+	return string(b.buf)
+}
+
+// suffix formats the captured stderr for an error message.
+func (b *limitedBuffer) suffix() string {
+	s := strings.TrimSpace(b.String())
+	if s == "" {
+		return ""
+	}
+	if b.truncated {
+		s = "..." + s
+	}
+	return "; stderr: " + s
+}
@@ -0,0 +1,142 @@
+package plugin
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writePlugin writes a shell script plugin and its manifest into dir.
+func writePlugin(t *testing.T, dir, name, manifest, script string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("plugin scripts need a POSIX shell")
+	}
+	if err := os.WriteFile(filepath.Join(dir, name+".sh"), []byte("#!/bin/sh\n"+script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, name+".json")
+	if err := os.WriteFile(path, []byte(manifest), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	path := writePlugin(t, dir, "echo", `{
+		"name": "Echo",
+		"description": "Echo the arguments back.\nMore detail.",
+		"parameters": {"type": "object", "properties": {"text": {"type": "string"}}, "required": ["text"]},
+		"command": "./echo.sh",
+		"args": ["--flag"]
+	}`, `echo "args=$*"; pwd; cat`)
+	p, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	work := t.TempDir()
+	work, _ = filepath.EvalSymlinks(work)
+
+	out, err := p.Run(context.Background(), work, `{"text":"hi"}`)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if want := "args=--flag\n" + work + "\n" + `{"text":"hi"}`; out != want {
+		t.Errorf("Expected %q, got %q", want, out)
+	}
+
+	if _, err := p.Run(context.Background(), work, `{"text":1}`); err == nil || !strings.Contains(err.Error(), "$.text: expected string") {
+		t.Errorf("Expected invalid arguments rejected before running, got %v", err)
+	}
+	if _, err := p.Run(context.Background(), work, `{`); err == nil {
+		t.Error("Expected malformed JSON rejected")
+	}
+}
+
+func TestRunFailures(t *testing.T) {
+	dir := t.TempDir()
+	failing, err := Load(writePlugin(t, dir, "fail", `{"name": "Fail", "description": "Fails.", "command": "./fail.sh"}`,
+		`echo "partial"; echo "boom: bad input" >&2; exit 3`))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	_, err = failing.Run(context.Background(), dir, "")
+	if err == nil || !strings.Contains(err.Error(), "exit status 3") || !strings.Contains(err.Error(), "stderr: boom: bad input") {
+		t.Errorf("Expected the exit status and stderr in the error, got %v", err)
+	}
+
+	slow, err := Load(writePlugin(t, dir, "slow", `{"name": "Slow", "description": "Sleeps.", "command": "./slow.sh", "timeout": "100ms"}`,
+		`echo "starting" >&2; sleep 5`))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	start := time.Now()
+	_, err = slow.Run(context.Background(), dir, "{}")
+	if err == nil || !strings.Contains(err.Error(), "timed out after 100ms") || !strings.Contains(err.Error(), "starting") {
+		t.Errorf("Expected a timeout with stderr, got %v", err)
+	}
+	if time.Since(start) > 3*time.Second {
+		t.Errorf("Expected the plugin killed at its timeout, took %s", time.Since(start))
+	}
+
+	loud, err := Load(writePlugin(t, dir, "loud", `{"name": "Loud", "description": "Prints a lot.", "command": "./loud.sh"}`,
+		`head -c 300000 /dev/zero | tr '\0' 'x'`))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	out, err := loud.Run(context.Background(), dir, "{}")
+	if err != nil || !strings.HasSuffix(out, "[output truncated to 262144 bytes]") {
+		t.Errorf("Expected truncated output, got %d bytes, %v", len(out), err)
+	}
+}
+
+func TestLoadDir(t *testing.T) {
+	dir := t.TempDir()
+	writePlugin(t, dir, "b", `{"name": "Beta", "description": "B.", "command": "./b.sh", "status": "Doing B...", "readOnly": true}`, "")
+	writePlugin(t, dir, "a", `{"name": "Alpha", "description": "A.", "command": "./a.sh"}`, "")
+	writePlugin(t, dir, "dup", `{"name": "Alpha", "description": "Again.", "command": "./dup.sh"}`, "")
+	for name, manifest := range map[string]string{
+		"badname":   `{"name": "no spaces", "description": "x", "command": "x"}`,
+		"nodesc":    `{"name": "NoDesc", "command": "x"}`,
+		"nocmd":     `{"name": "NoCmd", "description": "x"}`,
+		"badschema": `{"name": "BadSchema", "description": "x", "command": "x", "parameters": {"type": "string"}}`,
+		"badtime":   `{"name": "BadTime", "description": "x", "command": "x", "timeout": "soon"}`,
+		"unknown":   `{"name": "Unknown", "description": "x", "command": "x", "cmd": "y"}`,
+		"broken":    `{"name":`,
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name+".json"), []byte(manifest), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	plugins, errs := LoadDir(dir)
+	if len(plugins) != 2 || plugins[0].Name != "Alpha" || plugins[1].Name != "Beta" {
+		t.Fatalf("Expected Alpha and Beta, got %+v", plugins)
+	}
+	if len(errs) != 8 {
+		t.Errorf("Expected 8 broken manifests reported, got %d: %v", len(errs), errs)
+	}
+	if !plugins[1].ReadOnly || plugins[1].Status != "Doing B..." {
+		t.Errorf("Expected the manifest fields kept, got %+v", plugins[1].Manifest)
+	}
+	if plugins[0].command != filepath.Join(dir, "a.sh") {
+		t.Errorf("Expected the command resolved against the manifest, got %q", plugins[0].command)
+	}
+	if plugins[0].Parameters["type"] != "object" {
+		t.Errorf("Expected missing parameters to default to an empty object, got %v", plugins[0].Parameters)
+	}
+
+	if plugins, errs := LoadDir(filepath.Join(dir, "missing")); len(plugins) != 0 || len(errs) != 0 {
+		t.Errorf("Expected a missing directory to have no plugins, got %v, %v", plugins, errs)
+	}
+
+	summary := Summary(plugins)
+	if !strings.HasPrefix(summary, "# Plugins") || !strings.Contains(summary, "- Alpha: A.") || !strings.Contains(summary, "- Beta: B.") {
+		t.Errorf("Unexpected summary %q", summary)
+	}
+}
@@ -0,0 +1,80 @@
+package plugin
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func decode(t *testing.T, s string) map[string]any {
+	t.Helper()
+	var m map[string]any
+	if err := json.Unmarshal([]byte(s), &m); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestValidate(t *testing.T) {
+	schema := decode(t, `{
+		"type": "object",
+		"properties": {
+			"city": {"type": "string", "minLength": 1},
+			"days": {"type": "integer", "minimum": 1, "maximum": 7},
+			"units": {"type": "string", "enum": ["metric", "imperial"]},
+			"tags": {"type": "array", "items": {"type": "string"}, "maxItems": 2},
+			"note": {"type": ["string", "null"]}
+		},
+		"required": ["city"],
+		"additionalProperties": false
+	}`)
+	tests := []struct {
+		args string
+		want string
+	}{
+		{`{"city": "Oslo"}`, ""},
+		{`{"city": "Oslo", "days": 3, "units": "metric", "tags": ["a"], "note": null}`, ""},
+		{`{}`, `$: missing required property "city"`},
+		{`[]`, "$: expected object, got array"},
+		{`{"city": 5}`, "$.city: expected string, got number"},
+		{`{"city": ""}`, "$.city: must be at least 1 characters"},
+		{`{"city": "Oslo", "days": 2.5}`, "$.days: expected integer, got number"},
+		{`{"city": "Oslo", "days": 9}`, "$.days: must be at most 7"},
+		{`{"city": "Oslo", "units": "kelvin"}`, `$.units: must be one of ["metric","imperial"]`},
+		{`{"city": "Oslo", "tags": ["a", 1]}`, "$.tags[1]: expected string, got number"},
+		{`{"city": "Oslo", "tags": ["a", "b", "c"]}`, "$.tags: allows at most 2 items"},
+		{`{"city": "Oslo", "extra": true}`, `$: unknown property "extra"`},
+		{`{"city": "Oslo", "note": 1}`, "$.note: expected string or null, got number"},
+	}
+	for _, tt := range tests {
+		var value any
+		if err := json.Unmarshal([]byte(tt.args), &value); err != nil {
+			t.Fatal(err)
+		}
+		err := Validate(schema, value)
+		got := ""
+		if err != nil {
+			got = err.Error()
+		}
+		if got != tt.want {
+			t.Errorf("Validate(%s) = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestCheckSchema(t *testing.T) {
+	for _, s := range []string{
+		`{"type": "object", "properties": {"a": {"type": "text"}}}`,
+		`{"type": "object", "properties": {"a": "string"}}`,
+		`{"type": "object", "required": "a"}`,
+		`{"type": "object", "properties": {"a": {"type": "array", "items": [1]}}}`,
+		`{"type": 3}`,
+	} {
+		if err := checkSchema(decode(t, s), "parameters"); err == nil || !strings.HasPrefix(err.Error(), "parameters") {
+			t.Errorf("Expected %s to be rejected with its path, got %v", s, err)
+		}
+	}
+	if err := checkSchema(decode(t, `{"type": "object", "properties": {"a": {"type": "string", "description": "x", "format": "date"}}}`), "parameters"); err != nil {
+		t.Errorf("Expected unknown keywords to be allowed, got %v", err)
+	}
+}
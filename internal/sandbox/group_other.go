@@ -0,0 +1,14 @@
+//go:build !unix
+
+package sandbox
+
+import (
+	"os/exec"
+	"time"
+)
+
+// killGroup only stops waiting on the pipes of children the killed shell
+// leaves behind; Windows has no process groups to kill at once.
+func killGroup(cmd *exec.Cmd) {
+	cmd.WaitDelay = time.Second
+}
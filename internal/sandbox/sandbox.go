@@ -0,0 +1,190 @@
+// Package sandbox runs the agent's shell commands isolated from the rest of
+// the machine: the filesystem is read-only except for the workspace and a
+// private /tmp, godo's own directory is hidden, the network is off unless allowed, and the environment is
+// reduced to a few harmless variables. On Linux it uses bubblewrap when it
+// is installed and works, and otherwise namespaces set up by re-running
+// godo as a helper. Where neither is possible commands run in a restricted
+// mode that only cleans the environment and starts in the workspace.
+package sandbox
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Kind is how commands are isolated.
+type Kind string
+
+const (
+	Bubblewrap Kind = "bubblewrap"
+	Namespaces Kind = "namespaces"
+	// Restricted cleans the environment but does not isolate the
+	// filesystem or the network.
+	Restricted Kind = "restricted"
+)
+
+// Sandbox runs shell commands for a workspace.
+type Sandbox struct {
+	// Workspace is the only directory commands may write to, and where
+	// they start.
+	Workspace string
+	// Network lets commands reach the network.
+	Network bool
+	// Hidden are directories replaced by an empty one, so commands cannot
+	// read what they hold.
+	Hidden []string
+	Kind   Kind
+}
+
+// New returns a sandbox for workspace using the strongest isolation that
+// works on this machine. It hides ~/.godo, where the API keys are kept.
+func New(workspace string, network bool) *Sandbox {
+	return &Sandbox{Workspace: workspace, Network: network, Hidden: appDirs(), Kind: detect(workspace)}
+}
+
+// appDirs returns godo's directory in the home directory when it exists.
+func appDirs() []string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil
+	}
+	dir := filepath.Join(home, ".godo")
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return nil
+	}
+	return []string{dir}
+}
+
+// Isolated reports whether the filesystem and network are isolated, rather
+// than only the environment.
+func (s *Sandbox) Isolated() bool {
+	return s.Kind == Bubblewrap || s.Kind == Namespaces
+}
+
+// Command returns the command running script with sh inside the sandbox.
+func (s *Sandbox) Command(ctx context.Context, script string) *exec.Cmd {
+	var cmd *exec.Cmd
+	switch s.Kind {
+	case Bubblewrap:
+		cmd = exec.CommandContext(ctx, "bwrap", s.bwrapArgs(script)...)
+	case Namespaces:
+		cmd = s.namespacesCommand(ctx, script)
+	default:
+		cmd = exec.CommandContext(ctx, "sh", "-c", script)
+	}
+	killGroup(cmd)
+	cmd.Dir = s.Workspace
+	cmd.Env = Env()
+	return cmd
+}
+
+// Shell returns the command running script with sh outside any sandbox.
+// Like a sandboxed command, cancelling ctx kills every process the script
+// started, not only sh.
+func Shell(ctx context.Context, script string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "sh", "-c", script)
+	killGroup(cmd)
+	return cmd
+}
+
+// bwrapArgs mounts the whole filesystem read-only, then the workspace
+// writable over it and empty directories over the hidden ones.
+func (s *Sandbox) bwrapArgs(script string) []string {
+	args := []string{
+		"--die-with-parent",
+		"--new-session",
+		"--unshare-all",
+	}
+	if s.Network {
+		args = append(args, "--share-net")
+	}
+	args = append(args,
+		"--ro-bind", "/", "/",
+		"--dev", "/dev",
+		"--proc", "/proc",
+		"--tmpfs", "/tmp",
+	)
+	// A hidden directory holding the workspace goes under it, any other
+	// over it, so a workspace holding a hidden directory cannot show it.
+	for _, dir := range s.Hidden {
+		if within(s.Workspace, dir) {
+			args = append(args, "--tmpfs", dir)
+		}
+	}
+	args = append(args, "--bind", s.Workspace, s.Workspace)
+	for _, dir := range s.Hidden {
+		if !within(s.Workspace, dir) {
+			args = append(args, "--tmpfs", dir)
+		}
+	}
+	return append(args,
+		"--chdir", s.Workspace,
+		"--", "sh", "-c", script,
+	)
+}
+
+// keptEnv are the variables commands still see. Everything else, API keys
+// included, is dropped.
+var keptEnv = []string{"PATH", "HOME", "USER", "LOGNAME", "SHELL", "LANG", "LC_ALL", "LC_CTYPE", "LC_MESSAGES", "TERM", "TZ"}
+
+// Env returns the environment sandboxed commands run with.
+func Env() []string {
+	env := make([]string, 0, len(keptEnv))
+	for _, key := range keptEnv {
+		if v, ok := os.LookupEnv(key); ok {
+			env = append(env, key+"="+v)
+		}
+	}
+	return env
+}
+
+// blockedBy maps error messages to what the sandbox blocked.
+var blockedBy = []struct {
+	messages []string
+	network  bool
+	reason   string
+}{
+	{
+		messages: []string{"Read-only file system"},
+		reason:   "writing outside the workspace is blocked by the sandbox",
+	},
+	{
+		messages: []string{
+			"Network is unreachable",
+			"Temporary failure in name resolution",
+			"Could not resolve host",
+			"Name or service not known",
+			"No address associated with hostname",
+		},
+		network: true,
+		reason:  "network access is blocked by the sandbox",
+	},
+}
+
+// Blocked returns why a failed command was probably stopped by the sandbox,
+// judging by its output, or "" when nothing suggests it was.
+func (s *Sandbox) Blocked(failed bool, output string) string {
+	if !failed || !s.Isolated() {
+		return ""
+	}
+	for _, b := range blockedBy {
+		if b.network && s.Network {
+			continue
+		}
+		for _, msg := range b.messages {
+			if strings.Contains(output, msg) {
+				return b.reason
+			}
+		}
+	}
+	return ""
+}
+
+// within reports whether path is dir or below it.
+func within(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
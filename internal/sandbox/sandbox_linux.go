@@ -0,0 +1,275 @@
+package sandbox
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// helperArg as the first argument makes godo set up the sandbox and run
+// the command that follows; see RunHelper.
+const helperArg = "__godo-sandbox"
+
+// helperReady records that the program calls RunHelper, so re-running it
+// as the helper cannot start a second copy of the app.
+var helperReady bool
+
+// RunHelper must be called first thing in main. When the process was
+// started as the sandbox helper it isolates itself and replaces itself
+// with the command, never returning.
+func RunHelper() {
+	if len(os.Args) < 4 || os.Args[1] != helperArg {
+		helperReady = true
+		return
+	}
+	// Capabilities are per thread, and the thread that drops them must be
+	// the one that execs.
+	runtime.LockOSThread()
+	workspace, script, hidden := os.Args[2], os.Args[3], os.Args[4:]
+	if err := isolate(workspace, hidden); err != nil {
+		fmt.Fprintf(os.Stderr, "godo sandbox: %v\n", err)
+		os.Exit(126)
+	}
+	if err := dropPrivileges(); err != nil {
+		fmt.Fprintf(os.Stderr, "godo sandbox: %v\n", err)
+		os.Exit(126)
+	}
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		sh = "/bin/sh"
+	}
+	err = syscall.Exec(sh, []string{"sh", "-c", script}, os.Environ())
+	fmt.Fprintf(os.Stderr, "godo sandbox: failed to run sh: %v\n", err)
+	os.Exit(127)
+}
+
+func detect(workspace string) Kind {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	probe := &Sandbox{Workspace: workspace}
+	if _, err := exec.LookPath("bwrap"); err == nil {
+		probe.Kind = Bubblewrap
+		if probe.Command(ctx, "true").Run() == nil {
+			return Bubblewrap
+		}
+	}
+	if helperReady {
+		probe.Kind = Namespaces
+		if probe.Command(ctx, "true").Run() == nil {
+			return Namespaces
+		}
+	}
+	return Restricted
+}
+
+// namespacesCommand re-runs godo as the helper in new user and mount
+// namespaces, and a network namespace with only a loopback device unless
+// the network is allowed. The user namespace maps the caller to root so
+// the helper may mount; it has no more rights outside than the caller, and
+// drops them all before it runs the command.
+func (s *Sandbox) namespacesCommand(ctx context.Context, script string) *exec.Cmd {
+	args := append([]string{helperArg, s.Workspace, script}, s.Hidden...)
+	cmd := exec.CommandContext(ctx, "/proc/self/exe", args...)
+	flags := uintptr(syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS | syscall.CLONE_NEWIPC | syscall.CLONE_NEWUTS)
+	if !s.Network {
+		flags |= syscall.CLONE_NEWNET
+	}
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Cloneflags:  flags,
+		UidMappings: []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getuid(), Size: 1}},
+		GidMappings: []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getgid(), Size: 1}},
+		Setsid:      true,
+		Pdeathsig:   syscall.SIGKILL,
+	}
+	return cmd
+}
+
+// isolate makes every mount read-only except workspace, and gives the
+// command an empty /tmp and empty directories over the hidden ones. It runs
+// in the helper's own mount namespace.
+func isolate(workspace string, hidden []string) error {
+	if err := syscall.Mount("", "/", "", syscall.MS_REC|syscall.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("failed to make mounts private: %w", err)
+	}
+	// Give the workspace its own writable mount before the rest turns
+	// read-only, and keep a handle on it in case /tmp or a hidden
+	// directory covers it.
+	if err := syscall.Mount(workspace, workspace, "", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
+		return fmt.Errorf("failed to mount the workspace: %w", err)
+	}
+	ws, err := os.Open(workspace)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = ws.Close()
+	}()
+
+	mounts, err := readMounts()
+	if err != nil {
+		return err
+	}
+	for _, m := range mounts {
+		if within(m.point, workspace) {
+			continue
+		}
+		err := syscall.Mount("", m.point, "", syscall.MS_BIND|syscall.MS_REMOUNT|syscall.MS_RDONLY|m.flags, "")
+		if err == nil || errors.Is(err, syscall.ENOENT) || errors.Is(err, syscall.EACCES) {
+			continue
+		}
+		// Kernel filesystems may refuse; writes to them need privileges
+		// the helper does not have outside its namespaces anyway.
+		if within(m.point, "/proc") || within(m.point, "/sys") || within(m.point, "/dev") {
+			continue
+		}
+		return fmt.Errorf("failed to make %s read-only: %w", m.point, err)
+	}
+
+	if err := syscall.Mount("tmpfs", "/tmp", "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV, "mode=1777"); err != nil {
+		return fmt.Errorf("failed to mount /tmp: %w", err)
+	}
+	// Hidden directories holding the workspace go under it, any other over
+	// it, so a workspace holding a hidden directory cannot show it. One
+	// that /tmp already hides no longer exists and is skipped.
+	hide := func(dir string) error {
+		err := syscall.Mount("tmpfs", dir, "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV, "mode=0700")
+		if err != nil && !errors.Is(err, syscall.ENOENT) {
+			return fmt.Errorf("failed to hide %s: %w", dir, err)
+		}
+		return nil
+	}
+	covered := within(workspace, "/tmp")
+	for _, dir := range hidden {
+		if within(workspace, dir) {
+			if err := hide(dir); err != nil {
+				return err
+			}
+			covered = true
+		}
+	}
+	if covered {
+		if err := os.MkdirAll(workspace, 0o755); err != nil {
+			return err
+		}
+		handle := "/proc/self/fd/" + strconv.Itoa(int(ws.Fd()))
+		if err := syscall.Mount(handle, workspace, "", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
+			return fmt.Errorf("failed to mount the workspace: %w", err)
+		}
+	}
+	for _, dir := range hidden {
+		if !within(workspace, dir) {
+			if err := hide(dir); err != nil {
+				return err
+			}
+		}
+	}
+	return os.Chdir(workspace)
+}
+
+// dropPrivileges gives up every capability the helper holds as root of
+// its user namespace, for itself and for anything it runs, so the command
+// cannot unmount or remount what isolate set up.
+func dropPrivileges() error {
+	for c := 0; ; c++ {
+		err := unix.Prctl(unix.PR_CAPBSET_DROP, uintptr(c), 0, 0, 0)
+		if errors.Is(err, unix.EINVAL) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to drop capability %d: %w", c, err)
+		}
+	}
+	if err := unix.Prctl(unix.PR_CAP_AMBIENT, unix.PR_CAP_AMBIENT_CLEAR_ALL, 0, 0, 0); err != nil && !errors.Is(err, unix.EINVAL) {
+		return fmt.Errorf("failed to clear ambient capabilities: %w", err)
+	}
+	header := unix.CapUserHeader{Version: unix.LINUX_CAPABILITY_VERSION_3}
+	var data [2]unix.CapUserData
+	if err := unix.Capset(&header, &data[0]); err != nil {
+		return fmt.Errorf("failed to drop capabilities: %w", err)
+	}
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		return fmt.Errorf("failed to set no_new_privs: %w", err)
+	}
+	return nil
+}
+
+type mount struct {
+	point string
+	// flags are the mount's options that must be kept when it is
+	// remounted, since a user namespace may not clear them.
+	flags uintptr
+}
+
+var lockedFlags = map[string]uintptr{
+	"nosuid":      syscall.MS_NOSUID,
+	"nodev":       syscall.MS_NODEV,
+	"noexec":      syscall.MS_NOEXEC,
+	"noatime":     syscall.MS_NOATIME,
+	"nodiratime":  syscall.MS_NODIRATIME,
+	"relatime":    syscall.MS_RELATIME,
+	"strictatime": syscall.MS_STRICTATIME,
+}
+
+func readMounts() ([]mount, error) {
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	var mounts []mount
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		m, ok := parseMountInfo(sc.Text())
+		if ok {
+			mounts = append(mounts, m)
+		}
+	}
+	return mounts, sc.Err()
+}
+
+// parseMountInfo reads a line of /proc/self/mountinfo, such as
+//
+//	36 35 98:0 /mnt1 /mnt/parent rw,noatime master:1 - ext3 /dev/root rw
+func parseMountInfo(line string) (mount, bool) {
+	fields := strings.Fields(line)
+	if len(fields) < 6 {
+		return mount{}, false
+	}
+	m := mount{point: unescapeMount(fields[4])}
+	for _, opt := range strings.Split(fields[5], ",") {
+		m.flags |= lockedFlags[opt]
+	}
+	return m, true
+}
+
+// unescapeMount decodes the octal escapes mountinfo uses for spaces, tabs,
+// newlines and backslashes in paths.
+func unescapeMount(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if n, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				sb.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		sb.WriteByte(s[i])
+	}
+	return sb.String()
+}
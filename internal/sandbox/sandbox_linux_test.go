@@ -0,0 +1,238 @@
+package sandbox
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseMountInfo(t *testing.T) {
+	m, ok := parseMountInfo(`36 35 98:0 /mnt1 /mnt/my\040disk rw,nosuid,nodev,relatime master:1 - ext3 /dev/root rw`)
+	if !ok || m.point != "/mnt/my disk" {
+		t.Fatalf("Expected the escaped mount point decoded, got %+v", m)
+	}
+	if want := lockedFlags["nosuid"] | lockedFlags["nodev"] | lockedFlags["relatime"]; m.flags != want {
+		t.Errorf("Expected flags %x, got %x", want, m.flags)
+	}
+	if _, ok := parseMountInfo("garbage"); ok {
+		t.Error("Expected a short line to be skipped")
+	}
+}
+
+// isolatedSandbox returns a sandbox for a new workspace that isolates with
+// namespaces, skipping the test where the kernel does not allow them.
+func isolatedSandbox(t *testing.T) *Sandbox {
+	t.Helper()
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Sandbox{Workspace: dir, Kind: Namespaces}
+	if out, err := s.Command(context.Background(), "true").CombinedOutput(); err != nil {
+		t.Skipf("namespaces are not available: %v: %s", err, out)
+	}
+	return s
+}
+
+func TestNamespacesFilesystem(t *testing.T) {
+	s := isolatedSandbox(t)
+	outside, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	escaped := filepath.Join(outside, "sandbox-escape.txt")
+	t.Cleanup(func() { _ = os.Remove(escaped) })
+
+	out, err := s.Command(context.Background(), "pwd && echo hi > inside.txt && echo scratch > /tmp/scratch && cat /tmp/scratch").CombinedOutput()
+	if err != nil {
+		t.Fatalf("Expected writes to the workspace and /tmp to work, got %v: %s", err, out)
+	}
+	if want := s.Workspace + "\nscratch\n"; string(out) != want {
+		t.Errorf("Expected %q, got %q", want, out)
+	}
+	if data, err := os.ReadFile(filepath.Join(s.Workspace, "inside.txt")); err != nil || string(data) != "hi\n" {
+		t.Errorf("Expected the workspace file written, got %q, %v", data, err)
+	}
+
+	out, err = s.Command(context.Background(), "echo x > "+escaped).CombinedOutput()
+	if err == nil {
+		t.Fatalf("Expected writing outside the workspace to fail, got %s", out)
+	}
+	if reason := s.Blocked(true, string(out)); reason == "" {
+		t.Errorf("Expected the failure reported as blocked, got %q", out)
+	}
+	if _, err := os.Stat(escaped); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected nothing written outside the workspace, got %v", err)
+	}
+}
+
+func TestNamespacesHidden(t *testing.T) {
+	s := isolatedSandbox(t)
+	outside, err := os.MkdirTemp(".", "hidden")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(outside) })
+	inside := filepath.Join(s.Workspace, ".godo")
+	for _, dir := range []string{outside, inside} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, ".env"), []byte("OPENAI_API_KEY=secret\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	outside, err = filepath.Abs(outside)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Hidden = []string{outside, inside}
+
+	out, err := s.Command(context.Background(), "ls -A "+outside+" "+inside+" && echo hi > inside.txt && cat inside.txt").CombinedOutput()
+	if err != nil {
+		t.Fatalf("Command failed: %v: %s", err, out)
+	}
+	if strings.Contains(string(out), ".env") {
+		t.Errorf("Expected the hidden directories empty, got %s", out)
+	}
+	if !strings.HasSuffix(string(out), "hi\n") {
+		t.Errorf("Expected the workspace still writable, got %s", out)
+	}
+	if _, err := os.Stat(filepath.Join(outside, ".env")); err != nil {
+		t.Errorf("Expected the hidden file left in place, got %v", err)
+	}
+}
+
+func TestNamespacesMountsLocked(t *testing.T) {
+	s := isolatedSandbox(t)
+	hidden, err := os.MkdirTemp(".", "hidden")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(hidden) })
+	if err := os.WriteFile(filepath.Join(hidden, "key"), []byte("secret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if hidden, err = filepath.Abs(hidden); err != nil {
+		t.Fatal(err)
+	}
+	s.Hidden = []string{hidden}
+	outside, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	escaped := filepath.Join(outside, "sandbox-escape.txt")
+	t.Cleanup(func() { _ = os.Remove(escaped) })
+
+	tests := []struct {
+		name, script string
+	}{
+		{"umount", "umount " + hidden + " && cat " + hidden + "/key"},
+		{"remount", "mount -o remount,rw,bind / && echo x > " + escaped},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := s.Command(context.Background(), tt.script).CombinedOutput()
+			if err == nil {
+				t.Fatalf("Expected the command to fail, got %s", out)
+			}
+			if strings.Contains(string(out), "secret") {
+				t.Errorf("Expected the hidden file kept hidden, got %s", out)
+			}
+		})
+	}
+	if _, err := os.Stat(escaped); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected nothing written outside the workspace, got %v", err)
+	}
+}
+
+func TestNamespacesNetwork(t *testing.T) {
+	s := isolatedSandbox(t)
+	out, err := s.Command(context.Background(), "cat /proc/net/dev").CombinedOutput()
+	if err != nil {
+		t.Fatalf("Command failed: %v: %s", err, out)
+	}
+	for _, line := range strings.Split(string(out), "\n")[2:] {
+		if name, _, ok := strings.Cut(strings.TrimSpace(line), ":"); ok && name != "lo" {
+			t.Errorf("Expected only the loopback device, found %q", name)
+		}
+	}
+
+	s.Network = true
+	out, err = s.Command(context.Background(), "cat /proc/net/dev").CombinedOutput()
+	if err != nil {
+		t.Fatalf("Command failed: %v: %s", err, out)
+	}
+	host, err := os.ReadFile("/proc/net/dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(string(out), ":") != strings.Count(string(host), ":") {
+		t.Errorf("Expected the host's devices with the network allowed, got %s", out)
+	}
+}
+
+// running reports whether the process pid exists and is not a zombie.
+func running(pid string) bool {
+	stat, err := os.ReadFile("/proc/" + pid + "/stat")
+	if err != nil {
+		return false
+	}
+	// The state follows the command name, which is in parentheses.
+	fields := strings.Fields(string(stat[strings.LastIndexByte(string(stat), ')')+1:]))
+	return len(fields) > 0 && fields[0] != "Z"
+}
+
+func TestCancelKillsChildren(t *testing.T) {
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	commands := map[string]func(t *testing.T) func(context.Context, string) *exec.Cmd{
+		"shell": func(*testing.T) func(context.Context, string) *exec.Cmd { return Shell },
+		"restricted": func(*testing.T) func(context.Context, string) *exec.Cmd {
+			return (&Sandbox{Workspace: dir, Kind: Restricted}).Command
+		},
+		"namespaces": func(t *testing.T) func(context.Context, string) *exec.Cmd {
+			return isolatedSandbox(t).Command
+		},
+	}
+	for name, command := range commands {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			cmd := command(t)(ctx, "sleep 30 & echo $!; wait")
+			stdout, err := cmd.StdoutPipe()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := cmd.Start(); err != nil {
+				t.Fatal(err)
+			}
+			pid, err := bufio.NewReader(stdout).ReadString('\n')
+			if err != nil {
+				t.Fatalf("failed to read the child's pid: %v", err)
+			}
+			pid = strings.TrimSpace(pid)
+
+			cancel()
+			start := time.Now()
+			_ = cmd.Wait()
+			if waited := time.Since(start); waited > 2*time.Second {
+				t.Errorf("Expected Wait to return promptly, took %s", waited)
+			}
+			for deadline := time.Now().Add(2 * time.Second); running(pid); {
+				if time.Now().After(deadline) {
+					t.Fatalf("Expected the shell's child %s killed with it", pid)
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+		})
+	}
+}
@@ -0,0 +1,22 @@
+//go:build !linux
+
+package sandbox
+
+import (
+	"context"
+	"os/exec"
+)
+
+// RunHelper must be called first thing in main. Only Linux runs a helper,
+// so here it does nothing.
+func RunHelper() {}
+
+// detect always falls back to the restricted mode, since bubblewrap and
+// namespaces are Linux only.
+func detect(string) Kind {
+	return Restricted
+}
+
+func (s *Sandbox) namespacesCommand(ctx context.Context, script string) *exec.Cmd {
+	return exec.CommandContext(ctx, "sh", "-c", script)
+}
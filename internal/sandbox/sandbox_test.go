@@ -0,0 +1,105 @@
+package sandbox
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	// Sandboxed commands re-run the test binary as the helper.
+	RunHelper()
+	m.Run()
+}
+
+func TestEnv(t *testing.T) {
+	t.Setenv("OPENAI_API_KEY", "sk-secret")
+	t.Setenv("HOME", "/home/someone")
+	t.Setenv("LANG", "C.UTF-8")
+
+	env := Env()
+	if !slices.Contains(env, "HOME=/home/someone") || !slices.Contains(env, "LANG=C.UTF-8") {
+		t.Errorf("Expected HOME and LANG kept, got %q", env)
+	}
+	for _, kv := range env {
+		if strings.Contains(kv, "sk-secret") {
+			t.Errorf("Expected the API key dropped, got %q", env)
+		}
+	}
+}
+
+func TestRestrictedCommand(t *testing.T) {
+	t.Setenv("OPENAI_API_KEY", "sk-secret")
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Sandbox{Workspace: dir, Kind: Restricted}
+
+	out, err := s.Command(context.Background(), `pwd; echo "key=$OPENAI_API_KEY"`).CombinedOutput()
+	if err != nil {
+		t.Fatalf("Command failed: %v: %s", err, out)
+	}
+	if want := dir + "\nkey=\n"; string(out) != want {
+		t.Errorf("Expected the command in the workspace without the key, got %q", out)
+	}
+}
+
+func TestBwrapArgs(t *testing.T) {
+	s := &Sandbox{Workspace: "/work", Kind: Bubblewrap}
+	want := []string{
+		"--die-with-parent", "--new-session", "--unshare-all",
+		"--ro-bind", "/", "/", "--dev", "/dev", "--proc", "/proc", "--tmpfs", "/tmp",
+		"--bind", "/work", "/work", "--chdir", "/work",
+		"--", "sh", "-c", "make test",
+	}
+	if got := s.bwrapArgs("make test"); !reflect.DeepEqual(got, want) {
+		t.Errorf("bwrapArgs = %q, want %q", got, want)
+	}
+	s.Network = true
+	if got := s.bwrapArgs("make test"); !slices.Contains(got, "--share-net") {
+		t.Errorf("Expected the network shared when allowed, got %q", got)
+	}
+
+	s = &Sandbox{Workspace: "/home/me/.godo/work", Hidden: []string{"/home/me/.godo", "/home/me/.godo/work/keys"}, Kind: Bubblewrap}
+	want = []string{
+		"--die-with-parent", "--new-session", "--unshare-all",
+		"--ro-bind", "/", "/", "--dev", "/dev", "--proc", "/proc", "--tmpfs", "/tmp",
+		"--tmpfs", "/home/me/.godo",
+		"--bind", "/home/me/.godo/work", "/home/me/.godo/work",
+		"--tmpfs", "/home/me/.godo/work/keys",
+		"--chdir", "/home/me/.godo/work",
+		"--", "sh", "-c", "make test",
+	}
+	if got := s.bwrapArgs("make test"); !reflect.DeepEqual(got, want) {
+		t.Errorf("bwrapArgs with hidden directories = %q, want %q", got, want)
+	}
+}
+
+func TestBlocked(t *testing.T) {
+	isolated := &Sandbox{Kind: Namespaces}
+	online := &Sandbox{Kind: Bubblewrap, Network: true}
+	restricted := &Sandbox{Kind: Restricted}
+	tests := []struct {
+		s      *Sandbox
+		failed bool
+		output string
+		want   string
+	}{
+		{isolated, true, "touch: cannot touch '/etc/x': Read-only file system", "writing outside the workspace is blocked by the sandbox"},
+		{isolated, true, "curl: (6) Could not resolve host: example.com", "network access is blocked by the sandbox"},
+		{isolated, false, "Read-only file system", ""},
+		{isolated, true, "no such file", ""},
+		{online, true, "curl: (6) Could not resolve host: example.com", ""},
+		{online, true, "Read-only file system", "writing outside the workspace is blocked by the sandbox"},
+		{restricted, true, "Read-only file system", ""},
+	}
+	for _, tt := range tests {
+		if got := tt.s.Blocked(tt.failed, tt.output); got != tt.want {
+			t.Errorf("Blocked(%v, %q) with %s = %q, want %q", tt.failed, tt.output, tt.s.Kind, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package scanner
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+type ignoreRule struct {
+	base    string
+	parts   []string
+	negate  bool
+	dirOnly bool
+}
+
+// gitignore holds the rules of every .gitignore seen so far. Rules only apply
+// below the directory that declared them and the last matching rule wins.
+type gitignore struct {
+	rules []ignoreRule
+}
+
+func (g *gitignore) load(dir, rel string) error {
+	f, err := os.Open(filepath.Join(dir, ".gitignore"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if rule, ok := parseIgnoreRule(rel, sc.Text()); ok {
+			g.rules = append(g.rules, rule)
+		}
+	}
+	return sc.Err()
+}
+
+func parseIgnoreRule(base, line string) (ignoreRule, bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+	rule := ignoreRule{base: base}
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	}
+	line = strings.TrimPrefix(line, `\`)
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return ignoreRule{}, false
+	}
+
+	// A pattern without a slash (other than a trailing one) matches at any
+	// depth, which is the same as prefixing it with **/.
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	rule.parts = strings.Split(line, "/")
+	if !anchored {
+		rule.parts = append([]string{"**"}, rule.parts...)
+	}
+	return rule, true
+}
+
+func (g *gitignore) match(rel string, isDir bool) bool {
+	ignored := false
+	for _, rule := range g.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		target := rel
+		if rule.base != "" {
+			if !strings.HasPrefix(rel, rule.base+"/") {
+				continue
+			}
+			target = strings.TrimPrefix(rel, rule.base+"/")
+		}
+		if matchParts(rule.parts, strings.Split(target, "/")) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+func matchParts(patternParts, pathParts []string) bool {
+	if len(patternParts) == 0 {
+		return len(pathParts) == 0
+	}
+	if patternParts[0] == "**" {
+		if matchParts(patternParts[1:], pathParts) {
+			return true
+		}
+		if len(pathParts) > 0 {
+			return matchParts(patternParts, pathParts[1:])
+		}
+		return false
+	}
+	if len(pathParts) == 0 {
+		return false
+	}
+	ok, err := path.Match(patternParts[0], pathParts[0])
+	if err != nil || !ok {
+		return false
+	}
+	return matchParts(patternParts[1:], pathParts[1:])
+}
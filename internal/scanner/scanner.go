@@ -0,0 +1,137 @@
+// Package scanner finds TODO, FIXME and HACK comments in a source tree.
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Marker is a single TODO-style comment found in a file.
+type Marker struct {
+	Path        string `json:"path"`
+	Line        int    `json:"line"`
+	Kind        string `json:"kind"`
+	Text        string `json:"text"`
+	Fingerprint string `json:"fingerprint"`
+}
+
+// Location returns the file:line link of the marker.
+func (m Marker) Location() string {
+	return fmt.Sprintf("%s:%d", m.Path, m.Line)
+}
+
+const maxFileSize = 1 << 20
+
+var markerRe = regexp.MustCompile(`(?://|#|/\*|\*|--|;|<!--)\s*(TODO|FIXME|HACK)\b(?:\([^)]*\))?:?\s*(.*)$`)
+
+// Scan walks root, skipping .git and anything matched by .gitignore files,
+// and returns every marker comment it finds. Paths are relative to root.
+func Scan(root string) ([]Marker, error) {
+	rootAbs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve root %q: %w", root, err)
+	}
+
+	ignore := &gitignore{}
+	markers := []Marker{}
+
+	err = filepath.WalkDir(rootAbs, func(p string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		rel, err := filepath.Rel(rootAbs, p)
+		if err != nil {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+
+		if d.IsDir() {
+			if rel == "." {
+				return ignore.load(rootAbs, "")
+			}
+			if d.Name() == ".git" || ignore.match(rel, true) {
+				return filepath.SkipDir
+			}
+			return ignore.load(p, rel)
+		}
+		if !d.Type().IsRegular() || ignore.match(rel, false) {
+			return nil
+		}
+
+		found, err := scanFile(p, rel)
+		if err != nil {
+			return err
+		}
+		markers = append(markers, found...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("scan failed: %w", err)
+	}
+	return markers, nil
+}
+
+func scanFile(absPath, rel string) ([]Marker, error) {
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() > maxFileSize {
+		return nil, nil
+	}
+	data, err := os.ReadFile(absPath)
+	if err != nil {
+		return nil, err
+	}
+	head := data
+	if len(head) > 8000 {
+		head = head[:8000]
+	}
+	if bytes.IndexByte(head, 0) >= 0 {
+		return nil, nil
+	}
+
+	var markers []Marker
+	seen := map[string]int{}
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 0, 64*1024), maxFileSize)
+	line := 0
+	for sc.Scan() {
+		line++
+		match := markerRe.FindStringSubmatch(sc.Text())
+		if match == nil {
+			continue
+		}
+		text := strings.TrimSpace(match[2])
+		text = strings.TrimSpace(strings.TrimSuffix(strings.TrimSuffix(text, "*/"), "-->"))
+
+		// Identical comments in one file are told apart by their order so
+		// that moving code around keeps each marker's fingerprint stable.
+		key := match[1] + "|" + strings.Join(strings.Fields(text), " ")
+		seen[key]++
+		markers = append(markers, Marker{
+			Path:        rel,
+			Line:        line,
+			Kind:        match[1],
+			Text:        text,
+			Fingerprint: fingerprint(rel, key, seen[key]),
+		})
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", rel, err)
+	}
+	return markers, nil
+}
+
+func fingerprint(rel, key string, occurrence int) string {
+	sum := sha1.Sum(fmt.Appendf(nil, "%s|%s|%d", rel, key, occurrence))
+	return hex.EncodeToString(sum[:])
+}
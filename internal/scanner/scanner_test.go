@@ -0,0 +1,175 @@
+package scanner
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatalf("Failed to create dir for %s: %v", name, err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+}
+
+func markersByPath(markers []Marker) map[string][]Marker {
+	byPath := map[string][]Marker{}
+	for _, m := range markers {
+		byPath[m.Path] = append(byPath[m.Path], m)
+	}
+	return byPath
+}
+
+func TestScanFindsMarkers(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"main.go":       "package main\n\n// TODO: handle errors\nfunc main() {} // FIXME(bob): naming\n",
+		"script.sh":     "#!/bin/sh\n# HACK work around the old shell\n",
+		"query.sql":     "-- TODO add an index\nSELECT 1;\n",
+		"style.css":     "/* TODO: dark mode */\n",
+		"page.html":     "<!-- FIXME: broken link -->\n",
+		"notes.txt":     "nothing to do here\n",
+		"pkg/nested.go": "package pkg\n\n/*\n * TODO: split this file\n */\n",
+	})
+
+	markers, err := Scan(root)
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	byPath := markersByPath(markers)
+
+	tests := []struct {
+		path string
+		line int
+		kind string
+		text string
+	}{
+		{"main.go", 3, "TODO", "handle errors"},
+		{"main.go", 4, "FIXME", "naming"},
+		{"script.sh", 2, "HACK", "work around the old shell"},
+		{"query.sql", 1, "TODO", "add an index"},
+		{"style.css", 1, "TODO", "dark mode"},
+		{"page.html", 1, "FIXME", "broken link"},
+		{"pkg/nested.go", 4, "TODO", "split this file"},
+	}
+	for _, tt := range tests {
+		found := false
+		for _, m := range byPath[tt.path] {
+			if m.Line == tt.line {
+				found = true
+				if m.Kind != tt.kind || m.Text != tt.text {
+					t.Errorf("%s:%d: expected %s %q, got %s %q", tt.path, tt.line, tt.kind, tt.text, m.Kind, m.Text)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("Expected a marker at %s:%d", tt.path, tt.line)
+		}
+	}
+	if len(markers) != len(tests) {
+		t.Errorf("Expected %d markers, got %d: %+v", len(tests), len(markers), markers)
+	}
+}
+
+func TestScanRespectsGitignore(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		".gitignore":          "vendor/\n*.gen.go\n/build\n!keep.gen.go\n",
+		"vendor/lib/lib.go":   "// TODO: vendored\n",
+		"api.gen.go":          "// TODO: generated\n",
+		"keep.gen.go":         "// TODO: kept\n",
+		"build/out.go":        "// TODO: build output\n",
+		"src/build/ok.go":     "// TODO: not the root build\n",
+		"sub/.gitignore":      "local.go\n",
+		"sub/local.go":        "// TODO: ignored by nested gitignore\n",
+		"other/local.go":      "// TODO: only ignored under sub\n",
+		".git/hooks/x.sample": "# TODO: git internals\n",
+	})
+
+	markers, err := Scan(root)
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	byPath := markersByPath(markers)
+
+	for _, ignored := range []string{"vendor/lib/lib.go", "api.gen.go", "build/out.go", "sub/local.go", ".git/hooks/x.sample"} {
+		if len(byPath[ignored]) > 0 {
+			t.Errorf("Expected %s to be ignored", ignored)
+		}
+	}
+	for _, kept := range []string{"keep.gen.go", "src/build/ok.go", "other/local.go"} {
+		if len(byPath[kept]) != 1 {
+			t.Errorf("Expected one marker in %s, got %d", kept, len(byPath[kept]))
+		}
+	}
+}
+
+func TestScanSkipsBinaryFiles(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"blob.bin": "\x00\x01// TODO: not real\n",
+	})
+
+	markers, err := Scan(root)
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if len(markers) != 0 {
+		t.Errorf("Expected binary files to be skipped, got %+v", markers)
+	}
+}
+
+func TestFingerprintStableWhenLinesMove(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{"a.go": "// TODO: stable\n"})
+	before, err := Scan(root)
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+
+	writeFiles(t, root, map[string]string{"a.go": "package a\n\n\n// TODO:   stable\n"})
+	after, err := Scan(root)
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+
+	if len(before) != 1 || len(after) != 1 {
+		t.Fatalf("Expected one marker in each scan, got %d and %d", len(before), len(after))
+	}
+	if before[0].Fingerprint != after[0].Fingerprint {
+		t.Error("Expected fingerprint to survive a line move")
+	}
+	if after[0].Line != 4 {
+		t.Errorf("Expected the new line to be 4, got %d", after[0].Line)
+	}
+}
+
+func TestFingerprintDistinguishesDuplicates(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{"a.go": "// TODO: same\n// TODO: same\n"})
+
+	markers, err := Scan(root)
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if len(markers) != 2 {
+		t.Fatalf("Expected 2 markers, got %d", len(markers))
+	}
+	if markers[0].Fingerprint == markers[1].Fingerprint {
+		t.Error("Expected duplicate comments to get distinct fingerprints")
+	}
+}
+
+func TestMarkerLocation(t *testing.T) {
+	m := Marker{Path: "pkg/a.go", Line: 12}
+	if got := m.Location(); got != "pkg/a.go:12" {
+		t.Errorf("Expected 'pkg/a.go:12', got '%s'", got)
+	}
+}
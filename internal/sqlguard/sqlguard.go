@@ -0,0 +1,231 @@
+// Package sqlguard decides whether a SQL statement written by the model may
+// run against the godo database. It tokenizes the statement the way SQLite
+// does (strings, quoted identifiers and comments included), allows a single
+// statement only, lets reads through, limits writes to the todos table and
+// blocks everything else: DDL, ATTACH, VACUUM, transactions and pragmas that
+// change settings.
+package sqlguard
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Kind tells reads from writes.
+type Kind int
+
+const (
+	Read Kind = iota
+	Write
+)
+
+// WritableTable is the only table writes may target.
+const WritableTable = "todos"
+
+var (
+	ErrEmpty              = errors.New("empty SQL statement")
+	ErrMultipleStatements = errors.New("only one SQL statement can run at a time")
+	ErrForbidden          = errors.New("statement is not allowed")
+	ErrTable              = fmt.Errorf("writes are only allowed on the %s table", WritableTable)
+	ErrUnterminated       = errors.New("unterminated string, identifier or comment")
+)
+
+// Statement is a checked SQL statement.
+type Statement struct {
+	Kind Kind
+	// Verb is the upper-cased statement keyword, e.g. SELECT or DELETE.
+	Verb string
+	// Table is the write target, empty for reads.
+	Table string
+	// Destructive marks writes that delete or overwrite existing rows
+	// wholesale: DELETE, UPDATE without WHERE and REPLACE.
+	Destructive bool
+}
+
+// readPragmas are the schema introspection pragmas the model may use.
+var readPragmas = map[string]bool{
+	"table_info":       true,
+	"table_xinfo":      true,
+	"table_list":       true,
+	"index_list":       true,
+	"index_info":       true,
+	"index_xinfo":      true,
+	"foreign_key_list": true,
+}
+
+// blockedFunctions can reach outside the database even from a SELECT.
+var blockedFunctions = map[string]bool{
+	"load_extension": true,
+	"readfile":       true,
+	"writefile":      true,
+	"fts3_tokenizer": true,
+}
+
+// Check classifies query and returns an error when it must not run.
+func Check(query string) (Statement, error) {
+	tokens, err := tokenize(query)
+	if err != nil {
+		return Statement{}, err
+	}
+	for i, t := range tokens {
+		if t.is(";") {
+			for _, rest := range tokens[i+1:] {
+				if !rest.is(";") {
+					return Statement{}, ErrMultipleStatements
+				}
+			}
+			tokens = tokens[:i]
+			break
+		}
+	}
+	if len(tokens) == 0 {
+		return Statement{}, ErrEmpty
+	}
+	for i, t := range tokens {
+		if t.isName() && blockedFunctions[strings.ToLower(t.value())] && i+1 < len(tokens) && tokens[i+1].is("(") {
+			return Statement{}, fmt.Errorf("%w: %s() is blocked", ErrForbidden, t.text)
+		}
+	}
+
+	p := &parser{tokens: tokens}
+	verb := p.next().upper()
+	switch verb {
+	case "SELECT", "VALUES", "EXPLAIN":
+		return Statement{Kind: Read, Verb: verb}, nil
+	case "PRAGMA":
+		return checkPragma(p)
+	case "WITH":
+		verb = p.mainVerb()
+		switch verb {
+		case "SELECT", "VALUES":
+			return Statement{Kind: Read, Verb: verb}, nil
+		case "INSERT", "REPLACE", "UPDATE", "DELETE":
+			return checkWrite(p, verb)
+		}
+		return Statement{}, fmt.Errorf("%w: WITH must end in SELECT, INSERT, UPDATE or DELETE", ErrForbidden)
+	case "INSERT", "REPLACE", "UPDATE", "DELETE":
+		return checkWrite(p, verb)
+	}
+	return Statement{}, fmt.Errorf("%w: %s", ErrForbidden, verb)
+}
+
+func checkPragma(p *parser) (Statement, error) {
+	name := p.next()
+	if p.peek().is(".") {
+		p.next()
+		name = p.next()
+	}
+	if !readPragmas[strings.ToLower(name.value())] {
+		return Statement{}, fmt.Errorf("%w: PRAGMA %s", ErrForbidden, name.text)
+	}
+	for _, t := range p.tokens {
+		if t.is("=") {
+			return Statement{}, fmt.Errorf("%w: pragmas cannot be changed", ErrForbidden)
+		}
+	}
+	return Statement{Kind: Read, Verb: "PRAGMA"}, nil
+}
+
+// checkWrite reads the target table of an INSERT, REPLACE, UPDATE or DELETE
+// whose verb p has just consumed.
+func checkWrite(p *parser, verb string) (Statement, error) {
+	st := Statement{Kind: Write, Verb: verb}
+	switch verb {
+	case "INSERT", "UPDATE":
+		if p.peek().upper() == "OR" {
+			p.next()
+			if p.next().upper() == "REPLACE" {
+				st.Destructive = true
+			}
+		}
+	case "REPLACE":
+		st.Destructive = true
+	case "DELETE":
+		st.Destructive = true
+	}
+	if verb == "INSERT" || verb == "REPLACE" {
+		if p.next().upper() != "INTO" {
+			return Statement{}, fmt.Errorf("%w: expected INTO after %s", ErrForbidden, verb)
+		}
+	}
+	if verb == "DELETE" && p.next().upper() != "FROM" {
+		return Statement{}, fmt.Errorf("%w: expected FROM after DELETE", ErrForbidden)
+	}
+
+	name := p.next()
+	if p.peek().is(".") {
+		if !strings.EqualFold(name.value(), "main") {
+			return Statement{}, ErrTable
+		}
+		p.next()
+		name = p.next()
+	}
+	if !name.isName() || !strings.EqualFold(name.value(), WritableTable) {
+		return Statement{}, ErrTable
+	}
+	st.Table = WritableTable
+
+	if verb == "UPDATE" && !p.hasTopLevel("WHERE") {
+		st.Destructive = true
+	}
+	return st, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	if p.pos >= len(p.tokens) {
+		return token{}
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.peek()
+	if p.pos < len(p.tokens) {
+		p.pos++
+	}
+	return t
+}
+
+// mainVerb skips the common table expressions of a WITH clause and consumes
+// the keyword of the statement they belong to.
+func (p *parser) mainVerb() string {
+	depth := 0
+	for p.pos < len(p.tokens) {
+		t := p.next()
+		switch {
+		case t.is("("):
+			depth++
+		case t.is(")"):
+			depth--
+		case depth == 0 && t.kind == wordToken:
+			switch verb := t.upper(); verb {
+			case "SELECT", "VALUES", "INSERT", "REPLACE", "UPDATE", "DELETE":
+				return verb
+			}
+		}
+	}
+	return ""
+}
+
+// hasTopLevel reports whether keyword appears outside parentheses in the
+// tokens p has not consumed yet.
+func (p *parser) hasTopLevel(keyword string) bool {
+	depth := 0
+	for _, t := range p.tokens[p.pos:] {
+		switch {
+		case t.is("("):
+			depth++
+		case t.is(")"):
+			depth--
+		case depth == 0 && t.kind == wordToken && t.upper() == keyword:
+			return true
+		}
+	}
+	return false
+}
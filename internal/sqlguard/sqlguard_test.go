@@ -0,0 +1,121 @@
+package sqlguard
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheckAllowed(t *testing.T) {
+	tests := []struct {
+		name        string
+		query       string
+		kind        Kind
+		verb        string
+		destructive bool
+	}{
+		{"select", "SELECT * FROM todos WHERE Done = 0", Read, "SELECT", false},
+		{"select other table", "select key, content from memories", Read, "SELECT", false},
+		{"trailing semicolon", "SELECT 1;", Read, "SELECT", false},
+		{"semicolon in string", "SELECT * FROM todos WHERE Title = 'a; DROP TABLE todos'", Read, "SELECT", false},
+		{"semicolon in comment", "SELECT 1 -- ; DROP TABLE todos", Read, "SELECT", false},
+		{"block comment", "/* list */ SELECT 1 /* ; DELETE FROM todos */", Read, "SELECT", false},
+		{"values", "VALUES (1), (2)", Read, "VALUES", false},
+		{"cte select", "WITH open AS (SELECT * FROM todos WHERE Done = 0) SELECT COUNT(*) FROM open", Read, "SELECT", false},
+		{"pragma table_info", "PRAGMA table_info(todos)", Read, "PRAGMA", false},
+		{"pragma qualified", "PRAGMA main.table_info('todos')", Read, "PRAGMA", false},
+		{"insert", "INSERT INTO todos (Title, Description) VALUES ('a', 'b')", Write, "INSERT", false},
+		{"insert main schema", "INSERT INTO main.todos (Title, Description) VALUES ('a', 'b')", Write, "INSERT", false},
+		{"insert quoted", `INSERT INTO "todos" (Title, Description) VALUES ('a', 'b')`, Write, "INSERT", false},
+		{"insert bracket quoted", "INSERT INTO [main].[TODOS] (Title, Description) VALUES ('a', 'b')", Write, "INSERT", false},
+		{"update with where", "UPDATE todos SET Done = 1 WHERE Id = 3", Write, "UPDATE", false},
+		{"update where in string only", "UPDATE todos SET Title = 'WHERE'", Write, "UPDATE", true},
+		{"update where in subquery only", "UPDATE todos SET Done = (SELECT 1 FROM todos WHERE Id = 1)", Write, "UPDATE", true},
+		{"update or replace", "UPDATE OR REPLACE todos SET Id = 1 WHERE Id = 2", Write, "UPDATE", true},
+		{"delete with where", "DELETE FROM todos WHERE Id = 3", Write, "DELETE", true},
+		{"delete all", "delete from todos", Write, "DELETE", true},
+		{"replace", "REPLACE INTO todos (Id, Title, Description) VALUES (1, 'a', 'b')", Write, "REPLACE", true},
+		{"insert or replace", "INSERT OR REPLACE INTO todos (Id, Title, Description) VALUES (1, 'a', 'b')", Write, "INSERT", true},
+		{"cte delete", "WITH done AS (SELECT Id FROM todos WHERE Done) DELETE FROM todos WHERE Id IN done", Write, "DELETE", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st, err := Check(tt.query)
+			if err != nil {
+				t.Fatalf("Expected %q to be allowed, got %v", tt.query, err)
+			}
+			if st.Kind != tt.kind || st.Verb != tt.verb || st.Destructive != tt.destructive {
+				t.Errorf("Expected kind %d verb %s destructive %v, got %+v", tt.kind, tt.verb, tt.destructive, st)
+			}
+			if st.Kind == Write && st.Table != WritableTable {
+				t.Errorf("Expected the write target to be %s, got %q", WritableTable, st.Table)
+			}
+		})
+	}
+}
+
+func TestCheckRejected(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		err   error
+	}{
+		{"empty", "   ", ErrEmpty},
+		{"only comment", "-- nothing", ErrEmpty},
+		{"two statements", "SELECT 1; DROP TABLE memories", ErrMultipleStatements},
+		{"statement after comment", "SELECT 1; -- hi\nDELETE FROM todos", ErrMultipleStatements},
+		{"string closed early", "SELECT 'it''s'; DROP TABLE todos", ErrMultipleStatements},
+		{"drop", "DROP TABLE memories", ErrForbidden},
+		{"drop with leading comment", "/* harmless */ DROP TABLE memories", ErrForbidden},
+		{"create", "CREATE TABLE x (id INTEGER)", ErrForbidden},
+		{"create trigger", "CREATE TRIGGER t AFTER INSERT ON todos BEGIN DELETE FROM memories; END", ErrMultipleStatements},
+		{"alter", "ALTER TABLE todos ADD COLUMN x", ErrForbidden},
+		{"attach", "ATTACH DATABASE '/tmp/x.db' AS x", ErrForbidden},
+		{"detach", "DETACH DATABASE main", ErrForbidden},
+		{"vacuum", "VACUUM", ErrForbidden},
+		{"vacuum into", "VACUUM INTO '/tmp/copy.db'", ErrForbidden},
+		{"begin", "BEGIN", ErrForbidden},
+		{"pragma write", "PRAGMA writable_schema = ON", ErrForbidden},
+		{"pragma read but not allowed", "PRAGMA journal_mode", ErrForbidden},
+		{"pragma allowed name with assignment", "PRAGMA table_info = 1", ErrForbidden},
+		{"delete other table", "DELETE FROM memories", ErrTable},
+		{"delete chats", "DELETE FROM chats WHERE 1", ErrTable},
+		{"update other table", "UPDATE memories SET content = ''", ErrTable},
+		{"insert other table", "INSERT INTO chats (chat) VALUES ('x')", ErrTable},
+		{"quoted other table", `DELETE FROM "memories"`, ErrTable},
+		{"temp schema", "INSERT INTO temp.todos VALUES (1)", ErrTable},
+		{"other schema", "DELETE FROM aux.todos", ErrTable},
+		{"table name prefix", "DELETE FROM todos_backup", ErrTable},
+		{"cte delete other table", "WITH x AS (SELECT 1) DELETE FROM memories", ErrTable},
+		{"cte without statement", "WITH x AS (SELECT 1)", ErrForbidden},
+		{"load extension", "SELECT load_extension('/tmp/evil.so')", ErrForbidden},
+		{"load extension quoted", `SELECT "load_extension"('/tmp/evil.so')`, ErrForbidden},
+		{"load extension mixed case", "SELECT Load_Extension ('/tmp/evil.so')", ErrForbidden},
+		{"unterminated string", "SELECT 'abc", ErrUnterminated},
+		{"unterminated identifier", `SELECT "abc`, ErrUnterminated},
+		{"unterminated comment", "SELECT 1 /* DROP", ErrUnterminated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st, err := Check(tt.query)
+			if !errors.Is(err, tt.err) {
+				t.Errorf("Expected %q to fail with %v, got %+v, %v", tt.query, tt.err, st, err)
+			}
+		})
+	}
+}
+
+func TestTokenizeQuotedIdentifiers(t *testing.T) {
+	tokens, err := tokenize("SELECT \"a\"\"b\", `c``d`, [e f] FROM t")
+	if err != nil {
+		t.Fatalf("tokenize failed: %v", err)
+	}
+	var names []string
+	for _, tok := range tokens {
+		if tok.kind == identToken {
+			names = append(names, tok.value())
+		}
+	}
+	if len(names) != 3 || names[0] != `a"b` || names[1] != "c`d" || names[2] != "e f" {
+		t.Errorf("Unexpected identifiers: %q", names)
+	}
+}
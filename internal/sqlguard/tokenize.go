@@ -0,0 +1,130 @@
+package sqlguard
+
+import (
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	endToken tokenKind = iota
+	wordToken
+	identToken
+	stringToken
+	punctToken
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+func (t token) is(punct string) bool {
+	return t.kind == punctToken && t.text == punct
+}
+
+// isName reports whether t can name a table or function.
+func (t token) isName() bool {
+	return t.kind == wordToken || t.kind == identToken
+}
+
+func (t token) upper() string {
+	if t.kind != wordToken {
+		return ""
+	}
+	return strings.ToUpper(t.text)
+}
+
+// value returns the name a word or quoted identifier stands for.
+func (t token) value() string {
+	if t.kind != identToken {
+		return t.text
+	}
+	inner := t.text[1 : len(t.text)-1]
+	switch t.text[0] {
+	case '"':
+		return strings.ReplaceAll(inner, `""`, `"`)
+	case '`':
+		return strings.ReplaceAll(inner, "``", "`")
+	}
+	return inner
+}
+
+// tokenize splits query into words, quoted identifiers, string literals and
+// punctuation, dropping whitespace and comments.
+func tokenize(query string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == '\v':
+			i++
+		case strings.HasPrefix(query[i:], "--"):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				return tokens, nil
+			}
+			i += end + 1
+		case strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				return nil, ErrUnterminated
+			}
+			i += end + 4
+		case c == '\'':
+			end, err := quoted(query, i, '\'')
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{stringToken, query[i:end]})
+			i = end
+		case c == '"' || c == '`':
+			end, err := quoted(query, i, c)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{identToken, query[i:end]})
+			i = end
+		case c == '[':
+			end := strings.IndexByte(query[i:], ']')
+			if end < 0 {
+				return nil, ErrUnterminated
+			}
+			tokens = append(tokens, token{identToken, query[i : i+end+1]})
+			i += end + 1
+		case isWordByte(c):
+			start := i
+			for i < len(query) && isWordByte(query[i]) {
+				i++
+			}
+			tokens = append(tokens, token{wordToken, query[start:i]})
+		default:
+			tokens = append(tokens, token{punctToken, string(c)})
+			i++
+		}
+	}
+	return tokens, nil
+}
+
+// quoted returns the index just past the literal starting at query[start],
+// where a doubled quote character stands for itself.
+func quoted(query string, start int, quote byte) (int, error) {
+	for i := start + 1; i < len(query); i++ {
+		if query[i] != quote {
+			continue
+		}
+		if i+1 < len(query) && query[i+1] == quote {
+			i++
+			continue
+		}
+		return i + 1, nil
+	}
+	return 0, ErrUnterminated
+}
+
+// isWordByte matches the bytes SQLite allows in bare identifiers, keywords
+// and numbers. Bytes of multi-byte UTF-8 characters count as word bytes.
+func isWordByte(c byte) bool {
+	return c == '_' || c == '$' || c >= 0x80 ||
+		'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+}
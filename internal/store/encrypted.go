@@ -0,0 +1,172 @@
+package store
+
+import (
+	"strings"
+
+	"github.com/biisal/godo/internal/crypt"
+	"github.com/biisal/godo/internal/memory"
+)
+
+type encryptedMemoryStore struct {
+	inner  MemoryStore
+	cipher *crypt.Cipher
+}
+
+// NewEncryptedMemoryStore wraps inner so memory keys and contents are
+// encrypted with c. Keys are sealed deterministically, which lets the inner
+// store keep upserting and deleting by key.
+func NewEncryptedMemoryStore(inner MemoryStore, c *crypt.Cipher) MemoryStore {
+	return &encryptedMemoryStore{inner: inner, cipher: c}
+}
+
+func (s *encryptedMemoryStore) Save(key, content string) error {
+	key, content = strings.TrimSpace(key), strings.TrimSpace(content)
+	if key == "" || content == "" {
+		// Let the inner store report the validation error.
+		return s.inner.Save(key, content)
+	}
+	sealed, err := s.cipher.Seal(content)
+	if err != nil {
+		return err
+	}
+	return s.inner.Save(s.cipher.SealDeterministic(key), sealed)
+}
+
+func (s *encryptedMemoryStore) Delete(key string) error {
+	return s.inner.Delete(s.cipher.SealDeterministic(key))
+}
+
+func (s *encryptedMemoryStore) GetAll() ([]memory.MemoryEntry, error) {
+	entries, err := s.inner.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	for i, e := range entries {
+		if entries[i].Key, err = s.cipher.Open(e.Key); err != nil {
+			return nil, err
+		}
+		if entries[i].Content, err = s.cipher.Open(e.Content); err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
+
+// Search matches after decrypting, since the database only sees ciphertext.
+func (s *encryptedMemoryStore) Search(query string) ([]memory.MemoryEntry, error) {
+	entries, err := s.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	query = strings.ToLower(strings.TrimSpace(query))
+	if query == "" {
+		return entries, nil
+	}
+	var matches []memory.MemoryEntry
+	for _, e := range entries {
+		if strings.Contains(strings.ToLower(e.Key), query) || strings.Contains(strings.ToLower(e.Content), query) {
+			matches = append(matches, e)
+		}
+	}
+	return matches, nil
+}
+
+func (s *encryptedMemoryStore) GetMemoryContext() string {
+	entries, err := s.GetAll()
+	if err != nil {
+		return ""
+	}
+	return memory.FormatContext(entries)
+}
+
+// EncryptPayloads encrypts the chat messages, session titles and memories in q that are
+// still plaintext and returns how many rows it changed. Run it inside the
+// transaction that records the encryption parameters.
+func EncryptPayloads(q querier, c *crypt.Cipher) (int, error) {
+	changed := 0
+
+	type row struct {
+		id     int
+		values []string
+	}
+	collect := func(query string, columns int) ([]row, error) {
+		rows, err := q.Query(query)
+		if err != nil {
+			return nil, err
+		}
+		defer closeRows(rows)
+		var out []row
+		for rows.Next() {
+			r := row{values: make([]string, columns)}
+			dest := []any{&r.id}
+			for i := range r.values {
+				dest = append(dest, &r.values[i])
+			}
+			if err := rows.Scan(dest...); err != nil {
+				return nil, err
+			}
+			out = append(out, r)
+		}
+		return out, rows.Err()
+	}
+
+	chats, err := collect(`SELECT Id, COALESCE(chat, '') FROM chats`, 1)
+	if err != nil {
+		return 0, err
+	}
+	for _, r := range chats {
+		if crypt.IsSealed(r.values[0]) {
+			continue
+		}
+		sealed, err := c.Seal(r.values[0])
+		if err != nil {
+			return 0, err
+		}
+		if _, err := q.Exec(`UPDATE chats SET chat = ? WHERE Id = ?`, sealed, r.id); err != nil {
+			return 0, err
+		}
+		changed++
+	}
+
+	sessions, err := collect(`SELECT id, title FROM sessions WHERE title != ''`, 1)
+	if err != nil {
+		return 0, err
+	}
+	for _, r := range sessions {
+		if crypt.IsSealed(r.values[0]) {
+			continue
+		}
+		sealed, err := c.Seal(r.values[0])
+		if err != nil {
+			return 0, err
+		}
+		if _, err := q.Exec(`UPDATE sessions SET title = ? WHERE id = ?`, sealed, r.id); err != nil {
+			return 0, err
+		}
+		changed++
+	}
+
+	memories, err := collect(`SELECT id, key, content FROM memories`, 2)
+	if err != nil {
+		return 0, err
+	}
+	for _, r := range memories {
+		key, content := r.values[0], r.values[1]
+		if crypt.IsSealed(key) && crypt.IsSealed(content) {
+			continue
+		}
+		if !crypt.IsSealed(key) {
+			key = c.SealDeterministic(key)
+		}
+		if !crypt.IsSealed(content) {
+			if content, err = c.Seal(content); err != nil {
+				return 0, err
+			}
+		}
+		if _, err := q.Exec(`UPDATE memories SET key = ?, content = ? WHERE id = ?`, key, content, r.id); err != nil {
+			return 0, err
+		}
+		changed++
+	}
+	return changed, nil
+}
@@ -0,0 +1,342 @@
+package store
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/biisal/godo/internal/memory"
+	agentModel "github.com/biisal/godo/internal/tui/models/agent"
+	"github.com/biisal/godo/internal/tui/models/todo"
+)
+
+// FakeTodoStore is an in-memory TodoStore for tests.
+type FakeTodoStore struct {
+	mu      sync.Mutex
+	nextID  int
+	todos   map[int]todo.Todo
+	history []todo.HistoryEntry
+	sources []TodoSource
+}
+
+// NewFakeTodoStore returns an empty FakeTodoStore.
+func NewFakeTodoStore() *FakeTodoStore {
+	return &FakeTodoStore{nextID: 1, todos: map[int]todo.Todo{}}
+}
+
+func (f *FakeTodoStore) List() ([]todo.Todo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	todos := make([]todo.Todo, 0, len(f.todos))
+	for _, t := range f.todos {
+		todos = append(todos, t)
+	}
+	sort.Slice(todos, func(i, j int) bool { return todos[i].ID > todos[j].ID })
+	return todos, nil
+}
+
+func (f *FakeTodoStore) Get(id int) (*todo.Todo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t, ok := f.todos[id]
+	if !ok {
+		return nil, fmt.Errorf("todo %d: %w", id, ErrNotFound)
+	}
+	return &t, nil
+}
+
+func (f *FakeTodoStore) Add(title, description string) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	id := f.nextID
+	f.nextID++
+	f.todos[id] = todo.Todo{ID: id, TitleText: title, DescriptionText: description}
+	return id, nil
+}
+
+func (f *FakeTodoStore) Update(id int, title, description string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if t, ok := f.todos[id]; ok {
+		t.TitleText, t.DescriptionText = title, description
+		f.todos[id] = t
+	}
+	return nil
+}
+
+func (f *FakeTodoStore) Delete(id int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.todos, id)
+	return nil
+}
+
+func (f *FakeTodoStore) ToggleDone(id int) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t, ok := f.todos[id]
+	if !ok {
+		return false, fmt.Errorf("todo %d: %w", id, ErrNotFound)
+	}
+	t.Done = !t.Done
+	f.todos[id] = t
+	return t.Done, nil
+}
+
+func (f *FakeTodoStore) SetDone(id int, done bool) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t, ok := f.todos[id]
+	if !ok || t.Done == done {
+		return false, nil
+	}
+	t.Done = done
+	f.todos[id] = t
+	return true, nil
+}
+
+func (f *FakeTodoStore) AddHistory(id int, event, detail string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.history = append(f.history, todo.HistoryEntry{
+		ID:        len(f.history) + 1,
+		TodoID:    id,
+		Event:     event,
+		Detail:    detail,
+		CreatedAt: time.Now(),
+	})
+	return nil
+}
+
+func (f *FakeTodoStore) History(id int) ([]todo.HistoryEntry, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	entries := []todo.HistoryEntry{}
+	for _, e := range f.history {
+		if e.TodoID == id {
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
+
+func (f *FakeTodoStore) Sources(root string) ([]TodoSource, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	sources := []TodoSource{}
+	for _, src := range f.sources {
+		if src.Root == root {
+			sources = append(sources, src)
+		}
+	}
+	return sources, nil
+}
+
+func (f *FakeTodoStore) AddSource(src TodoSource) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, existing := range f.sources {
+		if existing.Root == src.Root && existing.Fingerprint == src.Fingerprint {
+			return fmt.Errorf("duplicate source %s", src.Fingerprint)
+		}
+	}
+	f.sources = append(f.sources, src)
+	return nil
+}
+
+func (f *FakeTodoStore) UpdateSource(src TodoSource) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, existing := range f.sources {
+		if existing.TodoID != src.TodoID {
+			continue
+		}
+		if existing.Path == src.Path && existing.Line == src.Line {
+			return false, nil
+		}
+		f.sources[i].Path, f.sources[i].Line = src.Path, src.Line
+		return true, nil
+	}
+	return false, nil
+}
+
+// Tx runs fn directly; the fake does not roll back on error.
+func (f *FakeTodoStore) Tx(fn func(TodoStore) error) error {
+	return fn(f)
+}
+
+// FakeChatStore is an in-memory ChatStore for tests.
+type FakeChatStore struct {
+	mu       sync.Mutex
+	nextID   int
+	sessions map[int]Session
+	messages map[int][]agentModel.Message
+}
+
+// NewFakeChatStore returns a FakeChatStore. Any msgs are put in session 1,
+// the way the sessions migration keeps an existing conversation.
+func NewFakeChatStore(msgs ...agentModel.Message) *FakeChatStore {
+	f := &FakeChatStore{nextID: 1, sessions: map[int]Session{}, messages: map[int][]agentModel.Message{}}
+	if len(msgs) > 0 {
+		s, _ := f.CreateSession("Earlier chat", "")
+		f.messages[s.ID] = msgs
+	}
+	return f
+}
+
+func (f *FakeChatStore) List(session int) ([]agentModel.Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.messages[session]), nil
+}
+
+func (f *FakeChatStore) Add(session int, msg agentModel.Message) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.messages[session] = append(f.messages[session], msg)
+	if s, ok := f.sessions[session]; ok {
+		s.UpdatedAt = time.Now()
+		f.sessions[session] = s
+	}
+	return nil
+}
+
+func (f *FakeChatStore) Truncate(session int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.messages, session)
+	return nil
+}
+
+func (f *FakeChatStore) Sessions() ([]Session, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	sessions := make([]Session, 0, len(f.sessions))
+	for _, s := range f.sessions {
+		sessions = append(sessions, s)
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		if !sessions[i].UpdatedAt.Equal(sessions[j].UpdatedAt) {
+			return sessions[i].UpdatedAt.After(sessions[j].UpdatedAt)
+		}
+		return sessions[i].ID > sessions[j].ID
+	})
+	return sessions, nil
+}
+
+func (f *FakeChatStore) GetSession(id int) (*Session, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s, ok := f.sessions[id]
+	if !ok {
+		return nil, fmt.Errorf("session %d: %w", id, ErrNotFound)
+	}
+	return &s, nil
+}
+
+func (f *FakeChatStore) CreateSession(title, dir string) (Session, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := time.Now()
+	s := Session{ID: f.nextID, Title: title, Dir: dir, CreatedAt: now, UpdatedAt: now}
+	f.nextID++
+	f.sessions[s.ID] = s
+	return s, nil
+}
+
+func (f *FakeChatStore) RenameSession(id int, title string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s, ok := f.sessions[id]
+	if !ok {
+		return fmt.Errorf("session %d: %w", id, ErrNotFound)
+	}
+	s.Title = title
+	f.sessions[id] = s
+	return nil
+}
+
+func (f *FakeChatStore) DeleteSession(id int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.sessions[id]; !ok {
+		return fmt.Errorf("session %d: %w", id, ErrNotFound)
+	}
+	delete(f.sessions, id)
+	delete(f.messages, id)
+	return nil
+}
+
+// FakeMemoryStore is an in-memory MemoryStore for tests.
+type FakeMemoryStore struct {
+	mu      sync.Mutex
+	nextID  int
+	entries map[string]memory.MemoryEntry
+}
+
+// NewFakeMemoryStore returns an empty FakeMemoryStore.
+func NewFakeMemoryStore() *FakeMemoryStore {
+	return &FakeMemoryStore{nextID: 1, entries: map[string]memory.MemoryEntry{}}
+}
+
+func (f *FakeMemoryStore) Save(key, content string) error {
+	key, content = strings.TrimSpace(key), strings.TrimSpace(content)
+	if key == "" {
+		return fmt.Errorf("memory key cannot be empty")
+	}
+	if content == "" {
+		return fmt.Errorf("memory content cannot be empty")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := time.Now()
+	e, ok := f.entries[key]
+	if !ok {
+		e = memory.MemoryEntry{ID: f.nextID, Key: key, CreatedAt: now}
+		f.nextID++
+	}
+	e.Content, e.UpdatedAt = content, now
+	f.entries[key] = e
+	return nil
+}
+
+func (f *FakeMemoryStore) Delete(key string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.entries, key)
+	return nil
+}
+
+func (f *FakeMemoryStore) GetAll() ([]memory.MemoryEntry, error) {
+	return f.Search("")
+}
+
+func (f *FakeMemoryStore) Search(query string) ([]memory.MemoryEntry, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	query = strings.ToLower(strings.TrimSpace(query))
+	var entries []memory.MemoryEntry
+	for _, e := range f.entries {
+		if query == "" || strings.Contains(strings.ToLower(e.Key), query) || strings.Contains(strings.ToLower(e.Content), query) {
+			entries = append(entries, e)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].UpdatedAt.Equal(entries[j].UpdatedAt) {
+			return entries[i].ID > entries[j].ID
+		}
+		return entries[i].UpdatedAt.After(entries[j].UpdatedAt)
+	})
+	return entries, nil
+}
+
+func (f *FakeMemoryStore) GetMemoryContext() string {
+	entries, err := f.GetAll()
+	if err != nil {
+		return ""
+	}
+	return memory.FormatContext(entries)
+}
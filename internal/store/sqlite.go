@@ -0,0 +1,381 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/biisal/godo/internal/crypt"
+	agentModel "github.com/biisal/godo/internal/tui/models/agent"
+	"github.com/biisal/godo/internal/tui/models/todo"
+)
+
+// querier is the part of *sql.DB and *sql.Tx the stores use, so the same
+// code runs inside and outside a transaction.
+type querier interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+type sqliteTodoStore struct {
+	db *sql.DB
+	q  querier
+}
+
+// NewSQLiteTodoStore returns a TodoStore backed by the todos tables in db.
+func NewSQLiteTodoStore(db *sql.DB) TodoStore {
+	return &sqliteTodoStore{db: db, q: db}
+}
+
+func (s *sqliteTodoStore) List() ([]todo.Todo, error) {
+	sqlStmt := `
+	SELECT Id , Title, Description, Done
+	FROM todos
+	ORDER BY Id DESC
+	`
+	rows, err := s.q.Query(sqlStmt)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRows(rows)
+
+	todos := []todo.Todo{}
+	for rows.Next() {
+		var t todo.Todo
+		if err := rows.Scan(&t.ID, &t.TitleText, &t.DescriptionText, &t.Done); err != nil {
+			return nil, err
+		}
+		todos = append(todos, t)
+	}
+	return todos, rows.Err()
+}
+
+func (s *sqliteTodoStore) Get(id int) (*todo.Todo, error) {
+	sqlStmt := `
+	SELECT Id , Title, Description, Done
+	FROM todos
+	WHERE Id = ?
+	`
+	t := &todo.Todo{}
+	err := s.q.QueryRow(sqlStmt, id).Scan(&t.ID, &t.TitleText, &t.DescriptionText, &t.Done)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("todo %d: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (s *sqliteTodoStore) Add(title, description string) (int, error) {
+	sqlStmt := `
+	INSERT INTO todos (Title, Description, Done)
+	VALUES (?, ?, ?)`
+	res, err := s.q.Exec(sqlStmt, title, description, false)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	return int(id), err
+}
+
+func (s *sqliteTodoStore) Update(id int, title, description string) error {
+	sqlStmt := `
+	UPDATE todos SET Title = ?, Description = ? WHERE Id = ?`
+	_, err := s.q.Exec(sqlStmt, title, description, id)
+	return err
+}
+
+func (s *sqliteTodoStore) Delete(id int) error {
+	_, err := s.q.Exec(`DELETE FROM todos WHERE Id = ?`, id)
+	return err
+}
+
+func (s *sqliteTodoStore) ToggleDone(id int) (bool, error) {
+	if _, err := s.q.Exec(`UPDATE todos SET Done = NOT Done WHERE Id = ?`, id); err != nil {
+		return false, err
+	}
+	var isDone bool
+	err := s.q.QueryRow(`SELECT Done FROM todos WHERE Id = ?`, id).Scan(&isDone)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, fmt.Errorf("todo %d: %w", id, ErrNotFound)
+	}
+	return isDone, err
+}
+
+func (s *sqliteTodoStore) SetDone(id int, done bool) (bool, error) {
+	res, err := s.q.Exec(`UPDATE todos SET Done = ? WHERE Id = ? AND Done != ?`, done, id, done)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (s *sqliteTodoStore) AddHistory(id int, event, detail string) error {
+	_, err := s.q.Exec(`INSERT INTO todo_history (todo_id, event, detail) VALUES (?, ?, ?)`, id, event, detail)
+	return err
+}
+
+func (s *sqliteTodoStore) History(id int) ([]todo.HistoryEntry, error) {
+	rows, err := s.q.Query(`SELECT id, todo_id, event, detail, created_at FROM todo_history WHERE todo_id = ? ORDER BY id`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRows(rows)
+
+	entries := []todo.HistoryEntry{}
+	for rows.Next() {
+		var e todo.HistoryEntry
+		if err := rows.Scan(&e.ID, &e.TodoID, &e.Event, &e.Detail, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+func (s *sqliteTodoStore) Sources(root string) ([]TodoSource, error) {
+	rows, err := s.q.Query(`SELECT todo_id, root, fingerprint, path, line FROM todo_sources WHERE root = ?`, root)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRows(rows)
+
+	sources := []TodoSource{}
+	for rows.Next() {
+		var src TodoSource
+		if err := rows.Scan(&src.TodoID, &src.Root, &src.Fingerprint, &src.Path, &src.Line); err != nil {
+			return nil, err
+		}
+		sources = append(sources, src)
+	}
+	return sources, rows.Err()
+}
+
+func (s *sqliteTodoStore) AddSource(src TodoSource) error {
+	_, err := s.q.Exec(`INSERT INTO todo_sources (todo_id, root, fingerprint, path, line) VALUES (?, ?, ?, ?, ?)`,
+		src.TodoID, src.Root, src.Fingerprint, src.Path, src.Line)
+	return err
+}
+
+func (s *sqliteTodoStore) UpdateSource(src TodoSource) (bool, error) {
+	res, err := s.q.Exec(`UPDATE todo_sources SET path = ?, line = ? WHERE todo_id = ? AND (path != ? OR line != ?)`,
+		src.Path, src.Line, src.TodoID, src.Path, src.Line)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (s *sqliteTodoStore) Tx(fn func(TodoStore) error) error {
+	if _, ok := s.q.(*sql.Tx); ok {
+		return fn(s)
+	}
+	tx, err := s.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(&sqliteTodoStore{db: s.db, q: tx}); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			slog.Error("error rolling back transaction", "err", rbErr)
+		}
+		return err
+	}
+	return tx.Commit()
+}
+
+type sqliteChatStore struct {
+	db     *sql.DB
+	cipher *crypt.Cipher
+}
+
+// NewSQLiteChatStore returns a ChatStore backed by the chats table in db.
+func NewSQLiteChatStore(db *sql.DB) ChatStore {
+	return &sqliteChatStore{db: db}
+}
+
+// NewEncryptedChatStore returns a ChatStore backed by the chats table in db
+// that encrypts every message with c.
+func NewEncryptedChatStore(db *sql.DB, c *crypt.Cipher) ChatStore {
+	return &sqliteChatStore{db: db, cipher: c}
+}
+
+func (s *sqliteChatStore) List(session int) ([]agentModel.Message, error) {
+	rows, err := s.db.Query("SELECT chat FROM chats WHERE session_id = ? ORDER BY Id", session)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query chats: %w", err)
+	}
+	defer closeRows(rows)
+
+	var history []agentModel.Message
+	for rows.Next() {
+		var chatContent string
+		if err := rows.Scan(&chatContent); err != nil {
+			return nil, fmt.Errorf("failed to scan chat: %w", err)
+		}
+		if s.cipher != nil {
+			if chatContent, err = s.cipher.Open(chatContent); err != nil {
+				return nil, err
+			}
+		}
+		msg := agentModel.Message{}
+		if err := json.Unmarshal([]byte(chatContent), &msg); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal chat: %w", err)
+		}
+		history = append(history, msg)
+	}
+	return history, rows.Err()
+}
+
+func (s *sqliteChatStore) Add(session int, msg agentModel.Message) error {
+	msgJSON, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	chat := string(msgJSON)
+	if s.cipher != nil {
+		if chat, err = s.cipher.Seal(chat); err != nil {
+			return err
+		}
+	}
+	if _, err = s.db.Exec("INSERT INTO chats (chat, session_id) VALUES (?, ?)", chat, session); err != nil {
+		return err
+	}
+	_, err = s.db.Exec("UPDATE sessions SET updated_at = "+sqlNow+" WHERE id = ?", session)
+	return err
+}
+
+func (s *sqliteChatStore) Truncate(session int) error {
+	_, err := s.db.Exec("DELETE FROM chats WHERE session_id = ?", session)
+	return err
+}
+
+// sqlNow is the current time with milliseconds, so sessions updated within
+// the same second still sort in order.
+const sqlNow = "strftime('%Y-%m-%d %H:%M:%f', 'now')"
+
+func (s *sqliteChatStore) Sessions() ([]Session, error) {
+	rows, err := s.db.Query("SELECT id, title, dir, created_at, updated_at FROM sessions ORDER BY updated_at DESC, id DESC")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sessions: %w", err)
+	}
+	defer closeRows(rows)
+
+	var sessions []Session
+	for rows.Next() {
+		var ss Session
+		if err := rows.Scan(&ss.ID, &ss.Title, &ss.Dir, &ss.CreatedAt, &ss.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan session: %w", err)
+		}
+		if ss.Title, err = s.openTitle(ss.Title); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, ss)
+	}
+	return sessions, rows.Err()
+}
+
+func (s *sqliteChatStore) GetSession(id int) (*Session, error) {
+	ss := Session{ID: id}
+	err := s.db.QueryRow("SELECT title, dir, created_at, updated_at FROM sessions WHERE id = ?", id).
+		Scan(&ss.Title, &ss.Dir, &ss.CreatedAt, &ss.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("session %d: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if ss.Title, err = s.openTitle(ss.Title); err != nil {
+		return nil, err
+	}
+	return &ss, nil
+}
+
+func (s *sqliteChatStore) CreateSession(title, dir string) (Session, error) {
+	sealed, err := s.sealTitle(title)
+	if err != nil {
+		return Session{}, err
+	}
+	res, err := s.db.Exec("INSERT INTO sessions (title, dir, created_at, updated_at) VALUES (?, ?, "+sqlNow+", "+sqlNow+")", sealed, dir)
+	if err != nil {
+		return Session{}, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return Session{}, err
+	}
+	ss, err := s.GetSession(int(id))
+	if err != nil {
+		return Session{}, err
+	}
+	return *ss, nil
+}
+
+func (s *sqliteChatStore) RenameSession(id int, title string) error {
+	sealed, err := s.sealTitle(title)
+	if err != nil {
+		return err
+	}
+	res, err := s.db.Exec("UPDATE sessions SET title = ? WHERE id = ?", sealed, id)
+	return sessionChanged(res, err, id)
+}
+
+func (s *sqliteChatStore) DeleteSession(id int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM chats WHERE session_id = ?", id); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	res, err := tx.Exec("DELETE FROM sessions WHERE id = ?", id)
+	if err := sessionChanged(res, err, id); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func sessionChanged(res sql.Result, err error, id int) error {
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("session %d: %w", id, ErrNotFound)
+	}
+	return nil
+}
+
+// sealTitle encrypts a session title, which usually quotes the first prompt.
+func (s *sqliteChatStore) sealTitle(title string) (string, error) {
+	if s.cipher == nil || title == "" {
+		return title, nil
+	}
+	return s.cipher.Seal(title)
+}
+
+// openTitle decrypts a title; titles written before the database was
+// encrypted are plain.
+func (s *sqliteChatStore) openTitle(title string) (string, error) {
+	if s.cipher == nil || !crypt.IsSealed(title) {
+		return title, nil
+	}
+	return s.cipher.Open(title)
+}
+
+func closeRows(rows *sql.Rows) {
+	if err := rows.Close(); err != nil {
+		slog.Error("error closing rows", "err", err)
+	}
+}
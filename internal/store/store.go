@@ -0,0 +1,84 @@
+// Package store defines the storage interfaces godo depends on, with SQLite
+// implementations for the app and in-memory fakes for tests.
+package store
+
+import (
+	"errors"
+	"time"
+
+	"github.com/biisal/godo/internal/memory"
+	agentModel "github.com/biisal/godo/internal/tui/models/agent"
+	"github.com/biisal/godo/internal/tui/models/todo"
+)
+
+// ErrNotFound is returned when a todo does not exist.
+var ErrNotFound = errors.New("not found")
+
+// TodoSource links a todo imported from a code comment to its location.
+type TodoSource struct {
+	TodoID      int
+	Root        string
+	Fingerprint string
+	Path        string
+	Line        int
+}
+
+// TodoStore persists todos, their history and their code sources.
+type TodoStore interface {
+	List() ([]todo.Todo, error)
+	Get(id int) (*todo.Todo, error)
+	Add(title, description string) (int, error)
+	Update(id int, title, description string) error
+	Delete(id int) error
+	ToggleDone(id int) (bool, error)
+	SetDone(id int, done bool) (bool, error)
+
+	AddHistory(id int, event, detail string) error
+	History(id int) ([]todo.HistoryEntry, error)
+
+	Sources(root string) ([]TodoSource, error)
+	AddSource(src TodoSource) error
+	UpdateSource(src TodoSource) (bool, error)
+
+	// Tx runs fn against a store whose changes are committed together, or
+	// not at all when fn returns an error.
+	Tx(fn func(TodoStore) error) error
+}
+
+// Session is one named agent conversation.
+type Session struct {
+	ID    int
+	Title string
+	// Dir is the working directory the session was started in.
+	Dir       string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// ChatStore persists agent conversations, each in its own session, in
+// order.
+type ChatStore interface {
+	List(session int) ([]agentModel.Message, error)
+	// Add appends msg to the session and marks the session updated.
+	Add(session int, msg agentModel.Message) error
+	Truncate(session int) error
+
+	// Sessions lists every session, most recently updated first.
+	Sessions() ([]Session, error)
+	GetSession(id int) (*Session, error)
+	CreateSession(title, dir string) (Session, error)
+	RenameSession(id int, title string) error
+	// DeleteSession removes the session and its messages.
+	DeleteSession(id int) error
+}
+
+// MemoryStore persists long-term key-value memories.
+type MemoryStore interface {
+	Save(key, content string) error
+	Delete(key string) error
+	GetAll() ([]memory.MemoryEntry, error)
+	Search(query string) ([]memory.MemoryEntry, error)
+	GetMemoryContext() string
+}
+
+var _ MemoryStore = (*memory.MemoryStore)(nil)
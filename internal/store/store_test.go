@@ -0,0 +1,429 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/biisal/godo/internal/crypt"
+	"github.com/biisal/godo/internal/memory"
+	"github.com/biisal/godo/internal/migrate"
+	agentModel "github.com/biisal/godo/internal/tui/models/agent"
+	_ "modernc.org/sqlite"
+)
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	dbPath := filepath.Join(t.TempDir(), "todo.db")
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	})
+	if _, err := migrate.Run(db, migrate.Backups{}); err != nil {
+		t.Fatalf("Failed to migrate database: %v", err)
+	}
+	return db
+}
+
+// todoStores returns every TodoStore implementation so the same contract is
+// checked against the SQLite store and the fake.
+func todoStores(t *testing.T) map[string]TodoStore {
+	return map[string]TodoStore{
+		"sqlite": NewSQLiteTodoStore(openTestDB(t)),
+		"fake":   NewFakeTodoStore(),
+	}
+}
+
+func chatStores(t *testing.T) map[string]ChatStore {
+	return map[string]ChatStore{
+		"sqlite": NewSQLiteChatStore(openTestDB(t)),
+		"fake":   NewFakeChatStore(),
+	}
+}
+
+func TestTodoStoreCRUD(t *testing.T) {
+	for name, s := range todoStores(t) {
+		t.Run(name, func(t *testing.T) {
+			id, err := s.Add("Write tests", "for the store")
+			if err != nil {
+				t.Fatalf("Add failed: %v", err)
+			}
+			if _, err := s.Add("Second", "todo"); err != nil {
+				t.Fatalf("Add failed: %v", err)
+			}
+
+			got, err := s.Get(id)
+			if err != nil {
+				t.Fatalf("Get failed: %v", err)
+			}
+			if got.TitleText != "Write tests" || got.DescriptionText != "for the store" || got.Done {
+				t.Errorf("Unexpected todo: %+v", got)
+			}
+
+			if err := s.Update(id, "Write more tests", "everywhere"); err != nil {
+				t.Fatalf("Update failed: %v", err)
+			}
+			got, _ = s.Get(id)
+			if got.TitleText != "Write more tests" || got.DescriptionText != "everywhere" {
+				t.Errorf("Update not applied: %+v", got)
+			}
+
+			todos, err := s.List()
+			if err != nil {
+				t.Fatalf("List failed: %v", err)
+			}
+			if len(todos) != 2 || todos[1].ID != id {
+				t.Fatalf("Expected 2 todos, newest first, ending with #%d, got %+v", id, todos)
+			}
+
+			if err := s.Delete(id); err != nil {
+				t.Fatalf("Delete failed: %v", err)
+			}
+			if _, err := s.Get(id); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Expected ErrNotFound after delete, got %v", err)
+			}
+		})
+	}
+}
+
+func TestTodoStoreDone(t *testing.T) {
+	for name, s := range todoStores(t) {
+		t.Run(name, func(t *testing.T) {
+			id, _ := s.Add("Toggle", "me")
+
+			done, err := s.ToggleDone(id)
+			if err != nil || !done {
+				t.Fatalf("Expected toggle to mark done, got %v, %v", done, err)
+			}
+			done, _ = s.ToggleDone(id)
+			if done {
+				t.Error("Expected second toggle to mark undone")
+			}
+
+			changed, err := s.SetDone(id, true)
+			if err != nil || !changed {
+				t.Fatalf("Expected SetDone to change the todo, got %v, %v", changed, err)
+			}
+			changed, _ = s.SetDone(id, true)
+			if changed {
+				t.Error("Expected SetDone on a done todo to report no change")
+			}
+			if _, err := s.ToggleDone(id + 100); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Expected ErrNotFound for a missing todo, got %v", err)
+			}
+		})
+	}
+}
+
+func TestTodoStoreHistoryAndSources(t *testing.T) {
+	for name, s := range todoStores(t) {
+		t.Run(name, func(t *testing.T) {
+			id, _ := s.Add("Imported", "from code")
+
+			if err := s.AddHistory(id, "closed", "abc123 fix it"); err != nil {
+				t.Fatalf("AddHistory failed: %v", err)
+			}
+			entries, err := s.History(id)
+			if err != nil {
+				t.Fatalf("History failed: %v", err)
+			}
+			if len(entries) != 1 || entries[0].Event != "closed" || entries[0].Detail != "abc123 fix it" {
+				t.Errorf("Unexpected history: %+v", entries)
+			}
+
+			src := TodoSource{TodoID: id, Root: "/repo", Fingerprint: "fp", Path: "main.go", Line: 3}
+			if err := s.AddSource(src); err != nil {
+				t.Fatalf("AddSource failed: %v", err)
+			}
+			src.Line = 7
+			changed, err := s.UpdateSource(src)
+			if err != nil || !changed {
+				t.Fatalf("Expected UpdateSource to change the line, got %v, %v", changed, err)
+			}
+			if changed, _ := s.UpdateSource(src); changed {
+				t.Error("Expected an unchanged source to report no change")
+			}
+
+			sources, err := s.Sources("/repo")
+			if err != nil {
+				t.Fatalf("Sources failed: %v", err)
+			}
+			if len(sources) != 1 || sources[0] != src {
+				t.Errorf("Expected %+v, got %+v", src, sources)
+			}
+			if other, _ := s.Sources("/elsewhere"); len(other) != 0 {
+				t.Errorf("Expected no sources for another root, got %+v", other)
+			}
+		})
+	}
+}
+
+func TestTodoStoreTx(t *testing.T) {
+	for name, s := range todoStores(t) {
+		t.Run(name, func(t *testing.T) {
+			err := s.Tx(func(ts TodoStore) error {
+				_, err := ts.Add("In a", "transaction")
+				return err
+			})
+			if err != nil {
+				t.Fatalf("Tx failed: %v", err)
+			}
+			todos, _ := s.List()
+			if len(todos) != 1 {
+				t.Errorf("Expected the committed todo, got %d todos", len(todos))
+			}
+		})
+	}
+}
+
+func TestSQLiteTodoStoreTxRollback(t *testing.T) {
+	s := NewSQLiteTodoStore(openTestDB(t))
+	boom := errors.New("boom")
+
+	err := s.Tx(func(ts TodoStore) error {
+		if _, err := ts.Add("Rolled", "back"); err != nil {
+			return err
+		}
+		return boom
+	})
+	if !errors.Is(err, boom) {
+		t.Fatalf("Expected the callback error, got %v", err)
+	}
+	todos, _ := s.List()
+	if len(todos) != 0 {
+		t.Errorf("Expected the todo to be rolled back, got %+v", todos)
+	}
+}
+
+func TestChatStore(t *testing.T) {
+	for name, s := range chatStores(t) {
+		t.Run(name, func(t *testing.T) {
+			session, err := s.CreateSession("greetings", "/home/me")
+			if err != nil {
+				t.Fatalf("CreateSession failed: %v", err)
+			}
+			other, _ := s.CreateSession("other", "")
+			_ = s.Add(other.ID, agentModel.Message{Role: agentModel.UserRole, Content: "elsewhere"})
+
+			msgs := []agentModel.Message{
+				{Role: agentModel.UserRole, Content: "hello"},
+				{Role: agentModel.AssistantRole, Content: "hi", Reasoning: "greet back"},
+			}
+			for _, m := range msgs {
+				if err := s.Add(session.ID, m); err != nil {
+					t.Fatalf("Add failed: %v", err)
+				}
+			}
+
+			got, err := s.List(session.ID)
+			if err != nil {
+				t.Fatalf("List failed: %v", err)
+			}
+			if len(got) != 2 || got[0].Content != "hello" || got[1].Reasoning != "greet back" {
+				t.Errorf("Unexpected messages: %+v", got)
+			}
+
+			if err := s.Truncate(session.ID); err != nil {
+				t.Fatalf("Truncate failed: %v", err)
+			}
+			if got, _ := s.List(session.ID); len(got) != 0 {
+				t.Errorf("Expected no messages after truncate, got %d", len(got))
+			}
+			if got, _ := s.List(other.ID); len(got) != 1 {
+				t.Errorf("Expected the other session untouched, got %d", len(got))
+			}
+		})
+	}
+}
+
+func TestChatSessions(t *testing.T) {
+	for name, s := range chatStores(t) {
+		t.Run(name, func(t *testing.T) {
+			first, _ := s.CreateSession("first", "/a")
+			second, _ := s.CreateSession("second", "/b")
+			if first.ID == second.ID || first.Dir != "/a" || first.CreatedAt.IsZero() {
+				t.Fatalf("Unexpected sessions %+v, %+v", first, second)
+			}
+
+			sessions, err := s.Sessions()
+			if err != nil || len(sessions) != 2 || sessions[0].ID != second.ID {
+				t.Fatalf("Expected the newest session first, got %+v (%v)", sessions, err)
+			}
+			_ = s.Add(first.ID, agentModel.Message{Role: agentModel.UserRole, Content: "bump"})
+			if sessions, _ := s.Sessions(); sessions[0].ID != first.ID {
+				t.Errorf("Expected a new message to move its session first, got %+v", sessions)
+			}
+
+			if err := s.RenameSession(first.ID, "renamed"); err != nil {
+				t.Fatalf("RenameSession failed: %v", err)
+			}
+			if got, err := s.GetSession(first.ID); err != nil || got.Title != "renamed" {
+				t.Errorf("Expected the new title, got %+v (%v)", got, err)
+			}
+
+			if err := s.DeleteSession(first.ID); err != nil {
+				t.Fatalf("DeleteSession failed: %v", err)
+			}
+			if _, err := s.GetSession(first.ID); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Expected ErrNotFound for a deleted session, got %v", err)
+			}
+			if got, _ := s.List(first.ID); len(got) != 0 {
+				t.Errorf("Expected the session's messages deleted, got %+v", got)
+			}
+			if err := s.RenameSession(first.ID, "x"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Expected ErrNotFound renaming a deleted session, got %v", err)
+			}
+		})
+	}
+}
+
+func TestFakeMemoryStore(t *testing.T) {
+	s := NewFakeMemoryStore()
+	if err := s.Save("editor", "prefers vim"); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if err := s.Save("editor", "prefers helix"); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	all, _ := s.GetAll()
+	if len(all) != 1 || all[0].Content != "prefers helix" {
+		t.Errorf("Expected the memory to be replaced, got %+v", all)
+	}
+	if found, _ := s.Search("HELIX"); len(found) != 1 {
+		t.Errorf("Expected a case-insensitive match, got %+v", found)
+	}
+	if err := s.Delete("editor"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if s.GetMemoryContext() != "" {
+		t.Error("Expected empty context with no memories")
+	}
+}
+
+func newTestCipher(t *testing.T) *crypt.Cipher {
+	t.Helper()
+	_, c, err := crypt.NewParams([]byte("secret"), 1000)
+	if err != nil {
+		t.Fatalf("NewParams failed: %v", err)
+	}
+	return c
+}
+
+func TestEncryptedChatStore(t *testing.T) {
+	db := openTestDB(t)
+	s := NewEncryptedChatStore(db, newTestCipher(t))
+
+	session, err := s.CreateSession("where I live", "")
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+	if err := s.Add(session.ID, agentModel.Message{Role: agentModel.UserRole, Content: "my address is 1 Main St"}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	var raw string
+	if err := db.QueryRow(`SELECT chat FROM chats`).Scan(&raw); err != nil {
+		t.Fatalf("Failed to read raw chat: %v", err)
+	}
+	if !crypt.IsSealed(raw) || strings.Contains(raw, "Main St") {
+		t.Errorf("Expected the stored chat to be encrypted, got %q", raw)
+	}
+
+	if err := db.QueryRow(`SELECT title FROM sessions`).Scan(&raw); err != nil {
+		t.Fatalf("Failed to read raw title: %v", err)
+	}
+	if !crypt.IsSealed(raw) {
+		t.Errorf("Expected the stored title to be encrypted, got %q", raw)
+	}
+
+	got, err := s.List(session.ID)
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(got) != 1 || got[0].Content != "my address is 1 Main St" {
+		t.Errorf("Expected the decrypted message, got %+v", got)
+	}
+	if sessions, err := s.Sessions(); err != nil || sessions[0].Title != "where I live" {
+		t.Errorf("Expected the decrypted title, got %+v (%v)", sessions, err)
+	}
+}
+
+func TestEncryptedMemoryStore(t *testing.T) {
+	db := openTestDB(t)
+	s := NewEncryptedMemoryStore(memory.NewMemoryStore(db), newTestCipher(t))
+
+	if err := s.Save("partner", "Alex, birthday in May"); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if err := s.Save(" partner ", "Alex, birthday on May 3rd"); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if err := s.Save("", "x"); err == nil {
+		t.Error("Expected an empty key to be rejected")
+	}
+
+	var key, content string
+	if err := db.QueryRow(`SELECT key, content FROM memories`).Scan(&key, &content); err != nil {
+		t.Fatalf("Expected exactly one stored memory: %v", err)
+	}
+	if !crypt.IsSealed(key) || !crypt.IsSealed(content) || strings.Contains(content, "Alex") {
+		t.Errorf("Expected key and content to be encrypted, got %q, %q", key, content)
+	}
+
+	found, err := s.Search("may 3")
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(found) != 1 || found[0].Key != "partner" {
+		t.Errorf("Expected to find the memory after decrypting, got %+v", found)
+	}
+	if !strings.Contains(s.GetMemoryContext(), "**partner**: Alex") {
+		t.Errorf("Unexpected memory context: %q", s.GetMemoryContext())
+	}
+
+	if err := s.Delete("partner"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if all, _ := s.GetAll(); len(all) != 0 {
+		t.Errorf("Expected the memory to be deleted, got %+v", all)
+	}
+}
+
+func TestEncryptPayloads(t *testing.T) {
+	db := openTestDB(t)
+	plainChats := NewSQLiteChatStore(db)
+	session, _ := plainChats.CreateSession("hello", "")
+	_ = plainChats.Add(session.ID, agentModel.Message{Role: agentModel.UserRole, Content: "hello"})
+	_ = memory.NewMemoryStore(db).Save("editor", "vim")
+
+	c := newTestCipher(t)
+	n, err := EncryptPayloads(db, c)
+	if err != nil {
+		t.Fatalf("EncryptPayloads failed: %v", err)
+	}
+	if n != 3 {
+		t.Errorf("Expected 3 rows encrypted, got %d", n)
+	}
+	if n, _ := EncryptPayloads(db, c); n != 0 {
+		t.Errorf("Expected a second run to change nothing, got %d", n)
+	}
+
+	chats, err := NewEncryptedChatStore(db, c).List(session.ID)
+	if err != nil || len(chats) != 1 || chats[0].Content != "hello" {
+		t.Errorf("Expected the chat to decrypt, got %+v, %v", chats, err)
+	}
+	if got, err := NewEncryptedChatStore(db, c).GetSession(session.ID); err != nil || got.Title != "hello" {
+		t.Errorf("Expected the title to decrypt, got %+v, %v", got, err)
+	}
+	memories, err := NewEncryptedMemoryStore(memory.NewMemoryStore(db), c).GetAll()
+	if err != nil || len(memories) != 1 || memories[0].Key != "editor" || memories[0].Content != "vim" {
+		t.Errorf("Expected the memory to decrypt, got %+v, %v", memories, err)
+	}
+}
@@ -2,278 +2,317 @@ package agent
 
 import (
 	"context"
-	"encoding/json"
+	"database/sql"
+	"errors"
 	"fmt"
 	"log/slog"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/biisal/godo/internal/builder"
 	"github.com/biisal/godo/internal/bus"
-	"github.com/biisal/godo/internal/config"
+	"github.com/biisal/godo/internal/llm"
+	"github.com/biisal/godo/internal/mcp"
+	"github.com/biisal/godo/internal/permission"
+	"github.com/biisal/godo/internal/plugin"
+	"github.com/biisal/godo/internal/sandbox"
+	"github.com/biisal/godo/internal/store"
+	"github.com/biisal/godo/internal/tui/actions/todo"
 	agentModel "github.com/biisal/godo/internal/tui/models/agent"
-	"github.com/openai/openai-go"
-	"github.com/openai/openai-go/option"
+	"github.com/biisal/godo/internal/workspace"
 )
 
+// Stores are the storage dependencies of a Bot.
+type Stores struct {
+	Todos    *todo.Service
+	Chats    store.ChatStore
+	Memories store.MemoryStore
+	// SQL is the database the PerformSql tool runs raw queries against.
+	SQL *sql.DB
+}
+
 type Bot struct {
 	History      []agentModel.Message
-	oaiMessages  []openai.ChatCompletionMessageParamUnion
-	client       *openai.Client
-	tools        []openai.ChatCompletionToolParam
+	provider     llm.Provider
+	tools        []llm.Tool
 	systemPrompt string
 	ModelName    string
+	// ContextBudget is the estimated size in tokens a request may reach
+	// before older turns are summarized; 0 never compacts.
+	ContextBudget int
+	// Session is the id of the stored conversation History belongs to; 0
+	// until the first message of a new conversation is saved.
+	Session int
+	// Dir is the working directory recorded with new sessions.
+	Dir string
+	// ToolWorkers caps how many read-only tool calls run at once; 0 uses
+	// DefaultToolWorkers.
+	ToolWorkers int
+	todos       *todo.Service
+	chats       store.ChatStore
+	memories    store.MemoryStore
+	sqlDB       *sql.DB
+	// confirm asks the user before a tool does something destructive.
+	confirm func(question string) bool
+	// Permissions decides which tool calls run, are denied or need the
+	// user's approval; nil runs every call.
+	Permissions *permission.Policy
+	approve     func(question string) bus.Approval
+	approveMu   sync.Mutex
+	// Workspace confines the file tools; paths outside it need the user's
+	// approval. nil leaves them unconfined.
+	Workspace *workspace.Workspace
+	// Sandbox isolates shell commands; nil runs them with the user's full
+	// environment and rights.
+	Sandbox *sandbox.Sandbox
+	// plugins are the user's tools added with AddPlugins, by name.
+	plugins map[string]*plugin.Plugin
+	// MCP runs the user's MCP servers, whose tools are offered next to the
+	// built-in ones; nil has none.
+	MCP *mcp.Manager
+	// depth is 0 for the main agent and counts the Delegate calls that led
+	// to a sub-agent. A sub-agent's messages are not saved, and its output
+	// goes to the delegate panel under delegateID, the call that started it.
+	depth      int
+	delegateID string
+	// allowed limits a sub-agent to the named tools; nil allows them all.
+	allowed map[string]bool
+	// maxSteps caps the model requests of one response; 0 uses
+	// maxToolSteps.
+	maxSteps int
+	// planMode limits the agent to investigating until the user approves
+	// a plan, which reviewPlan asks them about.
+	planMode   atomic.Bool
+	reviewPlan func(bus.Plan) bus.PlanReview
 }
 
-func NewBot() *Bot {
-	cb := builder.NewContextBuilder()
-	c := openai.NewClient(
-		option.WithAPIKey(config.Cfg.OPENAI_API_KEY),
-		option.WithBaseURL(config.Cfg.OPENAI_BASE_URL),
-	)
+func NewBot(stores Stores, provider llm.Provider) *Bot {
+	cb := builder.NewContextBuilder(stores.Memories)
 	return &Bot{
 		tools:        FormattedFunctions(),
 		systemPrompt: cb.BuildSystemPrompt(),
-		client:       &c,
+		provider:     provider,
+		todos:        stores.Todos,
+		chats:        stores.Chats,
+		memories:     stores.Memories,
+		sqlDB:        stores.SQL,
+		confirm:      bus.Confirm,
+		approve:      bus.Approve,
+		reviewPlan:   bus.ReviewPlan,
 	}
 }
 
+// GetChatHistoryFromDB returns the stored conversation as it is sent to the
+// model: turns replaced by a summary are left out.
 func (b *Bot) GetChatHistoryFromDB() (*[]agentModel.Message, error) {
-	sqlStmt := "SELECT chat FROM chats"
-	rows, err := config.Cfg.DB.Query(sqlStmt)
-	if err != nil {
-		return nil, fmt.Errorf("failed to query chats: %w", err)
+	if b.Session == 0 {
+		return &[]agentModel.Message{}, nil
 	}
-	defer func() {
-		if err := rows.Close(); err != nil {
-			slog.Error("error closing rows", "err", err)
-		}
-	}()
-
-	var history []agentModel.Message
-	for rows.Next() {
-		var chatContent []byte
-		if err := rows.Scan(&chatContent); err != nil {
-			return nil, fmt.Errorf("failed to scan chat: %w", err)
-		}
-		msg := agentModel.Message{}
-		if err := json.Unmarshal(chatContent, &msg); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal chat: %w", err)
-		}
-		history = append(history, msg)
+	log, err := b.chats.List(b.Session)
+	if err != nil {
+		return nil, err
 	}
+	history := repairToolCalls(activeHistory(log))
 	return &history, nil
 }
 
+// AddChatToDB saves msg to the current session, starting one titled after
+// the message when there is none yet.
 func (b *Bot) AddChatToDB(msg agentModel.Message) error {
-	sqlStmt := "INSERT INTO chats (chat) VALUES (?)"
-	msgJSON, err := json.Marshal(msg)
-	if err != nil {
-		return err
+	if b.Session == 0 {
+		session, err := b.chats.CreateSession(sessionTitle(msg.Content), b.Dir)
+		if err != nil {
+			return err
+		}
+		b.Session = session.ID
 	}
-	_, err = config.Cfg.DB.Exec(sqlStmt, string(msgJSON))
-	return err
+	return b.chats.Add(b.Session, msg)
 }
 
+// TruncateChats clears the messages of the current session.
 func (b *Bot) TruncateChats() error {
-	sqlStmt := "DELETE FROM chats"
-	_, err := config.Cfg.DB.Exec(sqlStmt)
-	if err == nil {
-		b.History = nil
-		b.oaiMessages = nil
-	}
-	return err
-}
-
-func toOAIMessage(m agentModel.Message) openai.ChatCompletionMessageParamUnion {
-	switch m.Role {
-	case agentModel.UserRole:
-		return openai.UserMessage(m.Content)
-	case agentModel.AssistantRole:
-		if len(m.ToolCalls) > 0 {
-			calls := make([]openai.ChatCompletionMessageToolCallParam, 0, len(m.ToolCalls))
-			for _, tc := range m.ToolCalls {
-				args := tc.Function.Arguments
-				if args == "" || !json.Valid([]byte(args)) {
-					args = "{}"
-				}
-				calls = append(calls, openai.ChatCompletionMessageToolCallParam{
-					ID:   tc.ID,
-					Type: "function",
-					Function: openai.ChatCompletionMessageToolCallFunctionParam{
-						Name:      tc.Function.Name,
-						Arguments: args,
-					},
-				})
-			}
-			asst := openai.ChatCompletionAssistantMessageParam{ToolCalls: calls}
-			return openai.ChatCompletionMessageParamUnion{OfAssistant: &asst}
+	if b.Session != 0 {
+		if err := b.chats.Truncate(b.Session); err != nil {
+			return err
 		}
-		return openai.AssistantMessage(m.Content)
-	case agentModel.ToolRole:
-		return openai.ToolMessage(m.Content, m.ToolCallID)
-	default:
-		return openai.UserMessage(m.Content)
 	}
+	b.History = nil
+	return nil
 }
 
+// appendMessage adds msg to History and saves it, so a resumed session has
+// every tool call and result in order.
 func (b *Bot) appendMessage(msg agentModel.Message) {
 	b.History = append(b.History, msg)
-	b.oaiMessages = append(b.oaiMessages, toOAIMessage(msg))
+	if err := b.save(msg); err != nil {
+		slog.Error("error saving chat to db", "err", err)
+	}
 }
 
-func (b *Bot) InitOAIMessages() {
-	b.oaiMessages = make([]openai.ChatCompletionMessageParamUnion, 0, len(b.History)+1)
-	b.oaiMessages = append(b.oaiMessages, openai.SystemMessage(b.systemPrompt))
-	for _, m := range b.History {
-		b.oaiMessages = append(b.oaiMessages, toOAIMessage(m))
+// save stores msg with the session; a sub-agent's conversation is thrown
+// away once it has answered.
+func (b *Bot) save(msg agentModel.Message) error {
+	if b.depth > 0 {
+		return nil
 	}
+	return b.AddChatToDB(msg)
 }
 
 const maxToolSteps = 200
 
-func deltaReasoning(delta openai.ChatCompletionChunkChoiceDelta) string {
-	if delta.JSON.ExtraFields == nil {
-		return ""
-	}
-	for _, key := range []string{"reasoning_content", "reasoning"} {
-		f, ok := delta.JSON.ExtraFields[key]
-		if !ok {
-			continue
-		}
-		var r string
-		if err := json.Unmarshal([]byte(f.Raw()), &r); err != nil {
-			slog.Error("failed to unmarshal reasoning", "key", key, "err", err)
-			continue
-		}
-		if r != "" {
-			return r
-		}
-	}
-	return ""
-}
+// ErrMaxSteps is returned when the model keeps calling tools past the step
+// limit of a response.
+var ErrMaxSteps = errors.New("agent exceeded max tool steps")
+
+// ErrCancelled is returned by AgentResponse when its context is cancelled.
+var ErrCancelled = errors.New("response cancelled")
 
-func (b *Bot) agentAPICall(refresh ...bool) (bool, error) {
+// cancelledMarker ends the assistant message saved for a cancelled response.
+const cancelledMarker = "[cancelled]"
+
+func (b *Bot) agentAPICall(ctx context.Context, refresh ...bool) (bool, error) {
 	isRefresh := false
 	if len(refresh) > 0 {
 		isRefresh = refresh[0]
 	}
-	if len(b.oaiMessages) == 0 {
-		b.InitOAIMessages()
-	}
-
-	for range maxToolSteps {
-		ctx, cancel := context.WithCancel(context.Background())
-
-		stream := b.client.Chat.Completions.NewStreaming(ctx, openai.ChatCompletionNewParams{
-			Model:    config.Cfg.OPENAI_MODEL,
-			Messages: b.oaiMessages,
-			Tools:    b.tools,
-		}, option.WithJSONSet("think", true))
-
-		acc := openai.ChatCompletionAccumulator{}
-		bus.EmitState(agentModel.StateThinking)
-		var reasoningBuilder strings.Builder
-		thiking := true
 
-		thinkStartTime := time.Now()
-		bus.EmitState(agentModel.StateThinking)
-		for stream.Next() {
-			chunk := stream.Current()
-			raw, _ := json.Marshal(chunk)
-			slog.Debug("Raw chunk received", "json", string(raw))
-			acc.AddChunk(chunk)
-
-			for _, choice := range chunk.Choices {
-				if r := deltaReasoning(choice.Delta); r != "" {
-					reasoningBuilder.WriteString(r)
-					bus.EmitThinking(r)
-					if choice.Delta.Content == "" {
-						continue
-					}
-				}
-				if thiking {
-					bus.EmitState(agentModel.StateReady)
-					bus.EmitMessageStatus(fmt.Sprintf("\nThought for %.1fs", time.Since(thinkStartTime).Seconds()))
-					thiking = false
+	steps := maxToolSteps
+	if b.maxSteps > 0 {
+		steps = b.maxSteps
+	}
+	// overflowing is set once the latest turn alone is over the budget,
+	// since compacting cannot help until the next turn.
+	overflowing := false
+	for range steps {
+		if ctx.Err() != nil {
+			return isRefresh, b.cancelResponse("", "")
+		}
+		if !overflowing && b.overBudget() {
+			// Keep recent turns in up to half the budget so the next
+			// compaction is a while away.
+			err := b.compact(ctx, b.ContextBudget/2)
+			switch {
+			case errors.Is(err, ErrNothingToCompact):
+				overflowing = true
+				b.emitStatus(fmt.Sprintf("\nThis turn alone is over the context budget of %d tokens, so there is nothing older to compact. Raise CONTEXT_BUDGET or start a new chat if the model runs out of context.", b.ContextBudget))
+			case err != nil:
+				if ctx.Err() != nil {
+					return isRefresh, b.cancelResponse("", "")
 				}
-				bus.EmitContent(choice.Delta.Content)
+				slog.Warn("failed to compact the conversation", "err", err)
 			}
 		}
-		cancel()
-
-		if err := stream.Err(); err != nil {
-			return isRefresh, fmt.Errorf("stream error: %w", err)
-		}
-
-		if len(acc.Choices) == 0 {
-			return isRefresh, nil
+		b.emitState(agentModel.StateThinking)
+		thinking := true
+		thinkStartTime := time.Now()
+		stopThinking := func() {
+			if thinking {
+				b.emitState(agentModel.StateReady)
+				b.emitStatus(fmt.Sprintf("\nThought for %.1fs", time.Since(thinkStartTime).Seconds()))
+				thinking = false
+			}
 		}
 
-		choice := acc.Choices[0]
-		toolCalls := choice.Message.ToolCalls
-
-		if len(toolCalls) > 0 {
-			assistantMsg := agentModel.Message{
-				Role:      agentModel.AssistantRole,
-				ToolCalls: toolCalls,
+		// The streamed text is kept so a cancelled reply can still be saved.
+		var content, reasoning strings.Builder
+		reply, err := b.provider.Stream(ctx, llm.Request{
+			System:   b.requestSystem(),
+			Messages: b.History,
+			Tools:    b.requestTools(),
+		}, func(d llm.Delta) {
+			if d.Reasoning != "" {
+				reasoning.WriteString(d.Reasoning)
+				b.emitThinking(d.Reasoning)
+				return
 			}
-			if txt := strings.TrimSpace(choice.Message.Content); txt != "" {
-				assistantMsg.Content = txt
-				bus.EmitContent("")
+			stopThinking()
+			content.WriteString(d.Content)
+			b.emitContent(d.Content)
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				stopThinking()
+				return isRefresh, b.cancelResponse(content.String(), reasoning.String())
 			}
-			assistantMsg.Reasoning = reasoningBuilder.String()
-			b.appendMessage(assistantMsg)
+			if hint := errorHint(err); hint != "" {
+				return isRefresh, fmt.Errorf("stream error: %w\n%s", err, hint)
+			}
+			return isRefresh, fmt.Errorf("stream error: %w", err)
+		}
+		stopThinking()
 
-			for _, tc := range toolCalls {
-				bus.EmitToolCall(tc.Function.Name)
-				slog.Info("\n\nRunning tool----------------------------", "name", tc.Function.Name, "args", tc.Function.Arguments)
-				result, shouldRefresh, err := runFunction(tc.Function.Name, tc)
-				slog.Info("\n\nTool result----------------------------", "result", result, "shouldRefresh", shouldRefresh, "err", err)
-				isRefresh = isRefresh || shouldRefresh
-				var resultStr string
-				if err != nil {
-					resultStr = err.Error()
-				} else {
-					if text, ok := result.(string); ok {
-						resultStr = text
-					} else {
-						b, _ := json.Marshal(result)
-						resultStr = string(b)
-					}
-				}
+		if len(reply.ToolCalls) > 0 {
+			reply.Content = strings.TrimSpace(reply.Content)
+			if reply.Content != "" {
+				b.emitContent("")
+			}
+			b.appendMessage(reply)
 
-				toolMsg := agentModel.Message{
-					Role:       agentModel.ToolRole,
-					ToolCallID: tc.ID,
-					Name:       tc.Function.Name,
-					Content:    resultStr,
-				}
-				b.appendMessage(toolMsg)
+			if b.runToolCalls(ctx, reply.ToolCalls) {
+				isRefresh = true
 			}
 			continue
 		}
 
-		finalText := choice.Message.Content
-		if finalText != "" || reasoningBuilder.Len() > 0 {
-			msg := agentModel.Message{
-				Role:      agentModel.AssistantRole,
-				Reasoning: reasoningBuilder.String(),
-				Content:   finalText,
-			}
-			b.appendMessage(msg)
-			if err := b.AddChatToDB(msg); err != nil {
-				slog.Error("error saving chat to db", "err", err)
-			}
+		if reply.Content != "" || reply.Reasoning != "" {
+			b.appendMessage(reply)
 		}
 
 		return isRefresh, nil
 	}
 
-	return isRefresh, fmt.Errorf("agent exceeded max tool steps (%d)", maxToolSteps)
+	return isRefresh, fmt.Errorf("%w (%d)", ErrMaxSteps, steps)
 }
 
-func (b *Bot) AgentResponse(prompt string) ([]agentModel.Message, bool, error) {
+// errorHint tells the user what to do about a failed model request.
+func errorHint(err error) string {
+	switch {
+	case errors.Is(err, llm.ErrAuth):
+		return "Check the API key of your provider in the config."
+	case errors.Is(err, llm.ErrModelNotFound):
+		return "Check the model name in the config."
+	case errors.Is(err, llm.ErrContextTooLong):
+		return "The conversation no longer fits the model; use /clear to start over."
+	case errors.Is(err, llm.ErrRateLimit):
+		return "The provider is rate limiting requests; wait a moment and try again."
+	}
+	return ""
+}
+
+// RetryStatus is the status line shown while a failed model request waits
+// to be retried.
+func RetryStatus(attempt, maxRetries int, delay time.Duration, err error) string {
+	reason := "Request failed"
+	switch {
+	case errors.Is(err, llm.ErrRateLimit):
+		reason = "Rate limited"
+	case errors.Is(err, llm.ErrServer):
+		reason = "Provider unavailable"
+	case errors.Is(err, llm.ErrConnection):
+		reason = "Connection lost"
+	}
+	return fmt.Sprintf("%s, retrying in %s (%d/%d)...", reason, delay.Round(100*time.Millisecond), attempt, maxRetries)
+}
+
+// cancelResponse ends a cancelled response with whatever the model had
+// streamed so far, marked as cancelled, and saves it like a normal reply.
+func (b *Bot) cancelResponse(content, reasoning string) error {
+	msg := agentModel.Message{
+		Role:      agentModel.AssistantRole,
+		Reasoning: reasoning,
+		Content:   strings.TrimSpace(strings.TrimSpace(content) + "\n\n" + cancelledMarker),
+	}
+	b.appendMessage(msg)
+	b.emitStatus("\n" + cancelledMarker)
+	return ErrCancelled
+}
+
+// AgentResponse sends prompt to the model and runs the tools it asks for
+// until it answers. Cancelling ctx stops the stream and any running tool;
+// the partial reply is kept and ErrCancelled is returned.
+func (b *Bot) AgentResponse(ctx context.Context, prompt string) ([]agentModel.Message, bool, error) {
 	if prompt == "" {
 		return nil, false, fmt.Errorf("empty message not allowd")
 	}
@@ -282,15 +321,16 @@ func (b *Bot) AgentResponse(prompt string) ([]agentModel.Message, bool, error) {
 		Content: prompt,
 	}
 	b.appendMessage(userMsg)
-	if err := b.AddChatToDB(userMsg); err != nil {
-		slog.Error("error saving chat to db", "err", err)
-	}
 
 	bus.EmitStreamStart()
 	bus.EmitState(agentModel.StateProcessing)
 
 	defer bus.EmitState(agentModel.StateIdle)
-	refresh, err := b.agentAPICall()
+	refresh, err := b.agentAPICall(ctx)
+	if errors.Is(err, ErrCancelled) {
+		bus.EmitStreamEnd()
+		return b.History, refresh, err
+	}
 	if err != nil {
 		return nil, refresh, err
 	}
@@ -298,9 +338,29 @@ func (b *Bot) AgentResponse(prompt string) ([]agentModel.Message, bool, error) {
 	return b.History, refresh, nil
 }
 
-func runFunction(funcName string, tc openai.ChatCompletionMessageToolCall) (any, bool, error) {
-	if fn, ok := tools[funcName]; ok {
-		return fn(tc)
+func (b *Bot) runFunction(ctx context.Context, funcName string, tc llm.ToolCall) (any, bool, error) {
+	fn, ok := tools[funcName]
+	if !ok {
+		fn, ok = b.extraTool(funcName)
+		if !ok {
+			return nil, false, fmt.Errorf("unknown function: %s", funcName)
+		}
+	}
+	if !b.offers(funcName) {
+		if b.PlanMode() {
+			return nil, false, fmt.Errorf("%s is not available in plan mode; investigate with read-only tools and submit a plan with SubmitPlan", funcName)
+		}
+		if funcName == SubmitPlanFunc {
+			return nil, false, fmt.Errorf("SubmitPlan is only available in plan mode")
+		}
+		return nil, false, fmt.Errorf("%s is not available to this sub-agent", funcName)
+	}
+	asked, err := b.checkPermission(tc)
+	if err != nil {
+		return nil, false, err
+	}
+	if asked {
+		ctx = withApproval(ctx)
 	}
-	return nil, false, fmt.Errorf("unknown function: %s", funcName)
+	return fn(b, ctx, tc)
 }
@@ -1,61 +1,518 @@
 package agent
 
 import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/biisal/godo/internal/bus"
+	"github.com/biisal/godo/internal/config"
+	"github.com/biisal/godo/internal/llm"
+	"github.com/biisal/godo/internal/migrate"
+	"github.com/biisal/godo/internal/permission"
+	"github.com/biisal/godo/internal/store"
+	todoAction "github.com/biisal/godo/internal/tui/actions/todo"
 	agentModel "github.com/biisal/godo/internal/tui/models/agent"
 )
 
-func TestAppendMessageUpdatesOAIMessages(t *testing.T) {
+func TestAppendMessageUpdatesHistory(t *testing.T) {
+	chats := store.NewFakeChatStore()
 	b := &Bot{
 		systemPrompt: "You are a test agent.",
+		chats:        chats,
 	}
 
-	b.InitOAIMessages()
+	b.appendMessage(agentModel.Message{Role: agentModel.UserRole, Content: "Hello World"})
+	b.appendMessage(agentModel.Message{Role: agentModel.AssistantRole, Content: "Hello Human"})
 
-	if len(b.oaiMessages) != 1 {
-		t.Fatalf("expected 1 oaiMessage (system prompt), got %d", len(b.oaiMessages))
+	if len(b.History) != 2 {
+		t.Fatalf("expected 2 History items, got %d", len(b.History))
+	}
+	if b.History[1].Content != "Hello Human" {
+		t.Errorf("expected the assistant reply last, got %+v", b.History[1])
+	}
+	if saved, _ := chats.List(b.Session); len(saved) != 2 {
+		t.Errorf("expected both messages saved, got %+v", saved)
 	}
+}
 
-	userMsg := agentModel.Message{
-		Role:    agentModel.UserRole,
-		Content: "Hello World",
+// scriptedProvider replays one reply per model call and records requests.
+type scriptedProvider struct {
+	replies  []llm.Message
+	requests []llm.Request
+}
+
+func (p *scriptedProvider) Name() string  { return "scripted" }
+func (p *scriptedProvider) Model() string { return "test-model" }
+
+func (p *scriptedProvider) Stream(_ context.Context, req llm.Request, onDelta func(llm.Delta)) (llm.Message, error) {
+	p.requests = append(p.requests, req)
+	if len(p.replies) == 0 {
+		return llm.Message{}, errors.New("no scripted reply left")
+	}
+	reply := p.replies[0]
+	p.replies = p.replies[1:]
+	if reply.Reasoning != "" {
+		onDelta(llm.Delta{Reasoning: reply.Reasoning})
+	}
+	if reply.Content != "" {
+		onDelta(llm.Delta{Content: reply.Content})
 	}
-	b.appendMessage(userMsg)
+	return reply, nil
+}
+
+func TestAgentResponseRunsToolLoop(t *testing.T) {
+	drainBus(t)
+	provider := &scriptedProvider{replies: []llm.Message{
+		{Role: llm.RoleAssistant, ToolCalls: []llm.ToolCall{{
+			ID:       "call_1",
+			Type:     "function",
+			Function: llm.FunctionCall{Name: AddTodoFunc, Arguments: `{"title":"Buy milk","description":"two litres"}`},
+		}}},
+		{Role: llm.RoleAssistant, Reasoning: "done", Content: "Added it."},
+	}}
+	chats := store.NewFakeChatStore()
+	b := NewBot(Stores{
+		Todos:    todoAction.NewService(store.NewFakeTodoStore(), config.StoreGlobal),
+		Chats:    chats,
+		Memories: store.NewFakeMemoryStore(),
+	}, provider)
 
-	if len(b.History) != 1 {
-		t.Errorf("expected 1 History item, got %d", len(b.History))
+	history, refresh, err := b.AgentResponse(context.Background(), "add buy milk")
+	if err != nil {
+		t.Fatalf("AgentResponse failed: %v", err)
+	}
+	if !refresh {
+		t.Error("Expected the AddTodo call to ask for a refresh")
+	}
+	if len(history) != 4 || history[2].Role != agentModel.ToolRole || history[2].ToolCallID != "call_1" || history[3].Content != "Added it." {
+		t.Fatalf("Expected user, tool call, tool result and reply, got %+v", history)
 	}
-	if len(b.oaiMessages) != 2 {
-		t.Fatalf("expected 2 oaiMessages (system + user), got %d", len(b.oaiMessages))
+	if len(provider.requests) != 2 || provider.requests[1].System == "" || len(provider.requests[1].Messages) != 3 || len(provider.requests[1].Tools) == 0 {
+		t.Errorf("Expected the second request to carry the tool result, got %+v", provider.requests)
 	}
+	if todos, _ := b.todos.GetTodos(); len(todos) != 1 {
+		t.Errorf("Expected the tool to add a todo, got %+v", todos)
+	}
+	if saved, _ := chats.List(b.Session); !reflect.DeepEqual(saved, history) {
+		t.Errorf("Expected every message to be saved in order, got %+v", saved)
+	}
+}
 
-	asstMsg := agentModel.Message{
-		Role:    agentModel.AssistantRole,
-		Content: "Hello Human",
+// cancellingProvider streams part of a reply, then cancels the request the
+// way Esc does in the TUI.
+type cancellingProvider struct {
+	scriptedProvider
+	cancel context.CancelFunc
+}
+
+func (p *cancellingProvider) Stream(ctx context.Context, req llm.Request, onDelta func(llm.Delta)) (llm.Message, error) {
+	if p.cancel == nil {
+		return p.scriptedProvider.Stream(ctx, req, onDelta)
 	}
-	b.appendMessage(asstMsg)
+	p.requests = append(p.requests, req)
+	onDelta(llm.Delta{Content: "Partial answer"})
+	p.cancel()
+	p.cancel = nil
+	<-ctx.Done()
+	return llm.Message{}, ctx.Err()
+}
 
-	if len(b.History) != 2 {
-		t.Errorf("expected 2 History items, got %d", len(b.History))
+func TestAgentResponseCancelDuringStream(t *testing.T) {
+	drainBus(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	provider := &cancellingProvider{cancel: cancel}
+	chats := store.NewFakeChatStore()
+	b := NewBot(Stores{Chats: chats, Memories: store.NewFakeMemoryStore()}, provider)
+
+	history, _, err := b.AgentResponse(ctx, "tell me a story")
+	if !errors.Is(err, ErrCancelled) {
+		t.Fatalf("Expected ErrCancelled, got %v", err)
+	}
+	if len(history) != 2 || history[1].Role != agentModel.AssistantRole || history[1].Content != "Partial answer\n\n[cancelled]" {
+		t.Fatalf("Expected the partial reply to be kept, got %+v", history)
+	}
+	if saved, _ := chats.List(b.Session); len(saved) != 2 || saved[1].Content != history[1].Content {
+		t.Errorf("Expected the prompt and partial reply to be saved, got %+v", saved)
+	}
+
+	// The next prompt goes out on top of the cancelled turn.
+	provider.replies = []llm.Message{{Role: llm.RoleAssistant, Content: "Sure."}}
+	if _, _, err := b.AgentResponse(context.Background(), "never mind"); err != nil {
+		t.Fatalf("AgentResponse after a cancel failed: %v", err)
 	}
-	if len(b.oaiMessages) != 3 {
-		t.Fatalf("expected 3 oaiMessages (system + user + assistant), got %d", len(b.oaiMessages))
+	if msgs := provider.requests[1].Messages; len(msgs) != 3 || msgs[2].Content != "never mind" {
+		t.Errorf("Expected the cancelled turn in the next request, got %+v", msgs)
 	}
 }
 
-func TestInitOAIMessagesRebuildsCorrectly(t *testing.T) {
-	b := &Bot{
-		systemPrompt: "System",
-		History: []agentModel.Message{
-			{Role: agentModel.UserRole, Content: "1"},
-			{Role: agentModel.AssistantRole, Content: "2"},
+func TestAgentResponseCancelDuringTools(t *testing.T) {
+	drainBus(t)
+	provider := &scriptedProvider{replies: []llm.Message{
+		{Role: llm.RoleAssistant, ToolCalls: []llm.ToolCall{
+			{ID: "call_1", Type: "function", Function: llm.FunctionCall{Name: RunShellCommandFunc, Arguments: `{"command":"sleep 30"}`}},
+			{ID: "call_2", Type: "function", Function: llm.FunctionCall{Name: ListTodosFunc, Arguments: `{}`}},
+		}},
+	}}
+	b := NewBot(Stores{
+		Todos:    todoAction.NewService(store.NewFakeTodoStore(), config.StoreGlobal),
+		Chats:    store.NewFakeChatStore(),
+		Memories: store.NewFakeMemoryStore(),
+	}, provider)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	start := time.Now()
+	history, _, err := b.AgentResponse(ctx, "wait a bit")
+	if !errors.Is(err, ErrCancelled) {
+		t.Fatalf("Expected ErrCancelled, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("Expected the shell command to be killed, took %s", elapsed)
+	}
+
+	// Both calls have a result, so the history is valid for the next request.
+	if len(history) != 5 {
+		t.Fatalf("Expected user, tool calls, two results and the marker, got %+v", history)
+	}
+	if history[2].ToolCallID != "call_1" || !strings.Contains(history[2].Content, "cancelled") {
+		t.Errorf("Expected the shell command to report the cancel, got %+v", history[2])
+	}
+	if history[3].ToolCallID != "call_2" || !strings.Contains(history[3].Content, "before it ran") {
+		t.Errorf("Expected a synthetic result for the skipped call, got %+v", history[3])
+	}
+	if history[4].Role != agentModel.AssistantRole || history[4].Content != "[cancelled]" {
+		t.Errorf("Expected the cancelled marker last, got %+v", history[4])
+	}
+	if len(provider.requests) != 1 {
+		t.Errorf("Expected no request after the cancel, got %d", len(provider.requests))
+	}
+}
+
+func newTestBot(chats *store.FakeChatStore, memories *store.FakeMemoryStore) *Bot {
+	return NewBot(Stores{
+		Todos:    todoAction.NewService(store.NewFakeTodoStore(), config.StoreGlobal),
+		Chats:    chats,
+		Memories: memories,
+	}, &scriptedProvider{})
+}
+
+// drainBus consumes bus messages emitted by tool handlers until the test ends.
+func drainBus(t *testing.T) {
+	t.Helper()
+	done := make(chan struct{})
+	t.Cleanup(func() { close(done) })
+	go func() {
+		for {
+			select {
+			case <-bus.StreamResponse:
+			case <-done:
+				return
+			}
+		}
+	}()
+}
+
+func TestChatHistoryUsesChatStore(t *testing.T) {
+	chats := store.NewFakeChatStore(agentModel.Message{Role: agentModel.UserRole, Content: "earlier"})
+	b := newTestBot(chats, store.NewFakeMemoryStore())
+	b.Session = 1
+
+	if err := b.AddChatToDB(agentModel.Message{Role: agentModel.AssistantRole, Content: "reply"}); err != nil {
+		t.Fatalf("AddChatToDB failed: %v", err)
+	}
+	history, err := b.GetChatHistoryFromDB()
+	if err != nil {
+		t.Fatalf("GetChatHistoryFromDB failed: %v", err)
+	}
+	if len(*history) != 2 || (*history)[1].Content != "reply" {
+		t.Fatalf("Expected 2 messages ending with the reply, got %+v", *history)
+	}
+
+	b.History = *history
+	if err := b.TruncateChats(); err != nil {
+		t.Fatalf("TruncateChats failed: %v", err)
+	}
+	if msgs, _ := chats.List(b.Session); len(msgs) != 0 || b.History != nil {
+		t.Errorf("Expected store and history to be cleared, got %d stored", len(msgs))
+	}
+}
+
+func TestSaveMemoryToolUsesMemoryStore(t *testing.T) {
+	drainBus(t)
+	memories := store.NewFakeMemoryStore()
+	b := newTestBot(store.NewFakeChatStore(), memories)
+
+	tc := llm.ToolCall{
+		Function: llm.FunctionCall{
+			Name:      SaveMemoryFunc,
+			Arguments: `{"key":"editor","content":"prefers vim"}`,
 		},
 	}
+	if _, _, err := b.runFunction(context.Background(), SaveMemoryFunc, tc); err != nil {
+		t.Fatalf("SaveMemory failed: %v", err)
+	}
+	entries, _ := memories.GetAll()
+	if len(entries) != 1 || entries[0].Key != "editor" {
+		t.Errorf("Expected the memory to be saved, got %+v", entries)
+	}
+	if _, _, err := b.runFunction(context.Background(), "NoSuchTool", tc); err == nil {
+		t.Error("Expected an error for an unknown tool")
+	}
+}
+
+func newSQLTestBot(t *testing.T, confirm func(string) bool) *Bot {
+	t.Helper()
+	dbPath := filepath.Join(t.TempDir(), "todo.db")
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+	if _, err := migrate.Run(db, migrate.Backups{}); err != nil {
+		t.Fatalf("Failed to migrate database: %v", err)
+	}
+	if _, err := db.Exec(`INSERT INTO todos (Title, Description) VALUES ('a', 'b'), ('c', 'd')`); err != nil {
+		t.Fatalf("Failed to seed todos: %v", err)
+	}
+	b := newTestBot(store.NewFakeChatStore(), store.NewFakeMemoryStore())
+	b.sqlDB = db
+	b.confirm = confirm
+	return b
+}
 
-	b.InitOAIMessages()
+func sqlToolCall(query string) llm.ToolCall {
+	args, _ := json.Marshal(map[string]string{"query": query})
+	return llm.ToolCall{
+		Function: llm.FunctionCall{Name: PerformSQLFunc, Arguments: string(args)},
+	}
+}
 
-	if len(b.oaiMessages) != 3 {
-		t.Fatalf("expected 3 messages, got %d", len(b.oaiMessages))
+func countTodos(t *testing.T, b *Bot) int {
+	t.Helper()
+	var n int
+	if err := b.sqlDB.QueryRow(`SELECT COUNT(*) FROM todos`).Scan(&n); err != nil {
+		t.Fatalf("Failed to count todos: %v", err)
 	}
+	return n
+}
+
+func TestPerformSqlGuard(t *testing.T) {
+	drainBus(t)
+	var asked []string
+	allow := false
+	b := newSQLTestBot(t, func(q string) bool {
+		asked = append(asked, q)
+		return allow
+	})
+
+	result, refresh, err := b.runPerformSql(context.Background(), sqlToolCall("SELECT Title FROM todos ORDER BY Id"))
+	if err != nil || refresh || !strings.Contains(result.(string), `"a"`) {
+		t.Errorf("Expected a read without refresh, got %v, %v, %v", result, refresh, err)
+	}
+
+	for _, query := range []string{
+		"DROP TABLE memories",
+		"DELETE FROM memories",
+		"SELECT 1; DELETE FROM todos",
+		"ATTACH DATABASE ':memory:' AS x",
+	} {
+		if _, _, err := b.runPerformSql(context.Background(), sqlToolCall(query)); err == nil {
+			t.Errorf("Expected %q to be blocked", query)
+		}
+	}
+
+	if _, refresh, err := b.runPerformSql(context.Background(), sqlToolCall("UPDATE todos SET Done = 1 WHERE Id = 1")); err != nil || !refresh {
+		t.Errorf("Expected a targeted update to run and refresh, got %v, %v", refresh, err)
+	}
+	if len(asked) != 0 {
+		t.Errorf("Expected no confirmation for safe statements, got %q", asked)
+	}
+
+	result, refresh, err = b.runPerformSql(context.Background(), sqlToolCall("DELETE FROM todos"))
+	if err != nil || refresh || !strings.Contains(result.(string), "declined") {
+		t.Errorf("Expected a declined delete, got %v, %v, %v", result, refresh, err)
+	}
+	if len(asked) != 1 || !strings.Contains(asked[0], "DELETE FROM todos") {
+		t.Errorf("Expected one confirmation naming the statement, got %q", asked)
+	}
+	if n := countTodos(t, b); n != 2 {
+		t.Fatalf("Expected the declined delete to leave 2 todos, got %d", n)
+	}
+
+	allow = true
+	if _, refresh, err := b.runPerformSql(context.Background(), sqlToolCall("DELETE FROM todos")); err != nil || !refresh {
+		t.Errorf("Expected the confirmed delete to run, got %v, %v", refresh, err)
+	}
+	if n := countTodos(t, b); n != 0 {
+		t.Errorf("Expected the confirmed delete to remove all todos, got %d", n)
+	}
+}
+
+func TestPerformSqlAsksOnce(t *testing.T) {
+	drainBus(t)
+	var asked []string
+	b := newSQLTestBot(t, func(question string) bool {
+		asked = append(asked, question)
+		return true
+	})
+	policy, err := permission.New(nil, DefaultPermissions)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	b.Permissions = policy
+	b.approve = func(question string) bus.Approval {
+		asked = append(asked, question)
+		return bus.AllowOnce
+	}
+
+	tc := sqlToolCall("DELETE FROM todos")
+	if _, _, err := b.runFunction(context.Background(), PerformSQLFunc, tc); err != nil {
+		t.Fatalf("PerformSql failed: %v", err)
+	}
+	if len(asked) != 1 {
+		t.Errorf("Expected one prompt for the delete, got %q", asked)
+	}
+	if n := countTodos(t, b); n != 0 {
+		t.Errorf("Expected the approved delete to remove all todos, got %d", n)
+	}
+}
+
+func callTool(t *testing.T, b *Bot, name string, args any) (map[string]any, bool, error) {
+	t.Helper()
+	raw, _ := json.Marshal(args)
+	result, refresh, err := b.runFunction(context.Background(), name, llm.ToolCall{
+		Function: llm.FunctionCall{Name: name, Arguments: string(raw)},
+	})
+	if err != nil {
+		return nil, refresh, err
+	}
+	// Round-trip through JSON like the result sent to the model.
+	encoded, _ := json.Marshal(result)
+	var decoded map[string]any
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatalf("Expected a JSON object from %s, got %s", name, encoded)
+	}
+	return decoded, refresh, nil
+}
+
+func TestTodoTools(t *testing.T) {
+	drainBus(t)
+	b := newTestBot(store.NewFakeChatStore(), store.NewFakeMemoryStore())
+
+	if _, _, err := callTool(t, b, AddTodoFunc, map[string]string{"title": " ", "description": "x"}); !errors.Is(err, todoAction.ErrorEmpty) {
+		t.Errorf("Expected AddTodo to keep the ErrorEmpty validation, got %v", err)
+	}
+	added, refresh, err := callTool(t, b, AddTodoFunc, map[string]string{"title": "Buy milk", "description": "two litres"})
+	if err != nil || !refresh {
+		t.Fatalf("AddTodo failed: %v, refresh %v", err, refresh)
+	}
+	todo := added["todo"].(map[string]any)
+	if todo["title"] != "Buy milk" || todo["done"] != false {
+		t.Fatalf("Expected the created todo, got %v", todo)
+	}
+	id := int(todo["id"].(float64))
+	_, _, _ = callTool(t, b, AddTodoFunc, map[string]string{"title": "Call mom", "description": "sunday"})
+
+	updated, _, err := callTool(t, b, UpdateTodoFunc, map[string]any{"id": id, "description": "one litre", "done": true})
+	if err != nil {
+		t.Fatalf("UpdateTodo failed: %v", err)
+	}
+	todo = updated["todo"].(map[string]any)
+	if todo["title"] != "Buy milk" || todo["description"] != "one litre" || todo["done"] != true {
+		t.Errorf("Expected only description and done to change, got %v", todo)
+	}
+	if _, _, err := callTool(t, b, UpdateTodoFunc, map[string]any{"id": id, "title": ""}); !errors.Is(err, todoAction.ErrorEmpty) {
+		t.Errorf("Expected UpdateTodo to reject a blank title, got %v", err)
+	}
+	if _, _, err := callTool(t, b, UpdateTodoFunc, map[string]any{"id": 999, "done": true}); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a missing todo, got %v", err)
+	}
+
+	listed, refresh, err := callTool(t, b, ListTodosFunc, map[string]string{"status": "open"})
+	if err != nil || refresh {
+		t.Fatalf("ListTodos failed: %v, refresh %v", err, refresh)
+	}
+	if listed["count"] != float64(1) || listed["total"] != float64(2) || listed["completed"] != float64(1) {
+		t.Errorf("Expected one open todo out of two, got %v", listed)
+	}
+
+	toggled, _, err := callTool(t, b, ToggleTodoFunc, map[string]int{"id": id})
+	if err != nil || toggled["done"] != false {
+		t.Errorf("Expected ToggleTodo to reopen the todo, got %v, %v", toggled, err)
+	}
+
+	if _, _, err := callTool(t, b, DeleteTodoFunc, map[string]int{"id": id}); err != nil {
+		t.Fatalf("DeleteTodo failed: %v", err)
+	}
+	if _, _, err := callTool(t, b, DeleteTodoFunc, map[string]int{"id": id}); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Expected a second delete to report ErrNotFound, got %v", err)
+	}
+}
+
+func TestQuerySQLiteTool(t *testing.T) {
+	drainBus(t)
+	path := filepath.Join(t.TempDir(), "app.db")
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	if _, err := db.Exec(`CREATE TABLE users (id INTEGER PRIMARY KEY, email TEXT); INSERT INTO users (email) VALUES ('a@example.com')`); err != nil {
+		t.Fatalf("Failed to set up database: %v", err)
+	}
+	_ = db.Close()
+	b := newTestBot(store.NewFakeChatStore(), store.NewFakeMemoryStore())
+
+	schema, _, err := callTool(t, b, SQLiteSchemaFunc, map[string]string{"path": path})
+	if err != nil || !strings.Contains(schema["schema"].(string), "table users") {
+		t.Errorf("Expected the users table in the schema, got %v, %v", schema, err)
+	}
+	result, refresh, err := callTool(t, b, QuerySQLiteFunc, map[string]any{"path": path, "query": "SELECT email FROM users"})
+	if err != nil || refresh || !strings.Contains(result["table"].(string), "a@example.com") {
+		t.Errorf("Expected a table with the row, got %v, %v", result, err)
+	}
+	if _, _, err := callTool(t, b, QuerySQLiteFunc, map[string]any{"path": path, "query": "DELETE FROM users"}); err == nil {
+		t.Error("Expected a write to be rejected")
+	}
+}
+
+func TestRetryStatus(t *testing.T) {
+	err := &llm.APIError{Provider: "anthropic", Category: llm.ErrRateLimit, StatusCode: 429}
+	if got := RetryStatus(1, 4, 2*time.Second, err); got != "Rate limited, retrying in 2s (1/4)..." {
+		t.Errorf("Unexpected status %q", got)
+	}
+	err = &llm.APIError{Provider: "openai", Category: llm.ErrConnection}
+	if got := RetryStatus(2, 4, 1234*time.Millisecond, fmt.Errorf("wrapped: %w", err)); got != "Connection lost, retrying in 1.2s (2/4)..." {
+		t.Errorf("Unexpected status %q", got)
+	}
+}
+
+func TestAgentResponseErrorHint(t *testing.T) {
+	drainBus(t)
+	b := newTestBot(store.NewFakeChatStore(), store.NewFakeMemoryStore())
+	b.provider = &failingProvider{err: &llm.APIError{Provider: "openai", Category: llm.ErrContextTooLong, StatusCode: 400, Message: "too long"}}
+
+	_, _, err := b.AgentResponse(context.Background(), "hi")
+	if !errors.Is(err, llm.ErrContextTooLong) || !strings.Contains(err.Error(), "/clear") {
+		t.Errorf("Expected a context error with a hint, got %v", err)
+	}
+}
+
+// failingProvider fails every request with err.
+type failingProvider struct {
+	scriptedProvider
+	err error
+}
+
+func (p *failingProvider) Stream(context.Context, llm.Request, func(llm.Delta)) (llm.Message, error) {
+	return llm.Message{}, p.err
 }
@@ -0,0 +1,168 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/biisal/godo/internal/llm"
+	agentModel "github.com/biisal/godo/internal/tui/models/agent"
+)
+
+// ErrNothingToCompact is returned by Compact when there are no older turns
+// to summarize.
+var ErrNothingToCompact = errors.New("nothing to compact yet")
+
+const summaryPrompt = `You summarize conversations between a user and their terminal assistant so the assistant can continue without the full transcript.
+Keep facts, decisions, user preferences, file paths, commands, todo ids and anything still open or promised. Drop greetings and repetition.
+Write plain text in at most 300 words, without a preamble.`
+
+// summaryHeader starts the content of every summary message.
+const summaryHeader = "Summary of the earlier conversation:\n"
+
+// maxToolResultChars caps each tool result in the transcript sent for
+// summarizing; the model's reply about it usually says what mattered.
+const maxToolResultChars = 1000
+
+// activeHistory returns the part of a stored chat log that is sent to the
+// model: the latest summary followed by the turns it kept and everything
+// after it. Logs without a summary are returned as they are.
+func activeHistory(log []agentModel.Message) []agentModel.Message {
+	last := -1
+	for i, m := range log {
+		if m.Summary {
+			last = i
+		}
+	}
+	if last < 0 {
+		return log
+	}
+
+	summary := log[last]
+	start := last
+	for turns := 0; start > 0 && turns < summary.KeptTurns; {
+		start--
+		if !log[start].Summary && log[start].Role == agentModel.UserRole {
+			turns++
+		}
+	}
+	history := []agentModel.Message{summary}
+	for _, m := range log[start:] {
+		if !m.Summary {
+			history = append(history, m)
+		}
+	}
+	return history
+}
+
+// overBudget reports whether the next request would exceed the context
+// budget. A budget of 0 turns compaction off.
+func (b *Bot) overBudget() bool {
+	if b.ContextBudget <= 0 {
+		return false
+	}
+	return llm.EstimateRequestTokens(llm.Request{
+		System:   b.requestSystem(),
+		Messages: b.History,
+		Tools:    b.requestTools(),
+	}) > b.ContextBudget
+}
+
+// compactCut returns the index of the first message to keep: the start of
+// the oldest turn that still fits in keepTokens, but never later than the
+// last turn. It returns 0 when there is nothing older to summarize.
+func (b *Bot) compactCut(keepTokens int) int {
+	cut, tokens := 0, 0
+	for i := len(b.History) - 1; i > 0; i-- {
+		tokens += llm.EstimateTokens(b.History[i])
+		if b.History[i].Role != agentModel.UserRole || b.History[i].Summary {
+			continue
+		}
+		if cut != 0 && tokens > keepTokens {
+			break
+		}
+		cut = i
+	}
+	if cut == 1 && b.History[0].Summary {
+		// Only the previous summary is older.
+		return 0
+	}
+	return cut
+}
+
+// Compact summarizes all turns but the latest into a summary message, which
+// is stored with the chats and replaces them in History. The original
+// messages stay in the database.
+func (b *Bot) Compact(ctx context.Context) error {
+	return b.compact(ctx, 0)
+}
+
+// compact keeps the most recent turns that fit in keepTokens, and at least
+// the latest one, and summarizes the rest.
+func (b *Bot) compact(ctx context.Context, keepTokens int) error {
+	cut := b.compactCut(keepTokens)
+	if cut == 0 {
+		return ErrNothingToCompact
+	}
+	b.emitState(agentModel.StateCompacting)
+
+	older, kept := b.History[:cut], b.History[cut:]
+	reply, err := b.provider.Stream(ctx, llm.Request{
+		System:   summaryPrompt,
+		Messages: []llm.Message{{Role: llm.RoleUser, Content: transcript(older)}},
+	}, func(llm.Delta) {})
+	if err != nil {
+		return fmt.Errorf("failed to summarize the conversation: %w", err)
+	}
+	text := strings.TrimSpace(reply.Content)
+	if text == "" {
+		return fmt.Errorf("failed to summarize the conversation: the model returned no summary")
+	}
+
+	keptTurns := 0
+	for _, m := range kept {
+		if m.Role == agentModel.UserRole {
+			keptTurns++
+		}
+	}
+	summary := agentModel.Message{
+		Role:      agentModel.SystemRole,
+		Content:   summaryHeader + text,
+		Summary:   true,
+		KeptTurns: keptTurns,
+	}
+	if err := b.save(summary); err != nil {
+		return fmt.Errorf("failed to save the summary: %w", err)
+	}
+	b.History = append([]agentModel.Message{summary}, kept...)
+	slog.Info("compacted conversation", "summarized", len(older), "kept", len(kept))
+	b.emitStatus(fmt.Sprintf("\nCompacted %d earlier messages into a summary", len(older)))
+	return nil
+}
+
+// transcript renders msgs as plain text for the summarizer, so tool calls
+// need no matching results.
+func transcript(msgs []agentModel.Message) string {
+	var sb strings.Builder
+	for _, m := range msgs {
+		switch {
+		case m.Summary:
+			sb.WriteString(strings.TrimPrefix(m.Content, summaryHeader))
+		case m.Role == agentModel.ToolRole:
+			content := m.Content
+			if len(content) > maxToolResultChars {
+				content = content[:maxToolResultChars] + "..."
+			}
+			fmt.Fprintf(&sb, "tool %s returned: %s", m.Name, content)
+		default:
+			sb.WriteString(m.Role + ": " + m.Content)
+			for _, tc := range m.ToolCalls {
+				fmt.Fprintf(&sb, "\n%s called %s %s", m.Role, tc.Function.Name, tc.Function.Arguments)
+			}
+		}
+		sb.WriteString("\n\n")
+	}
+	return strings.TrimSpace(sb.String())
+}
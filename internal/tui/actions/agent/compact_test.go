@@ -0,0 +1,203 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/biisal/godo/internal/bus"
+	"github.com/biisal/godo/internal/llm"
+	"github.com/biisal/godo/internal/store"
+	agentModel "github.com/biisal/godo/internal/tui/models/agent"
+)
+
+func turns(texts ...string) []agentModel.Message {
+	var msgs []agentModel.Message
+	for i, text := range texts {
+		role := agentModel.UserRole
+		if i%2 == 1 {
+			role = agentModel.AssistantRole
+		}
+		msgs = append(msgs, agentModel.Message{Role: role, Content: text})
+	}
+	return msgs
+}
+
+func summaryOf(text string, kept int) agentModel.Message {
+	return agentModel.Message{Role: agentModel.SystemRole, Content: summaryHeader + text, Summary: true, KeptTurns: kept}
+}
+
+func TestActiveHistory(t *testing.T) {
+	log := turns("u1", "a1", "u2", "a2")
+	if got := activeHistory(log); !reflect.DeepEqual(got, log) {
+		t.Errorf("Expected a log without a summary unchanged, got %+v", got)
+	}
+
+	first := summaryOf("one", 1)
+	second := summaryOf("two", 2)
+	log = append(turns("u1", "a1", "u2", "a2"), first)
+	log = append(log, turns("u3", "a3", "u4", "a4")...)
+	log = append(log, second)
+	log = append(log, turns("u5", "a5")...)
+
+	want := []agentModel.Message{second}
+	want = append(want, turns("u3", "a3", "u4", "a4", "u5", "a5")...)
+	if got := activeHistory(log); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected the latest summary and the turns after its cut, got %+v", got)
+	}
+}
+
+func TestCompact(t *testing.T) {
+	drainBus(t)
+	log := turns("I like green tea", "Noted.", "Add a todo to buy tea", "Added todo 3.", "What next?", "Brew it.")
+	chats := store.NewFakeChatStore(log...)
+	b := newTestBot(chats, store.NewFakeMemoryStore())
+	b.Session = 1
+	provider := &scriptedProvider{replies: []llm.Message{{Role: llm.RoleAssistant, Content: "The user likes green tea; todo 3 is buying tea."}}}
+	b.provider = provider
+	b.History = append([]agentModel.Message(nil), log...)
+
+	if err := b.Compact(context.Background()); err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+	want := append([]agentModel.Message{summaryOf("The user likes green tea; todo 3 is buying tea.", 1)}, log[4:]...)
+	if !reflect.DeepEqual(b.History, want) {
+		t.Fatalf("Expected the summary and the last turn, got %+v", b.History)
+	}
+
+	req := provider.requests[0]
+	if req.System != summaryPrompt || len(req.Tools) != 0 || len(req.Messages) != 1 {
+		t.Fatalf("Unexpected summary request %+v", req)
+	}
+	if content := req.Messages[0].Content; !strings.Contains(content, "user: I like green tea") || strings.Contains(content, "Brew it.") {
+		t.Errorf("Expected only the older turns in the transcript, got %q", content)
+	}
+
+	// The originals stay stored, and the next start sees the compacted view.
+	saved, _ := chats.List(b.Session)
+	if len(saved) != len(log)+1 || !saved[len(log)].Summary {
+		t.Errorf("Expected the summary appended to the stored chats, got %+v", saved)
+	}
+	loaded, err := b.GetChatHistoryFromDB()
+	if err != nil || !reflect.DeepEqual(*loaded, b.History) {
+		t.Errorf("Expected the stored chats to load as the compacted history, got %+v (%v)", loaded, err)
+	}
+
+	if err := b.Compact(context.Background()); !errors.Is(err, ErrNothingToCompact) {
+		t.Errorf("Expected nothing left to compact, got %v", err)
+	}
+}
+
+func TestCompactKeepsRecentTurns(t *testing.T) {
+	b := newTestBot(store.NewFakeChatStore(), store.NewFakeMemoryStore())
+	b.History = turns("u1", "a1", "u2", strings.Repeat("x", 400), "u3", "a3")
+	if cut := b.compactCut(0); cut != 4 {
+		t.Errorf("Expected only the last turn kept with no room, got cut %d", cut)
+	}
+	if cut := b.compactCut(1000); cut != 2 {
+		t.Errorf("Expected all but the first turn kept with room, got cut %d", cut)
+	}
+	if cut := b.compactCut(50); cut != 4 {
+		t.Errorf("Expected the long turn summarized, got cut %d", cut)
+	}
+
+	b.History = turns("u1", "a1")
+	if cut := b.compactCut(0); cut != 0 {
+		t.Errorf("Expected a single turn to stay, got cut %d", cut)
+	}
+}
+
+func TestAgentResponseCompactsOverBudget(t *testing.T) {
+	drainBus(t)
+	log := turns("u1", "a1", "u2", "a2")
+	chats := store.NewFakeChatStore(log...)
+	b := newTestBot(chats, store.NewFakeMemoryStore())
+	b.Session = 1
+	provider := &scriptedProvider{replies: []llm.Message{
+		{Role: llm.RoleAssistant, Content: "Two short turns."},
+		{Role: llm.RoleAssistant, Content: "a3"},
+	}}
+	b.provider = provider
+	b.History = append([]agentModel.Message(nil), log...)
+	b.ContextBudget = 1
+
+	history, _, err := b.AgentResponse(context.Background(), "u3")
+	if err != nil {
+		t.Fatalf("AgentResponse failed: %v", err)
+	}
+	want := append([]agentModel.Message{summaryOf("Two short turns.", 1)}, turns("u3", "a3")...)
+	if !reflect.DeepEqual(history, want) {
+		t.Fatalf("Expected the summary, prompt and reply, got %+v", history)
+	}
+	if len(provider.requests) != 2 || len(provider.requests[1].Messages) != 2 {
+		t.Errorf("Expected the reply request to carry the summary and prompt, got %+v", provider.requests)
+	}
+	if saved, _ := chats.List(b.Session); len(saved) != 7 {
+		t.Errorf("Expected the originals, prompt, summary and reply stored, got %d", len(saved))
+	}
+}
+
+func TestAgentResponseReportsOverflowOnce(t *testing.T) {
+	var mu sync.Mutex
+	var statuses []string
+	done := make(chan struct{})
+	t.Cleanup(func() { close(done) })
+	go func() {
+		for {
+			select {
+			case msg := <-bus.StreamResponse:
+				if msg.Type == "messageStatus" {
+					mu.Lock()
+					statuses = append(statuses, msg.Text)
+					mu.Unlock()
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	b := newTestBot(store.NewFakeChatStore(), store.NewFakeMemoryStore())
+	provider := &scriptedProvider{replies: []llm.Message{
+		toolCallReply("call_1", ProjectTreeFunc, `{}`),
+		toolCallReply("call_2", ProjectTreeFunc, `{}`),
+		{Role: llm.RoleAssistant, Content: "Done."},
+	}}
+	b.provider = provider
+	b.ContextBudget = 1
+
+	if _, _, err := b.AgentResponse(context.Background(), "look around"); err != nil {
+		t.Fatalf("AgentResponse failed: %v", err)
+	}
+	if len(provider.requests) != 3 {
+		t.Errorf("Expected no summarizing request, got %d requests", len(provider.requests))
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	n := 0
+	for _, s := range statuses {
+		if strings.Contains(s, "over the context budget") {
+			n++
+		}
+	}
+	if n != 1 {
+		t.Errorf("Expected the overflow reported once, got %q", statuses)
+	}
+}
+
+func TestOverBudgetCountsPlanPrompt(t *testing.T) {
+	b := newTestBot(store.NewFakeChatStore(), store.NewFakeMemoryStore())
+	b.History = turns("u1")
+	b.SetPlanMode(true)
+	// Enough for the request without the plan mode instructions.
+	b.ContextBudget = llm.EstimateRequestTokens(llm.Request{
+		System:   b.systemPrompt,
+		Messages: b.History,
+		Tools:    b.requestTools(),
+	})
+	if !b.overBudget() {
+		t.Error("Expected the plan mode instructions counted against the budget")
+	}
+}
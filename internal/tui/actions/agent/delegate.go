@@ -0,0 +1,195 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/biisal/godo/internal/bus"
+	"github.com/biisal/godo/internal/llm"
+	agentModel "github.com/biisal/godo/internal/tui/models/agent"
+)
+
+const (
+	// MaxDelegateDepth is how deeply sub-agents may start sub-agents of
+	// their own.
+	MaxDelegateDepth = 2
+	// DefaultDelegateSteps and MaxDelegateSteps bound the model requests of
+	// a sub-agent.
+	DefaultDelegateSteps = 30
+	MaxDelegateSteps     = 50
+)
+
+const delegatePrompt = `You are a sub-agent working on a single task for another agent, which sees nothing but your final answer.
+Work through the task with the tools you have. You cannot ask the user anything.
+When you are done, answer with a concise summary of what you found or changed: the facts, file paths and line numbers the other agent needs, and anything you could not finish. Leave out raw tool output it does not need.`
+
+const stepLimitPrompt = `You are summarizing the work of a sub-agent that ran out of steps before finishing its task.
+From the transcript below, write what it found or changed so far and what is left to do, with the facts, file paths and line numbers another agent needs to carry on.`
+
+// delegatedMarker ends the output of a sub-agent in the delegate panel.
+const delegatedMarker = "[done]"
+
+// child returns a sub-agent for the call id with its own history, limited
+// to the tools in allowed and to maxSteps model requests.
+func (b *Bot) child(id string, allowed map[string]bool, maxSteps int) *Bot {
+	return &Bot{
+		provider:      b.provider,
+		tools:         b.tools,
+		systemPrompt:  b.systemPrompt + "\n\n---\n\n" + delegatePrompt,
+		ModelName:     b.ModelName,
+		ContextBudget: b.ContextBudget,
+		Dir:           b.Dir,
+		ToolWorkers:   b.ToolWorkers,
+		todos:         b.todos,
+		chats:         b.chats,
+		memories:      b.memories,
+		sqlDB:         b.sqlDB,
+		confirm:       b.confirm,
+		Permissions:   b.Permissions,
+		approve:       b.approve,
+		Workspace:     b.Workspace,
+		Sandbox:       b.Sandbox,
+		plugins:       b.plugins,
+		MCP:           b.MCP,
+		depth:         b.depth + 1,
+		delegateID:    id,
+		allowed:       allowed,
+		maxSteps:      maxSteps,
+	}
+}
+
+// delegateTools returns the tools a sub-agent of b may use: names, or by
+// default every read-only tool of b. A sub-agent at the depth limit cannot
+// delegate further.
+func (b *Bot) delegateTools(names []string) (map[string]bool, error) {
+	offered := b.requestTools()
+	allowed := map[string]bool{}
+	if len(names) == 0 {
+		for _, tool := range offered {
+			if b.readOnly(tool.Name) {
+				allowed[tool.Name] = true
+			}
+		}
+		return allowed, nil
+	}
+	for _, name := range names {
+		if !slices.ContainsFunc(offered, func(tool llm.Tool) bool { return tool.Name == name }) {
+			return nil, fmt.Errorf("tool %s is not available to delegate", name)
+		}
+		allowed[name] = true
+	}
+	if b.depth+1 >= MaxDelegateDepth {
+		delete(allowed, DelegateFunc)
+	}
+	return allowed, nil
+}
+
+func (b *Bot) runDelegate(ctx context.Context, tc llm.ToolCall) (any, bool, error) {
+	var args struct {
+		Task     string   `json:"task"`
+		Tools    []string `json:"tools"`
+		MaxSteps int      `json:"maxSteps"`
+	}
+	if err := json.Unmarshal([]byte(tc.Function.Arguments), &args); err != nil {
+		return "", false, fmt.Errorf("invalid tool arguments: %w", err)
+	}
+	task := strings.TrimSpace(args.Task)
+	if task == "" {
+		return "", false, fmt.Errorf("task is required")
+	}
+	if b.depth >= MaxDelegateDepth {
+		return "", false, fmt.Errorf("sub-agents may only be nested %d deep; do this task yourself", MaxDelegateDepth)
+	}
+	allowed, err := b.delegateTools(args.Tools)
+	if err != nil {
+		return "", false, err
+	}
+	steps := args.MaxSteps
+	if steps <= 0 {
+		steps = DefaultDelegateSteps
+	}
+	steps = min(steps, MaxDelegateSteps)
+
+	sub := b.child(tc.ID, allowed, steps)
+	bus.EmitDelegate(tc.ID, "Task: "+task+"\n\n")
+	sub.appendMessage(agentModel.Message{Role: agentModel.UserRole, Content: task})
+	refresh, err := sub.agentAPICall(ctx)
+	if errors.Is(err, ErrMaxSteps) {
+		err = sub.summarizeProgress(ctx)
+	}
+	if err != nil {
+		return "", refresh, fmt.Errorf("sub-agent failed: %w", err)
+	}
+	bus.EmitDelegate(tc.ID, "\n"+delegatedMarker+"\n")
+
+	summary := ""
+	if last := sub.History[len(sub.History)-1]; last.Role == agentModel.AssistantRole {
+		summary = strings.TrimSpace(last.Content)
+	}
+	if summary == "" {
+		summary = "The sub-agent finished without an answer."
+	}
+	return summary, refresh, nil
+}
+
+// summarizeProgress answers for a sub-agent that ran out of steps with a
+// summary of its transcript, so its work is not lost. The transcript is
+// sent as text because the tools of its calls are no longer offered.
+func (b *Bot) summarizeProgress(ctx context.Context) error {
+	b.emitStatus("\nOut of steps, summarizing the progress")
+	reply, err := b.provider.Stream(ctx, llm.Request{
+		System:   stepLimitPrompt,
+		Messages: []llm.Message{{Role: llm.RoleUser, Content: transcript(b.History)}},
+	}, func(d llm.Delta) {
+		b.emitContent(d.Content)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to summarize the progress: %w", err)
+	}
+	b.appendMessage(agentModel.Message{Role: agentModel.AssistantRole, Content: reply.Content})
+	return nil
+}
+
+// emitState shows text on the status line, marked when a sub-agent is at
+// work.
+func (b *Bot) emitState(text string) {
+	if b.depth > 0 {
+		text = "Sub-agent: " + text
+	}
+	bus.EmitState(text)
+}
+
+// emitStatus adds a status line to the chat, or to the delegate panel for
+// a sub-agent.
+func (b *Bot) emitStatus(text string) {
+	if b.depth > 0 {
+		bus.EmitDelegate(b.delegateID, text+"\n")
+		return
+	}
+	bus.EmitMessageStatus(text)
+}
+
+// emitThinking streams reasoning to the chat. A sub-agent's is left out of
+// the delegate panel, which shows its answer and tool calls.
+func (b *Bot) emitThinking(text string) {
+	if b.depth == 0 {
+		bus.EmitThinking(text)
+	}
+}
+
+// emitContent streams reply text to the chat, or to the delegate panel for
+// a sub-agent. Empty text ends a paragraph.
+func (b *Bot) emitContent(text string) {
+	if b.depth == 0 {
+		bus.EmitContent(text)
+		return
+	}
+	if text == "" {
+		text = "\n"
+	}
+	bus.EmitDelegate(b.delegateID, text)
+}
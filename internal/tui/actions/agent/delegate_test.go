@@ -0,0 +1,124 @@
+package agent
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/biisal/godo/internal/config"
+	"github.com/biisal/godo/internal/llm"
+	"github.com/biisal/godo/internal/store"
+	todoAction "github.com/biisal/godo/internal/tui/actions/todo"
+)
+
+func toolCallReply(id, name, args string) llm.Message {
+	return llm.Message{Role: llm.RoleAssistant, ToolCalls: []llm.ToolCall{{
+		ID:       id,
+		Type:     "function",
+		Function: llm.FunctionCall{Name: name, Arguments: args},
+	}}}
+}
+
+func toolNames(tools []llm.Tool) []string {
+	var names []string
+	for _, tool := range tools {
+		names = append(names, tool.Name)
+	}
+	return names
+}
+
+func TestDelegate(t *testing.T) {
+	drainBus(t)
+	provider := &scriptedProvider{replies: []llm.Message{
+		toolCallReply("call_1", DelegateFunc, `{"task":"Count the open todos"}`),
+		// The sub-agent.
+		toolCallReply("call_2", ListTodosFunc, `{"status":"open"}`),
+		{Role: llm.RoleAssistant, Content: "There are no open todos."},
+		// The main agent again.
+		{Role: llm.RoleAssistant, Content: "You have nothing open."},
+	}}
+	chats := store.NewFakeChatStore()
+	b := NewBot(Stores{
+		Todos:    todoAction.NewService(store.NewFakeTodoStore(), config.StoreGlobal),
+		Chats:    chats,
+		Memories: store.NewFakeMemoryStore(),
+	}, provider)
+
+	history, _, err := b.AgentResponse(context.Background(), "how many todos are open?")
+	if err != nil {
+		t.Fatalf("AgentResponse failed: %v", err)
+	}
+	if len(history) != 4 || history[2].ToolCallID != "call_1" || history[2].Content != "There are no open todos." {
+		t.Fatalf("Expected only the sub-agent's answer in the history, got %+v", history)
+	}
+	if saved, _ := chats.List(b.Session); !reflect.DeepEqual(saved, history) {
+		t.Errorf("Expected the sub-agent's messages left unsaved, got %+v", saved)
+	}
+
+	child := provider.requests[1]
+	if !strings.HasSuffix(child.System, delegatePrompt) || len(child.Messages) != 1 || child.Messages[0].Content != "Count the open todos" {
+		t.Errorf("Expected the sub-agent to start with the task alone, got %+v", child)
+	}
+	names := toolNames(child.Tools)
+	if !slices.Contains(names, ListTodosFunc) || slices.Contains(names, AddTodoFunc) || slices.Contains(names, DelegateFunc) {
+		t.Errorf("Expected the sub-agent offered only read-only tools, got %v", names)
+	}
+	if len(provider.requests[3].Messages) != 3 {
+		t.Errorf("Expected the main agent to see the delegated call only, got %+v", provider.requests[3].Messages)
+	}
+}
+
+func TestDelegateTools(t *testing.T) {
+	drainBus(t)
+	b := newTestBot(store.NewFakeChatStore(), store.NewFakeMemoryStore())
+
+	allowed, err := b.delegateTools([]string{AddTodoFunc, DelegateFunc})
+	if err != nil || !reflect.DeepEqual(allowed, map[string]bool{AddTodoFunc: true, DelegateFunc: true}) {
+		t.Errorf("Expected the named tools allowed, got %v, %v", allowed, err)
+	}
+	if _, err := b.delegateTools([]string{"Nope"}); err == nil {
+		t.Error("Expected an unknown tool rejected")
+	}
+
+	// At the depth limit a sub-agent cannot delegate further, nor give
+	// tools it does not have.
+	sub := b.child("call_1", map[string]bool{ListTodosFunc: true, DelegateFunc: true}, 5)
+	sub.depth = MaxDelegateDepth - 1
+	if allowed, err := sub.delegateTools([]string{ListTodosFunc, DelegateFunc}); err != nil || allowed[DelegateFunc] {
+		t.Errorf("Expected Delegate withheld at the depth limit, got %v, %v", allowed, err)
+	}
+	if _, err := sub.delegateTools([]string{AddTodoFunc}); err == nil {
+		t.Error("Expected a tool the sub-agent lacks rejected")
+	}
+	if _, _, err := callTool(t, sub, AddTodoFunc, map[string]any{"title": "x", "description": "y"}); err == nil || !strings.Contains(err.Error(), "not available") {
+		t.Errorf("Expected a tool outside the allowed set refused, got %v", err)
+	}
+	sub.depth = MaxDelegateDepth
+	if _, _, err := callTool(t, sub, DelegateFunc, map[string]any{"task": "dig deeper"}); err == nil || !strings.Contains(err.Error(), "nested") {
+		t.Errorf("Expected delegation past the depth limit refused, got %v", err)
+	}
+}
+
+func TestDelegateStepLimit(t *testing.T) {
+	drainBus(t)
+	provider := &scriptedProvider{replies: []llm.Message{
+		toolCallReply("call_2", ListTodosFunc, `{}`),
+		{Role: llm.RoleAssistant, Content: "Listed the todos; none were found yet."},
+	}}
+	b := newTestBot(store.NewFakeChatStore(), store.NewFakeMemoryStore())
+	b.provider = provider
+
+	result, _, err := b.runFunction(t.Context(), DelegateFunc, llm.ToolCall{
+		ID:       "call_1",
+		Function: llm.FunctionCall{Name: DelegateFunc, Arguments: `{"task":"Audit the todos","maxSteps":1}`},
+	})
+	if err != nil || result != "Listed the todos; none were found yet." {
+		t.Fatalf("Expected a summary of the progress, got %v, %v", result, err)
+	}
+	summary := provider.requests[1]
+	if summary.System != stepLimitPrompt || len(summary.Tools) != 0 || !strings.Contains(summary.Messages[0].Content, "tool ListTodos returned") {
+		t.Errorf("Expected the transcript summarized without tools, got %+v", summary)
+	}
+}
@@ -1,9 +1,9 @@
 package agent
 
 import (
-	"github.com/openai/openai-go"
-	"github.com/openai/openai-go/shared"
-	"github.com/openai/openai-go/shared/constant"
+	"context"
+
+	"github.com/biisal/godo/internal/llm"
 )
 
 const (
@@ -21,355 +21,550 @@ const (
 	InsertAtLineFunc     = "InsertAtLine"
 	SaveMemoryFunc       = "SaveMemory"
 	RecallMemoriesFunc   = "RecallMemories"
+	ScanTodosFunc        = "ScanTodos"
+	ListTodosFunc        = "ListTodos"
+	AddTodoFunc          = "AddTodo"
+	UpdateTodoFunc       = "UpdateTodo"
+	ToggleTodoFunc       = "ToggleTodo"
+	DeleteTodoFunc       = "DeleteTodo"
+	SQLiteSchemaFunc     = "SQLiteSchema"
+	QuerySQLiteFunc      = "QuerySQLite"
+	DelegateFunc         = "Delegate"
+	SubmitPlanFunc       = "SubmitPlan"
 )
 
-var tools = map[string]func(openai.ChatCompletionMessageToolCall) (any, bool, error){
-	PerformSQLFunc:       runPerformSql,
-	RunShellCommandFunc:  runShellCommand,
-	ReadSkillFunc:        runReadSkill,
-	GlobSearchFunc:       runGlobSearch,
-	ReadFilesFunc:        runReadFiles,
-	ProjectTreeFunc:      runProjectTree,
-	DuckDuckGoSearchFunc: runDuckDuckGoSearch,
-	ScrapePageFunc:       runScrapePage,
-	WriteFileFunc:        runWriteFile,
-	EditFileFunc:         runEditFile,
-	PatchFileFunc:        runPatchFile,
-	InsertAtLineFunc:     runInsertAtLine,
-	SaveMemoryFunc:       runSaveMemory,
-	RecallMemoriesFunc:   runRecallMemories,
+var tools = map[string]func(*Bot, context.Context, llm.ToolCall) (any, bool, error){
+	PerformSQLFunc:       (*Bot).runPerformSql,
+	RunShellCommandFunc:  (*Bot).runShellCommand,
+	ReadSkillFunc:        (*Bot).runReadSkill,
+	GlobSearchFunc:       (*Bot).runGlobSearch,
+	ReadFilesFunc:        (*Bot).runReadFiles,
+	ProjectTreeFunc:      (*Bot).runProjectTree,
+	DuckDuckGoSearchFunc: (*Bot).runDuckDuckGoSearch,
+	ScrapePageFunc:       (*Bot).runScrapePage,
+	WriteFileFunc:        (*Bot).runWriteFile,
+	EditFileFunc:         (*Bot).runEditFile,
+	PatchFileFunc:        (*Bot).runPatchFile,
+	InsertAtLineFunc:     (*Bot).runInsertAtLine,
+	SaveMemoryFunc:       (*Bot).runSaveMemory,
+	RecallMemoriesFunc:   (*Bot).runRecallMemories,
+	ScanTodosFunc:        (*Bot).runScanTodos,
+	ListTodosFunc:        (*Bot).runListTodos,
+	AddTodoFunc:          (*Bot).runAddTodo,
+	UpdateTodoFunc:       (*Bot).runUpdateTodo,
+	ToggleTodoFunc:       (*Bot).runToggleTodo,
+	DeleteTodoFunc:       (*Bot).runDeleteTodo,
+	SQLiteSchemaFunc:     (*Bot).runSQLiteSchema,
+	QuerySQLiteFunc:      (*Bot).runQuerySQLite,
+	SubmitPlanFunc:       (*Bot).runSubmitPlan,
+}
+
+func init() {
+	// A sub-agent runs tools itself, so Delegate would be part of the
+	// initialization of tools.
+	tools[DelegateFunc] = (*Bot).runDelegate
 }
 
-func FormattedFunctions() []openai.ChatCompletionToolParam {
-	return []openai.ChatCompletionToolParam{
+func FormattedFunctions() []llm.Tool {
+	return []llm.Tool{
+		{
+			Name: ListTodosFunc,
+			Description: `List the user's todos, newest first, with optional filters.
+CRITICAL: Use this tool (not PerformSql or RunShellCommand) to list, find or count todos.
+Returns the matching todos as JSON with id, title, description, done and store, plus total, completed and pending counts.`,
+			Parameters: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"status": map[string]any{
+						"type":        "string",
+						"enum":        []string{"all", "open", "done"},
+						"description": "Only return open or done todos. Defaults to all.",
+					},
+					"query": map[string]any{
+						"type":        "string",
+						"description": "Optional case-insensitive text to match in the title or description.",
+					},
+					"limit": map[string]any{
+						"type":        "integer",
+						"description": "Optional maximum number of todos to return.",
+					},
+				},
+			},
+		},
+		{
+			Name: AddTodoFunc,
+			Description: `Add a new todo. Both title and description are required and cannot be blank.
+Returns the created todo with its id.`,
+			Parameters: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"title": map[string]any{
+						"type":        "string",
+						"description": "Short title of the todo.",
+					},
+					"description": map[string]any{
+						"type":        "string",
+						"description": "Details of the todo.",
+					},
+				},
+				"required": []string{"title", "description"},
+			},
+		},
+		{
+			Name: UpdateTodoFunc,
+			Description: `Update an existing todo by id. Only the fields you pass are changed.
+Use done to mark a todo complete or pending. Returns the updated todo.`,
+			Parameters: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"id": map[string]any{
+						"type":        "integer",
+						"description": "Id of the todo to update.",
+					},
+					"title": map[string]any{
+						"type":        "string",
+						"description": "New title. Cannot be blank.",
+					},
+					"description": map[string]any{
+						"type":        "string",
+						"description": "New description. Cannot be blank.",
+					},
+					"done": map[string]any{
+						"type":        "boolean",
+						"description": "Whether the todo is complete.",
+					},
+				},
+				"required": []string{"id"},
+			},
+		},
+		{
+			Name:        ToggleTodoFunc,
+			Description: `Flip a todo between done and pending. Returns its new done state.`,
+			Parameters: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"id": map[string]any{
+						"type":        "integer",
+						"description": "Id of the todo to toggle.",
+					},
+				},
+				"required": []string{"id"},
+			},
+		},
 		{
-			Type: constant.Function("function"),
-			Function: shared.FunctionDefinitionParam{
-				Name: PerformSQLFunc,
-				Description: openai.String(`Execute any SQLite query on the 'todos' database.
-CRITICAL: You MUST use this tool for ALL todo-related operations (listing, adding, completing, editing, deleting, finding).
+			Name:        DeleteTodoFunc,
+			Description: `Delete a todo by id. Returns the deleted todo.`,
+			Parameters: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"id": map[string]any{
+						"type":        "integer",
+						"description": "Id of the todo to delete.",
+					},
+				},
+				"required": []string{"id"},
+			},
+		},
+		{
+			Name: PerformSQLFunc,
+			Description: `Execute a single SQLite statement on the 'todos' database.
+Prefer ListTodos, AddTodo, UpdateTodo, ToggleTodo and DeleteTodo for todo management. Only use raw SQL when those tools cannot express the request, such as bulk changes or custom reports.
 DO NOT use the RunShellCommand tool for todo management.
 Table schema: todos (Id INTEGER PRIMARY KEY, Title TEXT, Description TEXT, Done BOOLEAN)
-Always write valid SQLite syntax and return the raw output.`),
-				Parameters: shared.FunctionParameters{
-					"type": "object",
-					"properties": map[string]any{
-						"query": map[string]any{
-							"type":        "string",
-							"description": "The SQLite query to execute (SELECT, INSERT, UPDATE, DELETE, etc.)",
-						},
+Rules: one statement per call. SELECT can read any table; INSERT, UPDATE and DELETE are only allowed on todos. CREATE, DROP, ALTER, ATTACH and setting PRAGMAs are rejected. Every DELETE, every REPLACE (including INSERT OR REPLACE and UPDATE OR REPLACE) and UPDATE without WHERE ask the user first.
+Always write valid SQLite syntax and return the raw output.`,
+			Parameters: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"query": map[string]any{
+						"type":        "string",
+						"description": "The single SQLite statement to execute (SELECT, or INSERT, UPDATE, DELETE on todos)",
 					},
-					"required": []string{"query"},
 				},
+				"required": []string{"query"},
 			},
 		},
 		{
-			Type: constant.Function("function"),
-			Function: shared.FunctionDefinitionParam{
-				Name:        RunShellCommandFunc,
-				Description: openai.String(`Execute a shell command and return its output. Use with caution.`),
-				Parameters: shared.FunctionParameters{
-					"type": "object",
-					"properties": map[string]any{
-						"command": map[string]any{
-							"type":        "string",
-							"description": "The shell command to execute.",
-						},
+			Name:        RunShellCommandFunc,
+			Description: `Execute a shell command and return its output. Use with caution.`,
+			Parameters: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"command": map[string]any{
+						"type":        "string",
+						"description": "The shell command to execute.",
 					},
-					"required": []string{"command"},
 				},
+				"required": []string{"command"},
 			},
 		},
 		{
-			Type: constant.Function("function"),
-			Function: shared.FunctionDefinitionParam{
-				Name: ReadSkillFunc,
-				Description: openai.String(`Read the instructions for a specific skill from its markdown file.
-Provide the name of the skill (without the .md extension). Use this when you need specific instructions provided in the system prompt's skills list.`),
-				Parameters: shared.FunctionParameters{
-					"type": "object",
-					"properties": map[string]any{
-						"skillName": map[string]any{
-							"type":        "string",
-							"description": "The name of the skill to read.",
-						},
+			Name: ReadSkillFunc,
+			Description: `Read the instructions for a specific skill from its markdown file.
+Provide the name of the skill (without the .md extension). Use this when you need specific instructions provided in the system prompt's skills list.`,
+			Parameters: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"skillName": map[string]any{
+						"type":        "string",
+						"description": "The name of the skill to read.",
 					},
-					"required": []string{"skillName"},
 				},
+				"required": []string{"skillName"},
 			},
 		},
 		{
-			Type: constant.Function("function"),
-			Function: shared.FunctionDefinitionParam{
-				Name: GlobSearchFunc,
-				Description: openai.String(`Find files by glob pattern under a root directory.
-Supports standard glob wildcards plus recursive ** (example: src/**/*.jsx).`),
-				Parameters: shared.FunctionParameters{
-					"type": "object",
-					"properties": map[string]any{
-						"pattern": map[string]any{
-							"type":        "string",
-							"description": "Glob pattern to match (for example: src/**/*.jsx, **/*.go, *.md).",
-						},
-						"root": map[string]any{
-							"type":        "string",
-							"description": "Optional root directory to search from. Defaults to current working directory.",
-						},
+			Name: GlobSearchFunc,
+			Description: `Find files by glob pattern under a root directory.
+Supports standard glob wildcards plus recursive ** (example: src/**/*.jsx).`,
+			Parameters: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"pattern": map[string]any{
+						"type":        "string",
+						"description": "Glob pattern to match (for example: src/**/*.jsx, **/*.go, *.md).",
+					},
+					"root": map[string]any{
+						"type":        "string",
+						"description": "Optional root directory to search from. Defaults to current working directory.",
 					},
-					"required": []string{"pattern"},
 				},
+				"required": []string{"pattern"},
 			},
 		},
 		{
-			Type: constant.Function("function"),
-			Function: shared.FunctionDefinitionParam{
-				Name: ReadFilesFunc,
-				Description: openai.String(`Read multiple files in one tool call.
-Returns file content and metadata for each requested path.`),
-				Parameters: shared.FunctionParameters{
-					"type": "object",
-					"properties": map[string]any{
-						"paths": map[string]any{
-							"type":        "array",
-							"description": "List of file paths to read.",
-							"items": map[string]any{
-								"type": "string",
-							},
-						},
-						"maxBytesPerFile": map[string]any{
-							"type":        "integer",
-							"description": "Optional byte limit per file. Defaults to 65536.",
+			Name: ReadFilesFunc,
+			Description: `Read multiple files in one tool call.
+Returns file content and metadata for each requested path.`,
+			Parameters: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"paths": map[string]any{
+						"type":        "array",
+						"description": "List of file paths to read.",
+						"items": map[string]any{
+							"type": "string",
 						},
 					},
-					"required": []string{"paths"},
+					"maxBytesPerFile": map[string]any{
+						"type":        "integer",
+						"description": "Optional byte limit per file. Defaults to 65536.",
+					},
 				},
+				"required": []string{"paths"},
 			},
 		},
 		{
-			Type: constant.Function("function"),
-			Function: shared.FunctionDefinitionParam{
-				Name: ProjectTreeFunc,
-				Description: openai.String(`Return an at-a-glance project directory tree.
-Use this to quickly inspect folder/file structure.`),
-				Parameters: shared.FunctionParameters{
-					"type": "object",
-					"properties": map[string]any{
-						"root": map[string]any{
-							"type":        "string",
-							"description": "Optional root directory for the tree. Defaults to current working directory.",
-						},
-						"maxDepth": map[string]any{
-							"type":        "integer",
-							"description": "Optional depth limit. Defaults to 4.",
-						},
-						"includeFiles": map[string]any{
-							"type":        "boolean",
-							"description": "Whether to include files in addition to directories. Defaults to true.",
-						},
+			Name: ProjectTreeFunc,
+			Description: `Return an at-a-glance project directory tree.
+Use this to quickly inspect folder/file structure.`,
+			Parameters: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"root": map[string]any{
+						"type":        "string",
+						"description": "Optional root directory for the tree. Defaults to current working directory.",
+					},
+					"maxDepth": map[string]any{
+						"type":        "integer",
+						"description": "Optional depth limit. Defaults to 4.",
+					},
+					"includeFiles": map[string]any{
+						"type":        "boolean",
+						"description": "Whether to include files in addition to directories. Defaults to true.",
 					},
 				},
 			},
 		},
 		{
-			Type: constant.Function("function"),
-			Function: shared.FunctionDefinitionParam{
-				Name: DuckDuckGoSearchFunc,
-				Description: openai.String(`Search DuckDuckGo by POSTing a query and return ranked results.
+			Name: DuckDuckGoSearchFunc,
+			Description: `Search DuckDuckGo by POSTing a query and return ranked results.
 Returns a list of results with title, URL, and description/snippet.
-Use the page parameter to paginate through results for deeper searches.`),
-				Parameters: shared.FunctionParameters{
-					"type": "object",
-					"properties": map[string]any{
-						"query": map[string]any{
-							"type":        "string",
-							"description": "Search query to send to DuckDuckGo.",
-						},
-						"maxResults": map[string]any{
-							"type":        "integer",
-							"description": "Optional maximum number of results. Defaults to 10.",
-						},
-						"page": map[string]any{
-							"type":        "integer",
-							"description": "Page number for pagination (1-indexed). Defaults to 1. Use higher values to get more results beyond the first page.",
-						},
+Use the page parameter to paginate through results for deeper searches.`,
+			Parameters: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"query": map[string]any{
+						"type":        "string",
+						"description": "Search query to send to DuckDuckGo.",
+					},
+					"maxResults": map[string]any{
+						"type":        "integer",
+						"description": "Optional maximum number of results. Defaults to 10.",
+					},
+					"page": map[string]any{
+						"type":        "integer",
+						"description": "Page number for pagination (1-indexed). Defaults to 1. Use higher values to get more results beyond the first page.",
 					},
-					"required": []string{"query"},
 				},
+				"required": []string{"query"},
 			},
 		},
 		{
-			Type: constant.Function("function"),
-			Function: shared.FunctionDefinitionParam{
-				Name: ScrapePageFunc,
-				Description: openai.String(`Fetch and scrape a webpage by URL.
-Returns title, description, and extracted plain text content.`),
-				Parameters: shared.FunctionParameters{
-					"type": "object",
-					"properties": map[string]any{
-						"url": map[string]any{
-							"type":        "string",
-							"description": "Page URL to fetch and scrape.",
-						},
-						"maxChars": map[string]any{
-							"type":        "integer",
-							"description": "Optional max number of characters of extracted text. Defaults to 8000.",
-						},
+			Name: ScrapePageFunc,
+			Description: `Fetch and scrape a webpage by URL.
+Returns title, description, and extracted plain text content.`,
+			Parameters: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"url": map[string]any{
+						"type":        "string",
+						"description": "Page URL to fetch and scrape.",
+					},
+					"maxChars": map[string]any{
+						"type":        "integer",
+						"description": "Optional max number of characters of extracted text. Defaults to 8000.",
 					},
-					"required": []string{"url"},
 				},
+				"required": []string{"url"},
 			},
 		},
 		{
-			Type: constant.Function("function"),
-			Function: shared.FunctionDefinitionParam{
-				Name: WriteFileFunc,
-				Description: openai.String(`Create or overwrite a file on disk with the provided content.
-Can optionally create parent directories and append instead of overwrite.`),
-				Parameters: shared.FunctionParameters{
-					"type": "object",
-					"properties": map[string]any{
-						"path": map[string]any{
-							"type":        "string",
-							"description": "File path to write.",
-						},
-						"content": map[string]any{
-							"type":        "string",
-							"description": "Text content to write.",
-						},
-						"createParents": map[string]any{
-							"type":        "boolean",
-							"description": "If true, create parent directories when missing.",
-						},
-						"append": map[string]any{
-							"type":        "boolean",
-							"description": "If true, append to existing file instead of overwriting.",
-						},
+			Name: WriteFileFunc,
+			Description: `Create or overwrite a file on disk with the provided content.
+Can optionally create parent directories and append instead of overwrite.`,
+			Parameters: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"path": map[string]any{
+						"type":        "string",
+						"description": "File path to write.",
+					},
+					"content": map[string]any{
+						"type":        "string",
+						"description": "Text content to write.",
+					},
+					"createParents": map[string]any{
+						"type":        "boolean",
+						"description": "If true, create parent directories when missing.",
+					},
+					"append": map[string]any{
+						"type":        "boolean",
+						"description": "If true, append to existing file instead of overwriting.",
 					},
-					"required": []string{"path", "content"},
 				},
+				"required": []string{"path", "content"},
 			},
 		},
 		{
-			Type: constant.Function("function"),
-			Function: shared.FunctionDefinitionParam{
-				Name: EditFileFunc,
-				Description: openai.String(`Edit part of a file without rewriting everything.
-Use either oldString/newString replacement, or lineNumber/newContent replacement.`),
-				Parameters: shared.FunctionParameters{
-					"type": "object",
-					"properties": map[string]any{
-						"path": map[string]any{
-							"type":        "string",
-							"description": "File path to edit.",
-						},
-						"oldString": map[string]any{
-							"type":        "string",
-							"description": "Existing text to replace.",
-						},
-						"newString": map[string]any{
-							"type":        "string",
-							"description": "Replacement text for oldString.",
-						},
-						"lineNumber": map[string]any{
-							"type":        "integer",
-							"description": "1-based line number to replace.",
-						},
-						"newContent": map[string]any{
-							"type":        "string",
-							"description": "New content for the specified lineNumber.",
-						},
+			Name: EditFileFunc,
+			Description: `Edit part of a file without rewriting everything.
+Use either oldString/newString replacement, or lineNumber/newContent replacement.`,
+			Parameters: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"path": map[string]any{
+						"type":        "string",
+						"description": "File path to edit.",
+					},
+					"oldString": map[string]any{
+						"type":        "string",
+						"description": "Existing text to replace.",
+					},
+					"newString": map[string]any{
+						"type":        "string",
+						"description": "Replacement text for oldString.",
+					},
+					"lineNumber": map[string]any{
+						"type":        "integer",
+						"description": "1-based line number to replace.",
+					},
+					"newContent": map[string]any{
+						"type":        "string",
+						"description": "New content for the specified lineNumber.",
 					},
-					"required": []string{"path"},
 				},
+				"required": []string{"path"},
 			},
 		},
 		{
-			Type: constant.Function("function"),
-			Function: shared.FunctionDefinitionParam{
-				Name: PatchFileFunc,
-				Description: openai.String(`Apply a unified diff patch to a file.
-The patch should target the same path and include proper hunk headers.`),
-				Parameters: shared.FunctionParameters{
-					"type": "object",
-					"properties": map[string]any{
-						"path": map[string]any{
-							"type":        "string",
-							"description": "Target file path for the patch.",
-						},
-						"patch": map[string]any{
-							"type":        "string",
-							"description": "Unified diff patch content.",
-						},
+			Name: PatchFileFunc,
+			Description: `Apply a unified diff patch to a file.
+The patch should target the same path and include proper hunk headers.`,
+			Parameters: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"path": map[string]any{
+						"type":        "string",
+						"description": "Target file path for the patch.",
+					},
+					"patch": map[string]any{
+						"type":        "string",
+						"description": "Unified diff patch content.",
 					},
-					"required": []string{"path", "patch"},
 				},
+				"required": []string{"path", "patch"},
 			},
 		},
 		{
-			Type: constant.Function("function"),
-			Function: shared.FunctionDefinitionParam{
-				Name:        InsertAtLineFunc,
-				Description: openai.String(`Insert content at a specific line number in a file.`),
-				Parameters: shared.FunctionParameters{
-					"type": "object",
-					"properties": map[string]any{
-						"path": map[string]any{
-							"type":        "string",
-							"description": "File path to modify.",
-						},
-						"lineNumber": map[string]any{
-							"type":        "integer",
-							"description": "1-based line number where content will be inserted.",
-						},
-						"content": map[string]any{
-							"type":        "string",
-							"description": "Content to insert.",
-						},
+			Name:        InsertAtLineFunc,
+			Description: `Insert content at a specific line number in a file.`,
+			Parameters: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"path": map[string]any{
+						"type":        "string",
+						"description": "File path to modify.",
+					},
+					"lineNumber": map[string]any{
+						"type":        "integer",
+						"description": "1-based line number where content will be inserted.",
+					},
+					"content": map[string]any{
+						"type":        "string",
+						"description": "Content to insert.",
 					},
-					"required": []string{"path", "lineNumber", "content"},
 				},
+				"required": []string{"path", "lineNumber", "content"},
 			},
 		},
 		{
-			Type: constant.Function("function"),
-			Function: shared.FunctionDefinitionParam{
-				Name: SaveMemoryFunc,
-				Description: openai.String(`Save a fact or preference to persistent memory.
+			Name: SaveMemoryFunc,
+			Description: `Save a fact or preference to persistent memory.
 Memories survive across conversations and even after /clear.
 Use this when the user tells you something worth remembering long-term (preferences, project details, personal facts).
-If a memory with the same key already exists, it will be updated.`),
-				Parameters: shared.FunctionParameters{
-					"type": "object",
-					"properties": map[string]any{
-						"key": map[string]any{
-							"type":        "string",
-							"description": "Short label for this memory (e.g. 'favorite_language', 'project_stack', 'user_name').",
-						},
-						"content": map[string]any{
-							"type":        "string",
-							"description": "The fact or preference to remember.",
-						},
+If a memory with the same key already exists, it will be updated.`,
+			Parameters: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"key": map[string]any{
+						"type":        "string",
+						"description": "Short label for this memory (e.g. 'favorite_language', 'project_stack', 'user_name').",
+					},
+					"content": map[string]any{
+						"type":        "string",
+						"description": "The fact or preference to remember.",
 					},
-					"required": []string{"key", "content"},
 				},
+				"required": []string{"key", "content"},
 			},
 		},
 		{
-			Type: constant.Function("function"),
-			Function: shared.FunctionDefinitionParam{
-				Name: RecallMemoriesFunc,
-				Description: openai.String(`Search persistent memories by keyword.
+			Name: RecallMemoriesFunc,
+			Description: `Search persistent memories by keyword.
 Returns matching memory entries. Use this to look up previously saved facts.
-Pass an empty query to list all memories.`),
-				Parameters: shared.FunctionParameters{
-					"type": "object",
-					"properties": map[string]any{
-						"query": map[string]any{
-							"type":        "string",
-							"description": "Search keyword to match against memory keys and content. Leave empty to list all.",
+Pass an empty query to list all memories.`,
+			Parameters: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"query": map[string]any{
+						"type":        "string",
+						"description": "Search keyword to match against memory keys and content. Leave empty to list all.",
+					},
+				},
+			},
+		},
+		{
+			Name: ScanTodosFunc,
+			Description: `Scan source code for TODO, FIXME and HACK comments and import them as todos.
+Respects .gitignore. Each imported todo keeps a file:line link in its description.
+Re-running the scan updates moved comments and marks todos done when their comment was removed.`,
+			Parameters: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"root": map[string]any{
+						"type":        "string",
+						"description": "Optional directory to scan. Defaults to the project directory, or the current working directory.",
+					},
+				},
+			},
+		},
+		{
+			Name: SQLiteSchemaFunc,
+			Description: `List the tables, views, columns and indexes of any SQLite database file on disk.
+The file is opened read-only and is never modified. Use this before QuerySQLite to learn the schema.
+Do not use this for the user's todos.`,
+			Parameters: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"path": map[string]any{
+						"type":        "string",
+						"description": "Path to the SQLite database file.",
+					},
+				},
+				"required": []string{"path"},
+			},
+		},
+		{
+			Name: QuerySQLiteFunc,
+			Description: `Run a read-only query (SELECT, WITH ... SELECT, VALUES or schema PRAGMAs) on any SQLite database file on disk and return the rows as a text table.
+The file is opened read-only; INSERT, UPDATE, DELETE, DDL and ATTACH are rejected. One statement per call.
+Results are limited to 50 rows by default and at most 500; the output says when more rows are available.`,
+			Parameters: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"path": map[string]any{
+						"type":        "string",
+						"description": "Path to the SQLite database file.",
+					},
+					"query": map[string]any{
+						"type":        "string",
+						"description": "The single read-only SQLite statement to run.",
+					},
+					"limit": map[string]any{
+						"type":        "integer",
+						"description": "Optional maximum number of rows. Defaults to 50, capped at 500.",
+					},
+				},
+				"required": []string{"path", "query"},
+			},
+		},
+		{
+			Name: DelegateFunc,
+			Description: `Hand a self-contained task to a sub-agent, which works through it with its own conversation and returns only its final summary.
+Use this for research that takes many tool calls, such as exploring a codebase or comparing web pages, to keep their output out of this conversation.
+The sub-agent cannot ask the user anything, so put everything it needs in the task. By default it may only use read-only tools.`,
+			Parameters: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"task": map[string]any{
+						"type":        "string",
+						"description": "What the sub-agent should do and what its summary should contain.",
+					},
+					"tools": map[string]any{
+						"type":        "array",
+						"items":       map[string]any{"type": "string"},
+						"description": "Optional names of the tools the sub-agent may use. Defaults to the read-only tools.",
+					},
+					"maxSteps": map[string]any{
+						"type":        "integer",
+						"description": "Optional maximum number of model requests. Defaults to 30, capped at 50.",
+					},
+				},
+				"required": []string{"task"},
+			},
+		},
+		{
+			Name: SubmitPlanFunc,
+			Description: `Submit the plan you propose for the user's review. Only available in plan mode, after investigating with the read-only tools.
+The user approves, edits or rejects it. Approved steps are added as todos and plan mode ends, giving you every tool again.`,
+			Parameters: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"summary": map[string]any{
+						"type":        "string",
+						"description": "One or two sentences on what the plan achieves and why.",
+					},
+					"steps": map[string]any{
+						"type":        "array",
+						"description": "The steps in the order to carry them out.",
+						"items": map[string]any{
+							"type": "object",
+							"properties": map[string]any{
+								"title": map[string]any{
+									"type":        "string",
+									"description": "Short imperative title of the step.",
+								},
+								"description": map[string]any{
+									"type":        "string",
+									"description": "What to change and where, such as files and functions.",
+								},
+							},
+							"required": []string{"title", "description"},
 						},
 					},
 				},
+				"required": []string{"summary", "steps"},
 			},
 		},
 	}
@@ -0,0 +1,89 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+
+	"github.com/biisal/godo/internal/bus"
+	"github.com/biisal/godo/internal/llm"
+	"github.com/biisal/godo/internal/mcp"
+)
+
+// requestTools is the tool list sent with a request: the built-in tools
+// and plugins, then the tools of the connected MCP servers, which change as
+// servers restart. Tools the agent may not call right now are left out.
+func (b *Bot) requestTools() []llm.Tool {
+	var offered []llm.Tool
+	for _, tool := range b.allTools() {
+		if b.offers(tool.Name) {
+			offered = append(offered, tool)
+		}
+	}
+	return offered
+}
+
+func (b *Bot) allTools() []llm.Tool {
+	if b.MCP == nil {
+		return b.tools
+	}
+	serverTools := b.MCP.Tools()
+	if len(serverTools) == 0 {
+		return b.tools
+	}
+	all := slices.Clip(b.tools)
+	for _, tool := range serverTools {
+		desc := tool.Description
+		if desc == "" {
+			desc = tool.Title
+		}
+		params := tool.InputSchema
+		if params == nil {
+			params = map[string]any{"type": "object", "properties": map[string]any{}}
+		}
+		all = append(all, llm.Tool{Name: tool.Name, Description: "[MCP " + tool.Server + "] " + desc, Parameters: params})
+	}
+	return all
+}
+
+// extraTool finds a tool that is not built in: a plugin, or else a tool of
+// an MCP server.
+func (b *Bot) extraTool(name string) (func(*Bot, context.Context, llm.ToolCall) (any, bool, error), bool) {
+	if p, ok := b.plugins[name]; ok {
+		return func(b *Bot, ctx context.Context, tc llm.ToolCall) (any, bool, error) {
+			return b.runPlugin(ctx, p, tc)
+		}, true
+	}
+	if b.MCP == nil {
+		return nil, false
+	}
+	if tool, ok := b.MCP.Lookup(name); ok {
+		return func(b *Bot, ctx context.Context, tc llm.ToolCall) (any, bool, error) {
+			return b.runMCPTool(ctx, tool, tc)
+		}, true
+	}
+	return nil, false
+}
+
+func (b *Bot) runMCPTool(ctx context.Context, tool mcp.ServerTool, tc llm.ToolCall) (any, bool, error) {
+	emitShell(tc, "calling "+tool.Tool.Name+" on MCP server "+tool.Server+"\n")
+	out, err := b.MCP.Call(ctx, tool.Name, json.RawMessage(tc.Function.Arguments))
+	if err != nil {
+		emitShell(tc, "error: "+err.Error()+"\n")
+		return "", false, err
+	}
+	emitShell(tc, out+"\n")
+	return out, false, nil
+}
+
+// WatchMCP reports the MCP servers' status to the TUI whenever it changes.
+func (b *Bot) WatchMCP() {
+	if b.MCP == nil {
+		return
+	}
+	b.MCP.OnChange(func() {
+		// The bus blocks until the TUI reads it, and a server may change
+		// status before the TUI starts or while a call holds its lock.
+		go bus.EmitMCPStatus()
+	})
+}
@@ -0,0 +1,79 @@
+package agent
+
+import (
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/biisal/godo/internal/llm"
+	"github.com/biisal/godo/internal/mcp"
+	"github.com/biisal/godo/internal/store"
+)
+
+// startTestMCP builds the fake MCP server of package mcp and starts it as
+// the server "fake".
+func startTestMCP(t *testing.T) *mcp.Manager {
+	t.Helper()
+	server := filepath.Join(t.TempDir(), "fakemcp")
+	if runtime.GOOS == "windows" {
+		server += ".exe"
+	}
+	if out, err := exec.Command("go", "build", "-o", server, "../../../mcp/testdata/fakemcp").CombinedOutput(); err != nil {
+		t.Fatalf("failed to build the fake server: %v\n%s", err, out)
+	}
+	m := mcp.NewManager([]mcp.ServerConfig{{Name: "fake", Command: server}})
+	t.Cleanup(m.Close)
+	if errs := m.Start(t.Context()); len(errs) != 0 {
+		t.Fatalf("Start failed: %v", errs)
+	}
+	return m
+}
+
+func TestMCPTools(t *testing.T) {
+	drainBus(t)
+	b := newTestBot(store.NewFakeChatStore(), store.NewFakeMemoryStore())
+	builtin := len(b.tools)
+	b.MCP = startTestMCP(t)
+
+	offered := b.requestTools()
+	i := slices.IndexFunc(offered, func(tool llm.Tool) bool { return tool.Name == "mcp__fake__echo" })
+	if i < 0 || offered[i].Description != "[MCP fake] Echo text back." || offered[i].Parameters["type"] != "object" {
+		t.Fatalf("Expected the server's tools offered under qualified names, got %+v", offered[builtin:])
+	}
+	if len(b.tools) != builtin {
+		t.Error("Expected the built-in tool list left alone")
+	}
+	if !b.readOnly("mcp__fake__echo") || b.readOnly("mcp__fake__fail") {
+		t.Error("Expected the server's read-only hints honoured")
+	}
+	b.SetPlanMode(true)
+	if b.offers("mcp__fake__echo") {
+		t.Error("Expected plan mode to withhold MCP tools despite their read-only hints")
+	}
+	b.SetPlanMode(false)
+
+	call := func(name, args string) (any, error) {
+		result, _, err := b.runFunction(t.Context(), name, llm.ToolCall{
+			Function: llm.FunctionCall{Name: name, Arguments: args},
+		})
+		return result, err
+	}
+	if result, err := call("mcp__fake__echo", `{"text":"hi"}`); err != nil || result != "hi" {
+		t.Errorf("Expected the call routed to the server, got %v, %v", result, err)
+	}
+	if _, err := call("mcp__fake__fail", `{}`); err == nil || err.Error() != "it broke" {
+		t.Errorf("Expected the tool's error, got %v", err)
+	}
+	if _, err := call("mcp__other__echo", `{}`); err == nil || !strings.Contains(err.Error(), "unknown function") {
+		t.Errorf("Expected an unknown server rejected, got %v", err)
+	}
+
+	// A crashed server is started again by the next call.
+	_, _ = call("mcp__fake__crash", `{}`)
+	if result, err := call("mcp__fake__echo", `{"text":"back"}`); err != nil || result != "back" {
+		t.Errorf("Expected the server restarted, got %v, %v", result, err)
+	}
+}
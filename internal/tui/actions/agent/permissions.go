@@ -0,0 +1,141 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/biisal/godo/internal/bus"
+	"github.com/biisal/godo/internal/llm"
+	"github.com/biisal/godo/internal/permission"
+)
+
+// ErrPermissionDenied is returned for tool calls a rule or the user denied.
+var ErrPermissionDenied = errors.New("permission denied")
+
+// DefaultPermissions ask before the tools that change files, the database
+// or anything a shell command can reach. Other built-in tools run unless a
+// rule says otherwise; plugin and MCP tools follow extraDefault.
+var DefaultPermissions = map[string]permission.Action{
+	RunShellCommandFunc: permission.Ask,
+	WriteFileFunc:       permission.Ask,
+	EditFileFunc:        permission.Ask,
+	PatchFileFunc:       permission.Ask,
+	InsertAtLineFunc:    permission.Ask,
+	PerformSQLFunc:      permission.Ask,
+}
+
+// subjectArgs are the arguments permission patterns match, in order of
+// preference.
+var subjectArgs = []string{"command", "path", "paths", "query", "url", "root"}
+
+// permissionSubject returns what a call acts on, such as its shell command
+// or file path, for matching against rule patterns.
+func permissionSubject(tc llm.ToolCall) string {
+	var args map[string]any
+	if err := json.Unmarshal([]byte(tc.Function.Arguments), &args); err != nil {
+		return ""
+	}
+	for _, name := range subjectArgs {
+		switch v := args[name].(type) {
+		case string:
+			return strings.TrimSpace(v)
+		case []any:
+			parts := make([]string, 0, len(v))
+			for _, p := range v {
+				if s, ok := p.(string); ok {
+					parts = append(parts, s)
+				}
+			}
+			return strings.Join(parts, " ")
+		}
+	}
+	return ""
+}
+
+// extraDefault is the action for a plugin or MCP tool that no rule
+// matches. Godo cannot tell what such a tool does, so it asks unless the
+// plugin is marked readOnly or the server hints the tool is read-only.
+func (b *Bot) extraDefault(tool string) (permission.Action, bool) {
+	if p, ok := b.plugins[tool]; ok {
+		if p.ReadOnly {
+			return permission.Allow, true
+		}
+		return permission.Ask, true
+	}
+	if b.MCP != nil {
+		if t, ok := b.MCP.Lookup(tool); ok {
+			if t.ReadOnly() {
+				return permission.Allow, true
+			}
+			return permission.Ask, true
+		}
+	}
+	return "", false
+}
+
+// checkPermission applies the permission rules to tc and asks the user
+// when they say so, reporting whether the user approved the call just now.
+// Answering "always" saves a rule allowing the same tool on the same
+// subject.
+func (b *Bot) checkPermission(tc llm.ToolCall) (bool, error) {
+	if b.Permissions == nil {
+		return false, nil
+	}
+	tool, subject := tc.Function.Name, permissionSubject(tc)
+	// One question at a time, and an answer may settle the calls waiting
+	// behind it.
+	b.approveMu.Lock()
+	defer b.approveMu.Unlock()
+
+	decide := b.Permissions.Decide
+	if tool == RunShellCommandFunc {
+		decide = b.Permissions.DecideCommand
+	}
+	action, rule := decide(tool, subject)
+	if rule == nil {
+		if def, ok := b.extraDefault(tool); ok {
+			action = def
+		}
+	}
+	switch action {
+	case permission.Allow:
+		return false, nil
+	case permission.Deny:
+		return false, fmt.Errorf("%w by the rule %s; do not retry this call", ErrPermissionDenied, rule)
+	}
+
+	question := "Allow " + tool + "?"
+	if subject != "" {
+		question += "\n" + subject
+	}
+	switch b.approve(question) {
+	case bus.AllowOnce:
+		return true, nil
+	case bus.AllowAlways:
+		always := permission.Rule{Tool: tool, Pattern: permission.Literal(subject), Action: permission.Allow}
+		if err := b.Permissions.Add(always); err != nil {
+			slog.Error("failed to save permission rule", "rule", always, "err", err)
+		}
+		return true, nil
+	}
+	return false, fmt.Errorf("%w by the user; do not retry this call unless they ask", ErrPermissionDenied)
+}
+
+type approvedKey struct{}
+
+// withApproval marks ctx as belonging to a call the user approved in a
+// permission prompt, so its tool does not ask the same question again.
+func withApproval(ctx context.Context) context.Context {
+	return context.WithValue(ctx, approvedKey{}, true)
+}
+
+// approved reports whether the user approved the call running with ctx in
+// a permission prompt.
+func approved(ctx context.Context) bool {
+	ok, _ := ctx.Value(approvedKey{}).(bool)
+	return ok
+}
@@ -0,0 +1,175 @@
+package agent
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/biisal/godo/internal/bus"
+	"github.com/biisal/godo/internal/llm"
+	"github.com/biisal/godo/internal/permission"
+	"github.com/biisal/godo/internal/plugin"
+	"github.com/biisal/godo/internal/store"
+)
+
+func TestPermissionSubject(t *testing.T) {
+	tests := map[string]string{
+		`{"command":"  go test ./... "}`:     "go test ./...",
+		`{"path":"notes.txt","content":"x"}`: "notes.txt",
+		`{"paths":["a.go","b.go"]}`:          "a.go b.go",
+		`{"query":"SELECT 1"}`:               "SELECT 1",
+		`{"maxResults":3}`:                   "",
+		`not json`:                           "",
+	}
+	for args, want := range tests {
+		if got := permissionSubject(llm.ToolCall{Function: llm.FunctionCall{Arguments: args}}); got != want {
+			t.Errorf("permissionSubject(%s) = %q, want %q", args, got, want)
+		}
+	}
+}
+
+// newPermissionTestBot returns a bot with the default permissions that
+// answers every approval prompt with answer and records the questions.
+func newPermissionTestBot(t *testing.T, answer bus.Approval, rules ...permission.Rule) (*Bot, *[]string) {
+	t.Helper()
+	b := newTestBot(store.NewFakeChatStore(), store.NewFakeMemoryStore())
+	policy, err := permission.New(rules, DefaultPermissions)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	b.Permissions = policy
+	var questions []string
+	b.approve = func(question string) bus.Approval {
+		questions = append(questions, question)
+		return answer
+	}
+	return b, &questions
+}
+
+func TestPermissionDeniedByUser(t *testing.T) {
+	drainBus(t)
+	b, questions := newPermissionTestBot(t, bus.Deny)
+	path := filepath.Join(t.TempDir(), "made")
+
+	_, _, err := callTool(t, b, RunShellCommandFunc, map[string]any{"command": "touch " + path})
+	if !errors.Is(err, ErrPermissionDenied) {
+		t.Fatalf("Expected ErrPermissionDenied, got %v", err)
+	}
+	if len(*questions) != 1 || (*questions)[0] != "Allow RunShellCommand?\ntouch "+path {
+		t.Errorf("Expected one approval prompt with the command, got %q", *questions)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected the denied command not to run, got %v", err)
+	}
+}
+
+func TestPermissionAllowAlways(t *testing.T) {
+	drainBus(t)
+	b, questions := newPermissionTestBot(t, bus.AllowAlways)
+	path := filepath.Join(t.TempDir(), "notes.txt")
+
+	for range 2 {
+		if _, _, err := callTool(t, b, WriteFileFunc, map[string]any{"path": path, "content": "hi"}); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+	if len(*questions) != 1 {
+		t.Errorf("Expected to be asked once, got %q", *questions)
+	}
+	rules := b.Permissions.Rules()
+	if len(rules) != 1 || rules[0].Tool != WriteFileFunc || rules[0].Action != permission.Allow {
+		t.Errorf("Expected an allow rule for the file, got %+v", rules)
+	}
+
+	other := filepath.Join(filepath.Dir(path), "other.txt")
+	if _, _, err := callTool(t, b, WriteFileFunc, map[string]any{"path": other, "content": "hi"}); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if len(*questions) != 2 {
+		t.Errorf("Expected another file to be asked about, got %q", *questions)
+	}
+}
+
+func TestPermissionExtraTools(t *testing.T) {
+	drainBus(t)
+	b, questions := newPermissionTestBot(t, bus.Deny)
+	reader := loadTestPlugin(t, `{"name": "Peek", "description": "Read.", "command": "./run.sh", "readOnly": true}`, "echo peeked")
+	writer := loadTestPlugin(t, `{"name": "Poke", "description": "Write.", "command": "./run.sh"}`, "echo poked")
+	if errs := b.AddPlugins([]*plugin.Plugin{reader, writer}); len(errs) != 0 {
+		t.Fatalf("AddPlugins failed: %v", errs)
+	}
+	b.MCP = startTestMCP(t)
+
+	tests := []struct {
+		tool  string
+		asked bool
+	}{
+		{"Peek", false},
+		{"Poke", true},
+		{"mcp__fake__echo", false},
+		{"mcp__fake__fail", true},
+	}
+	for _, tt := range tests {
+		*questions = nil
+		_, _, err := b.runFunction(t.Context(), tt.tool, llm.ToolCall{
+			Function: llm.FunctionCall{Name: tt.tool, Arguments: `{"text":"hi"}`},
+		})
+		if asked := len(*questions) == 1; asked != tt.asked {
+			t.Errorf("%s: expected asked %v, got questions %q", tt.tool, tt.asked, *questions)
+		}
+		if tt.asked && !errors.Is(err, ErrPermissionDenied) {
+			t.Errorf("%s: expected the denied call refused, got %v", tt.tool, err)
+		}
+	}
+
+	allow := permission.Rule{Tool: "Poke", Action: permission.Allow}
+	if err := b.Permissions.Add(allow); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	*questions = nil
+	result, _, err := b.runFunction(t.Context(), "Poke", llm.ToolCall{
+		Function: llm.FunctionCall{Name: "Poke", Arguments: `{}`},
+	})
+	if err != nil || result != "poked" || len(*questions) != 0 {
+		t.Errorf("Expected a rule to override the default, got %v, %v, %q", result, err, *questions)
+	}
+}
+
+func TestPermissionRules(t *testing.T) {
+	drainBus(t)
+	b, questions := newPermissionTestBot(t, bus.Deny,
+		permission.Rule{Tool: RunShellCommandFunc, Pattern: "echo *", Action: permission.Allow},
+		permission.Rule{Tool: permission.AnyTool, Pattern: "*rm -rf*", Action: permission.Deny},
+	)
+
+	result, _, err := b.runFunction(t.Context(), RunShellCommandFunc, llm.ToolCall{
+		Function: llm.FunctionCall{Name: RunShellCommandFunc, Arguments: `{"command":"echo allowed"}`},
+	})
+	if err != nil || result == nil {
+		t.Fatalf("Expected the allowed command to run, got %v, %v", result, err)
+	}
+	_, _, err = callTool(t, b, RunShellCommandFunc, map[string]any{"command": "echo x && rm -rf /tmp/nothing"})
+	if !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("Expected the deny rule to win over the allow rule, got %v", err)
+	}
+	_, _, err = callTool(t, b, RunShellCommandFunc, map[string]any{"command": "echo hi; rm -rf ~/nothing"})
+	if !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("Expected the deny rule to catch a later command, got %v", err)
+	}
+	if _, _, err := callTool(t, b, ListTodosFunc, map[string]any{}); err != nil {
+		t.Errorf("Expected tools without a default to run, got %v", err)
+	}
+	if len(*questions) != 0 {
+		t.Errorf("Expected no prompts, got %q", *questions)
+	}
+
+	// The allow rule covers no part but the first, so the user is asked.
+	_, _, err = callTool(t, b, RunShellCommandFunc, map[string]any{"command": "echo x && touch pwned"})
+	if !errors.Is(err, ErrPermissionDenied) || len(*questions) != 1 {
+		t.Errorf("Expected a compound command to ask, got %v, %q", err, *questions)
+	}
+	if len(*questions) != 1 {
+		t.Errorf("Expected no prompts, got %q", *questions)
+	}
+}
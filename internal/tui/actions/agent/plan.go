@@ -0,0 +1,117 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/biisal/godo/internal/bus"
+	"github.com/biisal/godo/internal/llm"
+)
+
+const planPrompt = `You are in plan mode. Investigate with the read-only tools you have, without changing anything, and then call SubmitPlan with a short summary and the concrete steps you propose.
+Each step should be one change the user can check, such as editing a file or running a command, with enough detail to carry it out.
+The user approves, edits or rejects the plan; do not start the work before it is approved.`
+
+// PlanMode reports whether the agent is limited to investigating and
+// proposing a plan.
+func (b *Bot) PlanMode() bool {
+	return b.planMode.Load()
+}
+
+// SetPlanMode limits the agent to read-only tools and SubmitPlan, or gives
+// it every tool again. An approved plan turns plan mode off by itself.
+func (b *Bot) SetPlanMode(on bool) {
+	b.planMode.Store(on)
+}
+
+// offers reports whether the model may call the tool name: a sub-agent may
+// only call its allowed tools, and plan mode allows the built-in read-only
+// tools, plugins the user marked readOnly and SubmitPlan, which is offered
+// in plan mode only. An MCP server's read-only hint is the server's own
+// claim, so plan mode withholds every MCP tool.
+func (b *Bot) offers(name string) bool {
+	if b.allowed != nil && !b.allowed[name] {
+		return false
+	}
+	if name == SubmitPlanFunc {
+		return b.PlanMode()
+	}
+	if !b.PlanMode() {
+		return true
+	}
+	if p, ok := b.plugins[name]; ok {
+		return p.ReadOnly
+	}
+	return readOnlyTools[name]
+}
+
+// requestSystem is the system prompt sent with a request.
+func (b *Bot) requestSystem() string {
+	if b.PlanMode() {
+		return b.systemPrompt + "\n\n---\n\n" + planPrompt
+	}
+	return b.systemPrompt
+}
+
+func (b *Bot) runSubmitPlan(ctx context.Context, tc llm.ToolCall) (any, bool, error) {
+	var plan bus.Plan
+	if err := json.Unmarshal([]byte(tc.Function.Arguments), &plan); err != nil {
+		return "", false, fmt.Errorf("invalid tool arguments: %w", err)
+	}
+	plan.Summary = strings.TrimSpace(plan.Summary)
+	if len(plan.Steps) == 0 {
+		return "", false, fmt.Errorf("the plan needs at least one step")
+	}
+	for i, step := range plan.Steps {
+		if strings.TrimSpace(step.Title) == "" {
+			return "", false, fmt.Errorf("step %d has no title", i+1)
+		}
+	}
+
+	review := b.reviewPlan(plan)
+	if !review.Approved || len(review.Steps) == 0 {
+		emitShell(tc, "plan rejected\n")
+		return map[string]any{
+			"approved": false,
+			"message":  "The user rejected the plan. Stay in plan mode and ask them what to change before submitting another.",
+		}, false, nil
+	}
+
+	// The approved steps are tracked as todos, which the agent ticks off
+	// as it works through them.
+	var tracked []map[string]any
+	for i, step := range review.Steps {
+		desc := strings.TrimSpace(step.Description)
+		if desc == "" {
+			desc = fmt.Sprintf("Step %d of the plan: %s", i+1, plan.Summary)
+		}
+		added, err := b.todos.AddTodo(step.Title, strings.TrimSpace(desc))
+		if err != nil {
+			return "", i > 0, fmt.Errorf("failed to track step %d: %w", i+1, err)
+		}
+		tracked = append(tracked, map[string]any{"id": added.ID, "title": added.TitleText})
+	}
+	b.SetPlanMode(false)
+	emitShell(tc, fmt.Sprintf("plan approved with %d steps\n", len(tracked)))
+	return map[string]any{
+		"approved": true,
+		"edited":   !stepsEqual(plan.Steps, review.Steps),
+		"steps":    tracked,
+		"message":  "The user approved the plan and plan mode is off, so every tool is available again. Carry out the steps in order and mark each todo done with ToggleTodo when it is finished.",
+	}, true, nil
+}
+
+func stepsEqual(a, b []bus.PlanStep) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if strings.TrimSpace(a[i].Title) != strings.TrimSpace(b[i].Title) ||
+			strings.TrimSpace(a[i].Description) != strings.TrimSpace(b[i].Description) {
+			return false
+		}
+	}
+	return true
+}
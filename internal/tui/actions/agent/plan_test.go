@@ -0,0 +1,87 @@
+package agent
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/biisal/godo/internal/bus"
+	"github.com/biisal/godo/internal/store"
+)
+
+func TestPlanModeTools(t *testing.T) {
+	drainBus(t)
+	b := newTestBot(store.NewFakeChatStore(), store.NewFakeMemoryStore())
+	if names := toolNames(b.requestTools()); slices.Contains(names, SubmitPlanFunc) || !slices.Contains(names, WriteFileFunc) {
+		t.Errorf("Expected every tool but SubmitPlan outside plan mode, got %v", names)
+	}
+	if _, _, err := callTool(t, b, SubmitPlanFunc, map[string]any{"summary": "x", "steps": []any{}}); err == nil || !strings.Contains(err.Error(), "only available in plan mode") {
+		t.Errorf("Expected SubmitPlan refused outside plan mode, got %v", err)
+	}
+
+	b.SetPlanMode(true)
+	names := toolNames(b.requestTools())
+	for _, name := range []string{ReadFilesFunc, GlobSearchFunc, ProjectTreeFunc, DuckDuckGoSearchFunc, SubmitPlanFunc} {
+		if !slices.Contains(names, name) {
+			t.Errorf("Expected %s offered in plan mode, got %v", name, names)
+		}
+	}
+	for _, name := range []string{WriteFileFunc, RunShellCommandFunc, AddTodoFunc, DelegateFunc} {
+		if slices.Contains(names, name) {
+			t.Errorf("Expected %s withheld in plan mode", name)
+		}
+	}
+	if !strings.HasSuffix(b.requestSystem(), planPrompt) {
+		t.Error("Expected the plan mode instructions in the system prompt")
+	}
+	if _, _, err := callTool(t, b, AddTodoFunc, map[string]any{"title": "x", "description": "y"}); err == nil || !strings.Contains(err.Error(), "plan mode") {
+		t.Errorf("Expected a write refused in plan mode, got %v", err)
+	}
+}
+
+func TestSubmitPlan(t *testing.T) {
+	drainBus(t)
+	b := newTestBot(store.NewFakeChatStore(), store.NewFakeMemoryStore())
+	b.SetPlanMode(true)
+	plan := map[string]any{
+		"summary": "Fix the login bug",
+		"steps": []map[string]any{
+			{"title": "Add a failing test", "description": "In auth_test.go"},
+			{"title": "Fix the check", "description": "In auth.go"},
+		},
+	}
+
+	var reviewed bus.Plan
+	b.reviewPlan = func(p bus.Plan) bus.PlanReview {
+		reviewed = p
+		return bus.PlanReview{}
+	}
+	out, refresh, err := callTool(t, b, SubmitPlanFunc, plan)
+	if err != nil || refresh || reviewed.Summary != "Fix the login bug" || len(reviewed.Steps) != 2 {
+		t.Fatalf("Expected the plan shown for review, got %+v, %v", reviewed, err)
+	}
+	if todos, _ := b.todos.GetTodos(); out["approved"] != false || len(todos) != 0 || !b.PlanMode() {
+		t.Errorf("Expected a rejected plan to track nothing and keep plan mode, got %v", out)
+	}
+
+	// The user edits the plan before approving it.
+	b.reviewPlan = func(p bus.Plan) bus.PlanReview {
+		return bus.PlanReview{Approved: true, Steps: []bus.PlanStep{p.Steps[0], {Title: "Fix the check and the message"}}}
+	}
+	out, refresh, err = callTool(t, b, SubmitPlanFunc, plan)
+	if err != nil || !refresh || out["approved"] != true || out["edited"] != true {
+		t.Fatalf("Expected the plan approved, got %v, %v", out, err)
+	}
+	if b.PlanMode() {
+		t.Error("Expected an approved plan to end plan mode")
+	}
+	todos, _ := b.todos.GetTodos()
+	if len(todos) != 2 || todos[0].TitleText != "Fix the check and the message" || todos[0].DescriptionText != "Step 2 of the plan: Fix the login bug" {
+		t.Errorf("Expected the approved steps tracked as todos, got %+v", todos)
+	}
+
+	b.SetPlanMode(true)
+	if _, _, err := callTool(t, b, SubmitPlanFunc, map[string]any{"summary": "x", "steps": []map[string]any{{"title": " "}}}); err == nil {
+		t.Error("Expected a step without a title rejected")
+	}
+}
@@ -0,0 +1,70 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/biisal/godo/internal/bus"
+	"github.com/biisal/godo/internal/llm"
+	"github.com/biisal/godo/internal/plugin"
+)
+
+// AddPlugins offers plugins to the model next to the built-in tools and
+// lists them in the system prompt. A plugin named like a built-in tool or
+// an earlier plugin is skipped and returned as an error.
+func (b *Bot) AddPlugins(plugins []*plugin.Plugin) []error {
+	var errs []error
+	var added []*plugin.Plugin
+	for _, p := range plugins {
+		if _, ok := tools[p.Name]; ok {
+			errs = append(errs, fmt.Errorf("%s: %s is a built-in tool", p.Path, p.Name))
+			continue
+		}
+		if _, ok := b.plugins[p.Name]; ok {
+			errs = append(errs, fmt.Errorf("%s: tool %s is already defined", p.Path, p.Name))
+			continue
+		}
+		if b.plugins == nil {
+			b.plugins = map[string]*plugin.Plugin{}
+		}
+		b.plugins[p.Name] = p
+		b.tools = append(b.tools, llm.Tool{Name: p.Name, Description: p.Description, Parameters: p.Parameters})
+		if p.Status != "" {
+			bus.SetToolStatus(p.Name, p.Status)
+		}
+		added = append(added, p)
+	}
+	if summary := plugin.Summary(added); summary != "" {
+		b.systemPrompt += "\n\n---\n\n" + summary
+	}
+	return errs
+}
+
+// readOnly reports whether calls of the tool name change nothing, so they
+// may run alongside each other.
+func (b *Bot) readOnly(name string) bool {
+	if p, ok := b.plugins[name]; ok {
+		return p.ReadOnly
+	}
+	if b.MCP != nil {
+		if tool, ok := b.MCP.Lookup(name); ok {
+			return tool.ReadOnly()
+		}
+	}
+	return readOnlyTools[name]
+}
+
+func (b *Bot) runPlugin(ctx context.Context, p *plugin.Plugin, tc llm.ToolCall) (any, bool, error) {
+	dir, err := b.workDir()
+	if err != nil {
+		return "", false, fmt.Errorf("failed to get current directory: %w", err)
+	}
+	emitShell(tc, "running plugin "+p.Name+"\n")
+	out, err := p.Run(ctx, dir, tc.Function.Arguments)
+	if err != nil {
+		emitShell(tc, "error: "+err.Error()+"\n")
+		return "", false, err
+	}
+	emitShell(tc, out+"\n")
+	return out, false, nil
+}
@@ -0,0 +1,79 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/biisal/godo/internal/llm"
+	"github.com/biisal/godo/internal/plugin"
+	"github.com/biisal/godo/internal/store"
+)
+
+// loadTestPlugin writes a plugin running script and loads it.
+func loadTestPlugin(t *testing.T, manifest, script string) *plugin.Plugin {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("plugin scripts need a POSIX shell")
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "run.sh"), []byte("#!/bin/sh\n"+script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "plugin.json")
+	if err := os.WriteFile(path, []byte(manifest), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := plugin.Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	return p
+}
+
+func TestPlugins(t *testing.T) {
+	drainBus(t)
+	b := newTestBot(store.NewFakeChatStore(), store.NewFakeMemoryStore())
+	greet := loadTestPlugin(t, `{
+		"name": "Greet",
+		"description": "Greet someone by name.",
+		"parameters": {"type": "object", "properties": {"name": {"type": "string"}}, "required": ["name"]},
+		"command": "./run.sh",
+		"readOnly": true
+	}`, `sed 's/.*"name":"\([^"]*\)".*/hello \1/'`)
+	clash := loadTestPlugin(t, `{"name": "WriteFile", "description": "Shadow a built-in.", "command": "./run.sh"}`, "")
+
+	errs := b.AddPlugins([]*plugin.Plugin{greet, clash})
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "WriteFile is a built-in tool") {
+		t.Errorf("Expected the clashing plugin rejected, got %v", errs)
+	}
+	if !slices.ContainsFunc(b.tools, func(tool llm.Tool) bool { return tool.Name == "Greet" }) {
+		t.Error("Expected the plugin offered to the model")
+	}
+	if !strings.Contains(b.systemPrompt, "# Plugins") || !strings.Contains(b.systemPrompt, "- Greet: Greet someone by name.") {
+		t.Errorf("Expected the plugin in the system prompt, got %q", b.systemPrompt)
+	}
+	if !b.readOnly("Greet") || b.readOnly(WriteFileFunc) || !b.readOnly(ReadFilesFunc) {
+		t.Error("Expected read-only plugins to run alongside other reads")
+	}
+	b.SetPlanMode(true)
+	if !b.offers("Greet") {
+		t.Error("Expected plan mode to allow a read-only plugin")
+	}
+	b.SetPlanMode(false)
+
+	result, _, err := b.runFunction(t.Context(), "Greet", llm.ToolCall{
+		Function: llm.FunctionCall{Name: "Greet", Arguments: `{"name":"Ada"}`},
+	})
+	if err != nil || result != "hello Ada" {
+		t.Errorf("Expected the plugin's output, got %q, %v", result, err)
+	}
+	if _, _, err := b.runFunction(t.Context(), "Greet", llm.ToolCall{
+		Function: llm.FunctionCall{Name: "Greet", Arguments: `{}`},
+	}); err == nil || !strings.Contains(err.Error(), `missing required property "name"`) {
+		t.Errorf("Expected the arguments validated, got %v", err)
+	}
+}
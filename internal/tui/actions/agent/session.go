@@ -0,0 +1,137 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/biisal/godo/internal/store"
+	agentModel "github.com/biisal/godo/internal/tui/models/agent"
+)
+
+// maxTitleRunes caps the length of titles taken from a first prompt.
+const maxTitleRunes = 50
+
+// sessionTitle returns a title for a session started with prompt: its first
+// non-empty line, shortened to maxTitleRunes.
+func sessionTitle(prompt string) string {
+	var title string
+	for line := range strings.SplitSeq(prompt, "\n") {
+		if title = strings.Join(strings.Fields(line), " "); title != "" {
+			break
+		}
+	}
+	if runes := []rune(title); len(runes) > maxTitleRunes {
+		title = strings.TrimSpace(string(runes[:maxTitleRunes-3])) + "..."
+	}
+	return title
+}
+
+// Sessions returns the stored sessions, most recently used first.
+func (b *Bot) Sessions() ([]store.Session, error) {
+	return b.chats.Sessions()
+}
+
+// NewSession starts a new conversation. It is stored once its first message
+// is saved.
+func (b *Bot) NewSession() {
+	b.Session = 0
+	b.History = nil
+}
+
+// ResumeSession makes the stored session id the current conversation.
+func (b *Bot) ResumeSession(id int) error {
+	if _, err := b.chats.GetSession(id); err != nil {
+		return err
+	}
+	log, err := b.chats.List(id)
+	if err != nil {
+		return err
+	}
+	b.Session = id
+	b.History = repairToolCalls(activeHistory(log))
+	return nil
+}
+
+// ContinueSession resumes the most recently used session started in Dir,
+// or else the conversation kept from before sessions existed, which has no
+// directory. It returns store.ErrNotFound when there is neither.
+func (b *Bot) ContinueSession() error {
+	sessions, err := b.chats.Sessions()
+	if err != nil {
+		return err
+	}
+	earlier := 0
+	for _, s := range sessions {
+		if s.Dir == b.Dir {
+			return b.ResumeSession(s.ID)
+		}
+		if s.Dir == "" && earlier == 0 {
+			earlier = s.ID
+		}
+	}
+	if earlier != 0 {
+		return b.ResumeSession(earlier)
+	}
+	return fmt.Errorf("no session in %s: %w", b.Dir, store.ErrNotFound)
+}
+
+// RenameSession changes the title of session id.
+func (b *Bot) RenameSession(id int, title string) error {
+	title = strings.TrimSpace(title)
+	if title == "" {
+		return errors.New("session title cannot be empty")
+	}
+	return b.chats.RenameSession(id, title)
+}
+
+// DeleteSession removes session id and its messages. Deleting the current
+// session starts a new one.
+func (b *Bot) DeleteSession(id int) error {
+	if err := b.chats.DeleteSession(id); err != nil {
+		return err
+	}
+	if id == b.Session {
+		b.NewSession()
+	}
+	return nil
+}
+
+// missingToolResult stands in for the result of a tool call that was saved
+// without one, for example when godo exited while the tool ran.
+const missingToolResult = "No result was recorded; the tool may not have finished."
+
+// repairToolCalls makes a stored log valid to send again: every tool call
+// is followed by exactly one result, and results without a call are dropped.
+func repairToolCalls(log []agentModel.Message) []agentModel.Message {
+	repaired := make([]agentModel.Message, 0, len(log))
+	for i := 0; i < len(log); i++ {
+		msg := log[i]
+		if msg.Role == agentModel.ToolRole {
+			continue // its call was not right before it
+		}
+		repaired = append(repaired, msg)
+		if len(msg.ToolCalls) == 0 {
+			continue
+		}
+
+		results := map[string]agentModel.Message{}
+		for i+1 < len(log) && log[i+1].Role == agentModel.ToolRole {
+			i++
+			results[log[i].ToolCallID] = log[i]
+		}
+		for _, tc := range msg.ToolCalls {
+			result, ok := results[tc.ID]
+			if !ok {
+				result = agentModel.Message{
+					Role:       agentModel.ToolRole,
+					ToolCallID: tc.ID,
+					Name:       tc.Function.Name,
+					Content:    missingToolResult,
+				}
+			}
+			repaired = append(repaired, result)
+		}
+	}
+	return repaired
+}
@@ -0,0 +1,173 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/biisal/godo/internal/llm"
+	"github.com/biisal/godo/internal/store"
+)
+
+func TestSessionTitle(t *testing.T) {
+	tests := map[string]string{
+		"add buy milk":                        "add buy milk",
+		"\n  fix   the build\nit fails on CI": "fix the build",
+		strings.Repeat("word ", 20):           strings.TrimSpace(strings.Repeat("word ", 10)[:47]) + "...",
+		"":                                    "",
+	}
+	for prompt, want := range tests {
+		if got := sessionTitle(prompt); got != want {
+			t.Errorf("sessionTitle(%q) = %q, want %q", prompt, got, want)
+		}
+	}
+}
+
+func TestAgentResponseStartsSession(t *testing.T) {
+	drainBus(t)
+	chats := store.NewFakeChatStore()
+	b := newTestBot(chats, store.NewFakeMemoryStore())
+	b.provider = &scriptedProvider{replies: []llm.Message{
+		{Role: llm.RoleAssistant, Content: "Hello."},
+		{Role: llm.RoleAssistant, Content: "Bye."},
+	}}
+	b.Dir = "/home/me/project"
+
+	if _, _, err := b.AgentResponse(context.Background(), "hi there\nsecond line"); err != nil {
+		t.Fatalf("AgentResponse failed: %v", err)
+	}
+	session, err := chats.GetSession(b.Session)
+	if err != nil || session.Title != "hi there" || session.Dir != "/home/me/project" {
+		t.Fatalf("Expected a session titled after the prompt, got %+v (%v)", session, err)
+	}
+
+	first := b.Session
+	b.NewSession()
+	if _, _, err := b.AgentResponse(context.Background(), "bye"); err != nil {
+		t.Fatalf("AgentResponse failed: %v", err)
+	}
+	if b.Session == first || len(b.History) != 2 {
+		t.Fatalf("Expected a second session with its own history, got session %d, %+v", b.Session, b.History)
+	}
+
+	if err := b.ResumeSession(first); err != nil {
+		t.Fatalf("ResumeSession failed: %v", err)
+	}
+	if b.Session != first || len(b.History) != 2 || b.History[1].Content != "Hello." {
+		t.Errorf("Expected the first conversation back, got %+v", b.History)
+	}
+	if err := b.ResumeSession(99); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for an unknown session, got %v", err)
+	}
+}
+
+func TestContinueSession(t *testing.T) {
+	chats := store.NewFakeChatStore()
+	here, _ := chats.CreateSession("here", "/a")
+	_, _ = chats.CreateSession("elsewhere", "/b")
+	b := newTestBot(chats, store.NewFakeMemoryStore())
+
+	b.Dir = "/a"
+	if err := b.ContinueSession(); err != nil || b.Session != here.ID {
+		t.Errorf("Expected the session started in /a, got %d (%v)", b.Session, err)
+	}
+	b.Dir = "/c"
+	if err := b.ContinueSession(); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound without a session in /c, got %v", err)
+	}
+
+	// The conversation migrated from before sessions has no directory.
+	earlier, _ := chats.CreateSession("Earlier chat", "")
+	if err := b.ContinueSession(); err != nil || b.Session != earlier.ID {
+		t.Errorf("Expected the migrated session without one in /c, got %d (%v)", b.Session, err)
+	}
+	b.Dir = "/a"
+	if err := b.ContinueSession(); err != nil || b.Session != here.ID {
+		t.Errorf("Expected the session started in /a to win, got %d (%v)", b.Session, err)
+	}
+}
+
+func TestRenameAndDeleteSession(t *testing.T) {
+	chats := store.NewFakeChatStore()
+	session, _ := chats.CreateSession("old", "")
+	b := newTestBot(chats, store.NewFakeMemoryStore())
+	_ = b.ResumeSession(session.ID)
+
+	if err := b.RenameSession(session.ID, "  "); err == nil {
+		t.Error("Expected an empty title to be rejected")
+	}
+	if err := b.RenameSession(session.ID, " new "); err != nil {
+		t.Fatalf("RenameSession failed: %v", err)
+	}
+	if got, _ := chats.GetSession(session.ID); got.Title != "new" {
+		t.Errorf("Expected the trimmed title, got %q", got.Title)
+	}
+
+	if err := b.DeleteSession(session.ID); err != nil {
+		t.Fatalf("DeleteSession failed: %v", err)
+	}
+	if b.Session != 0 || b.History != nil {
+		t.Errorf("Expected deleting the current session to start a new one, got session %d", b.Session)
+	}
+}
+
+func TestRepairToolCalls(t *testing.T) {
+	call := func(id string) llm.ToolCall {
+		return llm.ToolCall{ID: id, Function: llm.FunctionCall{Name: ReadFilesFunc}}
+	}
+	result := func(id string) llm.Message {
+		return llm.Message{Role: llm.RoleTool, ToolCallID: id, Name: ReadFilesFunc, Content: "ok " + id}
+	}
+	log := []llm.Message{
+		{Role: llm.RoleUser, Content: "read two files"},
+		{Role: llm.RoleAssistant, ToolCalls: []llm.ToolCall{call("a"), call("b")}},
+		result("b"),
+		result("stray"),
+		{Role: llm.RoleUser, Content: "are you there?"},
+		result("orphan"),
+	}
+
+	got := repairToolCalls(log)
+	if len(got) != 5 {
+		t.Fatalf("Expected prompt, call, two results and prompt, got %+v", got)
+	}
+	if got[2].ToolCallID != "a" || got[2].Content != missingToolResult {
+		t.Errorf("Expected a stand-in result for the unanswered call first, got %+v", got[2])
+	}
+	if !reflect.DeepEqual(got[3], result("b")) {
+		t.Errorf("Expected the stored result kept in call order, got %+v", got[3])
+	}
+	if got[4].Role != llm.RoleUser {
+		t.Errorf("Expected results without a call dropped, got %+v", got[4])
+	}
+}
+
+func TestResumeSessionKeepsToolActivity(t *testing.T) {
+	drainBus(t)
+	chats := store.NewFakeChatStore()
+	b := newTestBot(chats, store.NewFakeMemoryStore())
+	b.provider = &scriptedProvider{replies: []llm.Message{
+		{Role: llm.RoleAssistant, Reasoning: "check todos", ToolCalls: []llm.ToolCall{{
+			ID:       "call_1",
+			Function: llm.FunctionCall{Name: ListTodosFunc, Arguments: `{}`},
+		}}},
+		{Role: llm.RoleAssistant, Content: "You have no todos."},
+	}}
+	history, _, err := b.AgentResponse(context.Background(), "what is left?")
+	if err != nil {
+		t.Fatalf("AgentResponse failed: %v", err)
+	}
+
+	resumed := newTestBot(chats, store.NewFakeMemoryStore())
+	if err := resumed.ResumeSession(b.Session); err != nil {
+		t.Fatalf("ResumeSession failed: %v", err)
+	}
+	if len(resumed.History) != 4 || resumed.History[1].Reasoning != "check todos" || resumed.History[2].ToolCallID != "call_1" {
+		t.Fatalf("Expected the tool call, its result and reasoning back, got %+v", resumed.History)
+	}
+	if len(resumed.History) != len(history) {
+		t.Errorf("Expected the resumed history to match the original, got %+v", resumed.History)
+	}
+}
@@ -18,29 +18,42 @@ import (
 	"sync"
 	"time"
 
-	"github.com/biisal/godo/internal/bus"
 	"github.com/biisal/godo/internal/config"
-	"github.com/biisal/godo/internal/memory"
+	"github.com/biisal/godo/internal/extdb"
+	"github.com/biisal/godo/internal/llm"
+	"github.com/biisal/godo/internal/sandbox"
+	"github.com/biisal/godo/internal/sqlguard"
 	"github.com/biisal/godo/internal/tui/actions/todo"
+	"github.com/biisal/godo/internal/workspace"
 	"github.com/gocolly/colly/v2"
-	"github.com/openai/openai-go"
 )
 
-func runPerformSql(tc openai.ChatCompletionMessageToolCall) (any, bool, error) {
+func (b *Bot) runPerformSql(ctx context.Context, tc llm.ToolCall) (any, bool, error) {
 	var args struct {
 		Query string `json:"query"`
 	}
 	if err := json.Unmarshal([]byte(tc.Function.Arguments), &args); err != nil {
 		return "", false, fmt.Errorf("invalid tool arguments: %w", err)
 	}
-	result, err := todo.PerformSqlQuery(args.Query)
+	if b.sqlDB == nil {
+		return "", false, fmt.Errorf("no database available")
+	}
+	st, err := sqlguard.Check(args.Query)
 	if err != nil {
 		return "", false, err
 	}
-	return result, true, nil
+	// The permission prompt showed the statement already.
+	if st.Destructive && !approved(ctx) && !b.confirm(fmt.Sprintf("Run this %s on your todos?\n%s", st.Verb, strings.TrimSpace(args.Query))) {
+		return "The user declined to run this statement. Do not retry it unless they ask.", false, nil
+	}
+	result, err := todo.PerformSqlQuery(b.sqlDB, args.Query, st.Kind == sqlguard.Read)
+	if err != nil {
+		return "", false, err
+	}
+	return result, st.Kind == sqlguard.Write, nil
 }
 
-func runShellCommand(tc openai.ChatCompletionMessageToolCall) (any, bool, error) {
+func (b *Bot) runShellCommand(ctx context.Context, tc llm.ToolCall) (any, bool, error) {
 	var args struct {
 		Command string `json:"command"`
 	}
@@ -48,13 +61,19 @@ func runShellCommand(tc openai.ChatCompletionMessageToolCall) (any, bool, error)
 		return "", false, fmt.Errorf("invalid tool arguments: %w", err)
 	}
 
-	bus.EmitShell("$ " + args.Command + "\n")
+	emitShell(tc, "$ "+args.Command+"\n")
+	if b.Sandbox != nil && !b.Sandbox.Isolated() {
+		emitShell(tc, "[sandbox] restricted mode: only the environment is cleaned, files and network are not isolated\n")
+	}
 
 	const timeout = 60 * time.Second
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
-	cmd := exec.CommandContext(ctx, "sh", "-c", args.Command)
+	cmd := sandbox.Shell(ctx, args.Command)
+	if b.Sandbox != nil {
+		cmd = b.Sandbox.Command(ctx, args.Command)
+	}
 
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
@@ -91,39 +110,60 @@ func runShellCommand(tc openai.ChatCompletionMessageToolCall) (any, bool, error)
 	go readOutput(stdout)
 	go readOutput(stderr)
 
+	var waitErr error
 	go func() {
-		_ = cmd.Wait()
+		waitErr = cmd.Wait()
 		wg.Wait()
 		close(outputChan)
 	}()
 
 	for out := range outputChan {
 		fullOutput.WriteString(out)
-		bus.EmitShell(out)
+		emitShell(tc, out)
 	}
 
 	output := fullOutput.String()
 
-	// If the context timed out, return an error so the agent knows.
-	if ctx.Err() == context.DeadlineExceeded {
+	// If the context timed out or was cancelled, return an error so the
+	// agent knows.
+	switch ctx.Err() {
+	case context.DeadlineExceeded:
 		msg := fmt.Sprintf("command timed out after %s", timeout)
 		fullOutput.WriteString("\n" + msg + "\n")
-		bus.EmitShell(msg + "\n")
+		emitShell(tc, msg+"\n")
 		slog.Warn("shell command timed out", "command", args.Command, "timeout", timeout)
 		return fullOutput.String(), false, errors.New(msg)
+	case context.Canceled:
+		msg := "command cancelled by the user"
+		fullOutput.WriteString("\n" + msg + "\n")
+		emitShell(tc, msg+"\n")
+		slog.Info("shell command cancelled", "command", args.Command)
+		return fullOutput.String(), false, errors.New(msg)
+	}
+
+	if b.Sandbox != nil {
+		if reason := b.Sandbox.Blocked(waitErr != nil, output); reason != "" {
+			msg := "[sandbox] " + reason
+			emitShell(tc, msg+"\n")
+			slog.Info("shell command blocked by the sandbox", "command", args.Command, "reason", reason)
+			output += "\n" + msg + "\n"
+		}
 	}
 
 	slog.Debug("command output completed", "command", args.Command)
 	return output, false, nil
 }
 
-func runReadSkill(tc openai.ChatCompletionMessageToolCall) (any, bool, error) {
+func (b *Bot) runReadSkill(ctx context.Context, tc llm.ToolCall) (any, bool, error) {
 	var args struct {
 		SkillName string `json:"skillName"`
 	}
 	if err := json.Unmarshal([]byte(tc.Function.Arguments), &args); err != nil {
 		return "", false, fmt.Errorf("invalid tool arguments: %w", err)
 	}
+	if args.SkillName == "" || args.SkillName != filepath.Base(args.SkillName) || strings.HasPrefix(args.SkillName, ".") {
+		return "", false, fmt.Errorf("invalid skill name %q", args.SkillName)
+	}
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
 		slog.Warn("Could not determine user home directory for skills", "err", err)
@@ -137,7 +177,7 @@ func runReadSkill(tc openai.ChatCompletionMessageToolCall) (any, bool, error) {
 	return string(content), false, nil
 }
 
-func runGlobSearch(tc openai.ChatCompletionMessageToolCall) (any, bool, error) {
+func (b *Bot) runGlobSearch(ctx context.Context, tc llm.ToolCall) (any, bool, error) {
 	var args struct {
 		Pattern string `json:"pattern"`
 		Root    string `json:"root"`
@@ -153,9 +193,9 @@ func runGlobSearch(tc openai.ChatCompletionMessageToolCall) (any, bool, error) {
 		args.Root = "."
 	}
 
-	rootAbs, err := filepath.Abs(args.Root)
+	rootAbs, err := b.resolvePath(tc, args.Root, workspace.Read)
 	if err != nil {
-		return "", false, fmt.Errorf("failed to resolve root %q: %w", args.Root, err)
+		return "", false, err
 	}
 
 	pattern := filepath.ToSlash(filepath.Clean(args.Pattern))
@@ -197,7 +237,7 @@ func runGlobSearch(tc openai.ChatCompletionMessageToolCall) (any, bool, error) {
 	}, false, nil
 }
 
-func runReadFiles(tc openai.ChatCompletionMessageToolCall) (any, bool, error) {
+func (b *Bot) runReadFiles(ctx context.Context, tc llm.ToolCall) (any, bool, error) {
 	var args struct {
 		Paths           []string `json:"paths"`
 		MaxBytesPerFile int      `json:"maxBytesPerFile"`
@@ -212,19 +252,14 @@ func runReadFiles(tc openai.ChatCompletionMessageToolCall) (any, bool, error) {
 		args.MaxBytesPerFile = 64 * 1024
 	}
 
-	cwd, err := os.Getwd()
-	if err != nil {
-		return "", false, fmt.Errorf("failed to get current directory: %w", err)
-	}
-
 	results := make([]map[string]any, 0, len(args.Paths))
 	for _, rawPath := range args.Paths {
 		pathArg := strings.TrimSpace(rawPath)
 		if pathArg != "" {
-			bus.EmitShell("reading " + pathArg + "\n")
+			emitShell(tc, "reading "+pathArg+"\n")
 		}
 		if pathArg == "" {
-			bus.EmitShell("error: empty path\n")
+			emitShell(tc, "error: empty path\n")
 			results = append(results, map[string]any{
 				"path":  rawPath,
 				"error": "empty path",
@@ -232,15 +267,19 @@ func runReadFiles(tc openai.ChatCompletionMessageToolCall) (any, bool, error) {
 			continue
 		}
 
-		targetPath := pathArg
-		if !filepath.IsAbs(targetPath) {
-			targetPath = filepath.Join(cwd, targetPath)
+		targetPath, err := b.resolvePath(tc, pathArg, workspace.Read)
+		if err != nil {
+			emitShell(tc, "error: "+err.Error()+"\n")
+			results = append(results, map[string]any{
+				"path":  pathArg,
+				"error": err.Error(),
+			})
+			continue
 		}
-		targetPath = filepath.Clean(targetPath)
 
 		info, statErr := os.Stat(targetPath)
 		if statErr != nil {
-			bus.EmitShell("error: " + statErr.Error() + "\n")
+			emitShell(tc, "error: "+statErr.Error()+"\n")
 			results = append(results, map[string]any{
 				"path":  pathArg,
 				"error": statErr.Error(),
@@ -248,7 +287,7 @@ func runReadFiles(tc openai.ChatCompletionMessageToolCall) (any, bool, error) {
 			continue
 		}
 		if info.IsDir() {
-			bus.EmitShell("error: path is a directory\n")
+			emitShell(tc, "error: path is a directory\n")
 			results = append(results, map[string]any{
 				"path":  pathArg,
 				"error": "path is a directory",
@@ -258,7 +297,7 @@ func runReadFiles(tc openai.ChatCompletionMessageToolCall) (any, bool, error) {
 
 		data, readErr := os.ReadFile(targetPath)
 		if readErr != nil {
-			bus.EmitShell("error: " + readErr.Error() + "\n")
+			emitShell(tc, "error: "+readErr.Error()+"\n")
 			results = append(results, map[string]any{
 				"path":  pathArg,
 				"error": readErr.Error(),
@@ -278,7 +317,7 @@ func runReadFiles(tc openai.ChatCompletionMessageToolCall) (any, bool, error) {
 			"truncated": truncated,
 			"content":   string(data),
 		})
-		bus.EmitShell(string(data) + "\n")
+		emitShell(tc, string(data)+"\n")
 	}
 
 	return map[string]any{
@@ -287,7 +326,7 @@ func runReadFiles(tc openai.ChatCompletionMessageToolCall) (any, bool, error) {
 	}, false, nil
 }
 
-func runWriteFile(tc openai.ChatCompletionMessageToolCall) (any, bool, error) {
+func (b *Bot) runWriteFile(ctx context.Context, tc llm.ToolCall) (any, bool, error) {
 	var args struct {
 		Path          string `json:"path"`
 		Content       string `json:"content"`
@@ -305,16 +344,10 @@ func runWriteFile(tc openai.ChatCompletionMessageToolCall) (any, bool, error) {
 	createParents := args.CreateParents != nil && *args.CreateParents
 	appendMode := args.Append != nil && *args.Append
 
-	cwd, err := os.Getwd()
+	targetPath, err := b.resolvePath(tc, pathArg, workspace.Write)
 	if err != nil {
-		return "", false, fmt.Errorf("failed to get current directory: %w", err)
-	}
-
-	targetPath := pathArg
-	if !filepath.IsAbs(targetPath) {
-		targetPath = filepath.Join(cwd, targetPath)
+		return "", false, err
 	}
-	targetPath = filepath.Clean(targetPath)
 
 	if createParents {
 		parentDir := filepath.Dir(targetPath)
@@ -359,7 +392,7 @@ func runWriteFile(tc openai.ChatCompletionMessageToolCall) (any, bool, error) {
 	}, false, nil
 }
 
-func runEditFile(tc openai.ChatCompletionMessageToolCall) (any, bool, error) {
+func (b *Bot) runEditFile(ctx context.Context, tc llm.ToolCall) (any, bool, error) {
 	var args struct {
 		Path       string `json:"path"`
 		OldString  string `json:"oldString"`
@@ -375,7 +408,7 @@ func runEditFile(tc openai.ChatCompletionMessageToolCall) (any, bool, error) {
 		return "", false, fmt.Errorf("path is required")
 	}
 
-	targetPath, err := resolvePath(pathArg)
+	targetPath, err := b.resolvePath(tc, pathArg, workspace.Write)
 	if err != nil {
 		return "", false, err
 	}
@@ -434,7 +467,7 @@ func runEditFile(tc openai.ChatCompletionMessageToolCall) (any, bool, error) {
 	}, false, nil
 }
 
-func runPatchFile(tc openai.ChatCompletionMessageToolCall) (any, bool, error) {
+func (b *Bot) runPatchFile(ctx context.Context, tc llm.ToolCall) (any, bool, error) {
 	var args struct {
 		Path  string `json:"path"`
 		Patch string `json:"patch"`
@@ -450,17 +483,24 @@ func runPatchFile(tc openai.ChatCompletionMessageToolCall) (any, bool, error) {
 		return "", false, fmt.Errorf("patch is required")
 	}
 
-	targetPath, err := resolvePath(pathArg)
+	targetPath, err := b.resolvePath(tc, pathArg, workspace.Write)
 	if err != nil {
 		return "", false, err
 	}
-	cwd, err := os.Getwd()
+	// The patch may touch more files than path, and each needs the same
+	// access.
+	for _, p := range patchPaths(args.Patch) {
+		if _, err := b.resolvePath(tc, p, workspace.Write); err != nil {
+			return "", false, err
+		}
+	}
+	dir, err := b.workDir()
 	if err != nil {
 		return "", false, fmt.Errorf("failed to get current directory: %w", err)
 	}
 
 	cmd := exec.Command("git", "apply", "--recount", "--whitespace=nowarn", "-")
-	cmd.Dir = cwd
+	cmd.Dir = dir
 	cmd.Stdin = strings.NewReader(args.Patch)
 	out, err := cmd.CombinedOutput()
 	if err != nil {
@@ -478,7 +518,7 @@ func runPatchFile(tc openai.ChatCompletionMessageToolCall) (any, bool, error) {
 	}, false, nil
 }
 
-func runInsertAtLine(tc openai.ChatCompletionMessageToolCall) (any, bool, error) {
+func (b *Bot) runInsertAtLine(ctx context.Context, tc llm.ToolCall) (any, bool, error) {
 	var args struct {
 		Path       string `json:"path"`
 		LineNumber int    `json:"lineNumber"`
@@ -495,7 +535,7 @@ func runInsertAtLine(tc openai.ChatCompletionMessageToolCall) (any, bool, error)
 		return "", false, fmt.Errorf("lineNumber must be >= 1")
 	}
 
-	targetPath, err := resolvePath(pathArg)
+	targetPath, err := b.resolvePath(tc, pathArg, workspace.Write)
 	if err != nil {
 		return "", false, err
 	}
@@ -532,19 +572,7 @@ func runInsertAtLine(tc openai.ChatCompletionMessageToolCall) (any, bool, error)
 	}, false, nil
 }
 
-func resolvePath(pathArg string) (string, error) {
-	cwd, err := os.Getwd()
-	if err != nil {
-		return "", fmt.Errorf("failed to get current directory: %w", err)
-	}
-	targetPath := pathArg
-	if !filepath.IsAbs(targetPath) {
-		targetPath = filepath.Join(cwd, targetPath)
-	}
-	return filepath.Clean(targetPath), nil
-}
-
-func runProjectTree(tc openai.ChatCompletionMessageToolCall) (any, bool, error) {
+func (b *Bot) runProjectTree(ctx context.Context, tc llm.ToolCall) (any, bool, error) {
 	var args struct {
 		Root         string `json:"root"`
 		MaxDepth     int    `json:"maxDepth"`
@@ -564,11 +592,11 @@ func runProjectTree(tc openai.ChatCompletionMessageToolCall) (any, bool, error)
 		includeFiles = *args.IncludeFiles
 	}
 
-	rootAbs, err := filepath.Abs(args.Root)
+	rootAbs, err := b.resolvePath(tc, args.Root, workspace.Read)
 	if err != nil {
-		return "", false, fmt.Errorf("failed to resolve root %q: %w", args.Root, err)
+		return "", false, err
 	}
-	bus.EmitShell("building tree for " + rootAbs + "\n")
+	emitShell(tc, "building tree for "+rootAbs+"\n")
 
 	const maxEntries = 2000
 	lines := make([]string, 0, 256)
@@ -615,7 +643,7 @@ func runProjectTree(tc openai.ChatCompletionMessageToolCall) (any, bool, error)
 	}
 
 	treeText := strings.Join(lines, "\n")
-	bus.EmitShell(treeText + "\n")
+	emitShell(tc, treeText+"\n")
 
 	return map[string]any{
 		"root":      rootAbs,
@@ -672,7 +700,7 @@ func matchGlobParts(patternParts, pathParts []string) bool {
 	return matchGlobParts(patternParts[1:], pathParts[1:])
 }
 
-func runDuckDuckGoSearch(tc openai.ChatCompletionMessageToolCall) (any, bool, error) {
+func (b *Bot) runDuckDuckGoSearch(ctx context.Context, tc llm.ToolCall) (any, bool, error) {
 	var args struct {
 		Query      string `json:"query"`
 		MaxResults int    `json:"maxResults"`
@@ -700,6 +728,7 @@ func runDuckDuckGoSearch(tc openai.ChatCompletionMessageToolCall) (any, bool, er
 
 	c := colly.NewCollector(
 		colly.UserAgent("godo-agent/1.0 (+https://github.com/biisal/godo)"),
+		colly.StdlibContext(ctx),
 	)
 	c.SetRequestTimeout(20 * time.Second)
 
@@ -759,7 +788,7 @@ func runDuckDuckGoSearch(tc openai.ChatCompletionMessageToolCall) (any, bool, er
 	}, false, nil
 }
 
-func runScrapePage(tc openai.ChatCompletionMessageToolCall) (any, bool, error) {
+func (b *Bot) runScrapePage(ctx context.Context, tc llm.ToolCall) (any, bool, error) {
 	var args struct {
 		URL      string `json:"url"`
 		MaxChars int    `json:"maxChars"`
@@ -780,6 +809,7 @@ func runScrapePage(tc openai.ChatCompletionMessageToolCall) (any, bool, error) {
 
 	c := colly.NewCollector(
 		colly.UserAgent("godo-agent/1.0 (+https://github.com/biisal/godo)"),
+		colly.StdlibContext(ctx),
 	)
 	c.SetRequestTimeout(20 * time.Second)
 
@@ -881,7 +911,7 @@ func normalizeSpace(s string) string {
 	return strings.Join(strings.Fields(html.UnescapeString(s)), " ")
 }
 
-func runSaveMemory(tc openai.ChatCompletionMessageToolCall) (any, bool, error) {
+func (b *Bot) runSaveMemory(ctx context.Context, tc llm.ToolCall) (any, bool, error) {
 	var args struct {
 		Key     string `json:"key"`
 		Content string `json:"content"`
@@ -890,12 +920,11 @@ func runSaveMemory(tc openai.ChatCompletionMessageToolCall) (any, bool, error) {
 		return "", false, fmt.Errorf("invalid tool arguments: %w", err)
 	}
 
-	store := memory.NewMemoryStore(config.Cfg.DB)
-	if err := store.Save(args.Key, args.Content); err != nil {
+	if err := b.memories.Save(args.Key, args.Content); err != nil {
 		return "", false, fmt.Errorf("failed to save memory: %w", err)
 	}
 
-	bus.EmitShell(fmt.Sprintf("saved memory: %s\n", args.Key))
+	emitShell(tc, fmt.Sprintf("saved memory: %s\n", args.Key))
 	return map[string]any{
 		"success": true,
 		"key":     args.Key,
@@ -903,7 +932,7 @@ func runSaveMemory(tc openai.ChatCompletionMessageToolCall) (any, bool, error) {
 	}, false, nil
 }
 
-func runRecallMemories(tc openai.ChatCompletionMessageToolCall) (any, bool, error) {
+func (b *Bot) runRecallMemories(ctx context.Context, tc llm.ToolCall) (any, bool, error) {
 	var args struct {
 		Query string `json:"query"`
 	}
@@ -911,8 +940,7 @@ func runRecallMemories(tc openai.ChatCompletionMessageToolCall) (any, bool, erro
 		return "", false, fmt.Errorf("invalid tool arguments: %w", err)
 	}
 
-	store := memory.NewMemoryStore(config.Cfg.DB)
-	entries, err := store.Search(args.Query)
+	entries, err := b.memories.Search(args.Query)
 	if err != nil {
 		return "", false, fmt.Errorf("failed to search memories: %w", err)
 	}
@@ -925,10 +953,251 @@ func runRecallMemories(tc openai.ChatCompletionMessageToolCall) (any, bool, erro
 		})
 	}
 
-	bus.EmitShell(fmt.Sprintf("found %d memories\n", len(results)))
+	emitShell(tc, fmt.Sprintf("found %d memories\n", len(results)))
 	return map[string]any{
 		"query":   args.Query,
 		"count":   len(results),
 		"results": results,
 	}, false, nil
 }
+
+func (b *Bot) runScanTodos(ctx context.Context, tc llm.ToolCall) (any, bool, error) {
+	var args struct {
+		Root string `json:"root"`
+	}
+	if err := json.Unmarshal([]byte(tc.Function.Arguments), &args); err != nil {
+		return "", false, fmt.Errorf("invalid tool arguments: %w", err)
+	}
+
+	root := strings.TrimSpace(args.Root)
+	if root != "" {
+		var err error
+		if root, err = b.resolvePath(tc, root, workspace.Read); err != nil {
+			return "", false, err
+		}
+	}
+	result, err := b.todos.ScanAndImport(root)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to scan todos: %w", err)
+	}
+
+	emitShell(tc, fmt.Sprintf("scanned %s: %d added, %d updated, %d closed, %d reopened\n", result.Root, result.Added, result.Updated, result.Closed, result.Reopened))
+	return result, true, nil
+}
+
+func (b *Bot) runListTodos(ctx context.Context, tc llm.ToolCall) (any, bool, error) {
+	var args struct {
+		Status string `json:"status"`
+		Query  string `json:"query"`
+		Limit  int    `json:"limit"`
+	}
+	if err := json.Unmarshal([]byte(tc.Function.Arguments), &args); err != nil {
+		return "", false, fmt.Errorf("invalid tool arguments: %w", err)
+	}
+
+	todos, err := b.todos.ListTodos(todo.Filter{Status: args.Status, Query: args.Query, Limit: args.Limit})
+	if err != nil {
+		return "", false, fmt.Errorf("failed to list todos: %w", err)
+	}
+	total, completed, pending, err := b.todos.GetTodosInfo()
+	if err != nil {
+		return "", false, fmt.Errorf("failed to count todos: %w", err)
+	}
+
+	emitShell(tc, fmt.Sprintf("found %d todos\n", len(todos)))
+	return map[string]any{
+		"count":     len(todos),
+		"todos":     todos,
+		"total":     total,
+		"completed": completed,
+		"pending":   pending,
+	}, false, nil
+}
+
+func (b *Bot) runAddTodo(ctx context.Context, tc llm.ToolCall) (any, bool, error) {
+	var args struct {
+		Title       string `json:"title"`
+		Description string `json:"description"`
+	}
+	if err := json.Unmarshal([]byte(tc.Function.Arguments), &args); err != nil {
+		return "", false, fmt.Errorf("invalid tool arguments: %w", err)
+	}
+
+	added, err := b.todos.AddTodo(args.Title, args.Description)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to add todo: %w", err)
+	}
+	added.Store = b.todos.Name()
+
+	emitShell(tc, fmt.Sprintf("added todo #%d: %s\n", added.ID, added.TitleText))
+	return map[string]any{
+		"success": true,
+		"todo":    added,
+	}, true, nil
+}
+
+func (b *Bot) runUpdateTodo(ctx context.Context, tc llm.ToolCall) (any, bool, error) {
+	var args struct {
+		ID          int     `json:"id"`
+		Title       *string `json:"title"`
+		Description *string `json:"description"`
+		Done        *bool   `json:"done"`
+	}
+	if err := json.Unmarshal([]byte(tc.Function.Arguments), &args); err != nil {
+		return "", false, fmt.Errorf("invalid tool arguments: %w", err)
+	}
+	if args.Title == nil && args.Description == nil && args.Done == nil {
+		return "", false, fmt.Errorf("nothing to update: pass title, description or done")
+	}
+
+	current, err := b.todos.GetTodoById(args.ID)
+	if err != nil {
+		return "", false, err
+	}
+	if args.Title != nil || args.Description != nil {
+		title, description := current.TitleText, current.DescriptionText
+		if args.Title != nil {
+			title = *args.Title
+		}
+		if args.Description != nil {
+			description = *args.Description
+		}
+		if _, err := b.todos.ModifyTodo(args.ID, title, description); err != nil {
+			return "", false, fmt.Errorf("failed to update todo: %w", err)
+		}
+	}
+	if args.Done != nil && *args.Done != current.Done {
+		if _, err := b.todos.ToggleDone(args.ID); err != nil {
+			return "", false, fmt.Errorf("failed to update todo: %w", err)
+		}
+	}
+
+	updated, err := b.todos.GetTodoById(args.ID)
+	if err != nil {
+		return "", false, err
+	}
+	updated.Store = b.todos.Name()
+	emitShell(tc, fmt.Sprintf("updated todo #%d\n", updated.ID))
+	return map[string]any{
+		"success": true,
+		"todo":    updated,
+	}, true, nil
+}
+
+func (b *Bot) runToggleTodo(ctx context.Context, tc llm.ToolCall) (any, bool, error) {
+	var args struct {
+		ID int `json:"id"`
+	}
+	if err := json.Unmarshal([]byte(tc.Function.Arguments), &args); err != nil {
+		return "", false, fmt.Errorf("invalid tool arguments: %w", err)
+	}
+
+	done, err := b.todos.ToggleDone(args.ID)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to toggle todo: %w", err)
+	}
+
+	emitShell(tc, fmt.Sprintf("todo #%d done: %t\n", args.ID, done))
+	return map[string]any{
+		"success": true,
+		"id":      args.ID,
+		"done":    done,
+	}, true, nil
+}
+
+func (b *Bot) runDeleteTodo(ctx context.Context, tc llm.ToolCall) (any, bool, error) {
+	var args struct {
+		ID int `json:"id"`
+	}
+	if err := json.Unmarshal([]byte(tc.Function.Arguments), &args); err != nil {
+		return "", false, fmt.Errorf("invalid tool arguments: %w", err)
+	}
+
+	deleted, err := b.todos.GetTodoById(args.ID)
+	if err != nil {
+		return "", false, err
+	}
+	deleted.Store = b.todos.Name()
+	if _, err := b.todos.DeleteTodo(args.ID); err != nil {
+		return "", false, fmt.Errorf("failed to delete todo: %w", err)
+	}
+
+	emitShell(tc, fmt.Sprintf("deleted todo #%d: %s\n", deleted.ID, deleted.TitleText))
+	return map[string]any{
+		"success": true,
+		"todo":    deleted,
+	}, true, nil
+}
+
+func (b *Bot) runSQLiteSchema(ctx context.Context, tc llm.ToolCall) (any, bool, error) {
+	var args struct {
+		Path string `json:"path"`
+	}
+	if err := json.Unmarshal([]byte(tc.Function.Arguments), &args); err != nil {
+		return "", false, fmt.Errorf("invalid tool arguments: %w", err)
+	}
+
+	dbPath, err := b.resolvePath(tc, args.Path, workspace.Read)
+	if err != nil {
+		return "", false, err
+	}
+	db, err := extdb.Open(dbPath)
+	if err != nil {
+		return "", false, err
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			slog.Error("error closing external database", "err", err)
+		}
+	}()
+
+	tables, err := db.Schema()
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read schema: %w", err)
+	}
+
+	emitShell(tc, fmt.Sprintf("schema of %s: %d tables\n", db.Path, len(tables)))
+	return map[string]any{
+		"path":   db.Path,
+		"schema": extdb.FormatSchema(tables),
+	}, false, nil
+}
+
+func (b *Bot) runQuerySQLite(ctx context.Context, tc llm.ToolCall) (any, bool, error) {
+	var args struct {
+		Path  string `json:"path"`
+		Query string `json:"query"`
+		Limit int    `json:"limit"`
+	}
+	if err := json.Unmarshal([]byte(tc.Function.Arguments), &args); err != nil {
+		return "", false, fmt.Errorf("invalid tool arguments: %w", err)
+	}
+
+	dbPath, err := b.resolvePath(tc, args.Path, workspace.Read)
+	if err != nil {
+		return "", false, err
+	}
+	db, err := extdb.Open(dbPath)
+	if err != nil {
+		return "", false, err
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			slog.Error("error closing external database", "err", err)
+		}
+	}()
+
+	result, err := db.Query(args.Query, args.Limit)
+	if err != nil {
+		return "", false, err
+	}
+
+	table := result.Table()
+	emitShell(tc, table)
+	return map[string]any{
+		"path":      db.Path,
+		"rowCount":  len(result.Rows),
+		"truncated": result.Truncated,
+		"table":     table,
+	}, false, nil
+}
@@ -0,0 +1,129 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
+
+	"github.com/biisal/godo/internal/bus"
+	"github.com/biisal/godo/internal/llm"
+	agentModel "github.com/biisal/godo/internal/tui/models/agent"
+)
+
+// readOnlyTools only read files, the web or databases, so several calls to
+// them can run at once. Every other tool runs on its own.
+var readOnlyTools = map[string]bool{
+	ReadSkillFunc:        true,
+	GlobSearchFunc:       true,
+	ReadFilesFunc:        true,
+	ProjectTreeFunc:      true,
+	DuckDuckGoSearchFunc: true,
+	ScrapePageFunc:       true,
+	RecallMemoriesFunc:   true,
+	ListTodosFunc:        true,
+	SQLiteSchemaFunc:     true,
+	QuerySQLiteFunc:      true,
+}
+
+// DefaultToolWorkers is how many read-only tool calls run at once when
+// ToolWorkers is not set.
+const DefaultToolWorkers = 4
+
+type toolResult struct {
+	msg     agentModel.Message
+	refresh bool
+}
+
+// runToolCalls runs the tool calls of one reply and appends their results
+// to History in the order of the calls. Consecutive read-only calls run
+// concurrently; any other call waits for the calls before it and runs alone.
+func (b *Bot) runToolCalls(ctx context.Context, calls []llm.ToolCall) bool {
+	refresh := false
+	for start := 0; start < len(calls); {
+		end := start + 1
+		if b.readOnly(calls[start].Function.Name) {
+			for end < len(calls) && b.readOnly(calls[end].Function.Name) {
+				end++
+			}
+		}
+		for _, r := range b.runToolBatch(ctx, calls[start:end]) {
+			refresh = refresh || r.refresh
+			b.appendMessage(r.msg)
+		}
+		start = end
+	}
+	return refresh
+}
+
+// runToolBatch runs calls on up to ToolWorkers goroutines and returns their
+// results in call order.
+func (b *Bot) runToolBatch(ctx context.Context, calls []llm.ToolCall) []toolResult {
+	results := make([]toolResult, len(calls))
+	workers := b.ToolWorkers
+	if workers <= 0 {
+		workers = DefaultToolWorkers
+	}
+	workers = min(workers, len(calls))
+	if workers == 1 {
+		for i, tc := range calls {
+			results[i] = b.runToolCall(ctx, tc)
+		}
+		return results
+	}
+
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				results[i] = b.runToolCall(ctx, calls[i])
+			}
+		}()
+	}
+	for i := range calls {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	return results
+}
+
+// runToolCall runs one call and returns its result message. Every call needs
+// a result for the history to stay valid, so calls skipped by a cancel get
+// one too.
+func (b *Bot) runToolCall(ctx context.Context, tc llm.ToolCall) toolResult {
+	msg := agentModel.Message{
+		Role:       agentModel.ToolRole,
+		ToolCallID: tc.ID,
+		Name:       tc.Function.Name,
+	}
+	if ctx.Err() != nil {
+		msg.Content = "Cancelled by the user before it ran."
+		return toolResult{msg: msg}
+	}
+
+	bus.EmitToolCall(tc.ID, tc.Function.Name)
+	if b.depth > 0 {
+		bus.EmitDelegate(b.delegateID, "\n▸ "+tc.Function.Name+"\n")
+	}
+	slog.Info("\n\nRunning tool----------------------------", "name", tc.Function.Name, "args", tc.Function.Arguments)
+	result, shouldRefresh, err := b.runFunction(ctx, tc.Function.Name, tc)
+	slog.Info("\n\nTool result----------------------------", "result", result, "shouldRefresh", shouldRefresh, "err", err)
+	if err != nil {
+		msg.Content = err.Error()
+	} else if text, ok := result.(string); ok {
+		msg.Content = text
+	} else {
+		data, _ := json.Marshal(result)
+		msg.Content = string(data)
+	}
+	return toolResult{msg: msg, refresh: shouldRefresh}
+}
+
+// emitShell sends output of the tool call tc to the shell panel.
+func emitShell(tc llm.ToolCall, text string) {
+	bus.EmitToolShell(tc.ID, tc.Function.Name, text)
+}
@@ -0,0 +1,122 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/biisal/godo/internal/llm"
+	"github.com/biisal/godo/internal/store"
+	agentModel "github.com/biisal/godo/internal/tui/models/agent"
+)
+
+// toolLog records the order tool calls start and finish in, and how many
+// ran at once.
+type toolLog struct {
+	mu      sync.Mutex
+	events  []string
+	started int
+	running int
+	peak    int
+}
+
+func (l *toolLog) add(event string, delta int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, event)
+	if delta > 0 {
+		l.started++
+	}
+	l.running += delta
+	l.peak = max(l.peak, l.running)
+}
+
+func (l *toolLog) startedSoFar() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.started
+}
+
+// registerTestTools adds a read-only tool that waits until the number of
+// calls in its "wait" argument have started, and a tool that writes.
+func registerTestTools(t *testing.T) *toolLog {
+	t.Helper()
+	log := &toolLog{}
+	run := func(_ *Bot, _ context.Context, tc llm.ToolCall) (any, bool, error) {
+		var args struct {
+			Name string `json:"name"`
+			Wait int    `json:"wait"`
+		}
+		_ = json.Unmarshal([]byte(tc.Function.Arguments), &args)
+		log.add("start "+args.Name, 1)
+		defer log.add("end "+args.Name, -1)
+		for deadline := time.Now().Add(time.Second); log.startedSoFar() < args.Wait && time.Now().Before(deadline); {
+			time.Sleep(time.Millisecond)
+		}
+		return "result " + args.Name, false, nil
+	}
+	tools["TestRead"], tools["TestWrite"] = run, run
+	readOnlyTools["TestRead"] = true
+	t.Cleanup(func() {
+		delete(tools, "TestRead")
+		delete(tools, "TestWrite")
+		delete(readOnlyTools, "TestRead")
+	})
+	return log
+}
+
+func testCall(tool, name string, wait int) llm.ToolCall {
+	return llm.ToolCall{
+		ID:       "call_" + name,
+		Function: llm.FunctionCall{Name: tool, Arguments: fmt.Sprintf(`{"name":%q,"wait":%d}`, name, wait)},
+	}
+}
+
+func TestRunToolCallsParallelReads(t *testing.T) {
+	drainBus(t)
+	log := registerTestTools(t)
+	b := newTestBot(store.NewFakeChatStore(), store.NewFakeMemoryStore())
+	calls := []llm.ToolCall{
+		testCall("TestRead", "r1", 3),
+		testCall("TestRead", "r2", 3),
+		testCall("TestRead", "r3", 3),
+		testCall("TestWrite", "w", 0),
+		testCall("TestRead", "r4", 0),
+	}
+
+	b.runToolCalls(context.Background(), calls)
+
+	if log.peak != 3 {
+		t.Errorf("Expected the three reads to run at once, peak was %d", log.peak)
+	}
+	tail := log.events[6:]
+	if want := []string{"start w", "end w", "start r4", "end r4"}; fmt.Sprint(tail) != fmt.Sprint(want) {
+		t.Errorf("Expected the write alone after the reads and the last read after it, got %v", log.events)
+	}
+	if len(b.History) != len(calls) {
+		t.Fatalf("Expected one result per call, got %+v", b.History)
+	}
+	for i, msg := range b.History {
+		if msg.Role != agentModel.ToolRole || msg.ToolCallID != calls[i].ID || msg.Content != "result "+calls[i].ID[len("call_"):] {
+			t.Errorf("Expected result %d for %s, got %+v", i, calls[i].ID, msg)
+		}
+	}
+}
+
+func TestRunToolCallsWorkerLimit(t *testing.T) {
+	drainBus(t)
+	log := registerTestTools(t)
+	b := newTestBot(store.NewFakeChatStore(), store.NewFakeMemoryStore())
+	b.ToolWorkers = 1
+
+	b.runToolCalls(context.Background(), []llm.ToolCall{
+		testCall("TestRead", "r1", 0),
+		testCall("TestRead", "r2", 0),
+	})
+	if log.peak != 1 || fmt.Sprint(log.events) != "[start r1 end r1 start r2 end r2]" {
+		t.Errorf("Expected the reads one at a time with one worker, got %v", log.events)
+	}
+}
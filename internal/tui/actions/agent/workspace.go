@@ -0,0 +1,97 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/biisal/godo/internal/bus"
+	"github.com/biisal/godo/internal/llm"
+	"github.com/biisal/godo/internal/workspace"
+)
+
+// resolvePath returns the real path of pathArg for tc to read or write.
+// Paths outside the workspace are only used when the user approves them;
+// answering "always" allows the path for the rest of the session.
+func (b *Bot) resolvePath(tc llm.ToolCall, pathArg string, access workspace.Access) (string, error) {
+	if b.Workspace == nil {
+		return cwdPath(pathArg)
+	}
+	path, err := b.Workspace.Resolve(pathArg, access)
+	if !errors.Is(err, workspace.ErrOutside) {
+		return path, err
+	}
+
+	b.approveMu.Lock()
+	defer b.approveMu.Unlock()
+	// An answer to a call running alongside this one may have allowed it.
+	if path, err := b.Workspace.Resolve(pathArg, access); !errors.Is(err, workspace.ErrOutside) {
+		return path, err
+	}
+	question := fmt.Sprintf("Allow %s to %s outside the workspace?\n%s", tc.Function.Name, access, path)
+	switch b.approve(question) {
+	case bus.AllowOnce:
+		return path, nil
+	case bus.AllowAlways:
+		b.Workspace.Allow(path, access)
+		return path, nil
+	}
+	return "", fmt.Errorf("%w by the user: %v; do not retry this call unless they ask", ErrPermissionDenied, err)
+}
+
+// cwdPath makes pathArg absolute against the working directory, for bots
+// without a workspace.
+func cwdPath(pathArg string) (string, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", fmt.Errorf("failed to get current directory: %w", err)
+	}
+	targetPath := pathArg
+	if !filepath.IsAbs(targetPath) {
+		targetPath = filepath.Join(cwd, targetPath)
+	}
+	return filepath.Clean(targetPath), nil
+}
+
+// workDir is the directory tools that run commands, such as git apply,
+// start in.
+func (b *Bot) workDir() (string, error) {
+	if b.Workspace != nil {
+		return b.Workspace.Root(), nil
+	}
+	return os.Getwd()
+}
+
+// patchPaths returns the files a unified diff changes, without the a/ and
+// b/ prefixes git adds.
+func patchPaths(patch string) []string {
+	var paths []string
+	seen := map[string]bool{}
+	for line := range strings.Lines(patch) {
+		var name string
+		switch {
+		case strings.HasPrefix(line, "--- "), strings.HasPrefix(line, "+++ "):
+			name = line[4:]
+		case strings.HasPrefix(line, "rename from "), strings.HasPrefix(line, "rename to "), strings.HasPrefix(line, "copy to "):
+			_, name, _ = strings.Cut(strings.TrimPrefix(strings.TrimPrefix(line, "rename "), "copy "), " ")
+		default:
+			continue
+		}
+		// Drop a trailing timestamp, as diff -u writes them after a tab.
+		name, _, _ = strings.Cut(strings.TrimRight(name, "\r\n"), "\t")
+		name = strings.TrimSpace(name)
+		if name == "" || name == "/dev/null" {
+			continue
+		}
+		if strings.HasPrefix(line, "--- a/") || strings.HasPrefix(line, "+++ b/") {
+			name = name[2:]
+		}
+		if !seen[name] {
+			seen[name] = true
+			paths = append(paths, name)
+		}
+	}
+	return paths
+}
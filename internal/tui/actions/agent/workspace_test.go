@@ -0,0 +1,242 @@
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/biisal/godo/internal/bus"
+	"github.com/biisal/godo/internal/llm"
+	"github.com/biisal/godo/internal/sandbox"
+	"github.com/biisal/godo/internal/store"
+	"github.com/biisal/godo/internal/workspace"
+)
+
+func TestMain(m *testing.M) {
+	// Sandboxed shell commands re-run the test binary as the helper.
+	sandbox.RunHelper()
+	m.Run()
+}
+
+// newWorkspaceTestBot returns a bot confined to a new workspace next to an
+// "outside" directory, answering every approval prompt with answer.
+func newWorkspaceTestBot(t *testing.T, answer bus.Approval) (b *Bot, ws, outside string, questions *[]string) {
+	t.Helper()
+	base, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws, outside = filepath.Join(base, "ws"), filepath.Join(base, "outside")
+	for _, dir := range []string{ws, outside} {
+		if err := os.Mkdir(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(outside, "secret.txt"), []byte("secret"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	b = newTestBot(store.NewFakeChatStore(), store.NewFakeMemoryStore())
+	if b.Workspace, err = workspace.New(ws, nil); err != nil {
+		t.Fatalf("workspace.New failed: %v", err)
+	}
+	questions = &[]string{}
+	b.approve = func(question string) bus.Approval {
+		*questions = append(*questions, question)
+		return answer
+	}
+	return b, ws, outside, questions
+}
+
+func TestWorkspaceConfinesWrites(t *testing.T) {
+	drainBus(t)
+	b, ws, outside, questions := newWorkspaceTestBot(t, bus.Deny)
+
+	result, _, err := callTool(t, b, WriteFileFunc, map[string]any{"path": "notes.txt", "content": "hi"})
+	if err != nil || result["path"] != filepath.Join(ws, "notes.txt") {
+		t.Fatalf("Expected a relative path to be written in the workspace, got %v, %v", result, err)
+	}
+
+	if err := os.Symlink(outside, filepath.Join(ws, "out")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+	for _, path := range []string{
+		"../outside/new.txt",
+		filepath.Join(outside, "new.txt"),
+		"out/new.txt",
+		"out/../../outside/new.txt",
+	} {
+		_, _, err := callTool(t, b, WriteFileFunc, map[string]any{"path": path, "content": "x"})
+		if !errors.Is(err, ErrPermissionDenied) || !strings.Contains(err.Error(), "outside the workspace") {
+			t.Errorf("WriteFile(%q) = %v, want a denial", path, err)
+		}
+	}
+	for _, tool := range []string{EditFileFunc, InsertAtLineFunc} {
+		_, _, err := callTool(t, b, tool, map[string]any{
+			"path": "out/secret.txt", "oldString": "secret", "newString": "gone", "lineNumber": 1, "content": "x",
+		})
+		if !errors.Is(err, ErrPermissionDenied) {
+			t.Errorf("%s through a link = %v, want a denial", tool, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(outside, "new.txt")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected nothing written outside the workspace, got %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(outside, "secret.txt")); string(data) != "secret" {
+		t.Errorf("Expected the outside file untouched, got %q", data)
+	}
+	want := "Allow WriteFile to write outside the workspace?\n" + filepath.Join(outside, "new.txt")
+	if len(*questions) != 6 || (*questions)[0] != want {
+		t.Errorf("Expected the user asked about each call with the real path, got %q", *questions)
+	}
+}
+
+func TestWorkspaceConfinesReads(t *testing.T) {
+	drainBus(t)
+	b, ws, outside, questions := newWorkspaceTestBot(t, bus.Deny)
+	if err := os.WriteFile(filepath.Join(ws, "in.txt"), []byte("inside"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "secret.txt"), filepath.Join(ws, "link.txt")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	result, _, err := callTool(t, b, ReadFilesFunc, map[string]any{"paths": []string{"in.txt", "link.txt", "../outside/secret.txt"}})
+	if err != nil {
+		t.Fatalf("ReadFiles failed: %v", err)
+	}
+	results := result["results"].([]any)
+	if got := results[0].(map[string]any)["content"]; got != "inside" {
+		t.Errorf("Expected the workspace file read, got %v", results[0])
+	}
+	for _, r := range results[1:] {
+		entry := r.(map[string]any)
+		if entry["content"] != nil || !strings.Contains(entry["error"].(string), "outside the workspace") {
+			t.Errorf("Expected the outside file refused, got %v", entry)
+		}
+	}
+	if len(*questions) != 2 {
+		t.Errorf("Expected a prompt for each outside file, got %q", *questions)
+	}
+
+	for _, tool := range []string{GlobSearchFunc, ProjectTreeFunc} {
+		if _, _, err := callTool(t, b, tool, map[string]any{"root": "..", "pattern": "*"}); !errors.Is(err, ErrPermissionDenied) {
+			t.Errorf("%s of the parent = %v, want a denial", tool, err)
+		}
+	}
+	if _, _, err := callTool(t, b, SQLiteSchemaFunc, map[string]any{"path": filepath.Join(outside, "app.db")}); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("SQLiteSchema outside = %v, want a denial", err)
+	}
+}
+
+func TestWorkspaceApproval(t *testing.T) {
+	drainBus(t)
+	b, _, outside, questions := newWorkspaceTestBot(t, bus.AllowOnce)
+	secret := filepath.Join(outside, "secret.txt")
+
+	for range 2 {
+		result, _, err := callTool(t, b, ReadFilesFunc, map[string]any{"paths": []string{secret}})
+		if err != nil || result["results"].([]any)[0].(map[string]any)["content"] != "secret" {
+			t.Fatalf("Expected an approved read, got %v, %v", result, err)
+		}
+	}
+	if len(*questions) != 2 {
+		t.Errorf("Expected allow once to ask every time, got %q", *questions)
+	}
+
+	b.approve = func(question string) bus.Approval {
+		*questions = append(*questions, question)
+		return bus.AllowAlways
+	}
+	for range 2 {
+		if _, _, err := callTool(t, b, ReadFilesFunc, map[string]any{"paths": []string{secret}}); err != nil {
+			t.Fatalf("ReadFiles failed: %v", err)
+		}
+	}
+	if len(*questions) != 3 {
+		t.Errorf("Expected allow always to ask once more, got %q", *questions)
+	}
+	if _, _, err := callTool(t, b, WriteFileFunc, map[string]any{"path": secret, "content": "x"}); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if len(*questions) != 4 {
+		t.Errorf("Expected always allowing a read not to allow writing, got %q", *questions)
+	}
+}
+
+func TestPatchPaths(t *testing.T) {
+	patch := `diff --git a/main.go b/main.go
+--- a/main.go
++++ b/main.go
+@@ -1 +1 @@
+-old
++new
+--- /dev/null
++++ b/docs/new file.md	2024-01-01 00:00:00
+@@ -0,0 +1 @@
++hi
+diff --git a/old.go b/../outside/moved.go
+rename from old.go
+rename to ../outside/moved.go
+--- ../escape.txt
++++ ../escape.txt
+`
+	want := []string{"main.go", "docs/new file.md", "old.go", "../outside/moved.go", "../escape.txt"}
+	if got := patchPaths(patch); !reflect.DeepEqual(got, want) {
+		t.Errorf("patchPaths = %q, want %q", got, want)
+	}
+}
+
+func TestPatchFileConfined(t *testing.T) {
+	drainBus(t)
+	b, ws, _, _ := newWorkspaceTestBot(t, bus.Deny)
+	if err := os.WriteFile(filepath.Join(ws, "a.txt"), []byte("a\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	patch := "--- a/a.txt\n+++ b/a.txt\n@@ -1 +1 @@\n-a\n+b\n--- /dev/null\n+++ ../outside/evil.txt\n@@ -0,0 +1 @@\n+x\n"
+	_, _, err := callTool(t, b, PatchFileFunc, map[string]any{"path": "a.txt", "patch": patch})
+	if !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("Expected a patch reaching outside to be denied, got %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(ws, "a.txt")); string(data) != "a\n" {
+		t.Errorf("Expected no part of the denied patch applied, got %q", data)
+	}
+}
+
+func TestShellSandbox(t *testing.T) {
+	drainBus(t)
+	b, ws, _, _ := newWorkspaceTestBot(t, bus.Deny)
+	b.Sandbox = &sandbox.Sandbox{Workspace: ws, Kind: sandbox.Namespaces}
+	if out, err := b.Sandbox.Command(t.Context(), "true").CombinedOutput(); err != nil {
+		t.Skipf("namespaces are not available: %v: %s", err, out)
+	}
+	t.Setenv("OPENAI_API_KEY", "sk-secret")
+
+	result, _, err := b.runFunction(t.Context(), RunShellCommandFunc, testShellCall(`echo "key=$OPENAI_API_KEY" > inside.txt && cat inside.txt`))
+	if err != nil || result != "key=\n" {
+		t.Errorf("Expected the command to write in the workspace without the key, got %q, %v", result, err)
+	}
+
+	// The sandbox has its own /tmp, so try somewhere else outside.
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	escaped := filepath.Join(cwd, "sandbox-escape")
+	t.Cleanup(func() { _ = os.Remove(escaped) })
+	result, _, err = b.runFunction(t.Context(), RunShellCommandFunc, testShellCall("touch "+escaped))
+	if err != nil || !strings.Contains(result.(string), "[sandbox] writing outside the workspace is blocked by the sandbox") {
+		t.Errorf("Expected the blocked write reported, got %q, %v", result, err)
+	}
+	if _, err := os.Stat(escaped); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected nothing written outside the workspace, got %v", err)
+	}
+}
+
+func testShellCall(command string) llm.ToolCall {
+	raw, _ := json.Marshal(map[string]string{"command": command})
+	return llm.ToolCall{Function: llm.FunctionCall{Name: RunShellCommandFunc, Arguments: string(raw)}}
+}
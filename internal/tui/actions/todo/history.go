@@ -0,0 +1,30 @@
+package todo
+
+import (
+	"github.com/biisal/godo/internal/tui/models/todo"
+)
+
+const (
+	EventClosed     = "closed"
+	EventReferenced = "referenced"
+	// EventRemoved and EventReopened record a scan closing a todo whose
+	// comment is gone and reopening it when the comment comes back.
+	EventRemoved  = "removed"
+	EventReopened = "reopened"
+)
+
+// SetDone marks a todo as done or not done. It reports whether the state
+// actually changed.
+func (s *Service) SetDone(id int, done bool) (bool, error) {
+	return s.store.SetDone(id, done)
+}
+
+// AddHistory records an event for a todo.
+func (s *Service) AddHistory(id int, event, detail string) error {
+	return s.store.AddHistory(id, event, detail)
+}
+
+// GetHistory returns the recorded events of a todo, oldest first.
+func (s *Service) GetHistory(id int) ([]todo.HistoryEntry, error) {
+	return s.store.History(id)
+}
@@ -0,0 +1,154 @@
+package todo
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/biisal/godo/internal/config"
+	"github.com/biisal/godo/internal/scanner"
+	"github.com/biisal/godo/internal/store"
+)
+
+const maxImportedTitle = 80
+
+// ImportResult summarizes what a code scan changed in the todo list.
+type ImportResult struct {
+	Root     string `json:"root"`
+	Found    int    `json:"found"`
+	Added    int    `json:"added"`
+	Updated  int    `json:"updated"`
+	Closed   int    `json:"closed"`
+	Reopened int    `json:"reopened"`
+}
+
+// ScanAndImport scans root for TODO comments and imports them. An empty root
+// means the project directory, or the working directory outside a project.
+func (s *Service) ScanAndImport(root string) (ImportResult, error) {
+	if root == "" {
+		root = config.Cfg.PROJECT_DIR
+	}
+	if root == "" {
+		root = "."
+	}
+	rootAbs, err := filepath.Abs(root)
+	if err != nil {
+		return ImportResult{}, fmt.Errorf("failed to resolve root %q: %w", root, err)
+	}
+	markers, err := scanner.Scan(rootAbs)
+	if err != nil {
+		return ImportResult{}, err
+	}
+	return s.ImportMarkers(rootAbs, markers)
+}
+
+// ImportMarkers syncs TODO comments found under root into the store.
+// New comments become todos, known ones get their file:line refreshed and
+// todos whose comment has disappeared are marked done, and reopened if it
+// comes back. Source rows of deleted todos are kept so a dismissed comment
+// is not imported again.
+func (s *Service) ImportMarkers(root string, markers []scanner.Marker) (ImportResult, error) {
+	result := ImportResult{Root: root, Found: len(markers)}
+	err := s.store.Tx(func(ts store.TodoStore) error {
+		sources, err := ts.Sources(root)
+		if err != nil {
+			return err
+		}
+		known := make(map[string]int, len(sources))
+		for _, src := range sources {
+			known[src.Fingerprint] = src.TodoID
+		}
+
+		for _, m := range markers {
+			title, description := importedTodoText(m)
+			src := store.TodoSource{Root: root, Fingerprint: m.Fingerprint, Path: m.Path, Line: m.Line}
+			todoID, ok := known[m.Fingerprint]
+			if !ok {
+				if src.TodoID, err = ts.Add(title, description); err != nil {
+					return err
+				}
+				if err := ts.AddSource(src); err != nil {
+					return err
+				}
+				result.Added++
+				continue
+			}
+			delete(known, m.Fingerprint)
+
+			src.TodoID = todoID
+			moved, err := ts.UpdateSource(src)
+			if err != nil {
+				return err
+			}
+			current, err := ts.Get(todoID)
+			if errors.Is(err, store.ErrNotFound) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if current.Done {
+				removed, err := closedByScan(ts, todoID)
+				if err != nil {
+					return err
+				}
+				if removed {
+					if _, err := ts.SetDone(todoID, false); err != nil {
+						return err
+					}
+					if err := ts.AddHistory(todoID, EventReopened, "comment is back at "+m.Location()); err != nil {
+						return err
+					}
+					result.Reopened++
+				}
+			}
+			if !moved {
+				continue
+			}
+			if err := ts.Update(todoID, current.TitleText, description); err != nil {
+				return err
+			}
+			result.Updated++
+		}
+
+		for _, todoID := range known {
+			closed, err := ts.SetDone(todoID, true)
+			if err != nil {
+				return err
+			}
+			if closed {
+				if err := ts.AddHistory(todoID, EventRemoved, "comment removed from the code"); err != nil {
+					return err
+				}
+				result.Closed++
+			}
+		}
+		return nil
+	})
+	return result, err
+}
+
+// closedByScan reports whether the last thing that happened to todo id was
+// a scan closing it, rather than the user or a commit.
+func closedByScan(ts store.TodoStore, id int) (bool, error) {
+	history, err := ts.History(id)
+	if err != nil || len(history) == 0 {
+		return false, err
+	}
+	return history[len(history)-1].Event == EventRemoved, nil
+}
+
+func importedTodoText(m scanner.Marker) (string, string) {
+	title := m.Kind + ": " + m.Text
+	if m.Text == "" {
+		title = m.Kind + " in " + m.Location()
+	}
+	if runes := []rune(title); len(runes) > maxImportedTitle {
+		title = string(runes[:maxImportedTitle-3]) + "..."
+	}
+	description := m.Location()
+	if m.Text != "" {
+		description = fmt.Sprintf("%s\n%s", m.Location(), m.Text)
+	}
+	return title, description
+}
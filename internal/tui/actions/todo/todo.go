@@ -1,6 +1,7 @@
 package todo
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
@@ -8,105 +9,146 @@ import (
 	"strconv"
 	"strings"
 
-	"github.com/biisal/godo/internal/config"
+	"github.com/biisal/godo/internal/store"
 	"github.com/biisal/godo/internal/tui/models/todo"
 )
 
 var (
-	ErrorEmpty     = errors.New("title or description can't be empty")
-	ErrorInvalidId = errors.New("invalid ID")
+	ErrorEmpty         = errors.New("title or description can't be empty")
+	ErrorInvalidId     = errors.New("invalid ID")
+	ErrorInvalidStatus = errors.New(`status must be "open", "done" or "all"`)
 )
 
-func GetTodos() ([]todo.Todo, error) {
-	sqlStmt := `
-	SELECT Id , Title, Description, Done
-	FROM todos
-	ORDER BY Id DESC
-	`
-	rows, err := config.Cfg.DB.Query(sqlStmt)
+// Filter selects todos for ListTodos. The zero value matches every todo.
+type Filter struct {
+	// Status is "open", "done", or "all" or empty for both.
+	Status string
+	// Query matches the title or description, ignoring case.
+	Query string
+	// Limit caps the number of todos returned when positive.
+	Limit int
+}
+
+// Service validates todo operations and applies them to a TodoStore.
+type Service struct {
+	store store.TodoStore
+	name  string
+}
+
+// NewService returns a Service over s. name identifies the store (global or
+// project) when todos from several stores are shown together.
+func NewService(s store.TodoStore, name string) *Service {
+	return &Service{store: s, name: name}
+}
+
+// Name returns the name of the store behind the service.
+func (s *Service) Name() string {
+	return s.name
+}
+
+func (s *Service) GetTodos() ([]todo.Todo, error) {
+	return s.store.List()
+}
+
+// AllTodos returns the todos of every given service, each tagged with the
+// store it belongs to. Nil services are skipped.
+func AllTodos(services ...*Service) ([]todo.Todo, error) {
+	all := []todo.Todo{}
+	for _, s := range services {
+		if s == nil {
+			continue
+		}
+		todos, err := s.GetTodos()
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range todos {
+			t.Store = s.name
+			all = append(all, t)
+		}
+	}
+	return all, nil
+}
+
+// ListTodos returns the todos matching f, newest first, tagged with the
+// store name.
+func (s *Service) ListTodos(f Filter) ([]todo.Todo, error) {
+	status := strings.ToLower(strings.TrimSpace(f.Status))
+	if status != "" && status != "all" && status != "open" && status != "done" {
+		return nil, ErrorInvalidStatus
+	}
+	todos, err := s.GetTodos()
 	if err != nil {
 		return nil, err
 	}
-	defer func() {
-		if err := rows.Close(); err != nil {
-			slog.Error("error closing rows", "err", err)
+	query := strings.ToLower(strings.TrimSpace(f.Query))
+	matched := []todo.Todo{}
+	for _, t := range todos {
+		if status == "open" && t.Done || status == "done" && !t.Done {
+			continue
 		}
-	}()
-	todos := []todo.Todo{}
-	for rows.Next() {
-		var t todo.Todo
-		if err := rows.Scan(&t.ID, &t.TitleText, &t.DescriptionText, &t.Done); err != nil {
-			return nil, err
+		if query != "" && !strings.Contains(strings.ToLower(t.TitleText), query) &&
+			!strings.Contains(strings.ToLower(t.DescriptionText), query) {
+			continue
+		}
+		t.Store = s.name
+		matched = append(matched, t)
+		if f.Limit > 0 && len(matched) == f.Limit {
+			break
 		}
-		todos = append(todos, t)
 	}
-	return todos, nil
+	return matched, nil
 }
 
-func GetTodosCount() string {
-	todos, err := GetTodos()
+func (s *Service) GetTodosCount() string {
+	todos, err := s.GetTodos()
 	if err != nil {
 		return "Not Found"
 	}
 	return "Total Todos: " + strconv.Itoa(len(todos))
 }
 
-func AddTodo(title, description string) ([]todo.Todo, error) {
+// AddTodo adds a todo and returns it as stored.
+func (s *Service) AddTodo(title, description string) (*todo.Todo, error) {
 	title, description = strings.TrimSpace(title), strings.TrimSpace(description)
 	if title == "" || description == "" {
 		return nil, ErrorEmpty
 	}
-	sqlStmt := `
-	INSERT INTO todos (Title, Description, Done)
-	VALUES (?, ?, ?)`
-	if _, err := config.Cfg.DB.Exec(sqlStmt, title, description, false); err != nil {
+	id, err := s.store.Add(title, description)
+	if err != nil {
 		return nil, err
 	}
-	return GetTodos()
+	return s.store.Get(id)
 }
 
-func DeleteTodo(id int) ([]todo.Todo, error) {
-	sqlStmt := `
-	DELETE FROM todos WHERE Id = ?`
-	if _, err := config.Cfg.DB.Exec(sqlStmt, id); err != nil {
+func (s *Service) DeleteTodo(id int) ([]todo.Todo, error) {
+	if err := s.store.Delete(id); err != nil {
 		return nil, err
 	}
-	return GetTodos()
+	return s.GetTodos()
 }
 
-func ModifyTodo(id int, title, description string) ([]todo.Todo, error) {
+func (s *Service) ModifyTodo(id int, title, description string) ([]todo.Todo, error) {
 	title, description = strings.TrimSpace(title), strings.TrimSpace(description)
 	if title == "" || description == "" {
 		return nil, ErrorEmpty
 	}
-	sqlStmt := `
-	UPDATE todos SET Title = ?, Description = ? WHERE Id = ?`
-	if _, err := config.Cfg.DB.Exec(sqlStmt, title, description, id); err != nil {
+	if err := s.store.Update(id, title, description); err != nil {
 		return nil, err
 	}
-	todos, err := GetTodos()
+	todos, err := s.GetTodos()
 	if err != nil {
 		return nil, err
 	}
 	return todos, nil
 }
 
-func ToggleDone(id int, doneStatus ...bool) (bool, error) {
-	sqlStmt := `
-	UPDATE todos SET Done = NOT Done WHERE Id = ?`
-	if _, err := config.Cfg.DB.Exec(sqlStmt, id); err != nil {
-		return false, err
-	}
-	var isDone bool
-	err := config.Cfg.DB.QueryRow(`SELECT Done FROM todos WHERE Id = ?`, id).Scan(&isDone)
-	if err != nil {
-		return false, err
-	}
-	return isDone, nil
+func (s *Service) ToggleDone(id int) (bool, error) {
+	return s.store.ToggleDone(id)
 }
 
-func GetTodosInfo() (int, int, int, error) {
-	todos, err := GetTodos()
+func (s *Service) GetTodosInfo() (int, int, int, error) {
+	todos, err := s.GetTodos()
 	if err != nil {
 		return 0, 0, 0, err
 	}
@@ -119,30 +161,50 @@ func GetTodosInfo() (int, int, int, error) {
 	return total, completed, total - completed, nil
 }
 
-func GetTodoById(id int) (*todo.Todo, error) {
-	sqlStmt := `
-	SELECT Id , Title, Description, Done
-	FROM todos
-	WHERE Id = ?
-	`
-	row := config.Cfg.DB.QueryRow(sqlStmt, id)
-	t := &todo.Todo{}
-	if err := row.Scan(&t.ID, &t.TitleText, &t.DescriptionText, &t.Done); err != nil {
+func (s *Service) GetTodoById(id int) (*todo.Todo, error) {
+	t, err := s.store.Get(id)
+	if err != nil {
 		return nil, err
 	}
 	return t, nil
 }
 
-func PerformSqlQuery(sqlStmt string) (string, error) {
-	rows, err := config.Cfg.DB.Query(sqlStmt)
+// PerformSqlQuery runs sqlStmt and returns the rows it produced as JSON.
+// With readOnly the statement runs on a connection in query_only mode, so
+// SQLite itself refuses any write the caller failed to spot.
+func PerformSqlQuery(db *sql.DB, sqlStmt string, readOnly bool) (string, error) {
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
 	if err != nil {
 		return "", err
 	}
-	if rows == nil {
-		return "[]", nil
+	defer func() {
+		if err := conn.Close(); err != nil {
+			slog.Error("error closing connection", "err", err)
+		}
+	}()
+
+	if readOnly {
+		if _, err := conn.ExecContext(ctx, "PRAGMA query_only = ON"); err != nil {
+			return "", err
+		}
+		defer func() {
+			if _, err := conn.ExecContext(ctx, "PRAGMA query_only = OFF"); err != nil {
+				slog.Error("error leaving query_only mode", "err", err)
+			}
+		}()
 	}
+
+	rows, err := conn.QueryContext(ctx, sqlStmt)
+	if err != nil {
+		return "", err
+	}
+	return rowsToJSON(sqlStmt, rows)
+}
+
+func rowsToJSON(sqlStmt string, rows *sql.Rows) (string, error) {
 	defer func() {
-		if err = rows.Close(); err != nil {
+		if err := rows.Close(); err != nil {
 			slog.Error("error closing rows", "err", err)
 		}
 	}()
@@ -0,0 +1,245 @@
+package todo
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/biisal/godo/internal/migrate"
+	"github.com/biisal/godo/internal/scanner"
+	"github.com/biisal/godo/internal/store"
+)
+
+func TestAddTodoValidation(t *testing.T) {
+	s := NewService(store.NewFakeTodoStore(), "global")
+
+	if _, err := s.AddTodo("  ", "description"); !errors.Is(err, ErrorEmpty) {
+		t.Errorf("Expected ErrorEmpty for a blank title, got %v", err)
+	}
+	if _, err := s.AddTodo("title", ""); !errors.Is(err, ErrorEmpty) {
+		t.Errorf("Expected ErrorEmpty for an empty description, got %v", err)
+	}
+
+	added, err := s.AddTodo("  Buy milk ", " two litres ")
+	if err != nil {
+		t.Fatalf("AddTodo failed: %v", err)
+	}
+	if added.ID == 0 || added.TitleText != "Buy milk" || added.DescriptionText != "two litres" {
+		t.Errorf("Expected the trimmed todo returned, got %+v", added)
+	}
+	if todos, _ := s.GetTodos(); len(todos) != 1 || todos[0] != *added {
+		t.Errorf("Expected the returned todo stored, got %+v", todos)
+	}
+	if _, err := s.ModifyTodo(added.ID, "", "x"); !errors.Is(err, ErrorEmpty) {
+		t.Errorf("Expected ErrorEmpty when modifying with a blank title, got %v", err)
+	}
+}
+
+// GetTodoById leaves Store unset, as GetTodos does, so the normal list
+// shows no store label; the All Stores list tags its items itself.
+func TestGetTodoByIdLeavesStoreUnset(t *testing.T) {
+	s := NewService(store.NewFakeTodoStore(), "project")
+	added, _ := s.AddTodo("Title", "Description")
+
+	got, err := s.GetTodoById(added.ID)
+	if err != nil {
+		t.Fatalf("GetTodoById failed: %v", err)
+	}
+	if got.Store != "" || got.TitleText != "Title" {
+		t.Errorf("Expected the untagged todo, got %+v", got)
+	}
+	if _, err := s.GetTodoById(99); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestAllTodos(t *testing.T) {
+	project := NewService(store.NewFakeTodoStore(), "project")
+	global := NewService(store.NewFakeTodoStore(), "global")
+	_, _ = project.AddTodo("Project", "todo")
+	_, _ = global.AddTodo("Global", "todo")
+
+	all, err := AllTodos(project, nil, global)
+	if err != nil {
+		t.Fatalf("AllTodos failed: %v", err)
+	}
+	if len(all) != 2 || all[0].Store != "project" || all[1].Store != "global" {
+		t.Errorf("Expected todos tagged by store, got %+v", all)
+	}
+}
+
+func TestImportMarkers(t *testing.T) {
+	s := NewService(store.NewFakeTodoStore(), "global")
+	markers := []scanner.Marker{
+		{Path: "main.go", Line: 3, Kind: "TODO", Text: "handle errors", Fingerprint: "a"},
+		{Path: "util.go", Line: 10, Kind: "FIXME", Text: "", Fingerprint: "b"},
+	}
+
+	result, err := s.ImportMarkers("/repo", markers)
+	if err != nil {
+		t.Fatalf("ImportMarkers failed: %v", err)
+	}
+	if result.Added != 2 || result.Found != 2 {
+		t.Errorf("Expected 2 added, got %+v", result)
+	}
+	// Todos are listed newest first.
+	todos, _ := s.GetTodos()
+	if todos[1].TitleText != "TODO: handle errors" || todos[0].TitleText != "FIXME in util.go:10" {
+		t.Errorf("Unexpected titles: %q, %q", todos[1].TitleText, todos[0].TitleText)
+	}
+
+	// The first comment moved, the second one was removed.
+	markers = []scanner.Marker{
+		{Path: "main.go", Line: 5, Kind: "TODO", Text: "handle errors", Fingerprint: "a"},
+	}
+	result, err = s.ImportMarkers("/repo", markers)
+	if err != nil {
+		t.Fatalf("ImportMarkers failed: %v", err)
+	}
+	if result.Added != 0 || result.Updated != 1 || result.Closed != 1 {
+		t.Errorf("Expected 1 updated and 1 closed, got %+v", result)
+	}
+	todos, _ = s.GetTodos()
+	if !strings.HasPrefix(todos[1].DescriptionText, "main.go:5") {
+		t.Errorf("Expected the location to be refreshed, got %q", todos[1].DescriptionText)
+	}
+	if !todos[0].Done {
+		t.Error("Expected the todo of the removed comment to be done")
+	}
+}
+
+func TestImportMarkersReopensReturningComments(t *testing.T) {
+	s := NewService(store.NewFakeTodoStore(), "global")
+	removed := scanner.Marker{Path: "main.go", Line: 1, Kind: "TODO", Text: "retry", Fingerprint: "a"}
+	finished := scanner.Marker{Path: "main.go", Line: 9, Kind: "TODO", Text: "log", Fingerprint: "b"}
+	if _, err := s.ImportMarkers("/repo", []scanner.Marker{removed, finished}); err != nil {
+		t.Fatalf("ImportMarkers failed: %v", err)
+	}
+	todos, _ := s.GetTodos()
+	retry, log := todos[1], todos[0]
+	// The user closes one todo themselves; the other's comment goes away.
+	if _, err := s.SetDone(log.ID, true); err != nil {
+		t.Fatalf("SetDone failed: %v", err)
+	}
+	if result, err := s.ImportMarkers("/repo", []scanner.Marker{finished}); err != nil || result.Closed != 1 {
+		t.Fatalf("Expected the removed comment's todo closed, got %+v, %v", result, err)
+	}
+
+	result, err := s.ImportMarkers("/repo", []scanner.Marker{removed, finished})
+	if err != nil {
+		t.Fatalf("ImportMarkers failed: %v", err)
+	}
+	if result.Reopened != 1 || result.Added != 0 {
+		t.Errorf("Expected the returning comment's todo reopened, got %+v", result)
+	}
+	if got, _ := s.GetTodoById(retry.ID); got.Done {
+		t.Error("Expected the todo of the re-added comment open again")
+	}
+	if got, _ := s.GetTodoById(log.ID); !got.Done {
+		t.Error("Expected a todo the user closed to stay done")
+	}
+	history, _ := s.GetHistory(retry.ID)
+	if len(history) != 2 || history[0].Event != EventRemoved || history[1].Event != EventReopened {
+		t.Errorf("Expected the removal and reopening recorded, got %+v", history)
+	}
+
+	// Scanning again changes nothing.
+	if result, err := s.ImportMarkers("/repo", []scanner.Marker{removed, finished}); err != nil || result.Reopened != 0 || result.Closed != 0 {
+		t.Errorf("Expected a repeat scan to change nothing, got %+v, %v", result, err)
+	}
+}
+
+func TestImportMarkersSkipsDeletedTodos(t *testing.T) {
+	s := NewService(store.NewFakeTodoStore(), "global")
+	markers := []scanner.Marker{{Path: "main.go", Line: 1, Kind: "HACK", Text: "temporary", Fingerprint: "a"}}
+
+	if _, err := s.ImportMarkers("/repo", markers); err != nil {
+		t.Fatalf("ImportMarkers failed: %v", err)
+	}
+	todos, _ := s.GetTodos()
+	if _, err := s.DeleteTodo(todos[0].ID); err != nil {
+		t.Fatalf("DeleteTodo failed: %v", err)
+	}
+
+	result, err := s.ImportMarkers("/repo", markers)
+	if err != nil {
+		t.Fatalf("ImportMarkers failed: %v", err)
+	}
+	if result.Added != 0 {
+		t.Errorf("Expected a dismissed comment not to be imported again, got %+v", result)
+	}
+}
+
+func TestPerformSqlQueryReadOnly(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "todo.db")
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+	if _, err := migrate.Run(db, migrate.Backups{}); err != nil {
+		t.Fatalf("Failed to migrate database: %v", err)
+	}
+	if _, err := PerformSqlQuery(db, `INSERT INTO todos (Title, Description) VALUES ('a', 'b')`, false); err != nil {
+		t.Fatalf("Expected a write to run, got %v", err)
+	}
+
+	if _, err := PerformSqlQuery(db, `DELETE FROM todos`, true); err == nil {
+		t.Error("Expected query_only mode to reject a write")
+	}
+	result, err := PerformSqlQuery(db, `SELECT Title FROM todos`, true)
+	if err != nil || !strings.Contains(result, `"a"`) {
+		t.Errorf("Expected the todo to survive, got %s, %v", result, err)
+	}
+	if _, err := PerformSqlQuery(db, `DELETE FROM todos`, false); err != nil {
+		t.Errorf("Expected query_only to be reset for later writes, got %v", err)
+	}
+}
+
+func TestListTodosFilter(t *testing.T) {
+	s := NewService(store.NewFakeTodoStore(), "global")
+	_, _ = s.AddTodo("Buy milk", "two litres")
+	_, _ = s.AddTodo("Write report", "quarterly MILK sales")
+	called, _ := s.AddTodo("Call mom", "sunday")
+	if _, err := s.ToggleDone(called.ID); err != nil {
+		t.Fatalf("ToggleDone failed: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		titles []string
+	}{
+		{"all", Filter{}, []string{"Call mom", "Write report", "Buy milk"}},
+		{"open", Filter{Status: "open"}, []string{"Write report", "Buy milk"}},
+		{"done", Filter{Status: "Done"}, []string{"Call mom"}},
+		{"query matches description", Filter{Query: "milk"}, []string{"Write report", "Buy milk"}},
+		{"limit", Filter{Status: "all", Limit: 1}, []string{"Call mom"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.ListTodos(tt.filter)
+			if err != nil {
+				t.Fatalf("ListTodos failed: %v", err)
+			}
+			var titles []string
+			for _, td := range got {
+				titles = append(titles, td.TitleText)
+				if td.Store != "global" {
+					t.Errorf("Expected todos tagged with the store, got %q", td.Store)
+				}
+			}
+			if strings.Join(titles, ",") != strings.Join(tt.titles, ",") {
+				t.Errorf("Expected %q, got %q", tt.titles, titles)
+			}
+		})
+	}
+
+	if _, err := s.ListTodos(Filter{Status: "later"}); !errors.Is(err, ErrorInvalidStatus) {
+		t.Errorf("Expected ErrorInvalidStatus, got %v", err)
+	}
+}
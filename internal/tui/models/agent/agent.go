@@ -1,11 +1,17 @@
 package agent
 
 import (
+	"context"
+	"fmt"
 	"strings"
+	"time"
 
+	"github.com/biisal/godo/internal/bus"
+	"github.com/biisal/godo/internal/llm"
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/textarea"
 	"github.com/charmbracelet/bubbles/textinput"
 	"github.com/charmbracelet/bubbles/viewport"
-	"github.com/openai/openai-go"
 )
 
 type AgentModel struct {
@@ -15,26 +21,88 @@ type AgentModel struct {
 	IsProcessing  bool
 	ShellViewport viewport.Model
 	ShellContent  strings.Builder
+	// ShellCallID is the tool call whose output ShellContent ends with.
+	ShellCallID string
+	// DelegateContent is the output of the sub-agents of the current
+	// response, shown in a panel beside the chat; DelegateCallID is the
+	// Delegate call it ends with.
+	DelegateViewport viewport.Model
+	DelegateContent  strings.Builder
+	DelegateCallID   string
+	// ConfirmReply is set while a tool waits for the user to answer y/n.
+	ConfirmReply chan bool
+	// ApprovalReply is set while a tool call waits for permission to run;
+	// ApprovalQuestion describes the call.
+	ApprovalReply    chan bus.Approval
+	ApprovalQuestion string
+	// PlanReply is set while a submitted Plan waits for the user's review.
+	// While PlanEditing, PlanInput holds the steps being edited.
+	PlanReply   chan bus.PlanReview
+	Plan        bus.Plan
+	PlanEditing bool
+	PlanInput   textarea.Model
+	// Cancel stops the response in flight; it is set while IsProcessing.
+	Cancel context.CancelFunc
+	// Sessions is the picker for resuming earlier conversations.
+	Sessions SessionPicker
 }
 
+// SessionPicker lists the stored chat sessions.
+type SessionPicker struct {
+	Open bool
+	List list.Model
+	// RenameInput edits the title of the selected session while Renaming.
+	RenameInput textinput.Model
+	Renaming    bool
+}
+
+// SessionItem is a chat session shown in the picker.
+type SessionItem struct {
+	ID        int
+	TitleText string
+	Dir       string
+	UpdatedAt time.Time
+	Current   bool
+}
+
+func (i SessionItem) Title() string {
+	title := i.TitleText
+	if title == "" {
+		title = fmt.Sprintf("Session %d", i.ID)
+	}
+	if i.Current {
+		title += " (current)"
+	}
+	return title
+}
+
+func (i SessionItem) Description() string {
+	desc := i.UpdatedAt.Local().Format("2006-01-02 15:04")
+	if i.Dir != "" {
+		desc += "  " + i.Dir
+	}
+	return desc
+}
+
+func (i SessionItem) FilterValue() string { return i.TitleText }
+
 const (
-	UserRole      = "user"
-	AssistantRole = "assistant"
-	SystemRole    = "system"
-	ToolRole      = "tool"
+	UserRole      = llm.RoleUser
+	AssistantRole = llm.RoleAssistant
+	SystemRole    = llm.RoleSystem
+	ToolRole      = llm.RoleTool
 
 	StateThinking   = "Thinking..."
 	StateProcessing = "Preparing request..."
 	StateWriting    = "Generating response..."
 	StateReady      = "Responding..."
 	StateIdle       = "Ask me anything"
+	StateConfirm    = "Allow? (y/n)"
+	StateApprove    = "Waiting for permission..."
+	StateCancelling = "Cancelling..."
+	StateCompacting = "Compacting conversation..."
+	StatePlanReview = "Review the plan..."
 )
 
-type Message struct {
-	Role       string                                 `json:"role"`
-	Reasoning  string                                 `json:"reasoning,omitempty"`
-	Content    string                                 `json:"content,omitempty"`
-	ToolCallID string                                 `json:"tool_call_id,omitempty"`
-	Name       string                                 `json:"name,omitempty"`
-	ToolCalls  []openai.ChatCompletionMessageToolCall `json:"tool_calls,omitempty"`
-}
+// Message is the provider-neutral chat message the agent keeps and stores.
+type Message = llm.Message
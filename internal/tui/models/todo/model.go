@@ -3,6 +3,7 @@ package todo
 import (
 	"fmt"
 	"io"
+	"time"
 
 	"github.com/biisal/godo/internal/tui/ui/styles"
 	"github.com/charmbracelet/bubbles/list"
@@ -18,6 +19,17 @@ type Todo struct {
 	TitleText       string `json:"title"`
 	DescriptionText string `json:"description"`
 	Done            bool   `json:"done"`
+	Store           string `json:"store,omitempty"`
+}
+
+// HistoryEntry is one recorded event in the life of a todo, such as being
+// closed by a git commit.
+type HistoryEntry struct {
+	ID        int       `json:"id"`
+	TodoID    int       `json:"todoId"`
+	Event     string    `json:"event"`
+	Detail    string    `json:"detail"`
+	CreatedAt time.Time `json:"createdAt"`
 }
 
 type Mode struct {
@@ -41,6 +53,7 @@ type TodoList struct {
 type TodoModel struct {
 	AddModel      TodoForm
 	ListModel     TodoList
+	AllListModel  TodoList
 	EditModel     TodoForm
 	Choices       []Mode
 	SelectedIndex int
@@ -70,6 +83,9 @@ func (d CustomDelegate) Render(w io.Writer, m list.Model, index int, listItem li
 
 	title := item.Title()
 	desc := item.Description()
+	if item.Store != "" {
+		title = "[" + item.Store + "] " + title
+	}
 
 	rowStyle := styles.ListRowStyle.Margin(0, 0).Padding(0, 2).BorderLeft(false)
 
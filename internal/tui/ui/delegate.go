@@ -0,0 +1,45 @@
+package ui
+
+import (
+	"github.com/biisal/godo/internal/bus"
+	"github.com/biisal/godo/internal/tui/ui/styles"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/reflow/wordwrap"
+)
+
+// minDelegatePanelWidth is the window width below which the delegate panel
+// is left out to keep the chat readable.
+const minDelegatePanelWidth = 80
+
+// writeDelegate adds output of a sub-agent to the delegate panel.
+func (m *TeaModel) writeDelegate(msg bus.StreamMsg) {
+	if msg.CallID != m.AgentModel.DelegateCallID {
+		// A sub-agent may start another, so label whose output follows.
+		if m.AgentModel.DelegateContent.Len() > 0 {
+			m.AgentModel.DelegateContent.WriteString("\n\n")
+		}
+		m.AgentModel.DelegateCallID = msg.CallID
+	}
+	m.AgentModel.DelegateContent.WriteString(msg.Text)
+}
+
+// DelegatePanelView shows what the sub-agents of the current response are
+// doing beside the chat. It is empty until one starts.
+func (m *TeaModel) DelegatePanelView(height int) string {
+	if m.AgentModel.DelegateContent.Len() == 0 || m.Width < minDelegatePanelWidth {
+		return ""
+	}
+	style := styles.ShellSidePanelStyle
+	frameWidth, frameHeight := style.GetFrameSize()
+	width := m.Width * 40 / 100
+	title := styles.InstructionStyle.Render("Sub-agent")
+
+	vp := &m.AgentModel.DelegateViewport
+	vp.Width = width - frameWidth
+	vp.Height = max(height-frameHeight-lipgloss.Height(title), 1)
+	vp.SetContent(wordwrap.String(m.AgentModel.DelegateContent.String(), vp.Width))
+	vp.GotoBottom()
+	return style.
+		Width(width - style.GetHorizontalMargins() - style.GetHorizontalBorderSize()).
+		Render(lipgloss.JoinVertical(lipgloss.Left, title, vp.View()))
+}
@@ -0,0 +1,72 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/biisal/godo/internal/mcp"
+	"github.com/biisal/godo/internal/tui/ui/styles"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+type mcpRestartedMsg struct {
+	name string
+	err  error
+}
+
+// mcpLabel summarizes the MCP servers for the help bar, such as "MCP 2/3"
+// when one of three is down. It is empty without servers.
+func (m *TeaModel) mcpLabel() string {
+	manager := m.AgentBot.MCP
+	if manager == nil || manager.Len() == 0 {
+		return ""
+	}
+	return fmt.Sprintf("  MCP %d/%d", manager.Connected(), manager.Len())
+}
+
+// mcpCommand handles "/mcp", which lists the servers, and
+// "/mcp restart <name>".
+func (m *TeaModel) mcpCommand(args []string) tea.Cmd {
+	manager := m.AgentBot.MCP
+	if manager == nil || manager.Len() == 0 {
+		m.writeChatStatus("No MCP servers are configured.")
+		return nil
+	}
+	if len(args) == 0 {
+		m.writeChatStatus(mcpStatusText(manager.Statuses()))
+		return nil
+	}
+	if args[0] != "restart" || len(args) != 2 {
+		m.writeChatStatus("Usage: /mcp or /mcp restart <server>")
+		return nil
+	}
+	name := args[1]
+	m.writeChatStatus("Restarting MCP server " + name + "...")
+	return func() tea.Msg {
+		return mcpRestartedMsg{name: name, err: manager.Restart(context.Background(), name)}
+	}
+}
+
+func mcpStatusText(statuses []mcp.ServerStatus) string {
+	var sb strings.Builder
+	sb.WriteString("MCP servers:")
+	for _, s := range statuses {
+		fmt.Fprintf(&sb, "\n  %s: %s", s.Name, s.Status)
+		if s.Tools > 0 {
+			fmt.Fprintf(&sb, ", %d tools", s.Tools)
+		}
+		if s.Err != nil {
+			fmt.Fprintf(&sb, " (%v)", s.Err)
+		}
+	}
+	return sb.String()
+}
+
+// writeChatStatus adds a status line to the chat without storing it in the
+// session.
+func (m *TeaModel) writeChatStatus(text string) {
+	m.ChatContent.WriteString(styles.StatusOutputStyle.Width(m.Width).Render(text) + "\n")
+	m.AgentModel.ChatViewport.SetContent(m.ChatContent.String())
+	m.AgentModel.ChatViewport.GotoBottom()
+}
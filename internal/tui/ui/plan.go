@@ -0,0 +1,157 @@
+package ui
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/biisal/godo/internal/bus"
+	agentModel "github.com/biisal/godo/internal/tui/models/agent"
+	"github.com/biisal/godo/internal/tui/ui/styles"
+	"github.com/charmbracelet/bubbles/textarea"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// togglePlanMode handles "/plan", which switches the agent between plan
+// mode and full tool access.
+func (m *TeaModel) togglePlanMode() {
+	on := !m.AgentBot.PlanMode()
+	m.AgentBot.SetPlanMode(on)
+	if on {
+		m.writeChatStatus("Plan mode on: the agent only reads and searches, then submits a plan for you to approve.")
+	} else {
+		m.writeChatStatus("Plan mode off: the agent can use every tool.")
+	}
+}
+
+// askPlanReview shows a submitted plan in the agent view, where y approves,
+// e edits and n rejects it.
+func (m *TeaModel) askPlanReview(msg bus.StreamMsg) {
+	for i, choice := range m.Choices {
+		if choice.Value == AgentMode.Value {
+			m.SelectedIndex = i
+		}
+	}
+	m.AgentModel.Sessions.Open = false
+	m.AgentModel.PlanReply = msg.PlanReply
+	m.AgentModel.Plan = *msg.Plan
+	m.AgentModel.PlanEditing = false
+	m.AgentModel.StateText = agentModel.StatePlanReview
+}
+
+func (m *TeaModel) answerPlanReview(msg tea.KeyMsg) tea.Cmd {
+	if m.AgentModel.PlanEditing {
+		switch msg.String() {
+		case "ctrl+s":
+			steps := parsePlanSteps(m.AgentModel.PlanInput.Value())
+			if len(steps) == 0 {
+				return m.ShowError(fmt.Errorf("the plan needs at least one step; press esc to stop editing"))
+			}
+			m.replyPlan(bus.PlanReview{Approved: true, Steps: steps}, "Approved the edited plan")
+		case "esc":
+			m.AgentModel.PlanEditing = false
+		default:
+			var cmd tea.Cmd
+			m.AgentModel.PlanInput, cmd = m.AgentModel.PlanInput.Update(msg)
+			return cmd
+		}
+		return nil
+	}
+
+	switch msg.String() {
+	case "y", "Y":
+		m.replyPlan(bus.PlanReview{Approved: true, Steps: m.AgentModel.Plan.Steps}, "Approved the plan")
+	case "e", "E":
+		input := textarea.New()
+		input.ShowLineNumbers = false
+		input.CharLimit = 0
+		input.SetValue(planText(m.AgentModel.Plan.Steps))
+		input.Focus()
+		m.AgentModel.PlanInput = input
+		m.AgentModel.PlanEditing = true
+		return textarea.Blink
+	case "n", "N", "esc":
+		m.replyPlan(bus.PlanReview{}, "Rejected the plan")
+	}
+	return nil
+}
+
+// replyPlan sends review to the agent and records the outcome in the chat.
+func (m *TeaModel) replyPlan(review bus.PlanReview, status string) {
+	m.AgentModel.PlanReply <- review
+	m.AgentModel.PlanReply = nil
+	m.AgentModel.PlanEditing = false
+	m.AgentModel.StateText = agentModel.StateProcessing
+	if review.Approved {
+		status += ":\n" + planText(review.Steps)
+	}
+	m.BuildAgentTextUI(status, "messageStatus")
+}
+
+// PlanReviewView shows the submitted plan with its options, or the editor
+// while the user edits it.
+func (m *TeaModel) PlanReviewView() string {
+	width := m.Width - 12
+	if m.AgentModel.PlanEditing {
+		input := &m.AgentModel.PlanInput
+		input.SetWidth(width - 4)
+		input.SetHeight(min(strings.Count(input.Value(), "\n")+2, max(m.Height/2, 3)))
+		options := styles.InstructionStyle.Render("ctrl+s approve · esc back")
+		return styles.ApprovalDialogStyle.
+			Width(width).
+			Render(lipgloss.JoinVertical(lipgloss.Left, "Edit the plan: a numbered line starts a step, the lines below it describe it.", "", input.View(), "", options))
+	}
+
+	plan := m.AgentModel.Plan
+	lines := strings.Split(planText(plan.Steps), "\n")
+	// Leave room for the chat; the editor shows every step.
+	if limit := max(m.Height/2, 3); len(lines) > limit {
+		lines = append(lines[:limit-1], fmt.Sprintf("... (e to see all %d steps)", len(plan.Steps)))
+	}
+	options := styles.InstructionStyle.Render("y approve · e edit · n reject")
+	return styles.ApprovalDialogStyle.
+		Width(width).
+		Render(lipgloss.JoinVertical(lipgloss.Left, "Plan: "+plan.Summary, "", strings.Join(lines, "\n"), "", options))
+}
+
+// planText lists steps as numbered titles with their descriptions indented
+// below, the format parsePlanSteps reads back.
+func planText(steps []bus.PlanStep) string {
+	var sb strings.Builder
+	for i, step := range steps {
+		fmt.Fprintf(&sb, "%d. %s\n", i+1, step.Title)
+		for line := range strings.SplitSeq(strings.TrimSpace(step.Description), "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				sb.WriteString("   " + line + "\n")
+			}
+		}
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+var stepLine = regexp.MustCompile(`^(?:\d+[.)]|[-*])(?:\s+(.*))?$`)
+
+// parsePlanSteps reads steps edited by the user: a numbered or bulleted
+// line starts a step and the lines after it describe it.
+func parsePlanSteps(text string) []bus.PlanStep {
+	var steps []bus.PlanStep
+	for line := range strings.SplitSeq(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if match := stepLine.FindStringSubmatch(line); match != nil || len(steps) == 0 {
+			title := line
+			if match != nil {
+				title = strings.TrimSpace(match[1])
+			}
+			steps = append(steps, bus.PlanStep{Title: title})
+			continue
+		}
+		step := &steps[len(steps)-1]
+		step.Description = strings.TrimSpace(step.Description + "\n" + line)
+	}
+	return slices.DeleteFunc(steps, func(step bus.PlanStep) bool { return step.Title == "" })
+}
@@ -0,0 +1,151 @@
+package ui
+
+import (
+	"strings"
+
+	agentModel "github.com/biisal/godo/internal/tui/models/agent"
+	"github.com/biisal/godo/internal/tui/ui/styles"
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// OpenSessionPicker shows the stored chat sessions in agent mode.
+func (m *TeaModel) OpenSessionPicker() error {
+	sessions, err := m.AgentBot.Sessions()
+	if err != nil {
+		return err
+	}
+	items := make([]list.Item, 0, len(sessions))
+	for _, s := range sessions {
+		items = append(items, agentModel.SessionItem{
+			ID:        s.ID,
+			TitleText: s.Title,
+			Dir:       s.Dir,
+			UpdatedAt: s.UpdatedAt,
+			Current:   s.ID == m.AgentBot.Session,
+		})
+	}
+	l := list.New(items, list.NewDefaultDelegate(), 0, 0)
+	l.Title = "Sessions "
+	l.SetShowStatusBar(false)
+	l.SetFilteringEnabled(false)
+	l.SetShowHelp(false)
+
+	picker := &m.AgentModel.Sessions
+	picker.List = l
+	picker.Open = true
+	picker.Renaming = false
+	picker.RenameInput = getTitleInput(false, "Title > ", "Enter session title")
+	m.resizeSessionPicker()
+	for i, choice := range m.Choices {
+		if choice.Value == AgentMode.Value {
+			m.SelectedIndex = i
+		}
+	}
+	return nil
+}
+
+// sessionIndex returns the position of session id in the picker list.
+func sessionIndex(l list.Model, id int) int {
+	for i, item := range l.Items() {
+		if s, ok := item.(agentModel.SessionItem); ok && s.ID == id {
+			return i
+		}
+	}
+	return 0
+}
+
+func (m *TeaModel) resizeSessionPicker() {
+	if !m.AgentModel.Sessions.Open {
+		return
+	}
+	m.AgentModel.Sessions.List.SetSize(m.Width*60/100, m.Height*70/100)
+	m.AgentModel.Sessions.RenameInput.Width = m.Width * 60 / 100
+}
+
+// SetUpSessionPickerKey handles keys while the session picker is open.
+func SetUpSessionPickerKey(key string, m *TeaModel, msg tea.KeyMsg) tea.Cmd {
+	picker := &m.AgentModel.Sessions
+	selected, ok := picker.List.SelectedItem().(agentModel.SessionItem)
+
+	if picker.Renaming {
+		switch key {
+		case "enter":
+			if err := m.AgentBot.RenameSession(selected.ID, picker.RenameInput.Value()); err != nil {
+				return m.ShowError(err)
+			}
+			if err := m.OpenSessionPicker(); err != nil {
+				return m.ShowError(err)
+			}
+			picker.List.Select(sessionIndex(picker.List, selected.ID))
+		case "esc":
+			picker.Renaming = false
+			picker.RenameInput.Blur()
+		default:
+			var cmd tea.Cmd
+			picker.RenameInput, cmd = picker.RenameInput.Update(msg)
+			return cmd
+		}
+		return nil
+	}
+
+	switch key {
+	case "esc":
+		picker.Open = false
+	case "enter":
+		if !ok {
+			return nil
+		}
+		if err := m.AgentBot.ResumeSession(selected.ID); err != nil {
+			return m.ShowError(err)
+		}
+		picker.Open = false
+		m.RenderChatContentFromHistory()
+	case "ctrl+r":
+		if ok {
+			picker.Renaming = true
+			picker.RenameInput.SetValue(selected.TitleText)
+			picker.RenameInput.CursorEnd()
+			return picker.RenameInput.Focus()
+		}
+	case "delete":
+		if !ok {
+			return nil
+		}
+		if err := m.AgentBot.DeleteSession(selected.ID); err != nil {
+			return m.ShowError(err)
+		}
+		if selected.Current {
+			m.RenderChatContentFromHistory()
+		}
+		index := picker.List.Index()
+		if err := m.OpenSessionPicker(); err != nil {
+			return m.ShowError(err)
+		}
+		picker.List.Select(min(index, len(picker.List.Items())-1))
+	default:
+		var cmd tea.Cmd
+		picker.List, cmd = picker.List.Update(msg)
+		return cmd
+	}
+	return nil
+}
+
+func SessionPickerView(m *TeaModel, maxHeight int) string {
+	picker := m.AgentModel.Sessions
+	var body string
+	if len(picker.List.Items()) == 0 {
+		body = styles.InstructionStyle.Render("No saved sessions yet")
+	} else {
+		body = picker.List.View()
+	}
+	hint := "enter resume  ctrl+r rename  delete remove  esc close"
+	if picker.Renaming {
+		body = lipgloss.JoinVertical(lipgloss.Left, body, styles.TodoInputStyle.Render(picker.RenameInput.View()))
+		hint = "enter save  esc cancel"
+	}
+	view := lipgloss.JoinVertical(lipgloss.Left, body, styles.InstructionStyle.Render(hint))
+	return lipgloss.Place(m.Width, maxHeight, lipgloss.Center, lipgloss.Center,
+		styles.TodoListStyle.Render(strings.TrimRight(view, "\n")))
+}
@@ -112,6 +112,9 @@ var (
 				Foreground(colors.MutedForeground).
 				Italic(true)
 
+	ToolActivityStyle = lipgloss.NewStyle().
+				Foreground(colors.MutedForeground)
+
 	HalfWidthLeftStyle = lipgloss.NewStyle().
 				AlignHorizontal(lipgloss.Left)
 
@@ -181,6 +184,11 @@ var (
 			Foreground(colors.Destructive).
 			Background(colors.DestructiveBg)
 
+	ApprovalDialogStyle = lipgloss.NewStyle().
+				Border(lipgloss.RoundedBorder()).
+				BorderForeground(colors.Accent).
+				Padding(0, 1)
+
 	ShellSidePanelStyle = lipgloss.NewStyle().
 				Border(lipgloss.RoundedBorder()).
 				BorderForeground(colors.Border).
@@ -1,11 +1,14 @@
 package ui
 
 import (
+	"errors"
+	"fmt"
 	"strings"
 
 	"github.com/biisal/godo/internal/bus"
 	"github.com/biisal/godo/internal/config"
 	"github.com/biisal/godo/internal/tui/actions/agent"
+	todoAction "github.com/biisal/godo/internal/tui/actions/todo"
 	agentModel "github.com/biisal/godo/internal/tui/models/agent"
 	"github.com/biisal/godo/internal/tui/models/todo"
 
@@ -29,6 +32,7 @@ var (
 	TodoAddMode  = todo.Mode{Value: "todoAddMode", Label: "Add Todo"}
 	TodoEditMode = todo.Mode{Value: "todoEditMode", Label: "Edit Todo"}
 	TodoListMode = todo.Mode{Value: "todoListMode", Label: "Todo List"}
+	TodoAllMode  = todo.Mode{Value: "todoAllMode", Label: "All Stores"}
 )
 
 type TeaModel struct {
@@ -45,6 +49,8 @@ type TeaModel struct {
 	ChatContent   strings.Builder
 	ThinkContent  strings.Builder
 	AgentBot      *agent.Bot
+	Todos         *todoAction.Service
+	GlobalTodos   *todoAction.Service
 }
 
 //	func waitForActivity(ev chan string) tea.Cmd {
@@ -72,12 +78,16 @@ func getTitleInput(focus bool, s ...string) textinput.Model {
 	return input
 }
 
-func InitialModel(agentBot *agent.Bot) *TeaModel {
+// InitialModel builds the TUI model. globalTodos is only set inside a project,
+// where todos is the project store and the All Stores view combines both.
+func InitialModel(agentBot *agent.Bot, todos, globalTodos *todoAction.Service) *TeaModel {
 	promptInput := textinput.New()
 	promptInput.Focus()
 	promptInput.Placeholder = "Ask me anything.. ANYTHING..."
 	teaModel := TeaModel{
 		AgentBot:      agentBot,
+		Todos:         todos,
+		GlobalTodos:   globalTodos,
 		SelectedIndex: 1,
 		Choices:       []todo.Mode{TodoMode, AgentMode},
 		TodoModel: todo.TodoModel{
@@ -102,6 +112,10 @@ func InitialModel(agentBot *agent.Bot) *TeaModel {
 		},
 	}
 
+	if globalTodos != nil {
+		teaModel.TodoModel.Choices = append(teaModel.TodoModel.Choices, TodoAllMode)
+	}
+
 	initialMode := teaModel.Choices[teaModel.SelectedIndex]
 	if (initialMode == TodoMode && config.Cfg.MODE == "agent") ||
 		(initialMode == AgentMode && config.Cfg.MODE == "todo") {
@@ -115,17 +129,28 @@ func InitialModel(agentBot *agent.Bot) *TeaModel {
 func (m *TeaModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var cmds []tea.Cmd
 	var cmd tea.Cmd
-	if m.Choices[m.SelectedIndex].Value == TodoMode.Value && m.TodoModel.Choices[m.TodoModel.SelectedIndex].Value == TodoListMode.Value {
-		m.TodoModel.ListModel.List, cmd = m.TodoModel.ListModel.List.Update(msg)
-		cmds = append(cmds, cmd)
+	if m.Choices[m.SelectedIndex].Value == TodoMode.Value {
+		switch m.TodoModel.Choices[m.TodoModel.SelectedIndex].Value {
+		case TodoListMode.Value:
+			m.TodoModel.ListModel.List, cmd = m.TodoModel.ListModel.List.Update(msg)
+			cmds = append(cmds, cmd)
+		case TodoAllMode.Value:
+			m.TodoModel.AllListModel.List, cmd = m.TodoModel.AllListModel.List.Update(msg)
+			cmds = append(cmds, cmd)
+		}
 	}
 	switch msg := msg.(type) {
 	case agentResponseMsg:
 		m.AgentModel.IsProcessing = false
+		if m.AgentModel.Cancel != nil {
+			m.AgentModel.Cancel()
+			m.AgentModel.Cancel = nil
+		}
 		if msg.refresh {
 			m.RefreshList()
 		}
-		if msg.err != nil {
+		// A cancelled response already ends with its marker in the chat.
+		if msg.err != nil && !errors.Is(msg.err, agent.ErrCancelled) {
 			m.ChatContent.WriteString(styles.ErrorInChatStyle.Width(m.Width).Render(msg.err.Error()) + "\n")
 			m.AgentModel.ChatViewport.SetContent(m.ChatContent.String())
 			m.AgentModel.ChatViewport.GotoBottom()
@@ -139,7 +164,24 @@ func (m *TeaModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case "status":
 			m.AgentModel.StateText = msg.Text
 			return m, nil
+		case "confirm":
+			m.askConfirm(msg)
+		case "approve":
+			m.askApproval(msg)
+		case "plan":
+			m.askPlanReview(msg)
+		case "delegate":
+			m.writeDelegate(msg)
+		case "mcp":
+			// The help bar reads the servers' status when it renders.
 		case "shell":
+			if msg.CallID != m.AgentModel.ShellCallID {
+				// Tool calls run concurrently, so label whose output follows.
+				m.AgentModel.ShellCallID = msg.CallID
+				if msg.Tool != "" {
+					m.AgentModel.ShellContent.WriteString("[" + msg.Tool + "]\n")
+				}
+			}
 			m.AgentModel.ShellContent.WriteString(msg.Text)
 			m.AgentModel.ShellViewport.SetContent(styles.ShellOutputStyle.Render(m.AgentModel.ShellContent.String()))
 			m.AgentModel.ShellViewport.GotoBottom()
@@ -147,6 +189,13 @@ func (m *TeaModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.BuildAgentTextUI(msg.Text, msg.Type)
 		}
 		return m, nil
+	case mcpRestartedMsg:
+		if msg.err != nil {
+			m.writeChatStatus(fmt.Sprintf("MCP server %s failed to restart: %v", msg.name, msg.err))
+		} else {
+			m.writeChatStatus("MCP server " + msg.name + " restarted.")
+		}
+		return m, nil
 	case clearErrorMsg:
 		m.Error = nil
 		return m, nil
@@ -182,7 +231,11 @@ func (m *TeaModel) View() string {
 		case TodoMode.Value:
 			s.WriteString(TodoView(m, maxHeight))
 		case AgentMode.Value:
-			s.WriteString(AgentView(m, maxHeight))
+			if m.AgentModel.Sessions.Open {
+				s.WriteString(SessionPickerView(m, maxHeight))
+			} else {
+				s.WriteString(AgentView(m, maxHeight))
+			}
 		}
 	}
 
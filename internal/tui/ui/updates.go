@@ -1,6 +1,7 @@
 package ui
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log/slog"
@@ -13,6 +14,7 @@ import (
 	"github.com/biisal/godo/internal/bus"
 	"github.com/biisal/godo/internal/config"
 	todoAction "github.com/biisal/godo/internal/tui/actions/todo"
+	agentModel "github.com/biisal/godo/internal/tui/models/agent"
 	"github.com/biisal/godo/internal/tui/models/todo"
 	"github.com/biisal/godo/internal/tui/ui/styles"
 
@@ -54,7 +56,7 @@ func SetUpFormKey(key string, model *todo.TodoForm, m *TeaModel, cmds *[]tea.Cmd
 	case "ctrl+s":
 		switch m.TodoModel.SelectedIndex {
 		case 1:
-			_, err := todoAction.AddTodo(m.TodoModel.AddModel.TitleInput.Value(), m.TodoModel.AddModel.DescInput.Value())
+			_, err := m.Todos.AddTodo(m.TodoModel.AddModel.TitleInput.Value(), m.TodoModel.AddModel.DescInput.Value())
 			if err != nil {
 				*cmds = append(*cmds, m.ShowError(err))
 				return
@@ -69,7 +71,7 @@ func SetUpFormKey(key string, model *todo.TodoForm, m *TeaModel, cmds *[]tea.Cmd
 				*cmds = append(*cmds, m.ShowError(ErrWrongTypeID))
 				return
 			}
-			_, err = todoAction.ModifyTodo(id, m.TodoModel.EditModel.TitleInput.Value(), m.TodoModel.EditModel.DescInput.Value())
+			_, err = m.Todos.ModifyTodo(id, m.TodoModel.EditModel.TitleInput.Value(), m.TodoModel.EditModel.DescInput.Value())
 			if err != nil {
 				*cmds = append(*cmds, m.ShowError(err))
 				return
@@ -112,10 +114,10 @@ func SetyUpListKey(key string, m *TeaModel, msg tea.KeyMsg, cmds *[]tea.Cmd) (te
 		selected := m.TodoModel.ListModel.List.SelectedItem()
 		if selected != nil {
 			id := selected.(todo.Todo).ID
-			if _, err := todoAction.ToggleDone(id); err != nil {
+			if _, err := m.Todos.ToggleDone(id); err != nil {
 				slog.Error("error toggling done", "id", id, "err", err)
 			}
-			item, err := todoAction.GetTodoById(id)
+			item, err := m.Todos.GetTodoById(id)
 			if err != nil {
 				slog.Info("error toggling done", "id", id, "err", err)
 				return m, nil
@@ -134,7 +136,7 @@ func SetyUpListKey(key string, m *TeaModel, msg tea.KeyMsg, cmds *[]tea.Cmd) (te
 	case "delete":
 		selected := m.TodoModel.ListModel.List.SelectedItem()
 		if selected != nil {
-			_, err := todoAction.DeleteTodo(selected.(todo.Todo).ID)
+			_, err := m.Todos.DeleteTodo(selected.(todo.Todo).ID)
 			if err != nil {
 				cmd := m.ShowError(err)
 				return m, &cmd
@@ -149,6 +151,40 @@ func SetyUpListKey(key string, m *TeaModel, msg tea.KeyMsg, cmds *[]tea.Cmd) (te
 	return m, nil
 }
 
+func SetUpAllListKey(key string, m *TeaModel, msg tea.KeyMsg) (tea.Model, *tea.Cmd) {
+	selected, ok := m.TodoModel.AllListModel.List.SelectedItem().(todo.Todo)
+	service := m.todoService(selected.Store)
+	ok = ok && service != nil
+	switch key {
+	case " ":
+		if ok {
+			if _, err := service.ToggleDone(selected.ID); err != nil {
+				slog.Error("error toggling done", "store", selected.Store, "id", selected.ID, "err", err)
+			}
+			item, err := service.GetTodoById(selected.ID)
+			if err != nil {
+				slog.Info("error toggling done", "store", selected.Store, "id", selected.ID, "err", err)
+				return m, nil
+			}
+			item.Store = selected.Store
+			m.TodoModel.AllListModel.List.SetItem(m.TodoModel.AllListModel.List.Index(), *item)
+		}
+	case "delete":
+		if ok {
+			if _, err := service.DeleteTodo(selected.ID); err != nil {
+				cmd := m.ShowError(err)
+				return m, &cmd
+			}
+			m.RefreshList()
+		}
+	case "j", "k":
+		vp, cmd := m.TodoModel.AllListModel.DescViewport.Update(msg)
+		m.TodoModel.AllListModel.DescViewport = vp
+		return m, &cmd
+	}
+	return m, nil
+}
+
 func UpdateOnKey(msg tea.KeyMsg, m *TeaModel) (tea.Model, tea.Cmd) {
 	key := msg.String()
 	var cmds []tea.Cmd
@@ -156,6 +192,17 @@ func UpdateOnKey(msg tea.KeyMsg, m *TeaModel) (tea.Model, tea.Cmd) {
 	if key == "ctrl+c" {
 		return m, tea.Quit
 	}
+	if m.AgentModel.ConfirmReply != nil {
+		m.answerConfirm(key)
+		return m, nil
+	}
+	if m.AgentModel.ApprovalReply != nil {
+		m.answerApproval(key)
+		return m, nil
+	}
+	if m.AgentModel.PlanReply != nil {
+		return m, m.answerPlanReview(msg)
+	}
 	switch m.Choices[m.SelectedIndex].Value {
 	case TodoMode.Value:
 		switch m.TodoModel.Choices[m.TodoModel.SelectedIndex].Value {
@@ -164,17 +211,38 @@ func UpdateOnKey(msg tea.KeyMsg, m *TeaModel) (tea.Model, tea.Cmd) {
 			if c != nil {
 				return m, *c
 			}
+		case TodoAllMode.Value:
+			m, c := SetUpAllListKey(key, m, msg)
+			if c != nil {
+				return m, *c
+			}
 		case TodoAddMode.Value:
 			SetUpFormKey(key, &m.TodoModel.AddModel, m, &cmds, msg)
 		case TodoEditMode.Value:
 			SetUpFormKey(key, &m.TodoModel.EditModel, m, &cmds, msg)
 		}
 	case AgentMode.Value:
+		if m.AgentModel.Sessions.Open {
+			return m, SetUpSessionPickerKey(key, m, msg)
+		}
 		switch key {
+		case "ctrl+o":
+			if !m.AgentModel.IsProcessing {
+				if err := m.OpenSessionPicker(); err != nil {
+					return m, m.ShowError(err)
+				}
+				return m, nil
+			}
 		case "up":
 			m.AgentModel.ChatViewport.ScrollUp(1)
 		case "down":
 			m.AgentModel.ChatViewport.ScrollDown(1)
+		case "esc":
+			if m.AgentModel.IsProcessing && m.AgentModel.Cancel != nil {
+				m.AgentModel.Cancel()
+				m.AgentModel.StateText = agentModel.StateCancelling
+				return m, nil
+			}
 		case "enter":
 			if m.AgentModel.IsProcessing {
 				return m, nil
@@ -191,13 +259,48 @@ func UpdateOnKey(msg tea.KeyMsg, m *TeaModel) (tea.Model, tea.Cmd) {
 				m.AgentModel.PromptInput.Reset()
 				return m, nil
 			}
+			if promtInput == "/new" {
+				m.AgentBot.NewSession()
+				m.ChatContent = strings.Builder{}
+				m.AgentModel.PromptInput.Reset()
+				return m, nil
+			}
+			if promtInput == "/sessions" {
+				m.AgentModel.PromptInput.Reset()
+				if err := m.OpenSessionPicker(); err != nil {
+					return m, m.ShowError(err)
+				}
+				return m, nil
+			}
+			if fields := strings.Fields(promtInput); fields[0] == "/mcp" {
+				m.AgentModel.PromptInput.Reset()
+				return m, m.mcpCommand(fields[1:])
+			}
+			if promtInput == "/plan" {
+				m.AgentModel.PromptInput.Reset()
+				m.togglePlanMode()
+				return m, nil
+			}
+			if promtInput == "/compact" {
+				m.AgentModel.PromptInput.Reset()
+				m.AgentModel.IsProcessing = true
+				ctx, cancel := context.WithCancel(context.Background())
+				m.AgentModel.Cancel = cancel
+				return m, tea.Cmd(func() tea.Msg {
+					err := m.AgentBot.Compact(ctx)
+					bus.EmitState(agentModel.StateIdle)
+					return agentResponseMsg{err: err}
+				})
+			}
 
 			slog.Debug("User Message Details", "Prompt", map[string]any{"promptInput": promtInput})
 			bus.EmitUser(promtInput)
 			m.AgentModel.PromptInput.Reset()
 			m.AgentModel.IsProcessing = true
+			ctx, cancel := context.WithCancel(context.Background())
+			m.AgentModel.Cancel = cancel
 			return m, tea.Cmd(func() tea.Msg {
-				_, refresh, err := m.AgentBot.AgentResponse(promtInput)
+				_, refresh, err := m.AgentBot.AgentResponse(ctx, promtInput)
 				return agentResponseMsg{
 					refresh: refresh,
 					err:     err,
@@ -216,6 +319,7 @@ func UpdateOnSize(msg tea.WindowSizeMsg, m *TeaModel) tea.Cmd {
 	m.Height = msg.Height
 	m.RefreshList()
 	m.RenderChatContentFromHistory()
+	m.resizeSessionPicker()
 	m.AgentModel.PromptInput.Width = m.Width
 	secondary := styles.Colors().Secondary
 	m.AgentModel.PromptInput.PromptStyle = m.AgentModel.PromptInput.PromptStyle.Background(secondary)
@@ -226,14 +330,14 @@ func UpdateOnSize(msg tea.WindowSizeMsg, m *TeaModel) tea.Cmd {
 	return nil
 }
 
-func (m *TeaModel) UpdateDescriptionContent() {
+func (m *TeaModel) UpdateDescriptionContent(l *todo.TodoList) {
 	LabelStyle := lipgloss.NewStyle().
 		Background(styles.Colors().Primary).
 		Padding(0, 1).
 		Foreground(styles.Colors().PrimaryForeground).
 		Bold(true)
 	var rightContent string
-	if selectedItem := m.TodoModel.ListModel.List.SelectedItem(); selectedItem != nil {
+	if selectedItem := l.List.SelectedItem(); selectedItem != nil {
 		slog.Debug("selected item in description view", "item", selectedItem)
 		if i, ok := selectedItem.(todo.Todo); ok {
 			slog.Debug("matched todo item", "id", i.ID)
@@ -242,30 +346,48 @@ func (m *TeaModel) UpdateDescriptionContent() {
 				statusText = "Compelted"
 			}
 			rightContent = fmt.Sprintf("%s : %s\n\n%s : %s ", LabelStyle.Render("Status"), statusText, LabelStyle.Render("Description"), i.Description())
+			if i.Store != "" {
+				rightContent = fmt.Sprintf("%s : %s\n\n", LabelStyle.Render("Store"), i.Store) + rightContent
+			}
 		}
 	}
 	slog.Debug("right content built", "length", len(rightContent))
-	m.TodoModel.ListModel.DescViewport.Height = m.Height * 50 / 100
-	m.TodoModel.ListModel.DescViewport.SetContent(rightContent)
+	l.DescViewport.Height = m.Height * 50 / 100
+	l.DescViewport.SetContent(rightContent)
 }
 
 func (m *TeaModel) RefreshList() {
-	todos, _ := todoAction.GetTodos()
-	doneCount := 0
+	todos, _ := m.Todos.GetTodos()
+	m.TodoModel.ListModel.List = m.newTodoList(todos, "Todos ")
+	if m.GlobalTodos != nil {
+		allTodos, _ := todoAction.AllTodos(m.Todos, m.GlobalTodos)
+		m.TodoModel.AllListModel.List = m.newTodoList(allTodos, "All Todos ")
+	}
+}
+
+// todoService returns the service of the named store.
+func (m *TeaModel) todoService(name string) *todoAction.Service {
+	for _, s := range []*todoAction.Service{m.Todos, m.GlobalTodos} {
+		if s != nil && s.Name() == name {
+			return s
+		}
+	}
+	return nil
+}
+
+func (m *TeaModel) newTodoList(todos []todo.Todo, title string) list.Model {
 	items := []list.Item{}
 	for _, todo := range todos {
-		if todo.Done {
-			doneCount++
-		}
 		items = append(items, todo)
 	}
 	innerWidth := m.Width * 60 / 100
 	innerHeight := m.Height * 80 / 100
 	slog.Debug("list refresh", "width", innerWidth)
-	m.TodoModel.ListModel.List = list.New(items, todo.CustomDelegate{Width: innerWidth - 2, Theme: styles.Theme{}}, 0, 0)
-	m.TodoModel.ListModel.List.SetSize(innerWidth, innerHeight)
-	m.TodoModel.ListModel.List.Title = "Todos "
-	m.TodoModel.ListModel.List.SetShowStatusBar(false)
+	l := list.New(items, todo.CustomDelegate{Width: innerWidth - 2, Theme: styles.Theme{}}, 0, 0)
+	l.SetSize(innerWidth, innerHeight)
+	l.Title = title
+	l.SetShowStatusBar(false)
+	return l
 }
 
 func (m *TeaModel) ToggleMode() {
@@ -289,6 +411,72 @@ func (m *TeaModel) ToggleMode() {
 	}
 }
 
+// askConfirm shows a question from a tool in the chat and switches to the
+// agent view, where the next y or n answers it.
+func (m *TeaModel) askConfirm(msg bus.StreamMsg) {
+	for i, choice := range m.Choices {
+		if choice.Value == AgentMode.Value {
+			m.SelectedIndex = i
+		}
+	}
+	m.AgentModel.ConfirmReply = msg.Reply
+	m.AgentModel.StateText = agentModel.StateConfirm
+	m.BuildAgentTextUI(msg.Text+" (y/n)", "messageStatus")
+}
+
+func (m *TeaModel) answerConfirm(key string) {
+	var allowed bool
+	switch key {
+	case "y", "Y":
+		allowed = true
+	case "n", "N", "esc":
+	default:
+		return
+	}
+	m.AgentModel.ConfirmReply <- allowed
+	m.AgentModel.ConfirmReply = nil
+	m.AgentModel.StateText = agentModel.StateProcessing
+	if allowed {
+		m.BuildAgentTextUI("Allowed", "messageStatus")
+	} else {
+		m.BuildAgentTextUI("Declined", "messageStatus")
+	}
+}
+
+// askApproval shows the permission dialog for a tool call in the agent
+// view, where y, a or n answers it.
+func (m *TeaModel) askApproval(msg bus.StreamMsg) {
+	for i, choice := range m.Choices {
+		if choice.Value == AgentMode.Value {
+			m.SelectedIndex = i
+		}
+	}
+	m.AgentModel.Sessions.Open = false
+	m.AgentModel.ApprovalReply = msg.Approval
+	m.AgentModel.ApprovalQuestion = msg.Text
+	m.AgentModel.StateText = agentModel.StateApprove
+}
+
+func (m *TeaModel) answerApproval(key string) {
+	var answer bus.Approval
+	var status string
+	switch key {
+	case "y", "Y":
+		answer, status = bus.AllowOnce, "Allowed once"
+	case "a", "A":
+		answer, status = bus.AllowAlways, "Always allowed"
+	case "n", "N", "esc":
+		answer, status = bus.Deny, "Denied"
+	default:
+		return
+	}
+	m.AgentModel.ApprovalReply <- answer
+	m.AgentModel.ApprovalReply = nil
+	m.AgentModel.StateText = agentModel.StateProcessing
+	m.BuildAgentTextUI(status+": "+m.AgentModel.ApprovalQuestion, "messageStatus")
+	m.AgentModel.ApprovalQuestion = ""
+}
+
 type clearErrorMsg struct{}
 
 func (m *TeaModel) ShowError(err error) tea.Cmd {
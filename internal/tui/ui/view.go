@@ -1,24 +1,26 @@
 package ui
 
 import (
+	"fmt"
 	"log/slog"
 	"strings"
 
 	"github.com/biisal/godo/internal/config"
+	"github.com/biisal/godo/internal/tui/models/todo"
 	"github.com/biisal/godo/internal/tui/ui/styles"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/muesli/reflow/wordwrap"
 )
 
-func RenderListView(m *TeaModel, maxHeight int) string {
+func RenderListView(m *TeaModel, l *todo.TodoList, maxHeight int) string {
 	leftWidth := m.Width * 60 / 100
-	left := styles.TodoListStyle.Height(maxHeight).Width(leftWidth).Render(m.TodoModel.ListModel.List.View())
+	left := styles.TodoListStyle.Height(maxHeight).Width(leftWidth).Render(l.List.View())
 
-	m.UpdateDescriptionContent()
+	m.UpdateDescriptionContent(l)
 	right := styles.TodoDescViewportStyle.Width(m.Width - leftWidth - 1).
 		BorderForeground(styles.Colors().Border).
 		Height(maxHeight).
-		Render(m.TodoModel.ListModel.DescViewport.View())
+		Render(l.DescViewport.View())
 
 	return lipgloss.JoinHorizontal(lipgloss.Top, left, right)
 }
@@ -45,7 +47,9 @@ func TodoView(m *TeaModel, maxHeight int) string {
 
 		s = lipgloss.JoinVertical(lipgloss.Center, topPart, inputView, descView.Render(descInput.View()))
 	case TodoListMode.Value:
-		return RenderListView(m, maxHeight)
+		return RenderListView(m, &m.TodoModel.ListModel, maxHeight)
+	case TodoAllMode.Value:
+		return RenderListView(m, &m.TodoModel.AllListModel, maxHeight)
 	case TodoEditMode.Value:
 		titleInput := m.TodoModel.EditModel.TitleInput
 		descInput := m.TodoModel.EditModel.DescInput
@@ -76,10 +80,16 @@ func TodoView(m *TeaModel, maxHeight int) string {
 func (m *TeaModel) AgentPromtInputView() (string, int) {
 	inputHeight := 1
 	marginX := 4
-	s := styles.InstructionStyle.Foreground(styles.Colors().Accent).Background(styles.Colors().Secondary).Render(config.Cfg.OPENAI_MODEL)
+	s := styles.InstructionStyle.Foreground(styles.Colors().Accent).Background(styles.Colors().Secondary).Render(config.ModelName())
 	s += lipgloss.NewStyle().Background(styles.Colors().Secondary).Render(" " + m.AgentModel.StateText)
 
 	fullInput := lipgloss.JoinVertical(lipgloss.Left, m.AgentModel.PromptInput.View(), s)
+	if m.AgentModel.ApprovalReply != nil {
+		fullInput = lipgloss.JoinVertical(lipgloss.Left, m.ApprovalDialogView(), s)
+	}
+	if m.AgentModel.PlanReply != nil {
+		fullInput = lipgloss.JoinVertical(lipgloss.Left, m.PlanReviewView(), s)
+	}
 	inputView := styles.AgentPromptStyle.
 		Width(m.Width - marginX*2).
 		Height(inputHeight - 1).
@@ -96,6 +106,14 @@ func (m *TeaModel) AgentPromtInputView() (string, int) {
 	return bottomPart, lipgloss.Height(bottomPart)
 }
 
+// ApprovalDialogView asks whether a tool call may run.
+func (m *TeaModel) ApprovalDialogView() string {
+	options := styles.InstructionStyle.Render("y allow once · a always allow · n deny")
+	return styles.ApprovalDialogStyle.
+		Width(m.Width - 12).
+		Render(lipgloss.JoinVertical(lipgloss.Left, m.AgentModel.ApprovalQuestion, "", options))
+}
+
 func AgentView(m *TeaModel, maxHeight int) string {
 	bottomPart, bottomHeight := m.AgentPromtInputView()
 
@@ -105,17 +123,10 @@ func AgentView(m *TeaModel, maxHeight int) string {
 	cWidth, cHeight := chatstyle.GetFrameSize()
 
 	chatWidth := m.Width
-	// var shellPanel string
-	// if m.AgentModel.ShellContent.Len() > 0 {
-	// 	chatWidth = m.Width * 60 / 100
-	// 	shellWidth := m.Width - chatWidth - 2
-	// 	m.AgentModel.ShellViewport.Width = shellWidth
-	// 	m.AgentModel.ShellViewport.Height = outerChatIViewHeight - 2
-	// 	shellPanel = styles.ShellSidePanelStyle.
-	// 		Width(shellWidth).
-	// 		Height(outerChatIViewHeight - 2).
-	// 		Render(m.AgentModel.ShellViewport.View())
-	// }
+	delegatePanel := m.DelegatePanelView(outerChatIViewHeight)
+	if delegatePanel != "" {
+		chatWidth -= lipgloss.Width(delegatePanel)
+	}
 
 	m.AgentModel.ChatViewport.Height = outerChatIViewHeight - cHeight
 	m.AgentModel.ChatViewport.Width = chatWidth - cWidth
@@ -144,13 +155,7 @@ func AgentView(m *TeaModel, maxHeight int) string {
 	m.AgentModel.ChatViewport.SetContent(wordwrap.String(content, chatWidth-cWidth-1))
 	chatView := chatstyle.Width(chatWidth).Render(m.AgentModel.ChatViewport.View())
 
-	// var combinedView string
-	// if shellPanel != "" {
-	// 	combinedView = lipgloss.JoinHorizontal(lipgloss.Top, chatView, shellPanel)
-	// } else {
-	// 	combinedView = chatView
-	// }
-	combinedView := chatView
+	combinedView := lipgloss.JoinHorizontal(lipgloss.Top, chatView, delegatePanel)
 
 	topPart := lipgloss.Place(
 		m.Width,
@@ -186,6 +191,9 @@ Todo List:
   enter      toggle done
   ctrl+e     edit todo
   j/k        next/previous todo 
+
+All Stores:
+  shows project and global todos
   `
 
 	rightHelp := `Forms:
@@ -194,8 +202,20 @@ Todo List:
   
 Agent:
   enter      send message
+  esc        cancel response
+  y/a/n      allow once, always, or
+             deny a tool call
   up/down    scroll chat
-  /clear     clear history
+  /clear     clear this session
+  /compact   summarize older turns
+  /plan      toggle plan mode; y/e/n
+             approve, edit or reject
+             a plan
+  /new       start a new session
+  /mcp       MCP server status;
+             /mcp restart <name>
+  ctrl+o     resume, rename or delete
+             sessions (/sessions)
 
 Actions:
   delete     remove todo
@@ -246,7 +266,18 @@ func HelpBarView(m *TeaModel) (string, int) {
 	rightPart := styles.InstructionStyle.AlignHorizontal(lipgloss.Right).Render(modeUI.String())
 	rightWidth := lipgloss.Width(rightPart)
 
-	leftPart := styles.InstructionStyle.Width(m.Width - rightWidth).Render("Help: Ctrl+b")
+	left := "Help: Ctrl+b  Store: " + config.StoreLabel()
+	if m.Choices[m.SelectedIndex].Value == AgentMode.Value {
+		left += m.mcpLabel()
+		// SHELL_SANDBOX is on but only the environment is cleaned.
+		if sb := m.AgentBot.Sandbox; sb != nil && !sb.Isolated() {
+			left += "  SANDBOX: ENV ONLY"
+		}
+		if m.AgentBot.PlanMode() {
+			left += "  PLAN"
+		}
+	}
+	leftPart := styles.InstructionStyle.Width(m.Width - rightWidth).Render(left)
 
 	s = lipgloss.JoinHorizontal(lipgloss.Top, leftPart, rightPart)
 	return s, lipgloss.Height(s)
@@ -257,6 +288,8 @@ func (m *TeaModel) BuildAgentTextUI(text string, msgType string) {
 	switch msgType {
 	case "stream_start":
 		m.ThinkContent.Reset()
+		m.AgentModel.DelegateContent.Reset()
+		m.AgentModel.DelegateCallID = ""
 	case "stream_end":
 		if m.ThinkContent.Len() > 0 {
 			m.ChatContent.WriteString(styles.ThinkingTokenStyle.Render(m.ThinkContent.String()))
@@ -284,12 +317,25 @@ func (m *TeaModel) BuildAgentTextUI(text string, msgType string) {
 }
 
 func (m *TeaModel) RenderChatContentFromHistory() {
+	results := map[string]string{}
+	for _, msg := range m.AgentBot.History {
+		if msg.Role == "tool" {
+			results[msg.ToolCallID] = msg.Content
+		}
+	}
+
 	var chatBlocks []string
 	for _, msg := range m.AgentBot.History {
 		text := msg.Content
 		switch msg.Role {
 		case "user":
 			chatBlocks = append(chatBlocks, styles.UserContentStyle.Width(m.Width).Render(text))
+		case "system":
+			if msg.Summary {
+				chatBlocks = append(chatBlocks, styles.StatusOutputStyle.Render(text))
+			}
+		case "tool":
+			// Shown with the call that asked for it.
 		default:
 			if msg.Reasoning != "" {
 				chatBlocks = append(chatBlocks, styles.ThinkingTokenStyle.Render(msg.Reasoning))
@@ -297,6 +343,10 @@ func (m *TeaModel) RenderChatContentFromHistory() {
 			if text != "" {
 				chatBlocks = append(chatBlocks, styles.AgentContentStyle.Width(m.Width).Render(text))
 			}
+			for _, tc := range msg.ToolCalls {
+				line := toolActivity(tc.Function.Name, tc.Function.Arguments, results[tc.ID], m.Width)
+				chatBlocks = append(chatBlocks, styles.ToolActivityStyle.Render(line))
+			}
 		}
 	}
 
@@ -309,3 +359,24 @@ func (m *TeaModel) RenderChatContentFromHistory() {
 		m.AgentModel.ChatViewport.GotoBottom()
 	}
 }
+
+// toolActivity collapses a past tool call and its result into one line of
+// at most width characters.
+func toolActivity(name, args, result string, width int) string {
+	args = strings.Join(strings.Fields(args), " ")
+	if args == "{}" {
+		args = ""
+	}
+	summary := "no result"
+	if lines := strings.Split(strings.TrimSpace(result), "\n"); lines[0] != "" {
+		summary = lines[0]
+		if len(lines) > 1 {
+			summary += fmt.Sprintf(" (+%d lines)", len(lines)-1)
+		}
+	}
+	line := fmt.Sprintf("▸ %s → %s", strings.TrimSpace(name+" "+args), summary)
+	if runes := []rune(line); width > 3 && len(runes) > width {
+		line = string(runes[:width-3]) + "..."
+	}
+	return line
+}
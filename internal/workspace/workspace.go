@@ -0,0 +1,214 @@
+// Package workspace confines the agent's file tools to a directory tree.
+// Paths are resolved with their symlinks followed, so a link inside the
+// workspace cannot be used to reach files outside it.
+package workspace
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ErrOutside is returned for paths the workspace does not give access to.
+var ErrOutside = errors.New("outside the workspace")
+
+// Access is what a tool wants to do with a path.
+type Access int
+
+const (
+	Read Access = iota
+	Write
+)
+
+func (a Access) String() string {
+	if a == Write {
+		return "write"
+	}
+	return "read"
+}
+
+// maxLinks bounds how many symlinks one path may go through, as the kernel
+// does with ELOOP.
+const maxLinks = 40
+
+type grant struct {
+	path   string
+	access Access
+}
+
+// Workspace is a root the agent may read and write under, plus extra roots
+// it may only read.
+type Workspace struct {
+	root      string
+	readRoots []string
+
+	mu sync.Mutex
+	// grants are paths the user allowed for the rest of the session.
+	grants []grant
+}
+
+// New returns a workspace rooted at root that may also read readRoots.
+// Relative paths are resolved against root.
+func New(root string, readRoots []string) (*Workspace, error) {
+	if strings.TrimSpace(root) == "" {
+		return nil, errors.New("workspace root is required")
+	}
+	real, err := realPath(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve workspace root %q: %w", root, err)
+	}
+	w := &Workspace{root: real}
+	for _, r := range readRoots {
+		if r = strings.TrimSpace(r); r == "" {
+			continue
+		}
+		real, err := realPath(w.abs(r))
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve read root %q: %w", r, err)
+		}
+		w.readRoots = append(w.readRoots, real)
+	}
+	return w, nil
+}
+
+// Root returns the workspace directory with its symlinks resolved.
+func (w *Workspace) Root() string {
+	return w.root
+}
+
+// Resolve returns the real path of path, which may not exist yet, and
+// checks that it may be used for access. Paths the workspace does not
+// cover fail with ErrOutside but still return their real path, so the
+// caller can ask the user about it.
+func (w *Workspace) Resolve(path string, access Access) (string, error) {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return "", errors.New("path is required")
+	}
+	real, err := realPath(w.abs(path))
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %q: %w", path, err)
+	}
+	if w.allows(real, access) {
+		return real, nil
+	}
+	if real != filepath.Clean(w.abs(path)) {
+		return real, fmt.Errorf("%s resolves to %s, %w %s", path, real, ErrOutside, w.root)
+	}
+	return real, fmt.Errorf("%s is %w %s", real, ErrOutside, w.root)
+}
+
+// Allow lets the rest of the session use path, and everything under it
+// when it is a directory, for access. Write access includes reading.
+func (w *Workspace) Allow(path string, access Access) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.grants = append(w.grants, grant{path: filepath.Clean(path), access: access})
+}
+
+func (w *Workspace) allows(real string, access Access) bool {
+	if within(real, w.root) {
+		return true
+	}
+	if access == Read {
+		for _, r := range w.readRoots {
+			if within(real, r) {
+				return true
+			}
+		}
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, g := range w.grants {
+		if g.access >= access && within(real, g.path) {
+			return true
+		}
+	}
+	return false
+}
+
+// abs makes path absolute against the workspace root, expanding a leading
+// ~/ to the home directory.
+func (w *Workspace) abs(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, path[1:])
+		}
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(w.root, path)
+	}
+	return filepath.Clean(path)
+}
+
+// within reports whether path is dir or below it.
+func within(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
+
+// realPath resolves the symlinks of the absolute path abs. Unlike
+// filepath.EvalSymlinks it accepts paths whose last parts do not exist yet,
+// and it follows a dangling link to where writing through it would create
+// a file.
+func realPath(abs string) (string, error) {
+	abs = filepath.Clean(abs)
+	for links := 0; ; {
+		existing, rest := abs, ""
+		for {
+			info, err := os.Lstat(existing)
+			if err == nil && info.Mode()&fs.ModeSymlink == 0 {
+				break
+			}
+			if err == nil {
+				// A link: follow it by hand, since EvalSymlinks fails on
+				// dangling ones.
+				links++
+				if links > maxLinks {
+					return "", fmt.Errorf("too many links in %s", abs)
+				}
+				target, err := os.Readlink(existing)
+				if err != nil {
+					return "", err
+				}
+				if !filepath.IsAbs(target) {
+					// The link's directory exists, and its own links decide
+					// where a relative target's ".." leads.
+					dir, err := filepath.EvalSymlinks(filepath.Dir(existing))
+					if err != nil {
+						return "", err
+					}
+					target = filepath.Join(dir, target)
+				}
+				existing, rest = "", filepath.Join(target, rest)
+				break
+			}
+			if !errors.Is(err, fs.ErrNotExist) {
+				return "", err
+			}
+			parent := filepath.Dir(existing)
+			if parent == existing {
+				return abs, nil
+			}
+			rest = filepath.Join(filepath.Base(existing), rest)
+			existing = parent
+		}
+		if existing == "" {
+			// Start again from the link's target.
+			abs = filepath.Clean(rest)
+			continue
+		}
+		resolved, err := filepath.EvalSymlinks(existing)
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(resolved, rest), nil
+	}
+}
@@ -0,0 +1,207 @@
+package workspace
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newTestWorkspace lays out a workspace next to a directory outside it:
+//
+//	base/ws/file.txt
+//	base/ws/sub/
+//	base/outside/secret.txt
+//	base/docs/readme.md
+func newTestWorkspace(t *testing.T, readRoots ...string) (*Workspace, string) {
+	t.Helper()
+	base, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, dir := range []string{"ws/sub", "outside", "docs"} {
+		if err := os.MkdirAll(filepath.Join(base, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, file := range []string{"ws/file.txt", "outside/secret.txt", "docs/readme.md"} {
+		if err := os.WriteFile(filepath.Join(base, file), []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, r := range readRoots {
+		readRoots[i] = filepath.Join(base, r)
+	}
+	w, err := New(filepath.Join(base, "ws"), readRoots)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	return w, base
+}
+
+func symlink(t *testing.T, target, link string) {
+	t.Helper()
+	if err := os.Symlink(target, link); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+}
+
+func TestResolveInside(t *testing.T) {
+	w, base := newTestWorkspace(t)
+	ws := filepath.Join(base, "ws")
+	tests := map[string]string{
+		"file.txt":                 filepath.Join(ws, "file.txt"),
+		"./sub/../file.txt":        filepath.Join(ws, "file.txt"),
+		"sub/new/deeper.txt":       filepath.Join(ws, "sub/new/deeper.txt"),
+		".":                        ws,
+		filepath.Join(ws, "sub"):   filepath.Join(ws, "sub"),
+		"  file.txt  ":             filepath.Join(ws, "file.txt"),
+		"sub/../../ws/file.txt":    filepath.Join(ws, "file.txt"),
+		"..file":                   filepath.Join(ws, "..file"),
+		filepath.Join(ws, "a..b"):  filepath.Join(ws, "a..b"),
+		"sub/./missing/../new.txt": filepath.Join(ws, "sub/new.txt"),
+	}
+	for path, want := range tests {
+		got, err := w.Resolve(path, Write)
+		if err != nil || got != want {
+			t.Errorf("Resolve(%q) = %q, %v; want %q", path, got, err, want)
+		}
+	}
+}
+
+func TestResolveTraversal(t *testing.T) {
+	w, base := newTestWorkspace(t)
+	for _, path := range []string{
+		"..",
+		"../outside/secret.txt",
+		"sub/../../outside/secret.txt",
+		"../ws2/file.txt",
+		filepath.Join(base, "outside/secret.txt"),
+		filepath.Join(base, "ws/../outside/new.txt"),
+		"/",
+		"~/.ssh/id_rsa",
+	} {
+		for _, access := range []Access{Read, Write} {
+			if _, err := w.Resolve(path, access); !errors.Is(err, ErrOutside) {
+				t.Errorf("Resolve(%q, %s) = %v, want ErrOutside", path, access, err)
+			}
+		}
+	}
+	// A sibling that shares the root's name as a prefix is not inside it.
+	if err := os.MkdirAll(filepath.Join(base, "ws-other"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Resolve("../ws-other/x", Read); !errors.Is(err, ErrOutside) {
+		t.Errorf("Expected a sibling with the same prefix to be outside, got %v", err)
+	}
+	if _, err := w.Resolve(" ", Read); err == nil || errors.Is(err, ErrOutside) {
+		t.Errorf("Expected an empty path to be rejected, got %v", err)
+	}
+}
+
+func TestResolveSymlinks(t *testing.T) {
+	w, base := newTestWorkspace(t)
+	ws, outside := filepath.Join(base, "ws"), filepath.Join(base, "outside")
+	symlink(t, outside, filepath.Join(ws, "out"))
+	symlink(t, filepath.Join(outside, "secret.txt"), filepath.Join(ws, "secret-link"))
+	symlink(t, "../../outside/missing.txt", filepath.Join(ws, "sub", "dangling"))
+	symlink(t, "sub", filepath.Join(ws, "alias"))
+	symlink(t, filepath.Join(ws, "loop-b"), filepath.Join(ws, "loop-a"))
+	symlink(t, filepath.Join(ws, "loop-a"), filepath.Join(ws, "loop-b"))
+
+	escapes := map[string]string{
+		"out/secret.txt":   filepath.Join(outside, "secret.txt"),
+		"out/new.txt":      filepath.Join(outside, "new.txt"),
+		"out/a/b/c.txt":    filepath.Join(outside, "a/b/c.txt"),
+		"secret-link":      filepath.Join(outside, "secret.txt"),
+		"sub/dangling":     filepath.Join(outside, "missing.txt"),
+		"alias/../out/x":   filepath.Join(outside, "x"),
+		"alias/../../etc/": filepath.Join(base, "etc"),
+	}
+	for path, real := range escapes {
+		got, err := w.Resolve(path, Write)
+		if !errors.Is(err, ErrOutside) {
+			t.Errorf("Resolve(%q) = %v, want ErrOutside", path, err)
+		}
+		if got != real {
+			t.Errorf("Resolve(%q) real path = %q, want %q", path, got, real)
+		}
+	}
+
+	if got, err := w.Resolve("alias/new.txt", Write); err != nil || got != filepath.Join(ws, "sub/new.txt") {
+		t.Errorf("Expected a link inside the workspace to resolve, got %q, %v", got, err)
+	}
+	if _, err := w.Resolve("loop-a", Read); err == nil || errors.Is(err, ErrOutside) {
+		t.Errorf("Expected a link loop to fail, got %v", err)
+	}
+}
+
+func TestReadRoots(t *testing.T) {
+	w, base := newTestWorkspace(t, "docs")
+	readme := filepath.Join(base, "docs/readme.md")
+
+	if got, err := w.Resolve(readme, Read); err != nil || got != readme {
+		t.Errorf("Expected a read root to be readable, got %q, %v", got, err)
+	}
+	if _, err := w.Resolve("../docs/readme.md", Read); err != nil {
+		t.Errorf("Expected a relative path into a read root to be readable, got %v", err)
+	}
+	if _, err := w.Resolve(readme, Write); !errors.Is(err, ErrOutside) {
+		t.Errorf("Expected a read root not to be writable, got %v", err)
+	}
+	symlink(t, filepath.Join(base, "outside"), filepath.Join(base, "docs", "out"))
+	if _, err := w.Resolve(filepath.Join(base, "docs/out/secret.txt"), Read); !errors.Is(err, ErrOutside) {
+		t.Errorf("Expected a link out of a read root to be outside, got %v", err)
+	}
+}
+
+func TestAllow(t *testing.T) {
+	w, base := newTestWorkspace(t)
+	outside := filepath.Join(base, "outside")
+	secret := filepath.Join(outside, "secret.txt")
+
+	w.Allow(secret, Read)
+	if _, err := w.Resolve(secret, Read); err != nil {
+		t.Errorf("Expected an allowed file to be readable, got %v", err)
+	}
+	if _, err := w.Resolve(secret, Write); !errors.Is(err, ErrOutside) {
+		t.Errorf("Expected a file allowed for reading not to be writable, got %v", err)
+	}
+	if _, err := w.Resolve(filepath.Join(outside, "other.txt"), Read); !errors.Is(err, ErrOutside) {
+		t.Errorf("Expected allowing a file not to allow its siblings, got %v", err)
+	}
+
+	w.Allow(outside, Write)
+	if _, err := w.Resolve(filepath.Join(outside, "new/file.txt"), Write); err != nil {
+		t.Errorf("Expected an allowed directory to be writable, got %v", err)
+	}
+	if _, err := w.Resolve(filepath.Join(outside, "new/file.txt"), Read); err != nil {
+		t.Errorf("Expected write access to include reading, got %v", err)
+	}
+}
+
+func TestNewResolvesRoot(t *testing.T) {
+	base, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	real := filepath.Join(base, "real")
+	if err := os.Mkdir(real, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	symlink(t, real, filepath.Join(base, "link"))
+
+	w, err := New(filepath.Join(base, "link"), nil)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if w.Root() != real {
+		t.Errorf("Expected the root's link resolved to %q, got %q", real, w.Root())
+	}
+	if _, err := w.Resolve(filepath.Join(base, "link", "x.txt"), Write); err != nil {
+		t.Errorf("Expected paths through the root's link to be inside, got %v", err)
+	}
+	if _, err := New("", nil); err == nil {
+		t.Error("Expected an empty root to be rejected")
+	}
+}